@@ -0,0 +1,103 @@
+package api
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/audit"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/auth"
+	"github.com/gorilla/mux"
+)
+
+// anonymousActor is recorded for calls that are not attributed to a caller.
+const anonymousActor = "anonymous"
+
+type AuditEntryResponse struct {
+	Sequence     int       `json:"sequence"`
+	Timestamp    time.Time `json:"timestamp"`
+	Actor        string    `json:"actor"`
+	Action       string    `json:"action"`
+	Target       string    `json:"target"`
+	RequestId    string    `json:"request_id"`
+	Status       int       `json:"status"`
+	PreviousHash string    `json:"previous_hash"`
+	Hash         string    `json:"hash"`
+}
+
+type AuditLogResponse struct {
+	// Head is the hash of the newest entry. Keeping it allows to detect a
+	// truncation of the log later on.
+	Head    string               `json:"head"`
+	Entries []AuditEntryResponse `json:"entries"`
+}
+
+func (s *Server) ReadAuditLog(response http.ResponseWriter, _ *http.Request) {
+	entries, err := s.audit.Entries()
+	if err != nil {
+		WriteInternalError(response)
+		return
+	}
+
+	auditResponse := AuditLogResponse{
+		Head:    audit.GenesisHash,
+		Entries: make([]AuditEntryResponse, 0, len(entries)),
+	}
+	for _, entry := range entries {
+		auditResponse.Entries = append(auditResponse.Entries, AuditEntryResponse(entry))
+		auditResponse.Head = entry.Hash
+	}
+	WriteAPIResponse(response, http.StatusOK, auditResponse)
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// auditMiddleware appends an entry to the audit log for every mutating call.
+func (s *Server) auditMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		switch request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			next.ServeHTTP(response, request)
+			return
+		}
+
+		requestId := requestId(response, request)
+
+		recorder := &statusRecorder{ResponseWriter: response, status: http.StatusOK}
+		next.ServeHTTP(recorder, request)
+
+		action := request.Method + " " + request.URL.Path
+		if route := mux.CurrentRoute(request); route != nil {
+			if template, err := route.GetPathTemplate(); err == nil {
+				action = request.Method + " " + template
+			}
+		}
+		_, err := s.audit.Append(audit.Record{
+			Actor:     actor(request),
+			Action:    action,
+			Target:    request.URL.Path,
+			RequestId: requestId,
+			Status:    recorder.status,
+		})
+		if err != nil {
+			log.Printf("audit: could not append %s %s: %v", action, requestId, err)
+		}
+	})
+}
+
+// actor identifies the caller of a request by its API key or client
+// certificate identity.
+func actor(request *http.Request) string {
+	if key, ok := auth.KeyFromContext(request.Context()); ok {
+		return string(key.Kind) + ":" + key.Id
+	}
+	return anonymousActor
+}
@@ -0,0 +1,69 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/audit"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain"
+)
+
+func TestAuditMiddleware_RecordsMutatingCalls(t *testing.T) {
+	log, _ := audit.NewLog(audit.NewMemorySink())
+	s := NewServer("", &SignatureDeviceDomainStub{
+		DecommissionFunc: func(tenant, id string, expectedVersion int) (domain.SignatureDevice, error) {
+			return domain.SignatureDevice{}, domain.ErrNotFound
+		},
+		ReadSignatureDevicesFunc: func(tenant string, query domain.DeviceQuery) (domain.DevicePage, error) {
+			return domain.DevicePage{}, nil
+		},
+	}, WithAuditLog(log))
+	router := s.Router()
+
+	req := httptest.NewRequest("POST", "/api/v0/devices/550e8400-e29b-11d4-a716-446655440000:decommission", nil)
+	req.Header.Set("X-Request-ID", "request")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/api/v0/devices", nil))
+
+	entries, _ := log.Entries()
+	assertEqual(t, 1, len(entries))
+	assertEqual(t, "anonymous", entries[0].Actor)
+	assertEqual(t, "POST /api/v0/devices/{id}:decommission", entries[0].Action)
+	assertEqual(t, "/api/v0/devices/550e8400-e29b-11d4-a716-446655440000:decommission", entries[0].Target)
+	assertEqual(t, "request", entries[0].RequestId)
+	assertEqual(t, http.StatusNotFound, entries[0].Status)
+}
+
+func TestAuditMiddleware_GeneratesRequestId(t *testing.T) {
+	log, _ := audit.NewLog(audit.NewMemorySink())
+	s := NewServer("", &SignatureDeviceDomainStub{}, WithAuditLog(log))
+	w := httptest.NewRecorder()
+
+	s.Router().ServeHTTP(w, httptest.NewRequest("POST", "/api/v0/devices", bytes.NewReader([]byte(`invalid`))))
+
+	entries, _ := log.Entries()
+	assertEqual(t, w.Header().Get("X-Request-ID"), entries[0].RequestId)
+	assertEqual(t, 36, len(entries[0].RequestId))
+}
+
+func TestReadAuditLog_Ok(t *testing.T) {
+	log, _ := audit.NewLog(audit.NewMemorySink())
+	entry, _ := log.Append(audit.Record{Actor: "anonymous", Action: "POST /api/v0/devices", Status: 201})
+	s := NewServer("", &SignatureDeviceDomainStub{}, WithAuditLog(log))
+	req := httptest.NewRequest("GET", "/api/v0/audit", nil)
+	w := httptest.NewRecorder()
+	s.ReadAuditLog(w, req)
+
+	var body struct {
+		Data AuditLogResponse `json:"data"`
+	}
+	_ = json.Unmarshal(w.Body.Bytes(), &body)
+
+	assertEqual(t, http.StatusOK, w.Result().StatusCode)
+	assertEqual(t, entry.Hash, body.Data.Head)
+	assertEqual(t, 1, len(body.Data.Entries))
+	assertEqual(t, entry.Hash, body.Data.Entries[0].Hash)
+}
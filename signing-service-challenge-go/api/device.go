@@ -3,148 +3,569 @@ package api
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/rbac"
 	"github.com/gorilla/mux"
 )
 
 type CreateSignatureDeviceRequest struct {
-	Id        string `json:"id"`
-	Algorithm string `json:"algorithm"`
-	Label     string `json:"label,omitempty"`
+	Id                  string `json:"id"`
+	Algorithm           string `json:"algorithm"`
+	Label               string `json:"label,omitempty"`
+	Mode                string `json:"mode,omitempty"`
+	AggregationWindowMs int64  `json:"aggregation_window_ms,omitempty"`
+	AggregationSize     int    `json:"aggregation_size,omitempty"`
 }
 
 type CreateSignatureDeviceResponse struct {
-	Id               string `json:"id"`
-	Algorithm        string `json:"algorithm"`
-	Label            string `json:"label,omitempty"`
-	SignatureCounter int    `json:"signature_counter"`
+	Id                  string                 `json:"id"`
+	Algorithm           string                 `json:"algorithm"`
+	Label               string                 `json:"label,omitempty"`
+	SignatureCounter    int                    `json:"signature_counter"`
+	Mode                string                 `json:"mode,omitempty"`
+	AggregationWindowMs int64                  `json:"aggregation_window_ms,omitempty"`
+	AggregationSize     int                    `json:"aggregation_size,omitempty"`
+	DecommissionedAt    *time.Time             `json:"decommissioned_at,omitempty"`
+	CreatedAt           *time.Time             `json:"created_at,omitempty"`
+	Tags                map[string]string      `json:"tags,omitempty"`
+	Metadata            map[string]interface{} `json:"metadata,omitempty"`
+	Version             int                    `json:"version,omitempty"`
+}
+
+func newSignatureDeviceResponse(device domain.SignatureDevice) CreateSignatureDeviceResponse {
+	deviceResponse := CreateSignatureDeviceResponse{
+		Id:                  device.Id,
+		Label:               device.Label,
+		Algorithm:           device.Algorithm,
+		SignatureCounter:    device.SignatureCounter,
+		Mode:                string(device.Mode),
+		AggregationWindowMs: device.AggregationWindow.Milliseconds(),
+		AggregationSize:     device.AggregationSize,
+		Tags:                device.Tags,
+		Metadata:            device.Metadata,
+		Version:             device.Version,
+	}
+	if !device.DecommissionedAt.IsZero() {
+		decommissionedAt := device.DecommissionedAt
+		deviceResponse.DecommissionedAt = &decommissionedAt
+	}
+	if !device.CreatedAt.IsZero() {
+		createdAt := device.CreatedAt
+		deviceResponse.CreatedAt = &createdAt
+	}
+	return deviceResponse
 }
 
 func (s *Server) CreateSignatureDevice(response http.ResponseWriter, request *http.Request) {
 	var createRequest CreateSignatureDeviceRequest
 	if err := json.NewDecoder(request.Body).Decode(&createRequest); err != nil {
-		WriteErrorResponse(response, http.StatusBadRequest, []string{
-			"invalid json body",
-		})
+		WriteProblem(response, http.StatusBadRequest, CodeInvalidJSON, "invalid json body")
+		return
+	}
+	if !s.authorize(response, request, rbac.ActionCreate, rbac.Resource{DeviceId: createRequest.Id, Label: createRequest.Label}) {
 		return
 	}
 
-	device, err := s.domain.CreateSignatureDevice(createRequest.Id, createRequest.Algorithm, createRequest.Label)
+	device, err := s.domain.CreateSignatureDevice(request.Context(), requestTenant(request), createRequest.Id, createRequest.Algorithm, createRequest.Label, domain.DeviceOptions{
+		Mode:              domain.DeviceMode(createRequest.Mode),
+		AggregationWindow: time.Duration(createRequest.AggregationWindowMs) * time.Millisecond,
+		AggregationSize:   createRequest.AggregationSize,
+	})
 	if err != nil {
 		if errors.Is(err, domain.ErrExists) {
-			WriteErrorResponse(response, http.StatusConflict, []string{
-				err.Error(),
-			})
+			writeError(response, http.StatusConflict, err)
 			return
 		}
-		if errors.Is(err, domain.ErrInvalidUUID) || errors.Is(err, domain.ErrInvalidAlgorithm) {
-			WriteErrorResponse(response, http.StatusBadRequest, []string{
-				err.Error(),
-			})
+		if errors.Is(err, domain.ErrInvalidUUID) || errors.Is(err, domain.ErrInvalidAlgorithm) ||
+			errors.Is(err, domain.ErrInvalidMode) || errors.Is(err, domain.ErrInvalidAggregation) {
+			writeError(response, http.StatusBadRequest, err)
+			return
+		}
+		if errors.Is(err, domain.ErrDeviceLimit) {
+			writeError(response, http.StatusForbidden, err)
 			return
 		}
-		WriteErrorResponse(response, http.StatusInternalServerError, []string{
-			http.StatusText(http.StatusInternalServerError),
-		})
+		WriteInternalError(response)
 		return
 	}
 
-	createResponse := CreateSignatureDeviceResponse{
-		Id:               device.Id,
-		Label:            device.Label,
-		Algorithm:        device.Algorithm,
-		SignatureCounter: device.SignatureCounter,
-	}
-	WriteAPIResponse(response, http.StatusCreated, createResponse)
+	WriteAPIResponse(response, http.StatusCreated, newSignatureDeviceResponse(device))
 }
 
 func (s *Server) ReadSignatureDevice(response http.ResponseWriter, request *http.Request) {
 	vars := mux.Vars(request)
 	id := vars["id"]
+	if !s.authorizeDevice(response, request, rbac.ActionRead, id) {
+		return
+	}
 
-	device, err := s.domain.ReadSignatureDevice(id)
+	device, err := s.domain.ReadSignatureDevice(request.Context(), requestTenant(request), id)
 	if err != nil {
 		if errors.Is(err, domain.ErrNotFound) {
-			WriteErrorResponse(response, http.StatusNotFound, []string{
-				err.Error(),
-			})
+			writeError(response, http.StatusNotFound, err)
 			return
 		}
-		WriteErrorResponse(response, http.StatusInternalServerError, []string{
-			http.StatusText(http.StatusInternalServerError),
-		})
+		WriteInternalError(response)
 		return
 	}
 
-	readResponse := CreateSignatureDeviceResponse{
-		Id:               device.Id,
-		Label:            device.Label,
-		Algorithm:        device.Algorithm,
-		SignatureCounter: device.SignatureCounter,
+	setETag(response, device)
+	if device.Version > 0 && request.Header.Get("If-None-Match") == etag(device.Version) {
+		response.WriteHeader(http.StatusNotModified)
+		return
 	}
-	WriteAPIResponse(response, http.StatusOK, readResponse)
+	WriteAPIResponse(response, http.StatusOK, newSignatureDeviceResponse(device))
 }
 
-func (s *Server) ReadSignatureDevices(response http.ResponseWriter, _ *http.Request) {
-	devices := s.domain.ReadSignatureDevices()
-	readResponse := make([]CreateSignatureDeviceResponse, 0)
-	for _, device := range devices {
-		readResponse = append(readResponse, CreateSignatureDeviceResponse{
-			Id:               device.Id,
-			Label:            device.Label,
-			Algorithm:        device.Algorithm,
-			SignatureCounter: device.SignatureCounter,
-		})
+// DecommissionSignatureDevice permanently stops a device from signing.
+func (s *Server) DecommissionSignatureDevice(response http.ResponseWriter, request *http.Request) {
+	vars := mux.Vars(request)
+	id := vars["id"]
+	if !s.authorizeDevice(response, request, rbac.ActionDecommission, id) {
+		return
+	}
+	expectedVersion, ok := ifMatch(response, request)
+	if !ok {
+		return
+	}
+
+	device, err := s.domain.DecommissionSignatureDevice(request.Context(), requestTenant(request), id, expectedVersion)
+	if err != nil {
+		writeSignError(response, err)
+		return
 	}
-	WriteAPIResponse(response, http.StatusOK, readResponse)
+
+	setETag(response, device)
+	WriteAPIResponse(response, http.StatusOK, newSignatureDeviceResponse(device))
+}
+
+// RotateSignatureDeviceKey replaces the key pair of a device. Signatures
+// made before are still verified with the key that made them.
+func (s *Server) RotateSignatureDeviceKey(response http.ResponseWriter, request *http.Request) {
+	vars := mux.Vars(request)
+	id := vars["id"]
+	if !s.authorizeDevice(response, request, rbac.ActionRotateKey, id) {
+		return
+	}
+	expectedVersion, ok := ifMatch(response, request)
+	if !ok {
+		return
+	}
+
+	device, err := s.domain.RotateSignatureDeviceKey(request.Context(), requestTenant(request), id, expectedVersion)
+	if err != nil {
+		writeSignError(response, err)
+		return
+	}
+
+	setETag(response, device)
+	WriteAPIResponse(response, http.StatusOK, newSignatureDeviceResponse(device))
+}
+
+// UpdateSignatureDevice applies a JSON Merge Patch (RFC 7386) to the label,
+// tags and metadata of a device. All other attributes are immutable.
+func (s *Server) UpdateSignatureDevice(response http.ResponseWriter, request *http.Request) {
+	if contentType := request.Header.Get("Content-Type"); contentType != "" &&
+		!strings.HasPrefix(contentType, "application/merge-patch+json") && !strings.HasPrefix(contentType, "application/json") {
+		WriteProblem(response, http.StatusUnsupportedMediaType, CodeUnsupportedMediaType, "unsupported content type "+contentType)
+		return
+	}
+	var members map[string]json.RawMessage
+	if err := json.NewDecoder(request.Body).Decode(&members); err != nil {
+		WriteProblem(response, http.StatusBadRequest, CodeInvalidJSON, "invalid json body")
+		return
+	}
+
+	var patch domain.DevicePatch
+	for name, value := range members {
+		switch name {
+		case "label":
+			var label *string
+			if err := json.Unmarshal(value, &label); err != nil {
+				WriteProblem(response, http.StatusBadRequest, CodeValidationFailed, "invalid patch", FieldError{Pointer: "/label", Detail: "must be a string"})
+				return
+			}
+			if label == nil {
+				label = new(string)
+			}
+			patch.Label = label
+		case "tags":
+			patch.Tags = value
+		case "metadata":
+			patch.Metadata = value
+		default:
+			WriteProblem(response, http.StatusBadRequest, CodeImmutableField, name+" cannot be changed", FieldError{Pointer: "/" + name, Detail: "cannot be changed"})
+			return
+		}
+	}
+
+	vars := mux.Vars(request)
+	id := vars["id"]
+	if !s.authorizeDevice(response, request, rbac.ActionUpdate, id) {
+		return
+	}
+	expectedVersion, ok := ifMatch(response, request)
+	if !ok {
+		return
+	}
+	patch.ExpectedVersion = expectedVersion
+
+	device, err := s.domain.UpdateSignatureDevice(request.Context(), requestTenant(request), id, patch)
+	if err != nil {
+		if errors.Is(err, domain.ErrInvalidPatch) || errors.Is(err, domain.ErrInvalidTags) || errors.Is(err, domain.ErrInvalidMetadata) {
+			writeError(response, http.StatusBadRequest, err)
+			return
+		}
+		writeSignError(response, err)
+		return
+	}
+
+	setETag(response, device)
+	WriteAPIResponse(response, http.StatusOK, newSignatureDeviceResponse(device))
+}
+
+// ReadSignatureDevices returns a page of devices. It filters by the query
+// parameters algorithm, label (a substring), state (active or
+// decommissioned), tag=<key>:<value>, min_counter and max_counter, and sorts by created_at,
+// label or signature_counter in ascending or descending order. The page
+// holds up to limit devices, the next one is read with cursor=<next_cursor>.
+func (s *Server) ReadSignatureDevices(response http.ResponseWriter, request *http.Request) {
+	query, err := parseDeviceQuery(request.URL.Query())
+	if err != nil {
+		writeError(response, http.StatusBadRequest, err)
+		return
+	}
+	// The list only contains the devices the caller may read.
+	query.Resources = s.resources(request, rbac.ActionRead)
+	page, err := s.domain.ReadSignatureDevices(request.Context(), requestTenant(request), query)
+	if err != nil {
+		if errors.Is(err, domain.ErrInvalidQuery) || errors.Is(err, domain.ErrInvalidCursor) {
+			writeError(response, http.StatusBadRequest, err)
+			return
+		}
+		WriteInternalError(response)
+		return
+	}
+
+	readResponse := make([]CreateSignatureDeviceResponse, 0, len(page.Devices))
+	for _, device := range page.Devices {
+		readResponse = append(readResponse, newSignatureDeviceResponse(device))
+	}
+	WritePageResponse(response, http.StatusOK, readResponse, page.NextCursor)
+}
+
+func parseDeviceQuery(values url.Values) (domain.DeviceQuery, error) {
+	query := domain.DeviceQuery{
+		Algorithm:     values.Get("algorithm"),
+		LabelContains: values.Get("label"),
+		State:         domain.DeviceState(values.Get("state")),
+		SortBy:        domain.SortField(values.Get("sort")),
+		Cursor:        values.Get("cursor"),
+	}
+	for _, tag := range values["tag"] {
+		key, value, found := strings.Cut(tag, ":")
+		if !found {
+			return domain.DeviceQuery{}, fmt.Errorf("%w: tag", domain.ErrInvalidQuery)
+		}
+		if query.Tags == nil {
+			query.Tags = make(map[string]string)
+		}
+		query.Tags[key] = value
+	}
+	switch values.Get("order") {
+	case "", "asc":
+	case "desc":
+		query.Descending = true
+	default:
+		return domain.DeviceQuery{}, fmt.Errorf("%w: order", domain.ErrInvalidQuery)
+	}
+	for name, target := range map[string]**int{"min_counter": &query.MinCounter, "max_counter": &query.MaxCounter} {
+		if value := values.Get(name); value != "" {
+			counter, err := strconv.Atoi(value)
+			if err != nil {
+				return domain.DeviceQuery{}, fmt.Errorf("%w: %s", domain.ErrInvalidQuery, name)
+			}
+			*target = &counter
+		}
+	}
+	if value := values.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil {
+			return domain.DeviceQuery{}, fmt.Errorf("%w: limit", domain.ErrInvalidQuery)
+		}
+		query.Limit = limit
+	}
+	return query, nil
 }
 
 type SignTransactionRequest struct {
 	DataToBeSigned string `json:"data_to_be_signed"`
+	Format         string `json:"format,omitempty"`
+	// CallbackURL receives the result of an asynchronous signing job.
+	CallbackURL string `json:"callback_url,omitempty"`
+	// CallbackSecret keys the HMAC of the callback body. A random secret is
+	// generated if empty.
+	CallbackSecret string `json:"callback_secret,omitempty"`
 }
 
 type SignTransactionResponse struct {
-	Signature  string `json:"signature"`
-	SignedData string `json:"signed_data"`
+	Signature      string                  `json:"signature"`
+	SignedData     string                  `json:"signed_data"`
+	SignedAt       time.Time               `json:"signed_at"`
+	KeyId          string                  `json:"key_id,omitempty"`
+	InclusionProof *InclusionProofResponse `json:"inclusion_proof,omitempty"`
+}
+
+type InclusionProofResponse struct {
+	Root      string   `json:"root"`
+	LeafIndex int      `json:"leaf_index"`
+	TreeSize  int      `json:"tree_size"`
+	Proof     []string `json:"proof"`
+}
+
+func newSignTransactionResponse(signature domain.Signature) SignTransactionResponse {
+	signResponse := SignTransactionResponse{
+		Signature:  signature.Signature,
+		SignedData: signature.SignedData,
+		SignedAt:   signature.SignedAt,
+		KeyId:      signature.KeyId,
+	}
+	if signature.Inclusion != nil {
+		signResponse.InclusionProof = &InclusionProofResponse{
+			Root:      signature.Inclusion.Root,
+			LeafIndex: signature.Inclusion.LeafIndex,
+			TreeSize:  signature.Inclusion.TreeSize,
+			Proof:     signature.Inclusion.Proof,
+		}
+	}
+	return signResponse
 }
 
 func (s *Server) SignTransaction(response http.ResponseWriter, request *http.Request) {
 	var signRequest SignTransactionRequest
 	if err := json.NewDecoder(request.Body).Decode(&signRequest); err != nil {
-		WriteErrorResponse(response, http.StatusBadRequest, []string{
-			"invalid json body",
-		})
+		WriteProblem(response, http.StatusBadRequest, CodeInvalidJSON, "invalid json body")
 		return
 	}
 
 	vars := mux.Vars(request)
 	id := vars["id"]
+	if !s.authorizeDevice(response, request, rbac.ActionSign, id) || !s.limit(response, request, id, 1) {
+		return
+	}
+	expectedVersion, ok := ifMatch(response, request)
+	if !ok {
+		return
+	}
+
+	if request.URL.Query().Get("async") == "true" {
+		s.submitJob(response, request, id, signRequest, expectedVersion)
+		return
+	}
 
-	signature, err := s.domain.SignTransaction(id, signRequest.DataToBeSigned)
+	signature, err := s.domain.SignTransaction(request.Context(), requestTenant(request), id, signRequest.DataToBeSigned, domain.SignOptions{
+		Format:          domain.SignatureFormat(signRequest.Format),
+		KeyId:           requestKeyId(request),
+		ExpectedVersion: expectedVersion,
+	})
 	if err != nil {
-		if errors.Is(err, domain.ErrNotFound) {
-			WriteErrorResponse(response, http.StatusNotFound, []string{
-				err.Error(),
+		writeSignError(response, err)
+		return
+	}
+	logSignatures(request, signature.Counter, 1)
+
+	WriteAPIResponse(response, http.StatusOK, newSignTransactionResponse(signature))
+}
+
+type SignTransactionsRequest struct {
+	DataToBeSigned []string `json:"data_to_be_signed"`
+	Format         string   `json:"format,omitempty"`
+}
+
+// SignTransactions signs an ordered batch of transactions with one gapless
+// range of the signature counter.
+func (s *Server) SignTransactions(response http.ResponseWriter, request *http.Request) {
+	var signRequest SignTransactionsRequest
+	if err := json.NewDecoder(request.Body).Decode(&signRequest); err != nil {
+		WriteProblem(response, http.StatusBadRequest, CodeInvalidJSON, "invalid json body")
+		return
+	}
+
+	vars := mux.Vars(request)
+	id := vars["id"]
+	if !s.authorizeDevice(response, request, rbac.ActionSign, id) || !s.limit(response, request, id, len(signRequest.DataToBeSigned)) {
+		return
+	}
+	expectedVersion, ok := ifMatch(response, request)
+	if !ok {
+		return
+	}
+
+	signatures, err := s.domain.SignTransactions(request.Context(), requestTenant(request), id, signRequest.DataToBeSigned, domain.SignOptions{
+		Format:          domain.SignatureFormat(signRequest.Format),
+		KeyId:           requestKeyId(request),
+		ExpectedVersion: expectedVersion,
+	})
+	if err != nil {
+		writeSignError(response, err)
+		return
+	}
+	if len(signatures) > 0 {
+		logSignatures(request, signatures[0].Counter, len(signatures))
+	}
+
+	signResponse := make([]SignTransactionResponse, 0, len(signatures))
+	for _, signature := range signatures {
+		signResponse = append(signResponse, newSignTransactionResponse(signature))
+	}
+	WriteAPIResponse(response, http.StatusOK, signResponse)
+}
+
+func writeSignError(response http.ResponseWriter, err error) {
+	if errors.Is(err, domain.ErrInvalidFormat) || errors.Is(err, domain.ErrEmptyBatch) || errors.Is(err, domain.ErrBatchTooLarge) {
+		writeError(response, http.StatusBadRequest, err)
+		return
+	}
+	if errors.Is(err, domain.ErrNotFound) {
+		writeError(response, http.StatusNotFound, err)
+		return
+	}
+	if errors.Is(err, domain.ErrModified) || errors.Is(err, domain.ErrDecommissioned) {
+		writeError(response, http.StatusConflict, err)
+		return
+	}
+	if errors.Is(err, domain.ErrPreconditionFailed) {
+		writeError(response, http.StatusPreconditionFailed, err)
+		return
+	}
+	if errors.Is(err, domain.ErrClockRegression) {
+		writeError(response, http.StatusServiceUnavailable, err)
+		return
+	}
+	if errors.Is(err, domain.ErrAggregationPending) {
+		writeError(response, http.StatusGatewayTimeout, err)
+		return
+	}
+	WriteInternalError(response)
+}
+
+type VerifySignatureRequest struct {
+	Signature  string `json:"signature"`
+	SignedData string `json:"signed_data,omitempty"`
+	Format     string `json:"format,omitempty"`
+}
+
+type VerifySignatureResponse struct {
+	Valid      bool   `json:"valid"`
+	SignedData string `json:"signed_data,omitempty"`
+}
+
+func (s *Server) VerifySignature(response http.ResponseWriter, request *http.Request) {
+	var verifyRequest VerifySignatureRequest
+	if err := json.NewDecoder(request.Body).Decode(&verifyRequest); err != nil {
+		WriteProblem(response, http.StatusBadRequest, CodeInvalidJSON, "invalid json body")
+		return
+	}
+
+	vars := mux.Vars(request)
+	id := vars["id"]
+	if !s.authorizeDevice(response, request, rbac.ActionVerify, id) {
+		return
+	}
+
+	signedData, err := s.domain.VerifySignature(request.Context(), requestTenant(request), id, domain.Verification{
+		Format:     domain.SignatureFormat(verifyRequest.Format),
+		Signature:  verifyRequest.Signature,
+		SignedData: verifyRequest.SignedData,
+	})
+	if err != nil {
+		if errors.Is(err, domain.ErrInvalidSignature) {
+			WriteAPIResponse(response, http.StatusOK, VerifySignatureResponse{
+				Valid: false,
 			})
 			return
 		}
-		if errors.Is(err, domain.ErrModified) {
-			WriteErrorResponse(response, http.StatusConflict, []string{
-				err.Error(),
-			})
+		if errors.Is(err, domain.ErrInvalidFormat) || errors.Is(err, domain.ErrMalformed) {
+			writeError(response, http.StatusBadRequest, err)
+			return
+		}
+		if errors.Is(err, domain.ErrNotFound) {
+			writeError(response, http.StatusNotFound, err)
 			return
 		}
-		WriteErrorResponse(response, http.StatusInternalServerError, []string{
-			http.StatusText(http.StatusInternalServerError),
-		})
+		WriteInternalError(response)
 		return
 	}
 
-	signResponse := SignTransactionResponse{
-		Signature:  signature.Signature,
-		SignedData: signature.SignedData,
+	WriteAPIResponse(response, http.StatusOK, VerifySignatureResponse{
+		Valid:      true,
+		SignedData: signedData,
+	})
+}
+
+type VerifyInclusionRequest struct {
+	DataToBeSigned string                 `json:"data_to_be_signed"`
+	InclusionProof InclusionProofResponse `json:"inclusion_proof"`
+	Signature      string                 `json:"signature"`
+	SignedData     string                 `json:"signed_data"`
+}
+
+type VerifyInclusionResponse struct {
+	Valid bool `json:"valid"`
+}
+
+// VerifyInclusion checks a transaction against a signed Merkle root.
+func (s *Server) VerifyInclusion(response http.ResponseWriter, request *http.Request) {
+	var verifyRequest VerifyInclusionRequest
+	if err := json.NewDecoder(request.Body).Decode(&verifyRequest); err != nil {
+		WriteProblem(response, http.StatusBadRequest, CodeInvalidJSON, "invalid json body")
+		return
 	}
-	WriteAPIResponse(response, http.StatusOK, signResponse)
+
+	vars := mux.Vars(request)
+	id := vars["id"]
+	if !s.authorizeDevice(response, request, rbac.ActionVerify, id) {
+		return
+	}
+
+	err := s.domain.VerifyInclusion(request.Context(), requestTenant(request), id, domain.InclusionVerification{
+		Data: verifyRequest.DataToBeSigned,
+		Inclusion: domain.InclusionProof{
+			Root:      verifyRequest.InclusionProof.Root,
+			LeafIndex: verifyRequest.InclusionProof.LeafIndex,
+			TreeSize:  verifyRequest.InclusionProof.TreeSize,
+			Proof:     verifyRequest.InclusionProof.Proof,
+		},
+		Signature:  verifyRequest.Signature,
+		SignedData: verifyRequest.SignedData,
+	})
+	if err != nil {
+		if errors.Is(err, domain.ErrInvalidSignature) {
+			WriteAPIResponse(response, http.StatusOK, VerifyInclusionResponse{
+				Valid: false,
+			})
+			return
+		}
+		if errors.Is(err, domain.ErrMalformed) {
+			writeError(response, http.StatusBadRequest, err)
+			return
+		}
+		if errors.Is(err, domain.ErrNotFound) {
+			writeError(response, http.StatusNotFound, err)
+			return
+		}
+		WriteInternalError(response)
+		return
+	}
+
+	WriteAPIResponse(response, http.StatusOK, VerifyInclusionResponse{
+		Valid: true,
+	})
 }
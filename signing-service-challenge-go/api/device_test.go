@@ -2,14 +2,18 @@ package api
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain"
+	"github.com/gorilla/mux"
 	"io"
 	"net/http"
 	"net/http/httptest"
 	"reflect"
 	"testing"
+	"time"
 )
 
 func assertEqual(t *testing.T, expected interface{}, actual interface{}) {
@@ -26,31 +30,61 @@ func assertJSONEqual(t *testing.T, expected []byte, actual []byte) {
 }
 
 type SignatureDeviceDomainStub struct {
-	CreateSignatureDeviceFunc func(id, algorithm, label string) (domain.SignatureDevice, error)
-	ReadSignatureDeviceFunc   func(id string) (domain.SignatureDevice, error)
-	SignTransactionFunc       func(id string, data string) (domain.Signature, error)
-	ReadSignatureDevicesFunc  func() []domain.SignatureDevice
+	CreateSignatureDeviceFunc func(tenant, id, algorithm, label string, options domain.DeviceOptions) (domain.SignatureDevice, error)
+	ReadSignatureDeviceFunc   func(tenant, id string) (domain.SignatureDevice, error)
+	SignTransactionFunc       func(tenant, id string, data string, options domain.SignOptions) (domain.Signature, error)
+	SignTransactionsFunc      func(tenant, id string, data []string, options domain.SignOptions) ([]domain.Signature, error)
+	VerifySignatureFunc       func(tenant, id string, verification domain.Verification) (string, error)
+	VerifyInclusionFunc       func(tenant, id string, verification domain.InclusionVerification) error
+	DecommissionFunc          func(tenant, id string, expectedVersion int) (domain.SignatureDevice, error)
+	ReadSignatureDevicesFunc  func(tenant string, query domain.DeviceQuery) (domain.DevicePage, error)
+	UpdateFunc                func(tenant, id string, patch domain.DevicePatch) (domain.SignatureDevice, error)
+	RotateKeyFunc             func(tenant, id string, expectedVersion int) (domain.SignatureDevice, error)
 }
 
-func (s *SignatureDeviceDomainStub) CreateSignatureDevice(id, algorithm, label string) (domain.SignatureDevice, error) {
-	return s.CreateSignatureDeviceFunc(id, algorithm, label)
+func (s *SignatureDeviceDomainStub) CreateSignatureDevice(_ context.Context, tenant, id, algorithm, label string, options domain.DeviceOptions) (domain.SignatureDevice, error) {
+	return s.CreateSignatureDeviceFunc(tenant, id, algorithm, label, options)
 }
 
-func (s *SignatureDeviceDomainStub) ReadSignatureDevice(id string) (domain.SignatureDevice, error) {
-	return s.ReadSignatureDeviceFunc(id)
+func (s *SignatureDeviceDomainStub) ReadSignatureDevice(_ context.Context, tenant, id string) (domain.SignatureDevice, error) {
+	return s.ReadSignatureDeviceFunc(tenant, id)
 }
 
-func (s *SignatureDeviceDomainStub) SignTransaction(id string, data string) (domain.Signature, error) {
-	return s.SignTransactionFunc(id, data)
+func (s *SignatureDeviceDomainStub) SignTransaction(_ context.Context, tenant, id string, data string, options domain.SignOptions) (domain.Signature, error) {
+	return s.SignTransactionFunc(tenant, id, data, options)
 }
 
-func (s *SignatureDeviceDomainStub) ReadSignatureDevices() []domain.SignatureDevice {
-	return s.ReadSignatureDevicesFunc()
+func (s *SignatureDeviceDomainStub) SignTransactions(_ context.Context, tenant, id string, data []string, options domain.SignOptions) ([]domain.Signature, error) {
+	return s.SignTransactionsFunc(tenant, id, data, options)
+}
+
+func (s *SignatureDeviceDomainStub) VerifySignature(_ context.Context, tenant, id string, verification domain.Verification) (string, error) {
+	return s.VerifySignatureFunc(tenant, id, verification)
+}
+
+func (s *SignatureDeviceDomainStub) VerifyInclusion(_ context.Context, tenant, id string, verification domain.InclusionVerification) error {
+	return s.VerifyInclusionFunc(tenant, id, verification)
+}
+
+func (s *SignatureDeviceDomainStub) DecommissionSignatureDevice(_ context.Context, tenant, id string, expectedVersion int) (domain.SignatureDevice, error) {
+	return s.DecommissionFunc(tenant, id, expectedVersion)
+}
+
+func (s *SignatureDeviceDomainStub) RotateSignatureDeviceKey(_ context.Context, tenant, id string, expectedVersion int) (domain.SignatureDevice, error) {
+	return s.RotateKeyFunc(tenant, id, expectedVersion)
+}
+
+func (s *SignatureDeviceDomainStub) ReadSignatureDevices(_ context.Context, tenant string, query domain.DeviceQuery) (domain.DevicePage, error) {
+	return s.ReadSignatureDevicesFunc(tenant, query)
+}
+
+func (s *SignatureDeviceDomainStub) UpdateSignatureDevice(_ context.Context, tenant, id string, patch domain.DevicePatch) (domain.SignatureDevice, error) {
+	return s.UpdateFunc(tenant, id, patch)
 }
 
 func TestCreateSignatureDevice_Ok(t *testing.T) {
 	s := NewServer("", &SignatureDeviceDomainStub{
-		CreateSignatureDeviceFunc: func(id, algorithm, label string) (domain.SignatureDevice, error) {
+		CreateSignatureDeviceFunc: func(tenant, id, algorithm, label string, options domain.DeviceOptions) (domain.SignatureDevice, error) {
 			return domain.SignatureDevice{
 				Id:               "550e8400-e29b-11d4-a716-446655440000",
 				Algorithm:        "ECC",
@@ -82,6 +116,55 @@ func TestCreateSignatureDevice_Ok(t *testing.T) {
 	}`))
 }
 
+func TestCreateSignatureDevice_OkMerkle(t *testing.T) {
+	var deviceOptions domain.DeviceOptions
+	s := NewServer("", &SignatureDeviceDomainStub{
+		CreateSignatureDeviceFunc: func(tenant, id, algorithm, label string, options domain.DeviceOptions) (domain.SignatureDevice, error) {
+			deviceOptions = options
+			return domain.SignatureDevice{
+				Id:                "550e8400-e29b-11d4-a716-446655440000",
+				Algorithm:         "ECC",
+				Mode:              options.Mode,
+				AggregationWindow: options.AggregationWindow,
+				AggregationSize:   options.AggregationSize,
+			}, nil
+		},
+	})
+	req := httptest.NewRequest(
+		"POST",
+		"/api/v0/devices",
+		bytes.NewReader([]byte(`{
+			"id": "550e8400-e29b-11d4-a716-446655440000",
+			"algorithm": "ECC",
+			"mode": "merkle",
+			"aggregation_window_ms": 250,
+			"aggregation_size": 64
+		}`),
+		))
+	w := httptest.NewRecorder()
+	s.CreateSignatureDevice(w, req)
+
+	resp := w.Result()
+	body, _ := io.ReadAll(resp.Body)
+
+	assertEqual(t, http.StatusCreated, resp.StatusCode)
+	assertEqual(t, domain.DeviceOptions{
+		Mode:              domain.ModeMerkle,
+		AggregationWindow: 250 * time.Millisecond,
+		AggregationSize:   64,
+	}, deviceOptions)
+	assertJSONEqual(t, []byte(`{
+	  "data": {
+		"id": "550e8400-e29b-11d4-a716-446655440000",
+		"algorithm": "ECC",
+		"signature_counter": 0,
+		"mode": "merkle",
+		"aggregation_window_ms": 250,
+		"aggregation_size": 64
+	  }
+	}`), body)
+}
+
 func TestCreateSignatureDevice_ErrInvalidJSON(t *testing.T) {
 	s := NewServer("", &SignatureDeviceDomainStub{})
 	req := httptest.NewRequest(
@@ -97,13 +180,17 @@ func TestCreateSignatureDevice_ErrInvalidJSON(t *testing.T) {
 
 	assertEqual(t, http.StatusBadRequest, resp.StatusCode)
 	assertJSONEqual(t, []byte(`{
-		"errors":["invalid json body"]
+		"type": "about:blank",
+		"title": "Bad Request",
+		"status": 400,
+		"detail": "invalid json body",
+		"code": "invalid_json"
 	}`), body)
 }
 
 func TestCreateSignatureDevice_ErrExists(t *testing.T) {
 	s := NewServer("", &SignatureDeviceDomainStub{
-		CreateSignatureDeviceFunc: func(id, algorithm, label string) (domain.SignatureDevice, error) {
+		CreateSignatureDeviceFunc: func(tenant, id, algorithm, label string, options domain.DeviceOptions) (domain.SignatureDevice, error) {
 			return domain.SignatureDevice{}, domain.ErrExists
 		},
 	})
@@ -123,13 +210,17 @@ func TestCreateSignatureDevice_ErrExists(t *testing.T) {
 
 	assertEqual(t, http.StatusConflict, resp.StatusCode)
 	assertJSONEqual(t, []byte(`{
-		"errors":["already exists"]
+		"type": "about:blank",
+		"title": "Conflict",
+		"status": 409,
+		"detail": "already exists",
+		"code": "device_exists"
 	}`), body)
 }
 
 func TestCreateSignatureDevice_ErrInvalidUUID(t *testing.T) {
 	s := NewServer("", &SignatureDeviceDomainStub{
-		CreateSignatureDeviceFunc: func(id, algorithm, label string) (domain.SignatureDevice, error) {
+		CreateSignatureDeviceFunc: func(tenant, id, algorithm, label string, options domain.DeviceOptions) (domain.SignatureDevice, error) {
 			return domain.SignatureDevice{}, domain.ErrInvalidUUID
 		},
 	})
@@ -149,13 +240,17 @@ func TestCreateSignatureDevice_ErrInvalidUUID(t *testing.T) {
 
 	assertEqual(t, http.StatusBadRequest, resp.StatusCode)
 	assertJSONEqual(t, []byte(`{
-		"errors":["invalid uuid"]
+		"type": "about:blank",
+		"title": "Bad Request",
+		"status": 400,
+		"detail": "invalid uuid",
+		"code": "invalid_uuid"
 	}`), body)
 }
 
 func TestCreateSignatureDevice_Err(t *testing.T) {
 	s := NewServer("", &SignatureDeviceDomainStub{
-		CreateSignatureDeviceFunc: func(id, algorithm, label string) (domain.SignatureDevice, error) {
+		CreateSignatureDeviceFunc: func(tenant, id, algorithm, label string, options domain.DeviceOptions) (domain.SignatureDevice, error) {
 			return domain.SignatureDevice{}, errors.New("generic error")
 		},
 	})
@@ -175,13 +270,16 @@ func TestCreateSignatureDevice_Err(t *testing.T) {
 
 	assertEqual(t, http.StatusInternalServerError, resp.StatusCode)
 	assertJSONEqual(t, []byte(`{
-		"errors":["Internal Server Error"]
+		"type": "about:blank",
+		"title": "Internal Server Error",
+		"status": 500,
+		"code": "internal_error"
 	}`), body)
 }
 
 func TestReadSignatureDevice_Ok(t *testing.T) {
 	s := NewServer("", &SignatureDeviceDomainStub{
-		ReadSignatureDeviceFunc: func(id string) (domain.SignatureDevice, error) {
+		ReadSignatureDeviceFunc: func(tenant, id string) (domain.SignatureDevice, error) {
 			return domain.SignatureDevice{
 				Id:               "550e8400-e29b-11d4-a716-446655440000",
 				Algorithm:        "ECC",
@@ -208,7 +306,7 @@ func TestReadSignatureDevice_Ok(t *testing.T) {
 
 func TestReadSignatureDevice_ErrNotFound(t *testing.T) {
 	s := NewServer("", &SignatureDeviceDomainStub{
-		ReadSignatureDeviceFunc: func(id string) (domain.SignatureDevice, error) {
+		ReadSignatureDeviceFunc: func(tenant, id string) (domain.SignatureDevice, error) {
 			return domain.SignatureDevice{}, domain.ErrNotFound
 		},
 	})
@@ -221,13 +319,17 @@ func TestReadSignatureDevice_ErrNotFound(t *testing.T) {
 
 	assertEqual(t, http.StatusNotFound, resp.StatusCode)
 	assertJSONEqual(t, []byte(`{
-	  "errors":["not found"]
-	}`), body)
+	  "type": "about:blank",
+	  "title": "Not Found",
+	  "status": 404,
+	  "detail": "not found",
+	  "code": "device_not_found"
+	 }`), body)
 }
 
 func TestReadSignatureDevice_Err(t *testing.T) {
 	s := NewServer("", &SignatureDeviceDomainStub{
-		ReadSignatureDeviceFunc: func(id string) (domain.SignatureDevice, error) {
+		ReadSignatureDeviceFunc: func(tenant, id string) (domain.SignatureDevice, error) {
 			return domain.SignatureDevice{}, errors.New("generic error")
 		},
 	})
@@ -240,16 +342,20 @@ func TestReadSignatureDevice_Err(t *testing.T) {
 
 	assertEqual(t, http.StatusInternalServerError, resp.StatusCode)
 	assertJSONEqual(t, []byte(`{
-	  "errors":["Internal Server Error"]
-	}`), body)
+	  "type": "about:blank",
+	  "title": "Internal Server Error",
+	  "status": 500,
+	  "code": "internal_error"
+	 }`), body)
 }
 
 func TestSignTransaction_Ok(t *testing.T) {
 	s := NewServer("", &SignatureDeviceDomainStub{
-		SignTransactionFunc: func(id string, data string) (domain.Signature, error) {
+		SignTransactionFunc: func(tenant, id string, data string, options domain.SignOptions) (domain.Signature, error) {
 			return domain.Signature{
 				Signature:  "jNpltKGS3268vNJxnKGx22bbmFoLXAiIQx7+RHntlszV2etE3sbs+f/aohtG5Lc7zpWulhuTamy3+SqZFbTGbQ==",
-				SignedData: "0_test_NTUwZTg0MDAtZTI5Yi0xMWQ0LWE3MTYtNDQ2NjU1NDQwMDAw",
+				SignedData: "0_test_NTUwZTg0MDAtZTI5Yi0xMWQ0LWE3MTYtNDQ2NjU1NDQwMDAw_2024-01-02T03:04:05Z",
+				SignedAt:   time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
 			}, nil
 		},
 	})
@@ -270,7 +376,8 @@ func TestSignTransaction_Ok(t *testing.T) {
 	assertJSONEqual(t, []byte(`{
 	  "data": {
 		"signature": "jNpltKGS3268vNJxnKGx22bbmFoLXAiIQx7+RHntlszV2etE3sbs+f/aohtG5Lc7zpWulhuTamy3+SqZFbTGbQ==",
-		"signed_data": "0_test_NTUwZTg0MDAtZTI5Yi0xMWQ0LWE3MTYtNDQ2NjU1NDQwMDAw"
+		"signed_data": "0_test_NTUwZTg0MDAtZTI5Yi0xMWQ0LWE3MTYtNDQ2NjU1NDQwMDAw_2024-01-02T03:04:05Z",
+		"signed_at": "2024-01-02T03:04:05Z"
 	  }
 	}`), body)
 }
@@ -290,13 +397,17 @@ func TestSignTransaction_ErrInvalidJSON(t *testing.T) {
 
 	assertEqual(t, http.StatusBadRequest, resp.StatusCode)
 	assertJSONEqual(t, []byte(`{
-	  "errors":["invalid json body"]
-	}`), body)
+	  "type": "about:blank",
+	  "title": "Bad Request",
+	  "status": 400,
+	  "detail": "invalid json body",
+	  "code": "invalid_json"
+	 }`), body)
 }
 
 func TestSignTransaction_ErrModified(t *testing.T) {
 	s := NewServer("", &SignatureDeviceDomainStub{
-		SignTransactionFunc: func(id string, data string) (domain.Signature, error) {
+		SignTransactionFunc: func(tenant, id string, data string, options domain.SignOptions) (domain.Signature, error) {
 			return domain.Signature{}, domain.ErrModified
 		},
 	})
@@ -315,13 +426,75 @@ func TestSignTransaction_ErrModified(t *testing.T) {
 
 	assertEqual(t, http.StatusConflict, resp.StatusCode)
 	assertJSONEqual(t, []byte(`{
-	  "errors":["concurrent modifications"]
-	}`), body)
+	  "type": "about:blank",
+	  "title": "Conflict",
+	  "status": 409,
+	  "detail": "concurrent modifications",
+	  "code": "concurrent_modification"
+	 }`), body)
+}
+
+func TestSignTransaction_ErrClockRegression(t *testing.T) {
+	s := NewServer("", &SignatureDeviceDomainStub{
+		SignTransactionFunc: func(tenant, id string, data string, options domain.SignOptions) (domain.Signature, error) {
+			return domain.Signature{}, domain.ErrClockRegression
+		},
+	})
+	req := httptest.NewRequest(
+		"POST",
+		"/api/v0/devices/550e8400-e29b-11d4-a716-446655440000:sign",
+		bytes.NewReader([]byte(`{
+			"data_to_be_signed": "data"
+		}`),
+		))
+	w := httptest.NewRecorder()
+	s.SignTransaction(w, req)
+
+	resp := w.Result()
+	body, _ := io.ReadAll(resp.Body)
+
+	assertEqual(t, http.StatusServiceUnavailable, resp.StatusCode)
+	assertJSONEqual(t, []byte(`{
+	  "type": "about:blank",
+	  "title": "Service Unavailable",
+	  "status": 503,
+	  "detail": "clock regression",
+	  "code": "clock_regression"
+	 }`), body)
+}
+
+func TestSignTransaction_ErrAggregationPending(t *testing.T) {
+	s := NewServer("", &SignatureDeviceDomainStub{
+		SignTransactionFunc: func(tenant, id string, data string, options domain.SignOptions) (domain.Signature, error) {
+			return domain.Signature{}, fmt.Errorf("%w: %w", domain.ErrAggregationPending, context.Canceled)
+		},
+	})
+	req := httptest.NewRequest(
+		"POST",
+		"/api/v0/devices/550e8400-e29b-11d4-a716-446655440000:sign",
+		bytes.NewReader([]byte(`{
+			"data_to_be_signed": "data"
+		}`),
+		))
+	w := httptest.NewRecorder()
+	s.SignTransaction(w, req)
+
+	resp := w.Result()
+	body, _ := io.ReadAll(resp.Body)
+
+	assertEqual(t, http.StatusGatewayTimeout, resp.StatusCode)
+	assertJSONEqual(t, []byte(`{
+	  "type": "about:blank",
+	  "title": "Gateway Timeout",
+	  "status": 504,
+	  "detail": "transaction is pending in a tree and may still be signed: context canceled",
+	  "code": "aggregation_pending"
+	 }`), body)
 }
 
 func TestSignTransaction_ErrNotFound(t *testing.T) {
 	s := NewServer("", &SignatureDeviceDomainStub{
-		SignTransactionFunc: func(id string, data string) (domain.Signature, error) {
+		SignTransactionFunc: func(tenant, id string, data string, options domain.SignOptions) (domain.Signature, error) {
 			return domain.Signature{}, domain.ErrNotFound
 		},
 	})
@@ -340,13 +513,17 @@ func TestSignTransaction_ErrNotFound(t *testing.T) {
 
 	assertEqual(t, http.StatusNotFound, resp.StatusCode)
 	assertJSONEqual(t, []byte(`{
-	  "errors":["not found"]
-	}`), body)
+	  "type": "about:blank",
+	  "title": "Not Found",
+	  "status": 404,
+	  "detail": "not found",
+	  "code": "device_not_found"
+	 }`), body)
 }
 
 func TestSignTransaction_Err(t *testing.T) {
 	s := NewServer("", &SignatureDeviceDomainStub{
-		SignTransactionFunc: func(id string, data string) (domain.Signature, error) {
+		SignTransactionFunc: func(tenant, id string, data string, options domain.SignOptions) (domain.Signature, error) {
 			return domain.Signature{}, errors.New("generic error")
 		},
 	})
@@ -365,14 +542,17 @@ func TestSignTransaction_Err(t *testing.T) {
 
 	assertEqual(t, http.StatusInternalServerError, resp.StatusCode)
 	assertJSONEqual(t, []byte(`{
-	  "errors":["Internal Server Error"]
-	}`), body)
+	  "type": "about:blank",
+	  "title": "Internal Server Error",
+	  "status": 500,
+	  "code": "internal_error"
+	 }`), body)
 }
 
 func TestReadSignatureDevices_Ok(t *testing.T) {
 	s := NewServer("", &SignatureDeviceDomainStub{
-		ReadSignatureDevicesFunc: func() []domain.SignatureDevice {
-			return []domain.SignatureDevice{
+		ReadSignatureDevicesFunc: func(tenant string, query domain.DeviceQuery) (domain.DevicePage, error) {
+			return domain.DevicePage{Devices: []domain.SignatureDevice{
 				{
 					Id:               "550e8400-e29b-11d4-a716-446655440000",
 					Algorithm:        "ECC",
@@ -383,7 +563,7 @@ func TestReadSignatureDevices_Ok(t *testing.T) {
 					Algorithm:        "RSA",
 					SignatureCounter: 1,
 				},
-			}
+			}}, nil
 		},
 	})
 	req := httptest.NewRequest("GET", "/api/v0/devices", nil)
@@ -409,3 +589,472 @@ func TestReadSignatureDevices_Ok(t *testing.T) {
 		]
 	}`), body)
 }
+
+func TestReadSignatureDevices_OkQuery(t *testing.T) {
+	var query domain.DeviceQuery
+	s := NewServer("", &SignatureDeviceDomainStub{
+		ReadSignatureDevicesFunc: func(tenant string, q domain.DeviceQuery) (domain.DevicePage, error) {
+			query = q
+			return domain.DevicePage{Devices: []domain.SignatureDevice{}, NextCursor: "next"}, nil
+		},
+	})
+	req := httptest.NewRequest("GET", "/api/v0/devices?algorithm=ECC&label=till&state=active&min_counter=1&max_counter=9&sort=label&order=desc&limit=10&cursor=abc&tag=store:12", nil)
+	w := httptest.NewRecorder()
+	s.ReadSignatureDevices(w, req)
+
+	resp := w.Result()
+	body, _ := io.ReadAll(resp.Body)
+
+	min, max := 1, 9
+	assertEqual(t, http.StatusOK, resp.StatusCode)
+	assertEqual(t, domain.DeviceQuery{
+		Algorithm:     "ECC",
+		LabelContains: "till",
+		State:         domain.DeviceStateActive,
+		Tags:          map[string]string{"store": "12"},
+		MinCounter:    &min,
+		MaxCounter:    &max,
+		SortBy:        domain.SortByLabel,
+		Descending:    true,
+		Cursor:        "abc",
+		Limit:         10,
+	}, query)
+	assertJSONEqual(t, []byte(`{"data":[],"next_cursor":"next"}`), body)
+}
+
+func TestReadSignatureDevices_ErrInvalidQuery(t *testing.T) {
+	s := NewServer("", &SignatureDeviceDomainStub{
+		ReadSignatureDevicesFunc: func(tenant string, query domain.DeviceQuery) (domain.DevicePage, error) {
+			return domain.DevicePage{}, domain.ErrInvalidCursor
+		},
+	})
+
+	for _, path := range []string{"/api/v0/devices?limit=ten", "/api/v0/devices?order=up", "/api/v0/devices?cursor=abc"} {
+		w := httptest.NewRecorder()
+		s.ReadSignatureDevices(w, httptest.NewRequest("GET", path, nil))
+		assertEqual(t, http.StatusBadRequest, w.Result().StatusCode)
+	}
+}
+
+func TestUpdateSignatureDevice_Ok(t *testing.T) {
+	var patch domain.DevicePatch
+	s := NewServer("", &SignatureDeviceDomainStub{
+		UpdateFunc: func(tenant, id string, p domain.DevicePatch) (domain.SignatureDevice, error) {
+			patch = p
+			return domain.SignatureDevice{
+				Id:        id,
+				Algorithm: "ECC",
+				Label:     *p.Label,
+				Tags:      map[string]string{"store": "12"},
+			}, nil
+		},
+	})
+	req := httptest.NewRequest(
+		"PATCH",
+		"/api/v0/devices/550e8400-e29b-11d4-a716-446655440000",
+		bytes.NewReader([]byte(`{"label": "till", "tags": {"store": "12"}}`)),
+	)
+	req.Header.Set("Content-Type", "application/merge-patch+json")
+	req = mux.SetURLVars(req, map[string]string{"id": "550e8400-e29b-11d4-a716-446655440000"})
+	w := httptest.NewRecorder()
+	s.UpdateSignatureDevice(w, req)
+
+	resp := w.Result()
+	body, _ := io.ReadAll(resp.Body)
+
+	assertEqual(t, http.StatusOK, resp.StatusCode)
+	assertEqual(t, `{"store": "12"}`, string(patch.Tags))
+	assertEqual(t, 0, len(patch.Metadata))
+	assertJSONEqual(t, []byte(`{
+		"data": {
+			"id": "550e8400-e29b-11d4-a716-446655440000",
+			"algorithm": "ECC",
+			"label": "till",
+			"signature_counter": 0,
+			"tags": {"store": "12"}
+		}
+	}`), body)
+}
+
+func TestUpdateSignatureDevice_ImmutableFields(t *testing.T) {
+	s := NewServer("", &SignatureDeviceDomainStub{})
+
+	for _, body := range []string{`{"signature_counter": 0}`, `{"public_key": "key"}`, `{"label": 1}`, `[]`} {
+		req := httptest.NewRequest("PATCH", "/api/v0/devices/550e8400-e29b-11d4-a716-446655440000", bytes.NewReader([]byte(body)))
+		w := httptest.NewRecorder()
+		s.UpdateSignatureDevice(w, req)
+		assertEqual(t, http.StatusBadRequest, w.Result().StatusCode)
+	}
+}
+
+func TestUpdateSignatureDevice_Errors(t *testing.T) {
+	cases := []struct {
+		err      error
+		expected int
+	}{
+		{domain.ErrNotFound, http.StatusNotFound},
+		{domain.ErrInvalidTags, http.StatusBadRequest},
+		{domain.ErrModified, http.StatusConflict},
+	}
+	for _, c := range cases {
+		s := NewServer("", &SignatureDeviceDomainStub{
+			UpdateFunc: func(tenant, id string, patch domain.DevicePatch) (domain.SignatureDevice, error) {
+				return domain.SignatureDevice{}, c.err
+			},
+		})
+		req := httptest.NewRequest("PATCH", "/api/v0/devices/550e8400-e29b-11d4-a716-446655440000", bytes.NewReader([]byte(`{"tags": {}}`)))
+		w := httptest.NewRecorder()
+		s.UpdateSignatureDevice(w, req)
+		assertEqual(t, c.expected, w.Result().StatusCode)
+	}
+
+	req := httptest.NewRequest("PATCH", "/api/v0/devices/550e8400-e29b-11d4-a716-446655440000", bytes.NewReader([]byte(`{}`)))
+	req.Header.Set("Content-Type", "text/plain")
+	w := httptest.NewRecorder()
+	NewServer("", &SignatureDeviceDomainStub{}).UpdateSignatureDevice(w, req)
+	assertEqual(t, http.StatusUnsupportedMediaType, w.Result().StatusCode)
+}
+
+func TestSignTransaction_OkFormat(t *testing.T) {
+	var format domain.SignatureFormat
+	s := NewServer("", &SignatureDeviceDomainStub{
+		SignTransactionFunc: func(tenant, id string, data string, options domain.SignOptions) (domain.Signature, error) {
+			format = options.Format
+			return domain.Signature{}, nil
+		},
+	})
+	req := httptest.NewRequest(
+		"POST",
+		"/api/v0/devices/550e8400-e29b-11d4-a716-446655440000:sign",
+		bytes.NewReader([]byte(`{
+			"data_to_be_signed": "data",
+			"format": "jws"
+		}`),
+		))
+	w := httptest.NewRecorder()
+	s.SignTransaction(w, req)
+
+	resp := w.Result()
+
+	assertEqual(t, http.StatusOK, resp.StatusCode)
+	assertEqual(t, domain.FormatJWS, format)
+}
+
+func TestSignTransaction_ErrInvalidFormat(t *testing.T) {
+	s := NewServer("", &SignatureDeviceDomainStub{
+		SignTransactionFunc: func(tenant, id string, data string, options domain.SignOptions) (domain.Signature, error) {
+			return domain.Signature{}, domain.ErrInvalidFormat
+		},
+	})
+	req := httptest.NewRequest(
+		"POST",
+		"/api/v0/devices/550e8400-e29b-11d4-a716-446655440000:sign",
+		bytes.NewReader([]byte(`{
+			"data_to_be_signed": "data",
+			"format": "xml"
+		}`),
+		))
+	w := httptest.NewRecorder()
+	s.SignTransaction(w, req)
+
+	resp := w.Result()
+	body, _ := io.ReadAll(resp.Body)
+
+	assertEqual(t, http.StatusBadRequest, resp.StatusCode)
+	assertJSONEqual(t, []byte(`{
+	  "type": "about:blank",
+	  "title": "Bad Request",
+	  "status": 400,
+	  "detail": "invalid signature format",
+	  "code": "invalid_signature_format"
+	 }`), body)
+}
+
+func TestVerifySignature_Ok(t *testing.T) {
+	s := NewServer("", &SignatureDeviceDomainStub{
+		VerifySignatureFunc: func(tenant, id string, verification domain.Verification) (string, error) {
+			return "0_test_NTUwZTg0MDAtZTI5Yi0xMWQ0LWE3MTYtNDQ2NjU1NDQwMDAw_2024-01-02T03:04:05Z", nil
+		},
+	})
+	req := httptest.NewRequest(
+		"POST",
+		"/api/v0/devices/550e8400-e29b-11d4-a716-446655440000:verify",
+		bytes.NewReader([]byte(`{
+			"signature": "eyJhbGciOiJFUzM4NCJ9.MA.c2ln",
+			"format": "jws"
+		}`),
+		))
+	w := httptest.NewRecorder()
+	s.VerifySignature(w, req)
+
+	resp := w.Result()
+	body, _ := io.ReadAll(resp.Body)
+
+	assertEqual(t, http.StatusOK, resp.StatusCode)
+	assertJSONEqual(t, []byte(`{
+	  "data": {
+		"valid": true,
+		"signed_data": "0_test_NTUwZTg0MDAtZTI5Yi0xMWQ0LWE3MTYtNDQ2NjU1NDQwMDAw_2024-01-02T03:04:05Z"
+	  }
+	}`), body)
+}
+
+func TestVerifySignature_OkInvalid(t *testing.T) {
+	s := NewServer("", &SignatureDeviceDomainStub{
+		VerifySignatureFunc: func(tenant, id string, verification domain.Verification) (string, error) {
+			return "", domain.ErrInvalidSignature
+		},
+	})
+	req := httptest.NewRequest(
+		"POST",
+		"/api/v0/devices/550e8400-e29b-11d4-a716-446655440000:verify",
+		bytes.NewReader([]byte(`{
+			"signature": "c2ln",
+			"signed_data": "data"
+		}`),
+		))
+	w := httptest.NewRecorder()
+	s.VerifySignature(w, req)
+
+	resp := w.Result()
+	body, _ := io.ReadAll(resp.Body)
+
+	assertEqual(t, http.StatusOK, resp.StatusCode)
+	assertJSONEqual(t, []byte(`{
+	  "data": {
+		"valid": false
+	  }
+	}`), body)
+}
+
+func TestVerifySignature_ErrMalformed(t *testing.T) {
+	s := NewServer("", &SignatureDeviceDomainStub{
+		VerifySignatureFunc: func(tenant, id string, verification domain.Verification) (string, error) {
+			return "", domain.ErrMalformed
+		},
+	})
+	req := httptest.NewRequest(
+		"POST",
+		"/api/v0/devices/550e8400-e29b-11d4-a716-446655440000:verify",
+		bytes.NewReader([]byte(`{
+			"signature": "%%%",
+			"format": "cose"
+		}`),
+		))
+	w := httptest.NewRecorder()
+	s.VerifySignature(w, req)
+
+	resp := w.Result()
+	body, _ := io.ReadAll(resp.Body)
+
+	assertEqual(t, http.StatusBadRequest, resp.StatusCode)
+	assertJSONEqual(t, []byte(`{
+	  "type": "about:blank",
+	  "title": "Bad Request",
+	  "status": 400,
+	  "detail": "malformed signature",
+	  "code": "malformed_signature"
+	 }`), body)
+}
+
+func TestSignTransactions_Ok(t *testing.T) {
+	s := NewServer("", &SignatureDeviceDomainStub{
+		SignTransactionsFunc: func(tenant, id string, data []string, options domain.SignOptions) ([]domain.Signature, error) {
+			return []domain.Signature{
+				{
+					Signature:  "c2lnbmF0dXJlMA==",
+					SignedData: "0_a_NTUwZTg0MDAtZTI5Yi0xMWQ0LWE3MTYtNDQ2NjU1NDQwMDAw_2024-01-02T03:04:05Z",
+					SignedAt:   time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+				},
+				{
+					Signature:  "c2lnbmF0dXJlMQ==",
+					SignedData: "1_b_c2lnbmF0dXJlMA==_2024-01-02T03:04:05Z",
+					SignedAt:   time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+				},
+			}, nil
+		},
+	})
+	req := httptest.NewRequest(
+		"POST",
+		"/api/v0/devices/550e8400-e29b-11d4-a716-446655440000:sign-batch",
+		bytes.NewReader([]byte(`{
+			"data_to_be_signed": ["a", "b"]
+		}`),
+		))
+	w := httptest.NewRecorder()
+	s.SignTransactions(w, req)
+
+	resp := w.Result()
+	body, _ := io.ReadAll(resp.Body)
+
+	assertEqual(t, http.StatusOK, resp.StatusCode)
+	assertJSONEqual(t, []byte(`{
+	  "data": [
+		{
+		  "signature": "c2lnbmF0dXJlMA==",
+		  "signed_data": "0_a_NTUwZTg0MDAtZTI5Yi0xMWQ0LWE3MTYtNDQ2NjU1NDQwMDAw_2024-01-02T03:04:05Z",
+		  "signed_at": "2024-01-02T03:04:05Z"
+		},
+		{
+		  "signature": "c2lnbmF0dXJlMQ==",
+		  "signed_data": "1_b_c2lnbmF0dXJlMA==_2024-01-02T03:04:05Z",
+		  "signed_at": "2024-01-02T03:04:05Z"
+		}
+	  ]
+	}`), body)
+}
+
+func TestSignTransactions_ErrEmptyBatch(t *testing.T) {
+	s := NewServer("", &SignatureDeviceDomainStub{
+		SignTransactionsFunc: func(tenant, id string, data []string, options domain.SignOptions) ([]domain.Signature, error) {
+			return nil, domain.ErrEmptyBatch
+		},
+	})
+	req := httptest.NewRequest(
+		"POST",
+		"/api/v0/devices/550e8400-e29b-11d4-a716-446655440000:sign-batch",
+		bytes.NewReader([]byte(`{
+			"data_to_be_signed": []
+		}`),
+		))
+	w := httptest.NewRecorder()
+	s.SignTransactions(w, req)
+
+	resp := w.Result()
+	body, _ := io.ReadAll(resp.Body)
+
+	assertEqual(t, http.StatusBadRequest, resp.StatusCode)
+	assertJSONEqual(t, []byte(`{
+	  "type": "about:blank",
+	  "title": "Bad Request",
+	  "status": 400,
+	  "detail": "empty batch",
+	  "code": "empty_batch"
+	 }`), body)
+}
+
+func TestVerifyInclusion_Ok(t *testing.T) {
+	var inclusion domain.InclusionVerification
+	s := NewServer("", &SignatureDeviceDomainStub{
+		VerifyInclusionFunc: func(tenant, id string, verification domain.InclusionVerification) error {
+			inclusion = verification
+			return nil
+		},
+	})
+	req := httptest.NewRequest(
+		"POST",
+		"/api/v0/devices/550e8400-e29b-11d4-a716-446655440000:verify-inclusion",
+		bytes.NewReader([]byte(`{
+			"data_to_be_signed": "test",
+			"inclusion_proof": {
+				"root": "ab",
+				"leaf_index": 1,
+				"tree_size": 2,
+				"proof": ["cd"]
+			},
+			"signature": "c2ln",
+			"signed_data": "0_ab_NTUw_2024-01-02T03:04:05Z"
+		}`),
+		))
+	w := httptest.NewRecorder()
+	s.VerifyInclusion(w, req)
+
+	resp := w.Result()
+	body, _ := io.ReadAll(resp.Body)
+
+	assertEqual(t, http.StatusOK, resp.StatusCode)
+	assertEqual(t, domain.InclusionProof{Root: "ab", LeafIndex: 1, TreeSize: 2, Proof: []string{"cd"}}, inclusion.Inclusion)
+	assertJSONEqual(t, []byte(`{
+	  "data": {
+		"valid": true
+	  }
+	}`), body)
+}
+
+func TestDecommissionSignatureDevice_Ok(t *testing.T) {
+	s := NewServer("", &SignatureDeviceDomainStub{
+		DecommissionFunc: func(tenant, id string, expectedVersion int) (domain.SignatureDevice, error) {
+			return domain.SignatureDevice{
+				Id:               "550e8400-e29b-11d4-a716-446655440000",
+				Algorithm:        "ECC",
+				SignatureCounter: 2,
+				Mode:             domain.ModeSingle,
+				DecommissionedAt: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+			}, nil
+		},
+	})
+	req := httptest.NewRequest("POST", "/api/v0/devices/550e8400-e29b-11d4-a716-446655440000:decommission", nil)
+	w := httptest.NewRecorder()
+	s.DecommissionSignatureDevice(w, req)
+
+	resp := w.Result()
+	body, _ := io.ReadAll(resp.Body)
+
+	assertEqual(t, http.StatusOK, resp.StatusCode)
+	assertJSONEqual(t, []byte(`{
+	  "data": {
+		"id": "550e8400-e29b-11d4-a716-446655440000",
+		"algorithm": "ECC",
+		"signature_counter": 2,
+		"mode": "single",
+		"decommissioned_at": "2024-01-02T03:04:05Z"
+	  }
+	}`), body)
+}
+
+func TestRotateSignatureDeviceKey_Ok(t *testing.T) {
+	s := NewServer("", &SignatureDeviceDomainStub{
+		RotateKeyFunc: func(tenant, id string, expectedVersion int) (domain.SignatureDevice, error) {
+			assertEqual(t, 3, expectedVersion)
+			return domain.SignatureDevice{
+				Id:               id,
+				Algorithm:        "ECC",
+				SignatureCounter: 2,
+				Mode:             domain.ModeSingle,
+				Version:          4,
+			}, nil
+		},
+	})
+	req := httptest.NewRequest("POST", "/api/v0/devices/550e8400-e29b-11d4-a716-446655440000:rotate-key", nil)
+	req.Header.Set("If-Match", `"3"`)
+	w := httptest.NewRecorder()
+	s.Router().ServeHTTP(w, req)
+
+	resp := w.Result()
+	body, _ := io.ReadAll(resp.Body)
+
+	assertEqual(t, http.StatusOK, resp.StatusCode)
+	assertEqual(t, `"4"`, resp.Header.Get("ETag"))
+	assertJSONEqual(t, []byte(`{
+	  "data": {
+		"id": "550e8400-e29b-11d4-a716-446655440000",
+		"algorithm": "ECC",
+		"signature_counter": 2,
+		"mode": "single",
+		"version": 4
+	  }
+	}`), body)
+}
+
+func TestDecommissionSignatureDevice_ErrDecommissioned(t *testing.T) {
+	s := NewServer("", &SignatureDeviceDomainStub{
+		DecommissionFunc: func(tenant, id string, expectedVersion int) (domain.SignatureDevice, error) {
+			return domain.SignatureDevice{}, domain.ErrDecommissioned
+		},
+	})
+	req := httptest.NewRequest("POST", "/api/v0/devices/550e8400-e29b-11d4-a716-446655440000:decommission", nil)
+	w := httptest.NewRecorder()
+	s.DecommissionSignatureDevice(w, req)
+
+	resp := w.Result()
+	body, _ := io.ReadAll(resp.Body)
+
+	assertEqual(t, http.StatusConflict, resp.StatusCode)
+	assertJSONEqual(t, []byte(`{
+		"type": "about:blank",
+		"title": "Conflict",
+		"status": 409,
+		"detail": "device decommissioned",
+		"code": "device_decommissioned"
+	}`), body)
+}
@@ -0,0 +1,37 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain"
+)
+
+// etag returns the strong entity tag of a device version.
+func etag(version int) string {
+	return `"` + strconv.Itoa(version) + `"`
+}
+
+// setETag tags the response with the version of the device.
+func setETag(response http.ResponseWriter, device domain.SignatureDevice) {
+	if device.Version > 0 {
+		response.Header().Set("ETag", etag(device.Version))
+	}
+}
+
+// ifMatch returns the device version a request expects from its If-Match
+// header, 0 if it is absent or "*". It writes 412 and returns false if the
+// header is not a single strong entity tag of a version.
+func ifMatch(response http.ResponseWriter, request *http.Request) (int, bool) {
+	value := strings.TrimSpace(request.Header.Get("If-Match"))
+	if value == "" || value == "*" {
+		return 0, true
+	}
+	version, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(value, `"`), `"`))
+	if err != nil || version <= 0 || etag(version) != value {
+		writeError(response, http.StatusPreconditionFailed, domain.ErrPreconditionFailed)
+		return 0, false
+	}
+	return version, true
+}
@@ -0,0 +1,103 @@
+package api
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain"
+)
+
+func TestReadSignatureDevice_ETag(t *testing.T) {
+	s := NewServer("", &SignatureDeviceDomainStub{
+		ReadSignatureDeviceFunc: func(tenant, id string) (domain.SignatureDevice, error) {
+			return domain.SignatureDevice{
+				Id:        "550e8400-e29b-11d4-a716-446655440000",
+				Algorithm: "ECC",
+				Version:   3,
+			}, nil
+		},
+	})
+	req := httptest.NewRequest("GET", "/api/v0/devices/550e8400-e29b-11d4-a716-446655440000", nil)
+	w := httptest.NewRecorder()
+	s.ReadSignatureDevice(w, req)
+
+	resp := w.Result()
+	body, _ := io.ReadAll(resp.Body)
+
+	assertEqual(t, http.StatusOK, resp.StatusCode)
+	assertEqual(t, `"3"`, resp.Header.Get("ETag"))
+	assertJSONEqual(t, []byte(`{
+	  "data": {
+		"id": "550e8400-e29b-11d4-a716-446655440000",
+		"algorithm": "ECC",
+		"signature_counter": 0,
+		"version": 3
+	  }
+	}`), body)
+
+	req = httptest.NewRequest("GET", "/api/v0/devices/550e8400-e29b-11d4-a716-446655440000", nil)
+	req.Header.Set("If-None-Match", `"3"`)
+	w = httptest.NewRecorder()
+	s.ReadSignatureDevice(w, req)
+
+	assertEqual(t, http.StatusNotModified, w.Result().StatusCode)
+}
+
+func TestDecommissionSignatureDevice_IfMatch(t *testing.T) {
+	var expected int
+	s := NewServer("", &SignatureDeviceDomainStub{
+		DecommissionFunc: func(tenant, id string, expectedVersion int) (domain.SignatureDevice, error) {
+			expected = expectedVersion
+			return domain.SignatureDevice{Id: id, Version: expectedVersion + 1}, nil
+		},
+	})
+	req := httptest.NewRequest("POST", "/api/v0/devices/550e8400-e29b-11d4-a716-446655440000:decommission", nil)
+	req.Header.Set("If-Match", `"7"`)
+	w := httptest.NewRecorder()
+	s.DecommissionSignatureDevice(w, req)
+
+	resp := w.Result()
+
+	assertEqual(t, http.StatusOK, resp.StatusCode)
+	assertEqual(t, 7, expected)
+	assertEqual(t, `"8"`, resp.Header.Get("ETag"))
+}
+
+func TestDecommissionSignatureDevice_ErrPreconditionFailed(t *testing.T) {
+	s := NewServer("", &SignatureDeviceDomainStub{
+		DecommissionFunc: func(tenant, id string, expectedVersion int) (domain.SignatureDevice, error) {
+			return domain.SignatureDevice{}, domain.ErrPreconditionFailed
+		},
+	})
+	req := httptest.NewRequest("POST", "/api/v0/devices/550e8400-e29b-11d4-a716-446655440000:decommission", nil)
+	req.Header.Set("If-Match", `"7"`)
+	w := httptest.NewRecorder()
+	s.DecommissionSignatureDevice(w, req)
+
+	resp := w.Result()
+	body, _ := io.ReadAll(resp.Body)
+
+	assertEqual(t, http.StatusPreconditionFailed, resp.StatusCode)
+	assertJSONEqual(t, []byte(`{
+		"type": "about:blank",
+		"title": "Precondition Failed",
+		"status": 412,
+		"detail": "precondition failed",
+		"code": "precondition_failed"
+	}`), body)
+}
+
+func TestSignTransaction_InvalidIfMatch(t *testing.T) {
+	s := NewServer("", &SignatureDeviceDomainStub{})
+	for _, value := range []string{`W/"1"`, `1`, `"0"`, `"1", "2"`} {
+		req := httptest.NewRequest("POST", "/api/v0/devices/550e8400-e29b-11d4-a716-446655440000:sign", bytes.NewReader([]byte(`{"data_to_be_signed": "a"}`)))
+		req.Header.Set("If-Match", value)
+		w := httptest.NewRecorder()
+		s.SignTransaction(w, req)
+
+		assertEqual(t, http.StatusPreconditionFailed, w.Result().StatusCode)
+	}
+}
@@ -1,25 +1,117 @@
 package api
 
-import "net/http"
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	healthPass = "pass"
+	healthFail = "fail"
+)
+
+// readinessTimeout bounds the checks of a single readiness probe.
+const readinessTimeout = 2 * time.Second
 
 type HealthResponse struct {
 	Status  string `json:"status"`
 	Version string `json:"version"`
+	// Checks holds "pass" or the error of every readiness check.
+	Checks map[string]string `json:"checks,omitempty"`
 }
 
+// Check reports whether a dependency of the service can serve requests.
+type Check func(ctx context.Context) error
+
 // Health evaluates the health of the service and writes a standardized response.
 func (s *Server) Health(response http.ResponseWriter, request *http.Request) {
 	if request.Method != http.MethodGet {
-		WriteErrorResponse(response, http.StatusMethodNotAllowed, []string{
-			http.StatusText(http.StatusMethodNotAllowed),
-		})
+		WriteProblem(response, http.StatusMethodNotAllowed, CodeMethodNotAllowed, "")
 		return
 	}
+	s.writeReadiness(response, request)
+}
+
+// Livez reports that the process serves requests. It does not depend on
+// the checks, so that a failing dependency does not restart the service.
+func (s *Server) Livez(response http.ResponseWriter, request *http.Request) {
+	WriteAPIResponse(response, http.StatusOK, HealthResponse{
+		Status:  healthPass,
+		Version: "v0",
+	})
+}
 
+// Readyz reports whether the service accepts traffic: it is not shutting
+// down and every readiness check passes.
+func (s *Server) Readyz(response http.ResponseWriter, request *http.Request) {
+	s.writeReadiness(response, request)
+}
+
+func (s *Server) writeReadiness(response http.ResponseWriter, request *http.Request) {
 	health := HealthResponse{
-		Status:  "pass",
+		Status:  healthPass,
 		Version: "v0",
 	}
+	if len(s.checks) > 0 {
+		health.Checks = s.runChecks(request.Context())
+	}
+	if s.Draining() {
+		if health.Checks == nil {
+			health.Checks = make(map[string]string)
+		}
+		health.Checks["server"] = "shutting down"
+	}
+	for _, result := range health.Checks {
+		if result != healthPass {
+			health.Status = healthFail
+		}
+	}
+
+	status := http.StatusOK
+	if health.Status != healthPass {
+		status = http.StatusServiceUnavailable
+	}
+	WriteAPIResponse(response, status, health)
+}
 
-	WriteAPIResponse(response, http.StatusOK, health)
+// runChecks runs the readiness checks concurrently, each bounded by the
+// readinessTimeout.
+func (s *Server) runChecks(ctx context.Context) map[string]string {
+	ctx, cancel := context.WithTimeout(ctx, readinessTimeout)
+	defer cancel()
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	checks := make(map[string]string, len(s.checks))
+	for name, check := range s.checks {
+		wg.Add(1)
+		go func(name string, check Check) {
+			defer wg.Done()
+			result := runCheck(ctx, check)
+			mu.Lock()
+			defer mu.Unlock()
+			checks[name] = result
+		}(name, check)
+	}
+	wg.Wait()
+	return checks
+}
+
+// runCheck fails a check that does not return before ctx is done.
+func runCheck(ctx context.Context, check Check) string {
+	done := make(chan error, 1)
+	go func() {
+		done <- check(ctx)
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			return err.Error()
+		}
+		return healthPass
+	case <-ctx.Done():
+		return ctx.Err().Error()
+	}
 }
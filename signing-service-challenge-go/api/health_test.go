@@ -0,0 +1,137 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain"
+)
+
+func TestLivez_IgnoresChecks(t *testing.T) {
+	s := NewServer("", &SignatureDeviceDomainStub{}, WithReadinessCheck("storage", func(ctx context.Context) error {
+		return errors.New("unreachable")
+	}))
+
+	w := httptest.NewRecorder()
+	s.Router().ServeHTTP(w, httptest.NewRequest("GET", "/livez", nil))
+
+	assertEqual(t, http.StatusOK, w.Code)
+	assertJSONEqual(t, []byte(`{"data":{"status":"pass","version":"v0"}}`), w.Body.Bytes())
+}
+
+func TestReadyz_Pass(t *testing.T) {
+	s := NewServer("", &SignatureDeviceDomainStub{},
+		WithReadinessCheck("storage", func(ctx context.Context) error { return nil }),
+		WithReadinessCheck("keys", func(ctx context.Context) error { return nil }),
+	)
+
+	w := httptest.NewRecorder()
+	s.Router().ServeHTTP(w, httptest.NewRequest("GET", "/readyz", nil))
+
+	assertEqual(t, http.StatusOK, w.Code)
+	assertJSONEqual(t, []byte(`{"data":{"status":"pass","version":"v0","checks":{"storage":"pass","keys":"pass"}}}`), w.Body.Bytes())
+}
+
+func TestReadyz_FailingCheck(t *testing.T) {
+	s := NewServer("", &SignatureDeviceDomainStub{},
+		WithReadinessCheck("storage", func(ctx context.Context) error { return errors.New("unreachable") }),
+		WithReadinessCheck("keys", func(ctx context.Context) error { return nil }),
+	)
+
+	w := httptest.NewRecorder()
+	s.Router().ServeHTTP(w, httptest.NewRequest("GET", "/readyz", nil))
+
+	assertEqual(t, http.StatusServiceUnavailable, w.Code)
+	assertJSONEqual(t, []byte(`{"data":{"status":"fail","version":"v0","checks":{"storage":"unreachable","keys":"pass"}}}`), w.Body.Bytes())
+}
+
+func TestReadyz_FailsWhileShuttingDown(t *testing.T) {
+	s := NewServer("", &SignatureDeviceDomainStub{})
+	assertEqual(t, nil, s.Shutdown(context.Background()))
+
+	w := httptest.NewRecorder()
+	s.Router().ServeHTTP(w, httptest.NewRequest("GET", "/readyz", nil))
+
+	assertEqual(t, http.StatusServiceUnavailable, w.Code)
+	assertJSONEqual(t, []byte(`{"data":{"status":"fail","version":"v0","checks":{"server":"shutting down"}}}`), w.Body.Bytes())
+}
+
+func TestShutdown_DrainsInFlightSigning(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	s := NewServer("", &SignatureDeviceDomainStub{
+		SignTransactionFunc: func(tenant, id string, data string, options domain.SignOptions) (domain.Signature, error) {
+			close(started)
+			<-release
+			return domain.Signature{Counter: 1}, nil
+		},
+	})
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assertEqual(t, nil, err)
+	served := make(chan error, 1)
+	go func() {
+		served <- s.Serve(listener)
+	}()
+
+	signed := make(chan int, 1)
+	go func() {
+		response, err := http.Post("http://"+listener.Addr().String()+"/api/v0/devices/550e8400-e29b-11d4-a716-446655440000:sign", "application/json", bytes.NewReader([]byte(`{"data_to_be_signed":"data"}`)))
+		if err != nil {
+			signed <- 0
+			return
+		}
+		response.Body.Close()
+		signed <- response.StatusCode
+	}()
+	<-started
+
+	shutdown := make(chan error, 1)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		shutdown <- s.Shutdown(ctx)
+	}()
+	select {
+	case <-shutdown:
+		t.Fatal("Shutdown returned while a signature was in flight")
+	case <-time.After(50 * time.Millisecond):
+	}
+	_, err = http.Get("http://" + listener.Addr().String() + "/livez")
+	if err == nil {
+		t.Error("Expected new connections to be refused")
+	}
+
+	close(release)
+	assertEqual(t, http.StatusOK, <-signed)
+	assertEqual(t, nil, <-shutdown)
+	assertEqual(t, nil, <-served)
+}
+
+func TestShutdown_DeadlineExceeded(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	started := make(chan struct{})
+	s := NewServer("", &SignatureDeviceDomainStub{
+		SignTransactionFunc: func(tenant, id string, data string, options domain.SignOptions) (domain.Signature, error) {
+			close(started)
+			<-release
+			return domain.Signature{}, nil
+		},
+	})
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assertEqual(t, nil, err)
+	go s.Serve(listener)
+	go http.Post("http://"+listener.Addr().String()+"/api/v0/devices/550e8400-e29b-11d4-a716-446655440000:sign", "application/json", bytes.NewReader([]byte(`{"data_to_be_signed":"data"}`)))
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	assertEqual(t, context.DeadlineExceeded, s.Shutdown(ctx))
+}
@@ -0,0 +1,99 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/jobs"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/rbac"
+	"github.com/gorilla/mux"
+)
+
+type JobResponse struct {
+	Id          string                   `json:"id"`
+	DeviceId    string                   `json:"device_id"`
+	Status      string                   `json:"status"`
+	Result      *SignTransactionResponse `json:"result,omitempty"`
+	Error       string                   `json:"error,omitempty"`
+	CreatedAt   time.Time                `json:"created_at"`
+	CompletedAt *time.Time               `json:"completed_at,omitempty"`
+	// CallbackSecret is only returned when the job is submitted.
+	CallbackSecret string `json:"callback_secret,omitempty"`
+}
+
+func newJobResponse(job jobs.Job) JobResponse {
+	jobResponse := JobResponse{
+		Id:        job.Id,
+		DeviceId:  job.DeviceId,
+		Status:    string(job.Status),
+		Error:     job.Error,
+		CreatedAt: job.CreatedAt,
+	}
+	if job.Signature != nil {
+		result := newSignTransactionResponse(*job.Signature)
+		jobResponse.Result = &result
+	}
+	if !job.CompletedAt.IsZero() {
+		completedAt := job.CompletedAt
+		jobResponse.CompletedAt = &completedAt
+	}
+	return jobResponse
+}
+
+// submitJob queues a SignTransaction call and answers with 202 Accepted and
+// the location the job can be polled at. The callback secret is only
+// returned here.
+func (s *Server) submitJob(response http.ResponseWriter, request *http.Request, id string, signRequest SignTransactionRequest, expectedVersion int) {
+	if s.jobs == nil {
+		WriteProblem(response, http.StatusBadRequest, CodeAsyncDisabled, "asynchronous signing is not enabled")
+		return
+	}
+
+	job, err := s.jobs.Submit(request.Context(), requestTenant(request), id, signRequest.DataToBeSigned, domain.SignOptions{
+		Format:          domain.SignatureFormat(signRequest.Format),
+		KeyId:           requestKeyId(request),
+		ExpectedVersion: expectedVersion,
+	}, signRequest.CallbackURL, signRequest.CallbackSecret)
+	if err != nil {
+		if errors.Is(err, jobs.ErrInvalidCallback) {
+			writeError(response, http.StatusBadRequest, err)
+			return
+		}
+		if errors.Is(err, jobs.ErrQueueFull) || errors.Is(err, jobs.ErrClosed) {
+			response.Header().Set("Retry-After", "1")
+			writeError(response, http.StatusServiceUnavailable, err)
+			return
+		}
+		WriteInternalError(response)
+		return
+	}
+
+	response.Header().Set("Location", "/api/v0/jobs/"+job.Id)
+	jobResponse := newJobResponse(job)
+	jobResponse.CallbackSecret = job.CallbackSecret
+	WriteAPIResponse(response, http.StatusAccepted, jobResponse)
+}
+
+func (s *Server) ReadJob(response http.ResponseWriter, request *http.Request) {
+	vars := mux.Vars(request)
+	id := vars["jobId"]
+
+	job, err := s.jobs.Find(requestTenant(request), id)
+	// A job of a device the caller may not read is reported as missing, so
+	// that the existence of the job does not leak.
+	if err == nil && !s.authorizesDevice(request, rbac.ActionRead, job.DeviceId) {
+		err = jobs.ErrNotFound
+	}
+	if err != nil {
+		if errors.Is(err, jobs.ErrNotFound) {
+			writeError(response, http.StatusNotFound, err)
+			return
+		}
+		WriteInternalError(response)
+		return
+	}
+
+	WriteAPIResponse(response, http.StatusOK, newJobResponse(job))
+}
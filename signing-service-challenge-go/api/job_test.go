@@ -0,0 +1,191 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/jobs"
+	"github.com/gorilla/mux"
+)
+
+type JobQueueStub struct {
+	SubmitFunc func(tenant, deviceId, data string, options domain.SignOptions, callbackURL, callbackSecret string) (jobs.Job, error)
+	FindFunc   func(tenant, id string) (jobs.Job, error)
+}
+
+func (s *JobQueueStub) Submit(_ context.Context, tenant, deviceId, data string, options domain.SignOptions, callbackURL, callbackSecret string) (jobs.Job, error) {
+	return s.SubmitFunc(tenant, deviceId, data, options, callbackURL, callbackSecret)
+}
+
+func (s *JobQueueStub) Find(tenant, id string) (jobs.Job, error) {
+	return s.FindFunc(tenant, id)
+}
+
+func (s *JobQueueStub) Close(ctx context.Context) error { return nil }
+
+func TestSignTransaction_OkAsync(t *testing.T) {
+	s := NewServer("", &SignatureDeviceDomainStub{}, WithJobQueue(&JobQueueStub{
+		SubmitFunc: func(tenant, deviceId, data string, options domain.SignOptions, callbackURL, callbackSecret string) (jobs.Job, error) {
+			assertEqual(t, "test", data)
+			assertEqual(t, "https://example.com/callback", callbackURL)
+			assertEqual(t, "secret", callbackSecret)
+			return jobs.Job{
+				Id:             "job",
+				DeviceId:       deviceId,
+				CallbackURL:    callbackURL,
+				CallbackSecret: callbackSecret,
+				Status:         jobs.StatusQueued,
+				CreatedAt:      time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+			}, nil
+		},
+	}))
+	req := httptest.NewRequest(
+		"POST",
+		"/api/v0/devices/550e8400-e29b-11d4-a716-446655440000:sign?async=true",
+		bytes.NewReader([]byte(`{
+			"data_to_be_signed": "test",
+			"callback_url": "https://example.com/callback",
+			"callback_secret": "secret"
+		}`),
+		))
+	req = mux.SetURLVars(req, map[string]string{"id": "550e8400-e29b-11d4-a716-446655440000"})
+	w := httptest.NewRecorder()
+	s.SignTransaction(w, req)
+
+	resp := w.Result()
+	body, _ := io.ReadAll(resp.Body)
+
+	assertEqual(t, http.StatusAccepted, resp.StatusCode)
+	assertEqual(t, "/api/v0/jobs/job", resp.Header.Get("Location"))
+	assertJSONEqual(t, []byte(`{
+	  "data": {
+		"id": "job",
+		"device_id": "550e8400-e29b-11d4-a716-446655440000",
+		"status": "queued",
+		"created_at": "2024-01-02T03:04:05Z",
+		"callback_secret": "secret"
+	  }
+	}`), body)
+}
+
+func TestSignTransaction_ErrAsyncDisabled(t *testing.T) {
+	s := NewServer("", &SignatureDeviceDomainStub{})
+	req := httptest.NewRequest(
+		"POST",
+		"/api/v0/devices/550e8400-e29b-11d4-a716-446655440000:sign?async=true",
+		bytes.NewReader([]byte(`{"data_to_be_signed": "test"}`)),
+	)
+	w := httptest.NewRecorder()
+	s.SignTransaction(w, req)
+
+	assertEqual(t, http.StatusBadRequest, w.Result().StatusCode)
+}
+
+func TestSignTransaction_ErrInvalidCallback(t *testing.T) {
+	s := NewServer("", &SignatureDeviceDomainStub{}, WithJobQueue(&JobQueueStub{
+		SubmitFunc: func(tenant, deviceId, data string, options domain.SignOptions, callbackURL, callbackSecret string) (jobs.Job, error) {
+			return jobs.Job{}, jobs.ErrInvalidCallback
+		},
+	}))
+	req := httptest.NewRequest(
+		"POST",
+		"/api/v0/devices/550e8400-e29b-11d4-a716-446655440000:sign?async=true",
+		bytes.NewReader([]byte(`{"data_to_be_signed": "test", "callback_url": "ftp://example.com"}`)),
+	)
+	w := httptest.NewRecorder()
+	s.SignTransaction(w, req)
+
+	resp := w.Result()
+	body, _ := io.ReadAll(resp.Body)
+
+	assertEqual(t, http.StatusBadRequest, resp.StatusCode)
+	assertJSONEqual(t, []byte(`{
+		"type": "about:blank",
+		"title": "Bad Request",
+		"status": 400,
+		"detail": "invalid callback url",
+		"code": "invalid_callback_url"
+	}`), body)
+}
+
+func TestSignTransaction_ErrQueueFull(t *testing.T) {
+	s := NewServer("", &SignatureDeviceDomainStub{}, WithJobQueue(&JobQueueStub{
+		SubmitFunc: func(tenant, deviceId, data string, options domain.SignOptions, callbackURL, callbackSecret string) (jobs.Job, error) {
+			return jobs.Job{}, jobs.ErrQueueFull
+		},
+	}))
+	req := httptest.NewRequest(
+		"POST",
+		"/api/v0/devices/550e8400-e29b-11d4-a716-446655440000:sign?async=true",
+		bytes.NewReader([]byte(`{"data_to_be_signed": "test"}`)),
+	)
+	w := httptest.NewRecorder()
+	s.SignTransaction(w, req)
+
+	resp := w.Result()
+
+	assertEqual(t, http.StatusServiceUnavailable, resp.StatusCode)
+	assertEqual(t, "1", resp.Header.Get("Retry-After"))
+}
+
+func TestReadJob_Ok(t *testing.T) {
+	s := NewServer("", &SignatureDeviceDomainStub{}, WithJobQueue(&JobQueueStub{
+		FindFunc: func(tenant, id string) (jobs.Job, error) {
+			assertEqual(t, "job", id)
+			return jobs.Job{
+				Id:       "job",
+				DeviceId: "550e8400-e29b-11d4-a716-446655440000",
+				Status:   jobs.StatusSucceeded,
+				Signature: &domain.Signature{
+					Signature:  "c2lnbmF0dXJl",
+					SignedData: "0_test_NTUwZTg0MDAtZTI5Yi0xMWQ0LWE3MTYtNDQ2NjU1NDQwMDAw_2024-01-02T03:04:05Z",
+					SignedAt:   time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+				},
+				CreatedAt:   time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+				CompletedAt: time.Date(2024, 1, 2, 3, 4, 6, 0, time.UTC),
+			}, nil
+		},
+	}))
+	req := httptest.NewRequest("GET", "/api/v0/jobs/job", nil)
+	req = mux.SetURLVars(req, map[string]string{"jobId": "job"})
+	w := httptest.NewRecorder()
+	s.ReadJob(w, req)
+
+	resp := w.Result()
+	body, _ := io.ReadAll(resp.Body)
+
+	assertEqual(t, http.StatusOK, resp.StatusCode)
+	assertJSONEqual(t, []byte(`{
+	  "data": {
+		"id": "job",
+		"device_id": "550e8400-e29b-11d4-a716-446655440000",
+		"status": "succeeded",
+		"result": {
+		  "signature": "c2lnbmF0dXJl",
+		  "signed_data": "0_test_NTUwZTg0MDAtZTI5Yi0xMWQ0LWE3MTYtNDQ2NjU1NDQwMDAw_2024-01-02T03:04:05Z",
+		  "signed_at": "2024-01-02T03:04:05Z"
+		},
+		"created_at": "2024-01-02T03:04:05Z",
+		"completed_at": "2024-01-02T03:04:06Z"
+	  }
+	}`), body)
+}
+
+func TestReadJob_ErrNotFound(t *testing.T) {
+	s := NewServer("", &SignatureDeviceDomainStub{}, WithJobQueue(&JobQueueStub{
+		FindFunc: func(tenant, id string) (jobs.Job, error) {
+			return jobs.Job{}, jobs.ErrNotFound
+		},
+	}))
+	req := httptest.NewRequest("GET", "/api/v0/jobs/unknown", nil)
+	w := httptest.NewRecorder()
+	s.ReadJob(w, req)
+
+	assertEqual(t, http.StatusNotFound, w.Result().StatusCode)
+}
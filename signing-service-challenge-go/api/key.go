@@ -0,0 +1,190 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/auth"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/tenant"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/transport"
+	"github.com/gorilla/mux"
+)
+
+// authenticates reports whether callers are authenticated by API keys or
+// client certificates.
+func (s *Server) authenticates() bool {
+	return s.keys != nil || s.identities != nil
+}
+
+// authenticate attaches the key of a valid Authorization: Bearer or
+// X-API-Key header to the request, or else the key bound to the identity of
+// the verified client certificate. Rejecting requests without a key is left
+// to scoped, so that every route decides whether it is public.
+func (s *Server) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		if key, ok := s.requestKey(request); ok {
+			request = request.WithContext(auth.WithKey(request.Context(), key))
+		}
+		next.ServeHTTP(response, request)
+	})
+}
+
+func (s *Server) requestKey(request *http.Request) (auth.Key, bool) {
+	token := request.Header.Get("X-API-Key")
+	if authorization := request.Header.Get("Authorization"); strings.HasPrefix(authorization, "Bearer ") {
+		token = strings.TrimPrefix(authorization, "Bearer ")
+	}
+	if token != "" && s.keys != nil {
+		key, err := s.keys.Authenticate(token)
+		return key, err == nil
+	}
+	if identity, ok := transport.ClientIdentity(request.TLS); ok && s.identities != nil {
+		key, err := s.identities.Resolve(identity)
+		return key, err == nil
+	}
+	return auth.Key{}, false
+}
+
+// scoped requires an authenticated key with the given scope if
+// authentication is enabled. Requests of the key are validated against the
+// OpenAPI document before the handler runs.
+func (s *Server) scoped(scope auth.Scope, handler http.HandlerFunc) http.Handler {
+	handler = validated(handler)
+	if !s.authenticates() {
+		return handler
+	}
+	return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		key, ok := auth.KeyFromContext(request.Context())
+		if !ok {
+			response.Header().Set("WWW-Authenticate", `Bearer realm="signing-service"`)
+			writeError(response, http.StatusUnauthorized, auth.ErrUnauthorized)
+			return
+		}
+		if !key.HasScope(scope) {
+			WriteProblem(response, http.StatusForbidden, CodeMissingScope, "missing scope "+string(scope))
+			return
+		}
+		handler(response, request)
+	})
+}
+
+// requestKeyId returns the id of the key that authenticated the request.
+func requestKeyId(request *http.Request) string {
+	key, _ := auth.KeyFromContext(request.Context())
+	return key.Id
+}
+
+type CreateKeyRequest struct {
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes"`
+	// Tenant defaults to the tenant of the caller. Issuing keys for other
+	// tenants requires the platform scope.
+	Tenant string `json:"tenant,omitempty"`
+}
+
+type KeyResponse struct {
+	Id        string     `json:"id"`
+	Tenant    string     `json:"tenant"`
+	Name      string     `json:"name,omitempty"`
+	Scopes    []string   `json:"scopes"`
+	Token     string     `json:"token,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+}
+
+func newKeyResponse(key auth.Key) KeyResponse {
+	scopes := make([]string, 0, len(key.Scopes))
+	for _, scope := range key.Scopes {
+		scopes = append(scopes, string(scope))
+	}
+	keyResponse := KeyResponse{
+		Id:        key.Id,
+		Tenant:    key.Tenant,
+		Name:      key.Name,
+		Scopes:    scopes,
+		CreatedAt: key.CreatedAt,
+	}
+	if !key.RevokedAt.IsZero() {
+		revokedAt := key.RevokedAt
+		keyResponse.RevokedAt = &revokedAt
+	}
+	return keyResponse
+}
+
+// CreateKey issues an API key. The token is only returned here. A caller
+// can only grant the scopes of its own key.
+func (s *Server) CreateKey(response http.ResponseWriter, request *http.Request) {
+	var createRequest CreateKeyRequest
+	if err := json.NewDecoder(request.Body).Decode(&createRequest); err != nil {
+		WriteProblem(response, http.StatusBadRequest, CodeInvalidJSON, "invalid json body")
+		return
+	}
+
+	caller, _ := auth.KeyFromContext(request.Context())
+	keyTenant := caller.Tenant
+	if createRequest.Tenant != "" && createRequest.Tenant != caller.Tenant {
+		if !caller.HasScope(auth.ScopePlatform) {
+			WriteProblem(response, http.StatusForbidden, CodeMissingScope, "missing scope "+string(auth.ScopePlatform))
+			return
+		}
+		if s.tenants == nil {
+			writeError(response, http.StatusNotFound, tenant.ErrNotFound)
+			return
+		}
+		if _, err := s.tenants.Find(createRequest.Tenant); err != nil {
+			writeTenantError(response, err)
+			return
+		}
+		keyTenant = createRequest.Tenant
+	}
+
+	scopes := make([]auth.Scope, 0, len(createRequest.Scopes))
+	for _, scope := range createRequest.Scopes {
+		if auth.Scope(scope).IsValid() && !caller.HasScope(auth.Scope(scope)) {
+			WriteProblem(response, http.StatusForbidden, CodeMissingScope, "cannot grant scope "+scope)
+			return
+		}
+		scopes = append(scopes, auth.Scope(scope))
+	}
+	key, token, err := s.keys.Create(keyTenant, createRequest.Name, scopes)
+	if err != nil {
+		if errors.Is(err, auth.ErrInvalidScope) {
+			writeError(response, http.StatusBadRequest, err)
+			return
+		}
+		WriteInternalError(response)
+		return
+	}
+
+	keyResponse := newKeyResponse(key)
+	keyResponse.Token = token
+	WriteAPIResponse(response, http.StatusCreated, keyResponse)
+}
+
+func (s *Server) ReadKeys(response http.ResponseWriter, request *http.Request) {
+	keys := s.keys.List(requestTenant(request))
+	readResponse := make([]KeyResponse, 0, len(keys))
+	for _, key := range keys {
+		readResponse = append(readResponse, newKeyResponse(key))
+	}
+	WriteAPIResponse(response, http.StatusOK, readResponse)
+}
+
+func (s *Server) RevokeKey(response http.ResponseWriter, request *http.Request) {
+	vars := mux.Vars(request)
+	id := vars["keyId"]
+
+	key, err := s.keys.Revoke(requestTenant(request), id)
+	if err != nil {
+		if errors.Is(err, auth.ErrNotFound) {
+			writeError(response, http.StatusNotFound, err)
+			return
+		}
+		WriteInternalError(response)
+		return
+	}
+	WriteAPIResponse(response, http.StatusOK, newKeyResponse(key))
+}
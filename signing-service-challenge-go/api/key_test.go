@@ -0,0 +1,174 @@
+package api
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/audit"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/auth"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/rbac"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/tenant"
+)
+
+func TestAuthentication_Scopes(t *testing.T) {
+	keyring := auth.NewKeyring()
+	_, reader, _ := keyring.Create(tenant.DefaultId, "reader", []auth.Scope{auth.ScopeDevicesRead})
+	s := NewServer("", &SignatureDeviceDomainStub{
+		ReadSignatureDevicesFunc: func(tenant string, query domain.DeviceQuery) (domain.DevicePage, error) {
+			return domain.DevicePage{}, nil
+		},
+	}, WithAuthentication(keyring))
+	router := s.Router()
+
+	cases := []struct {
+		method string
+		path   string
+		header string
+		token  string
+		status int
+	}{
+		{"GET", "/api/v0/health", "", "", http.StatusOK},
+		{"GET", "/api/v0/devices", "", "", http.StatusUnauthorized},
+		{"GET", "/api/v0/devices", "Authorization", "Bearer invalid.token", http.StatusUnauthorized},
+		{"GET", "/api/v0/devices", "Authorization", "Bearer " + reader, http.StatusOK},
+		{"GET", "/api/v0/devices", "X-API-Key", reader, http.StatusOK},
+		{"POST", "/api/v0/devices", "X-API-Key", reader, http.StatusForbidden},
+		{"GET", "/api/v0/keys", "X-API-Key", reader, http.StatusForbidden},
+	}
+	for _, c := range cases {
+		req := httptest.NewRequest(c.method, c.path, nil)
+		if c.header != "" {
+			req.Header.Set(c.header, c.token)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assertEqual(t, c.status, w.Result().StatusCode)
+	}
+}
+
+func TestKeys_Lifecycle(t *testing.T) {
+	keyring := auth.NewKeyring()
+	_, admin, _ := keyring.Create(tenant.DefaultId, "admin", auth.Scopes)
+	router := NewServer("", &SignatureDeviceDomainStub{}, WithAuthentication(keyring)).Router()
+	do := func(method string, path string, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, bytes.NewReader([]byte(body)))
+		req.Header.Set("Authorization", "Bearer "+admin)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do("POST", "/api/v0/keys", `{"name":"pos","scopes":["sign"]}`)
+	var created struct {
+		Data KeyResponse `json:"data"`
+	}
+	_ = json.Unmarshal(w.Body.Bytes(), &created)
+	assertEqual(t, http.StatusCreated, w.Result().StatusCode)
+	assertEqual(t, "pos", created.Data.Name)
+	key, err := keyring.Authenticate(created.Data.Token)
+	assertEqual(t, nil, err)
+	assertEqual(t, created.Data.Id, key.Id)
+
+	w = do("POST", "/api/v0/keys", `{"name":"pos","scopes":["everything"]}`)
+	assertEqual(t, http.StatusBadRequest, w.Result().StatusCode)
+
+	w = do("GET", "/api/v0/keys", "")
+	var listed struct {
+		Data []KeyResponse `json:"data"`
+	}
+	_ = json.Unmarshal(w.Body.Bytes(), &listed)
+	assertEqual(t, 2, len(listed.Data))
+	assertEqual(t, "", listed.Data[1].Token)
+
+	w = do("POST", "/api/v0/keys/"+created.Data.Id+":revoke", "")
+	assertEqual(t, http.StatusOK, w.Result().StatusCode)
+	_, err = keyring.Authenticate(created.Data.Token)
+	assertEqual(t, auth.ErrUnauthorized, err)
+
+	w = do("POST", "/api/v0/keys/unknown:revoke", "")
+	assertEqual(t, http.StatusNotFound, w.Result().StatusCode)
+}
+
+func TestSignTransaction_RecordsKeyId(t *testing.T) {
+	keyring := auth.NewKeyring()
+	key, token, _ := keyring.Create(tenant.DefaultId, "pos", []auth.Scope{auth.ScopeSign})
+	policy := rbac.NewPolicy()
+	_, _ = policy.Bind(rbac.Binding{Tenant: tenant.DefaultId, Subject: key.Id, Role: "cashier", Resources: []string{"*"}})
+	log, _ := audit.NewLog(audit.NewMemorySink())
+	var keyId string
+	s := NewServer("", &SignatureDeviceDomainStub{
+		ReadSignatureDeviceFunc: func(tenant, id string) (domain.SignatureDevice, error) {
+			return domain.SignatureDevice{Id: id}, nil
+		},
+		SignTransactionFunc: func(tenant, id string, data string, options domain.SignOptions) (domain.Signature, error) {
+			keyId = options.KeyId
+			return domain.Signature{KeyId: options.KeyId}, nil
+		},
+	}, WithAuthentication(keyring), WithPolicy(policy), WithAuditLog(log))
+
+	req := httptest.NewRequest("POST", "/api/v0/devices/550e8400-e29b-11d4-a716-446655440000:sign", bytes.NewReader([]byte(`{"data_to_be_signed":"data"}`)))
+	req.Header.Set("X-API-Key", token)
+	w := httptest.NewRecorder()
+	s.Router().ServeHTTP(w, req)
+
+	var body struct {
+		Data SignTransactionResponse `json:"data"`
+	}
+	_ = json.Unmarshal(w.Body.Bytes(), &body)
+	assertEqual(t, http.StatusOK, w.Result().StatusCode)
+	assertEqual(t, key.Id, keyId)
+	assertEqual(t, key.Id, body.Data.KeyId)
+	entries, _ := log.Entries()
+	assertEqual(t, "key:"+key.Id, entries[0].Actor)
+}
+
+func TestAuthentication_ClientCertificate(t *testing.T) {
+	identities := auth.NewIdentityMap()
+	_, _ = identities.Bind(auth.IdentityBinding{
+		Identity: "spiffe://mesh.example.com/ns/pos/sa/terminal",
+		Tenant:   tenant.DefaultId,
+		Scopes:   []auth.Scope{auth.ScopeSign},
+	})
+	policy := rbac.NewPolicy()
+	_, _ = policy.Bind(rbac.Binding{Tenant: tenant.DefaultId, Subject: "spiffe://mesh.example.com/ns/pos/sa/terminal", Role: "cashier", Resources: []string{"*"}})
+	log, _ := audit.NewLog(audit.NewMemorySink())
+	var keyId string
+	s := NewServer("", &SignatureDeviceDomainStub{
+		ReadSignatureDeviceFunc: func(tenant, id string) (domain.SignatureDevice, error) {
+			return domain.SignatureDevice{Id: id}, nil
+		},
+		SignTransactionFunc: func(tenant, id string, data string, options domain.SignOptions) (domain.Signature, error) {
+			keyId = options.KeyId
+			return domain.Signature{}, nil
+		},
+	}, WithClientIdentities(identities), WithPolicy(policy), WithAuditLog(log))
+	router := s.Router()
+	certificate := func(uri string) *tls.ConnectionState {
+		spiffe, _ := url.Parse(uri)
+		return &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{{URIs: []*url.URL{spiffe}}}}}
+	}
+
+	req := httptest.NewRequest("POST", "/api/v0/devices/550e8400-e29b-11d4-a716-446655440000:sign", bytes.NewReader([]byte(`{"data_to_be_signed":"data"}`)))
+	req.TLS = certificate("spiffe://mesh.example.com/ns/pos/sa/terminal")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assertEqual(t, http.StatusOK, w.Result().StatusCode)
+	assertEqual(t, "spiffe://mesh.example.com/ns/pos/sa/terminal", keyId)
+	entries, _ := log.Entries()
+	assertEqual(t, "cert:spiffe://mesh.example.com/ns/pos/sa/terminal", entries[0].Actor)
+
+	req = httptest.NewRequest("POST", "/api/v0/devices/550e8400-e29b-11d4-a716-446655440000:sign", bytes.NewReader([]byte(`{"data_to_be_signed":"data"}`)))
+	req.TLS = certificate("spiffe://mesh.example.com/ns/other/sa/unknown")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assertEqual(t, http.StatusUnauthorized, w.Result().StatusCode)
+}
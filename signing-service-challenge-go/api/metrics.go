@@ -0,0 +1,49 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/metrics"
+	"github.com/gorilla/mux"
+)
+
+type httpMetrics struct {
+	registry metrics.IRegistry
+	requests *metrics.Counter
+	latency  *metrics.Histogram
+}
+
+func newHTTPMetrics(registry metrics.IRegistry) *httpMetrics {
+	return &httpMetrics{
+		registry: registry,
+		requests: registry.Counter("http_requests_total", "HTTP requests by route, method and status.", "route", "method", "status"),
+		latency:  registry.Histogram("http_request_duration_seconds", "Latency of HTTP requests by route and method.", metrics.DefaultBuckets, "route", "method"),
+	}
+}
+
+// measure records the requests of a route. It runs as a middleware of the
+// Router, so that requests are labelled with the route template instead of
+// their path.
+func (m *httpMetrics) measure(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		start := time.Now()
+		recorder := &responseRecorder{ResponseWriter: response, status: http.StatusOK}
+		next.ServeHTTP(recorder, request)
+
+		route := ""
+		if current := mux.CurrentRoute(request); current != nil {
+			route, _ = current.GetPathTemplate()
+		}
+		m.requests.Inc(route, request.Method, strconv.Itoa(recorder.status))
+		m.latency.Observe(time.Since(start).Seconds(), route, request.Method)
+	})
+}
+
+// ReadMetrics writes the metrics in the Prometheus text format.
+func (s *Server) ReadMetrics(response http.ResponseWriter, _ *http.Request) {
+	response.Header().Set("Content-Type", metrics.ContentType)
+	response.WriteHeader(http.StatusOK)
+	s.metrics.registry.Write(response)
+}
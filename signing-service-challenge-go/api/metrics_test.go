@@ -0,0 +1,48 @@
+package api
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/auth"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/metrics"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/tenant"
+)
+
+func TestReadMetrics_RecordsRoutes(t *testing.T) {
+	s := NewServer("", &SignatureDeviceDomainStub{
+		ReadSignatureDeviceFunc: func(tenant, id string) (domain.SignatureDevice, error) {
+			return domain.SignatureDevice{}, domain.ErrNotFound
+		},
+	}, WithMetrics(metrics.NewRegistry()))
+	router := s.Router()
+
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/api/v0/devices/550e8400-e29b-11d4-a716-446655440000", nil))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+
+	assertEqual(t, http.StatusOK, w.Code)
+	assertEqual(t, metrics.ContentType, w.Header().Get("Content-Type"))
+	assertEqual(t, true, bytes.Contains(w.Body.Bytes(), []byte(`http_requests_total{route="/api/v0/devices/{id}",method="GET",status="404"} 1`+"\n")))
+	assertEqual(t, true, bytes.Contains(w.Body.Bytes(), []byte(`http_request_duration_seconds_count{route="/api/v0/devices/{id}",method="GET"} 1`+"\n")))
+}
+
+func TestReadMetrics_RequiresPlatformScope(t *testing.T) {
+	keyring := auth.NewKeyring()
+	_, reader, _ := keyring.Create(tenant.DefaultId, "reader", []auth.Scope{auth.ScopeDevicesRead})
+	_, platform, _ := keyring.Create(tenant.DefaultId, "platform", []auth.Scope{auth.ScopePlatform})
+	router := NewServer("", &SignatureDeviceDomainStub{}, WithAuthentication(keyring), WithMetrics(metrics.NewRegistry())).Router()
+
+	for token, status := range map[string]int{"": http.StatusUnauthorized, reader: http.StatusForbidden, platform: http.StatusOK} {
+		req := httptest.NewRequest("GET", "/metrics", nil)
+		if token != "" {
+			req.Header.Set("X-API-Key", token)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assertEqual(t, status, w.Code)
+	}
+}
@@ -0,0 +1,221 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// requestIdHeader carries the id that correlates a request with its access
+// log line, audit entry and problem responses.
+const requestIdHeader = "X-Request-ID"
+
+type requestIdKey struct{}
+
+type accessLogKey struct{}
+
+// RequestIdFromContext returns the id of the request a context belongs to.
+func RequestIdFromContext(ctx context.Context) (string, bool) {
+	requestId, ok := ctx.Value(requestIdKey{}).(string)
+	return requestId, ok
+}
+
+// AccessLogEntry is the JSON line written to the access log for every
+// request. TraceId is set for traced requests, DeviceId and Counter for
+// requests on a device and for signatures.
+type AccessLogEntry struct {
+	Time       time.Time `json:"time"`
+	RequestId  string    `json:"request_id"`
+	TraceId    string    `json:"trace_id,omitempty"`
+	Method     string    `json:"method"`
+	Path       string    `json:"path"`
+	Route      string    `json:"route,omitempty"`
+	Status     int       `json:"status"`
+	Bytes      int       `json:"bytes"`
+	DurationMs float64   `json:"duration_ms"`
+	DeviceId   string    `json:"device_id,omitempty"`
+	// Counter is the signature counter of the first signature created by
+	// the request and Signatures the number of signatures.
+	Counter    *int `json:"counter,omitempty"`
+	Signatures int  `json:"signatures,omitempty"`
+	Panic      bool `json:"panic,omitempty"`
+}
+
+// accessLogger writes one JSON line per entry. Lines of concurrent
+// requests are not interleaved.
+type accessLogger struct {
+	mu     sync.Mutex
+	writer io.Writer
+}
+
+func (l *accessLogger) write(entry *AccessLogEntry) {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		log.Printf("access log: encoding %s: %v", entry.RequestId, err)
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, err := l.writer.Write(append(line, '\n')); err != nil {
+		log.Printf("access log: writing %s: %v", entry.RequestId, err)
+	}
+}
+
+// responseRecorder records the status and size of a response.
+type responseRecorder struct {
+	http.ResponseWriter
+	status      int
+	bytes       int
+	wroteHeader bool
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	if !r.wroteHeader {
+		r.status = status
+		r.wroteHeader = true
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *responseRecorder) Write(data []byte) (int, error) {
+	if !r.wroteHeader {
+		r.WriteHeader(http.StatusOK)
+	}
+	n, err := r.ResponseWriter.Write(data)
+	r.bytes += n
+	return n, err
+}
+
+// Flush keeps the signature streams working behind the recorder.
+func (r *responseRecorder) Flush() {
+	if flusher, ok := r.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Handler returns the Router wrapped in the middleware chain: request ids,
+// the access log if enabled and panic recovery.
+func (s *Server) Handler() http.Handler {
+	handler := recoverer(s.Router())
+	if s.accessLog != nil {
+		handler = s.accessLogMiddleware(handler)
+	}
+	return requestIdMiddleware(handler)
+}
+
+// requestIdMiddleware propagates the X-Request-ID of a request or
+// generates one. The id is set on the response before any handler runs.
+func requestIdMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		requestId := request.Header.Get(requestIdHeader)
+		if requestId == "" {
+			requestId = uuid.NewString()
+		}
+		response.Header().Set(requestIdHeader, requestId)
+		ctx := context.WithValue(request.Context(), requestIdKey{}, requestId)
+		next.ServeHTTP(response, request.WithContext(ctx))
+	})
+}
+
+// requestId returns the id of a request. Requests that did not pass the
+// requestIdMiddleware get one here.
+func requestId(response http.ResponseWriter, request *http.Request) string {
+	if requestId, ok := RequestIdFromContext(request.Context()); ok {
+		return requestId
+	}
+	requestId := request.Header.Get(requestIdHeader)
+	if requestId == "" {
+		requestId = uuid.NewString()
+	}
+	response.Header().Set(requestIdHeader, requestId)
+	return requestId
+}
+
+// accessLogMiddleware writes an AccessLogEntry once a request is served.
+// Handlers add to the entry through the request context.
+func (s *Server) accessLogMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		start := time.Now()
+		entry := &AccessLogEntry{
+			Time:   start.UTC(),
+			Method: request.Method,
+			Path:   request.URL.Path,
+		}
+		entry.RequestId, _ = RequestIdFromContext(request.Context())
+		recorder := &responseRecorder{ResponseWriter: response, status: http.StatusOK}
+		ctx := context.WithValue(request.Context(), accessLogKey{}, entry)
+
+		defer func() {
+			entry.Status = recorder.status
+			entry.Bytes = recorder.bytes
+			entry.DurationMs = float64(time.Since(start).Microseconds()) / 1000
+			s.accessLog.write(entry)
+		}()
+		next.ServeHTTP(recorder, request.WithContext(ctx))
+	})
+}
+
+// accessLogEntry returns the entry of a request, or nil if the access log
+// is disabled.
+func accessLogEntry(request *http.Request) *AccessLogEntry {
+	entry, _ := request.Context().Value(accessLogKey{}).(*AccessLogEntry)
+	return entry
+}
+
+// logRoute adds the route template and device id of a request to its
+// access log entry. It runs as a middleware of the Router, where the route
+// is known.
+func logRoute(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		if entry := accessLogEntry(request); entry != nil {
+			if route := mux.CurrentRoute(request); route != nil {
+				entry.Route, _ = route.GetPathTemplate()
+			}
+			entry.DeviceId = mux.Vars(request)["id"]
+		}
+		next.ServeHTTP(response, request)
+	})
+}
+
+// logSignatures adds the counter of the first of the signatures a request
+// created to its access log entry.
+func logSignatures(request *http.Request, counter int, signatures int) {
+	if entry := accessLogEntry(request); entry != nil {
+		entry.Counter = &counter
+		entry.Signatures = signatures
+	}
+}
+
+// recoverer turns a panic of a handler into a 500 response. The stack is
+// logged, the response does not reveal it.
+func recoverer(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		recorder := &responseRecorder{ResponseWriter: response, status: http.StatusOK}
+		defer func() {
+			recovered := recover()
+			if recovered == nil {
+				return
+			}
+			if recovered == http.ErrAbortHandler {
+				panic(recovered)
+			}
+			requestId, _ := RequestIdFromContext(request.Context())
+			log.Printf("panic serving %s %s %s: %v\n%s", requestId, request.Method, request.URL.Path, recovered, debug.Stack())
+			if entry := accessLogEntry(request); entry != nil {
+				entry.Panic = true
+			}
+			if !recorder.wroteHeader {
+				WriteInternalError(recorder)
+			}
+		}()
+		next.ServeHTTP(recorder, request)
+	})
+}
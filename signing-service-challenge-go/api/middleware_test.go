@@ -0,0 +1,84 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain"
+)
+
+func TestRequestIdMiddleware(t *testing.T) {
+	handler := NewServer("", &SignatureDeviceDomainStub{}).Handler()
+
+	req := httptest.NewRequest("GET", "/api/v0/health", nil)
+	req.Header.Set("X-Request-ID", "request")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	assertEqual(t, "request", w.Header().Get("X-Request-ID"))
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/api/v0/health", nil))
+	assertEqual(t, 36, len(w.Header().Get("X-Request-ID")))
+}
+
+func TestAccessLogMiddleware_LogsSignatures(t *testing.T) {
+	var accessLog bytes.Buffer
+	s := NewServer("", &SignatureDeviceDomainStub{
+		SignTransactionFunc: func(tenant, id string, data string, options domain.SignOptions) (domain.Signature, error) {
+			return domain.Signature{Counter: 7}, nil
+		},
+	}, WithAccessLog(&accessLog))
+
+	req := httptest.NewRequest("POST", "/api/v0/devices/550e8400-e29b-11d4-a716-446655440000:sign", bytes.NewReader([]byte(`{"data_to_be_signed":"data"}`)))
+	req.Header.Set("X-Request-ID", "request")
+	s.Handler().ServeHTTP(httptest.NewRecorder(), req)
+
+	var entry AccessLogEntry
+	err := json.Unmarshal(accessLog.Bytes(), &entry)
+	assertEqual(t, nil, err)
+	assertEqual(t, "request", entry.RequestId)
+	assertEqual(t, "POST", entry.Method)
+	assertEqual(t, "/api/v0/devices/{id}:sign", entry.Route)
+	assertEqual(t, http.StatusOK, entry.Status)
+	assertEqual(t, "550e8400-e29b-11d4-a716-446655440000", entry.DeviceId)
+	assertEqual(t, 7, *entry.Counter)
+	assertEqual(t, 1, entry.Signatures)
+}
+
+func TestAccessLogMiddleware_LogsUnknownRoutes(t *testing.T) {
+	var accessLog bytes.Buffer
+	s := NewServer("", &SignatureDeviceDomainStub{}, WithAccessLog(&accessLog))
+
+	s.Handler().ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/unknown", nil))
+
+	var entry AccessLogEntry
+	_ = json.Unmarshal(accessLog.Bytes(), &entry)
+	assertEqual(t, http.StatusNotFound, entry.Status)
+	assertEqual(t, "/unknown", entry.Path)
+	assertEqual(t, (*int)(nil), entry.Counter)
+}
+
+func TestRecoverer_WritesInternalError(t *testing.T) {
+	var accessLog bytes.Buffer
+	s := NewServer("", &SignatureDeviceDomainStub{
+		SignTransactionFunc: func(tenant, id string, data string, options domain.SignOptions) (domain.Signature, error) {
+			panic("boom")
+		},
+	}, WithAccessLog(&accessLog))
+
+	req := httptest.NewRequest("POST", "/api/v0/devices/550e8400-e29b-11d4-a716-446655440000:sign", bytes.NewReader([]byte(`{"data_to_be_signed":"data"}`)))
+	req.Header.Set("X-Request-ID", "request")
+	w := httptest.NewRecorder()
+	s.Handler().ServeHTTP(w, req)
+
+	assertEqual(t, http.StatusInternalServerError, w.Code)
+	assertJSONEqual(t, []byte(`{"type":"about:blank","title":"Internal Server Error","status":500,"code":"internal_error","request_id":"request"}`), w.Body.Bytes())
+
+	var entry AccessLogEntry
+	_ = json.Unmarshal(accessLog.Bytes(), &entry)
+	assertEqual(t, http.StatusInternalServerError, entry.Status)
+	assertEqual(t, true, entry.Panic)
+}
@@ -0,0 +1,376 @@
+package api
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// openAPIDocument describes every route of the Router. Requests are
+// validated against it before they reach the handlers.
+//
+//go:embed openapi.json
+var openAPIDocument []byte
+
+// maxValidatedBodySize limits the JSON bodies read for validation. It fits
+// a full batch of transactions of up to 16 KiB each.
+const maxValidatedBodySize = domain.MaxBatchSize * 16 << 10
+
+var errBodyTooLarge = errors.New("request body too large")
+
+// openAPI is the subset of an OpenAPI 3 document needed for validation.
+type openAPI struct {
+	Paths      map[string]map[string]*operation `json:"paths"`
+	Components struct {
+		Schemas map[string]*schema `json:"schemas"`
+	} `json:"components"`
+}
+
+type operation struct {
+	OperationId string       `json:"operationId"`
+	Parameters  []parameter  `json:"parameters"`
+	RequestBody *requestBody `json:"requestBody"`
+}
+
+type parameter struct {
+	Name     string  `json:"name"`
+	In       string  `json:"in"`
+	Required bool    `json:"required"`
+	Schema   *schema `json:"schema"`
+}
+
+type requestBody struct {
+	Required bool `json:"required"`
+	Content  map[string]struct {
+		Schema *schema `json:"schema"`
+	} `json:"content"`
+}
+
+// schema is the subset of the OpenAPI schema object the validator
+// understands. Unknown keywords only document the API.
+type schema struct {
+	Ref           string             `json:"$ref"`
+	Type          string             `json:"type"`
+	Format        string             `json:"format"`
+	Nullable      bool               `json:"nullable"`
+	Enum          []interface{}      `json:"enum"`
+	Required      []string           `json:"required"`
+	Properties    map[string]*schema `json:"properties"`
+	MaxProperties *int               `json:"maxProperties"`
+	Items         *schema            `json:"items"`
+	MinItems      *int               `json:"minItems"`
+	MaxItems      *int               `json:"maxItems"`
+	MinLength     *int               `json:"minLength"`
+	MaxLength     *int               `json:"maxLength"`
+	Minimum       *float64           `json:"minimum"`
+	Maximum       *float64           `json:"maximum"`
+	Pattern       string             `json:"pattern"`
+}
+
+var spec = mustParseOpenAPI(openAPIDocument)
+
+func mustParseOpenAPI(document []byte) *openAPI {
+	var parsed openAPI
+	if err := json.Unmarshal(document, &parsed); err != nil {
+		panic("api: invalid openapi.json: " + err.Error())
+	}
+	return &parsed
+}
+
+// operation returns the operation of a route template and method, or nil if
+// the route is not documented.
+func (o *openAPI) operation(template, method string) *operation {
+	return o.Paths[template][strings.ToLower(method)]
+}
+
+func (o *openAPI) resolve(s *schema) *schema {
+	for s != nil && s.Ref != "" {
+		s = o.Components.Schemas[strings.TrimPrefix(s.Ref, "#/components/schemas/")]
+	}
+	return s
+}
+
+// ReadOpenAPI returns the OpenAPI document of the API.
+func (s *Server) ReadOpenAPI(response http.ResponseWriter, _ *http.Request) {
+	response.Header().Set("Content-Type", "application/json")
+	response.WriteHeader(http.StatusOK)
+	response.Write(openAPIDocument)
+}
+
+// validated rejects requests whose query parameters or JSON body do not
+// match the OpenAPI document with 400 and one FieldError per invalid field.
+func validated(handler http.HandlerFunc) http.HandlerFunc {
+	return func(response http.ResponseWriter, request *http.Request) {
+		route := mux.CurrentRoute(request)
+		if route == nil {
+			handler(response, request)
+			return
+		}
+		template, _ := route.GetPathTemplate()
+		operation := spec.operation(template, request.Method)
+		if operation == nil {
+			handler(response, request)
+			return
+		}
+
+		fieldErrors := spec.validateQuery(operation, request.URL.Query())
+		bodyErrors, err := spec.validateBody(operation, request)
+		if errors.Is(err, errBodyTooLarge) {
+			WriteProblem(response, http.StatusRequestEntityTooLarge, CodeBodyTooLarge, fmt.Sprintf("the request body exceeds %d bytes", maxValidatedBodySize))
+			return
+		}
+		if err != nil {
+			WriteProblem(response, http.StatusBadRequest, CodeInvalidJSON, "invalid json body")
+			return
+		}
+		fieldErrors = append(fieldErrors, bodyErrors...)
+		if len(fieldErrors) > 0 {
+			WriteProblem(response, http.StatusBadRequest, CodeValidationFailed, "the request does not match the API specification", fieldErrors...)
+			return
+		}
+		handler(response, request)
+	}
+}
+
+func (o *openAPI) validateQuery(operation *operation, values url.Values) []FieldError {
+	fieldErrors := make([]FieldError, 0)
+	for _, parameter := range operation.Parameters {
+		if parameter.In != "query" {
+			continue
+		}
+		raw, present := values[parameter.Name]
+		if !present {
+			if parameter.Required {
+				fieldErrors = append(fieldErrors, FieldError{Parameter: parameter.Name, Detail: "is required"})
+			}
+			continue
+		}
+		parameterSchema := o.resolve(parameter.Schema)
+		var parameterErrors []FieldError
+		if parameterSchema.Type == "array" {
+			items := make([]interface{}, 0, len(raw))
+			for _, value := range raw {
+				items = append(items, queryValue(o.resolve(parameterSchema.Items), value))
+			}
+			parameterErrors = o.validate(parameterSchema, "", items, nil)
+		} else {
+			parameterErrors = o.validate(parameterSchema, "", queryValue(parameterSchema, raw[0]), nil)
+		}
+		for _, fieldError := range parameterErrors {
+			fieldErrors = append(fieldErrors, FieldError{Parameter: parameter.Name, Detail: fieldError.Detail})
+		}
+	}
+	return fieldErrors
+}
+
+// queryValue converts a query parameter to the JSON value its schema
+// expects. Values that cannot be converted stay strings and fail the type
+// check.
+func queryValue(s *schema, value string) interface{} {
+	switch s.Type {
+	case "integer", "number":
+		if _, err := strconv.ParseFloat(value, 64); err == nil {
+			return json.Number(value)
+		}
+	case "boolean":
+		if parsed, err := strconv.ParseBool(value); err == nil {
+			return parsed
+		}
+	}
+	return value
+}
+
+// validateBody validates JSON bodies and restores the body for the handler.
+// Bodies of other media types are left to the handler. Bodies larger than
+// maxValidatedBodySize fail with errBodyTooLarge, they are never passed on
+// truncated.
+func (o *openAPI) validateBody(operation *operation, request *http.Request) ([]FieldError, error) {
+	if operation.RequestBody == nil || request.Body == nil {
+		return nil, nil
+	}
+	mediaType := "application/json"
+	if contentType := request.Header.Get("Content-Type"); contentType != "" {
+		mediaType, _, _ = mime.ParseMediaType(contentType)
+	}
+	content, documented := operation.RequestBody.Content[mediaType]
+	if !documented || (mediaType != "application/json" && !strings.HasSuffix(mediaType, "+json")) {
+		return nil, nil
+	}
+
+	body, err := io.ReadAll(io.LimitReader(request.Body, maxValidatedBodySize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(body) > maxValidatedBodySize {
+		return nil, errBodyTooLarge
+	}
+	request.Body = io.NopCloser(bytes.NewReader(body))
+
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	return o.validate(content.Schema, "", value, nil), nil
+}
+
+// validate appends a FieldError for every part of the value that does not
+// match the schema. The pointer locates the value in the body, it is empty
+// for the body itself.
+func (o *openAPI) validate(s *schema, pointer string, value interface{}, fieldErrors []FieldError) []FieldError {
+	s = o.resolve(s)
+	if s == nil {
+		return fieldErrors
+	}
+	invalid := func(format string, args ...interface{}) []FieldError {
+		return append(fieldErrors, FieldError{Pointer: pointer, Detail: fmt.Sprintf(format, args...)})
+	}
+	if value == nil {
+		if s.Nullable {
+			return fieldErrors
+		}
+		return invalid("must not be null")
+	}
+
+	switch s.Type {
+	case "object":
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return invalid("must be an object")
+		}
+		for _, required := range s.Required {
+			if _, present := object[required]; !present {
+				fieldErrors = append(fieldErrors, FieldError{Pointer: appendPointer(pointer, required), Detail: "is required"})
+			}
+		}
+		if s.MaxProperties != nil && len(object) > *s.MaxProperties {
+			fieldErrors = invalid("must have at most %d members", *s.MaxProperties)
+		}
+		properties := make([]string, 0, len(s.Properties))
+		for property := range s.Properties {
+			properties = append(properties, property)
+		}
+		sort.Strings(properties)
+		for _, property := range properties {
+			if member, present := object[property]; present {
+				fieldErrors = o.validate(s.Properties[property], appendPointer(pointer, property), member, fieldErrors)
+			}
+		}
+	case "array":
+		array, ok := value.([]interface{})
+		if !ok {
+			return invalid("must be an array")
+		}
+		if s.MinItems != nil && len(array) < *s.MinItems {
+			fieldErrors = invalid("must have at least %d items", *s.MinItems)
+		}
+		if s.MaxItems != nil && len(array) > *s.MaxItems {
+			fieldErrors = invalid("must have at most %d items", *s.MaxItems)
+		}
+		for i, item := range array {
+			fieldErrors = o.validate(s.Items, appendPointer(pointer, strconv.Itoa(i)), item, fieldErrors)
+		}
+	case "string":
+		text, ok := value.(string)
+		if !ok {
+			return invalid("must be a string")
+		}
+		if detail := validateString(s, text); detail != "" {
+			fieldErrors = invalid("%s", detail)
+		}
+	case "integer", "number":
+		number, ok := value.(json.Number)
+		if !ok && s.Type == "integer" {
+			return invalid("must be an integer")
+		}
+		if !ok {
+			return invalid("must be a number")
+		}
+		if _, err := number.Int64(); err != nil && s.Type == "integer" {
+			return invalid("must be an integer")
+		}
+		float, _ := number.Float64()
+		if s.Minimum != nil && float < *s.Minimum {
+			fieldErrors = invalid("must be at least %v", *s.Minimum)
+		}
+		if s.Maximum != nil && float > *s.Maximum {
+			fieldErrors = invalid("must be at most %v", *s.Maximum)
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return invalid("must be a boolean")
+		}
+	}
+
+	if len(s.Enum) > 0 && !contains(s.Enum, value) {
+		allowed := make([]string, 0, len(s.Enum))
+		for _, option := range s.Enum {
+			allowed = append(allowed, fmt.Sprint(option))
+		}
+		fieldErrors = invalid("must be one of %s", strings.Join(allowed, ", "))
+	}
+	return fieldErrors
+}
+
+// validateString returns why the text does not match the schema, or an
+// empty string if it does.
+func validateString(s *schema, text string) string {
+	if s.MinLength != nil && len(text) < *s.MinLength {
+		return fmt.Sprintf("must have at least %d characters", *s.MinLength)
+	}
+	if s.MaxLength != nil && len(text) > *s.MaxLength {
+		return fmt.Sprintf("must have at most %d characters", *s.MaxLength)
+	}
+	if s.Pattern != "" {
+		if matched, err := regexp.MatchString(s.Pattern, text); err == nil && !matched {
+			return "must match " + s.Pattern
+		}
+	}
+	var err error
+	switch s.Format {
+	case "uuid":
+		_, err = uuid.Parse(text)
+	case "uri":
+		var parsed *url.URL
+		if parsed, err = url.Parse(text); err == nil && !parsed.IsAbs() {
+			err = fmt.Errorf("relative uri")
+		}
+	case "date-time":
+		_, err = time.Parse(time.RFC3339, text)
+	}
+	if err != nil {
+		return "must be a valid " + s.Format
+	}
+	return ""
+}
+
+// appendPointer appends a reference token to a JSON Pointer (RFC 6901).
+func appendPointer(pointer, token string) string {
+	token = strings.ReplaceAll(token, "~", "~0")
+	return pointer + "/" + strings.ReplaceAll(token, "/", "~1")
+}
+
+func contains(options []interface{}, value interface{}) bool {
+	for _, option := range options {
+		if fmt.Sprint(option) == fmt.Sprint(value) {
+			return true
+		}
+	}
+	return false
+}
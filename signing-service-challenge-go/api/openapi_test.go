@@ -0,0 +1,219 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/audit"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/auth"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/metrics"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/persistence"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/ratelimit"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/stream"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/tenant"
+	"github.com/gorilla/mux"
+)
+
+func TestOpenAPI_DocumentsEveryRoute(t *testing.T) {
+	log, _ := audit.NewLog(audit.NewMemorySink())
+	tenants, _ := tenant.NewRegistry(0)
+	limiter, _ := ratelimit.NewLimiter(domain.NewSystemClock(), nil)
+	s := NewServer("", &SignatureDeviceDomainStub{},
+		WithTimeStampAuthority(&TimeStampAuthorityStub{}),
+		WithJobQueue(&JobQueueStub{}),
+		WithWebhooks(&DispatcherStub{}),
+		WithSignatureStream(stream.NewBroker()),
+		WithAuditLog(log),
+		WithAuthentication(auth.NewKeyring()),
+		WithTenants(tenants),
+		WithRateLimits(limiter),
+		WithMetrics(metrics.NewRegistry()),
+		WithProjections(&ProjectionsStub{}),
+	)
+
+	routed := make(map[string]bool)
+	err := s.Router().(*mux.Router).Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		template, err := route.GetPathTemplate()
+		if err != nil {
+			return err
+		}
+		methods, err := route.GetMethods()
+		if err != nil {
+			methods = []string{"GET"}
+		}
+		for _, method := range methods {
+			routed[method+" "+template] = true
+			if spec.operation(template, method) == nil {
+				t.Errorf("%s %s is not documented", method, template)
+			}
+		}
+		return nil
+	})
+	assertEqual(t, nil, err)
+
+	documented := make([]string, 0)
+	for template, operations := range spec.Paths {
+		for method := range operations {
+			documented = append(documented, strings.ToUpper(method)+" "+template)
+		}
+	}
+	sort.Strings(documented)
+	for _, operation := range documented {
+		if !routed[operation] {
+			t.Errorf("%s is documented but not routed", operation)
+		}
+	}
+}
+
+func TestOpenAPI_ResolvesEveryReference(t *testing.T) {
+	var document interface{}
+	assertEqual(t, nil, json.Unmarshal(openAPIDocument, &document))
+
+	var walk func(value interface{})
+	walk = func(value interface{}) {
+		switch value := value.(type) {
+		case map[string]interface{}:
+			for key, member := range value {
+				if reference, ok := member.(string); ok && key == "$ref" {
+					parts := strings.Split(strings.TrimPrefix(reference, "#/"), "/")
+					var target interface{} = document
+					for _, part := range parts {
+						target = target.(map[string]interface{})[part]
+					}
+					if target == nil {
+						t.Errorf("unresolved reference %s", reference)
+					}
+				}
+				walk(member)
+			}
+		case []interface{}:
+			for _, item := range value {
+				walk(item)
+			}
+		}
+	}
+	walk(document)
+}
+
+func TestReadOpenAPI_Ok(t *testing.T) {
+	router := NewServer("", &SignatureDeviceDomainStub{}, WithAuthentication(auth.NewKeyring())).Router()
+
+	w := serve(router, "GET", "/api/v0/openapi.json", "")
+	var document struct {
+		OpenAPI string `json:"openapi"`
+	}
+	_ = json.Unmarshal(w.Body.Bytes(), &document)
+
+	assertEqual(t, http.StatusOK, w.Result().StatusCode)
+	assertEqual(t, "application/json", w.Result().Header.Get("Content-Type"))
+	assertEqual(t, "3.0.3", document.OpenAPI)
+}
+
+func TestValidation_ReportsEveryField(t *testing.T) {
+	router := NewServer("", &SignatureDeviceDomainStub{}).Router()
+
+	w := serve(router, "POST", "/api/v0/devices", `{"id": "till", "algorithm": "DSA", "aggregation_size": -1, "label": 1}`)
+
+	assertEqual(t, http.StatusBadRequest, w.Result().StatusCode)
+	assertEqual(t, "application/problem+json", w.Result().Header.Get("Content-Type"))
+	assertJSONEqual(t, []byte(`{
+		"type": "about:blank",
+		"title": "Bad Request",
+		"status": 400,
+		"detail": "the request does not match the API specification",
+		"code": "validation_failed",
+		"errors": [
+			{"pointer": "/aggregation_size", "detail": "must be at least 0"},
+			{"pointer": "/algorithm", "detail": "must be one of ECC, RSA"},
+			{"pointer": "/id", "detail": "must be a valid uuid"},
+			{"pointer": "/label", "detail": "must be a string"}
+		]
+	}`), w.Body.Bytes())
+}
+
+func TestValidation_NestedFields(t *testing.T) {
+	router := NewServer("", &SignatureDeviceDomainStub{}).Router()
+
+	w := serve(router, "POST", "/api/v0/devices/"+tillDeviceId+":verify-inclusion", `{
+		"data_to_be_signed": "a",
+		"signature": "s",
+		"inclusion_proof": {"root": "r", "leaf_index": 1.5, "tree_size": 2, "proof": ["p", 1]}
+	}`)
+
+	assertEqual(t, http.StatusBadRequest, w.Result().StatusCode)
+	assertJSONEqual(t, []byte(`{
+		"type": "about:blank",
+		"title": "Bad Request",
+		"status": 400,
+		"detail": "the request does not match the API specification",
+		"code": "validation_failed",
+		"errors": [
+			{"pointer": "/signed_data", "detail": "is required"},
+			{"pointer": "/inclusion_proof/leaf_index", "detail": "must be an integer"},
+			{"pointer": "/inclusion_proof/proof/1", "detail": "must be a string"}
+		]
+	}`), w.Body.Bytes())
+}
+
+func TestValidation_QueryParameters(t *testing.T) {
+	router := NewServer("", &SignatureDeviceDomainStub{}).Router()
+
+	w := serve(router, "GET", "/api/v0/devices?state=gone&tag=store&limit=many", "")
+
+	assertEqual(t, http.StatusBadRequest, w.Result().StatusCode)
+	assertJSONEqual(t, []byte(`{
+		"type": "about:blank",
+		"title": "Bad Request",
+		"status": 400,
+		"detail": "the request does not match the API specification",
+		"code": "validation_failed",
+		"errors": [
+			{"parameter": "state", "detail": "must be one of active, decommissioned"},
+			{"parameter": "tag", "detail": "must match :"},
+			{"parameter": "limit", "detail": "must be an integer"}
+		]
+	}`), w.Body.Bytes())
+}
+
+func TestValidation_PassesValidRequests(t *testing.T) {
+	clock := domain.NewSystemClock()
+	router := NewServer("", domain.NewSignatureDeviceDomain(persistence.NewSignatureDeviceDb(), clock)).Router()
+
+	w := serve(router, "POST", "/api/v0/devices", `{"id": "`+tillDeviceId+`", "algorithm": "ECC", "label": "till"}`)
+	assertEqual(t, http.StatusCreated, w.Result().StatusCode)
+
+	w = serve(router, "GET", "/api/v0/devices?state=active&limit=10&tag=store:12", "")
+	assertEqual(t, http.StatusOK, w.Result().StatusCode)
+
+	w = serve(router, "POST", "/api/v0/devices/"+tillDeviceId+":sign", `{"data_to_be_signed": "a"}`)
+	assertEqual(t, http.StatusOK, w.Result().StatusCode)
+}
+
+func TestValidation_LargeBatch(t *testing.T) {
+	var signed int
+	router := NewServer("", &SignatureDeviceDomainStub{
+		SignTransactionsFunc: func(tenant, id string, data []string, options domain.SignOptions) ([]domain.Signature, error) {
+			signed = len(data)
+			return nil, nil
+		},
+	}).Router()
+	data := make([]string, domain.MaxBatchSize)
+	for i := range data {
+		data[i] = strings.Repeat("a", 1100)
+	}
+	body, _ := json.Marshal(SignTransactionsRequest{DataToBeSigned: data})
+
+	w := serve(router, "POST", "/api/v0/devices/"+tillDeviceId+":sign-batch", string(body))
+	assertEqual(t, http.StatusOK, w.Result().StatusCode)
+	assertEqual(t, domain.MaxBatchSize, signed)
+
+	w = serve(router, "POST", "/api/v0/devices/"+tillDeviceId+":sign-batch", `{"data_to_be_signed": ["`+strings.Repeat("a", maxValidatedBodySize)+`"]}`)
+	assertEqual(t, http.StatusRequestEntityTooLarge, w.Result().StatusCode)
+	var problem Problem
+	_ = json.Unmarshal(w.Body.Bytes(), &problem)
+	assertEqual(t, CodeBodyTooLarge, problem.Code)
+}
@@ -0,0 +1,207 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/auth"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/rbac"
+	"github.com/gorilla/mux"
+)
+
+// authorize checks the policy before an action on a device and writes 403
+// if it is forbidden. Callers with the admin scope, which can change the
+// policy anyway, are exempt.
+func (s *Server) authorize(response http.ResponseWriter, request *http.Request, action rbac.Action, resource rbac.Resource) bool {
+	if !s.authorizes(request, action, resource) {
+		writeError(response, http.StatusForbidden, rbac.ErrForbidden)
+		return false
+	}
+	return true
+}
+
+// authorizeDevice authorizes an action on an existing device. A missing
+// device is authorized by its id alone, so that the handler reports it.
+func (s *Server) authorizeDevice(response http.ResponseWriter, request *http.Request, action rbac.Action, id string) bool {
+	if !s.authorizesDevice(request, action, id) {
+		writeError(response, http.StatusForbidden, rbac.ErrForbidden)
+		return false
+	}
+	return true
+}
+
+func (s *Server) authorizesDevice(request *http.Request, action rbac.Action, id string) bool {
+	if _, restricted := restrictedKey(request); !restricted {
+		return true
+	}
+	resource := rbac.Resource{DeviceId: id}
+	if device, err := s.domain.ReadSignatureDevice(request.Context(), requestTenant(request), id); err == nil {
+		resource.Label = device.Label
+	}
+	return s.authorizes(request, action, resource)
+}
+
+func (s *Server) authorizes(request *http.Request, action rbac.Action, resource rbac.Resource) bool {
+	key, restricted := restrictedKey(request)
+	if !restricted {
+		return true
+	}
+	return s.policy.Authorize(key.Tenant, key.Id, action, resource) == nil
+}
+
+// resources returns the resource patterns the caller may perform an action
+// on, nil if it is not restricted by the policy.
+func (s *Server) resources(request *http.Request, action rbac.Action) []string {
+	key, restricted := restrictedKey(request)
+	if !restricted {
+		return nil
+	}
+	return s.policy.Resources(key.Tenant, key.Id, action)
+}
+
+// restrictedKey returns the key of the caller unless it is anonymous or has
+// the admin scope, which are not restricted by the policy.
+func restrictedKey(request *http.Request) (auth.Key, bool) {
+	key, ok := auth.KeyFromContext(request.Context())
+	if !ok || key.HasScope(auth.ScopeAdmin) {
+		return auth.Key{}, false
+	}
+	return key, true
+}
+
+type CreateRoleRequest struct {
+	Name    string   `json:"name"`
+	Actions []string `json:"actions"`
+}
+
+type RoleResponse struct {
+	Name    string   `json:"name"`
+	Actions []string `json:"actions"`
+	Builtin bool     `json:"builtin"`
+}
+
+func newRoleResponse(role rbac.Role) RoleResponse {
+	actions := make([]string, 0, len(role.Actions))
+	for _, action := range role.Actions {
+		actions = append(actions, string(action))
+	}
+	return RoleResponse{
+		Name:    role.Name,
+		Actions: actions,
+		Builtin: role.Tenant == "",
+	}
+}
+
+type CreateRoleBindingRequest struct {
+	// Subject is the id of an API key or the identity of a client certificate.
+	Subject   string   `json:"subject"`
+	Role      string   `json:"role"`
+	Resources []string `json:"resources"`
+}
+
+type RoleBindingResponse struct {
+	Id        string    `json:"id"`
+	Subject   string    `json:"subject"`
+	Role      string    `json:"role"`
+	Resources []string  `json:"resources"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func newRoleBindingResponse(binding rbac.Binding) RoleBindingResponse {
+	return RoleBindingResponse{
+		Id:        binding.Id,
+		Subject:   binding.Subject,
+		Role:      binding.Role,
+		Resources: binding.Resources,
+		CreatedAt: binding.CreatedAt,
+	}
+}
+
+func (s *Server) CreateRole(response http.ResponseWriter, request *http.Request) {
+	var createRequest CreateRoleRequest
+	if err := json.NewDecoder(request.Body).Decode(&createRequest); err != nil {
+		WriteProblem(response, http.StatusBadRequest, CodeInvalidJSON, "invalid json body")
+		return
+	}
+
+	actions := make([]rbac.Action, 0, len(createRequest.Actions))
+	for _, action := range createRequest.Actions {
+		actions = append(actions, rbac.Action(action))
+	}
+	role, err := s.policy.DefineRole(rbac.Role{
+		Name:    createRequest.Name,
+		Tenant:  requestTenant(request),
+		Actions: actions,
+	})
+	if err != nil {
+		writePolicyError(response, err)
+		return
+	}
+	WriteAPIResponse(response, http.StatusCreated, newRoleResponse(role))
+}
+
+func (s *Server) ReadRoles(response http.ResponseWriter, request *http.Request) {
+	roles := s.policy.Roles(requestTenant(request))
+	readResponse := make([]RoleResponse, 0, len(roles))
+	for _, role := range roles {
+		readResponse = append(readResponse, newRoleResponse(role))
+	}
+	WriteAPIResponse(response, http.StatusOK, readResponse)
+}
+
+func (s *Server) CreateRoleBinding(response http.ResponseWriter, request *http.Request) {
+	var createRequest CreateRoleBindingRequest
+	if err := json.NewDecoder(request.Body).Decode(&createRequest); err != nil {
+		WriteProblem(response, http.StatusBadRequest, CodeInvalidJSON, "invalid json body")
+		return
+	}
+
+	binding, err := s.policy.Bind(rbac.Binding{
+		Tenant:    requestTenant(request),
+		Subject:   createRequest.Subject,
+		Role:      createRequest.Role,
+		Resources: createRequest.Resources,
+	})
+	if err != nil {
+		writePolicyError(response, err)
+		return
+	}
+	WriteAPIResponse(response, http.StatusCreated, newRoleBindingResponse(binding))
+}
+
+func (s *Server) ReadRoleBindings(response http.ResponseWriter, request *http.Request) {
+	bindings := s.policy.Bindings(requestTenant(request))
+	readResponse := make([]RoleBindingResponse, 0, len(bindings))
+	for _, binding := range bindings {
+		readResponse = append(readResponse, newRoleBindingResponse(binding))
+	}
+	WriteAPIResponse(response, http.StatusOK, readResponse)
+}
+
+func (s *Server) DeleteRoleBinding(response http.ResponseWriter, request *http.Request) {
+	vars := mux.Vars(request)
+	if err := s.policy.Unbind(requestTenant(request), vars["bindingId"]); err != nil {
+		writePolicyError(response, err)
+		return
+	}
+	response.WriteHeader(http.StatusNoContent)
+}
+
+func writePolicyError(response http.ResponseWriter, err error) {
+	if errors.Is(err, rbac.ErrNotFound) {
+		writeError(response, http.StatusNotFound, err)
+		return
+	}
+	if errors.Is(err, rbac.ErrExists) {
+		writeError(response, http.StatusConflict, err)
+		return
+	}
+	if errors.Is(err, rbac.ErrInvalidRole) || errors.Is(err, rbac.ErrUnknownRole) ||
+		errors.Is(err, rbac.ErrInvalidBinding) || errors.Is(err, rbac.ErrInvalidResource) {
+		writeError(response, http.StatusBadRequest, err)
+		return
+	}
+	WriteInternalError(response)
+}
@@ -0,0 +1,140 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/auth"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/jobs"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/persistence"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/rbac"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/tenant"
+)
+
+const (
+	tillDeviceId   = "550e8400-e29b-11d4-a716-446655440000"
+	officeDeviceId = "550e8400-e29b-11d4-a716-446655440001"
+)
+
+type policyFixture struct {
+	router  http.Handler
+	keyring auth.IKeyring
+	policy  rbac.IPolicy
+	admin   string
+}
+
+func newPolicyFixture(t *testing.T) policyFixture {
+	t.Helper()
+	keyring := auth.NewKeyring()
+	policy := rbac.NewPolicy()
+	signatureDeviceDomain := domain.NewSignatureDeviceDomain(persistence.NewSignatureDeviceDb(), domain.NewSystemClock())
+	// Jobs are named after the device they sign for.
+	queue := &JobQueueStub{FindFunc: func(tenant, id string) (jobs.Job, error) {
+		return jobs.Job{Id: id, Tenant: tenant, DeviceId: id, Status: jobs.StatusQueued}, nil
+	}}
+	s := NewServer("", signatureDeviceDomain, WithAuthentication(keyring), WithPolicy(policy), WithJobQueue(queue))
+	_, admin, _ := keyring.Create(tenant.DefaultId, "admin", []auth.Scope{auth.ScopeAdmin, auth.ScopeDevicesRead, auth.ScopeDevicesWrite, auth.ScopeSign})
+	f := policyFixture{router: s.Router(), keyring: keyring, policy: policy, admin: admin}
+
+	w := f.do(admin, "POST", "/api/v0/devices", `{"id":"`+tillDeviceId+`","algorithm":"ECC","label":"till-1"}`)
+	assertEqual(t, http.StatusCreated, w.Result().StatusCode)
+	w = f.do(admin, "POST", "/api/v0/devices", `{"id":"`+officeDeviceId+`","algorithm":"ECC","label":"office"}`)
+	assertEqual(t, http.StatusCreated, w.Result().StatusCode)
+	return f
+}
+
+func (f policyFixture) do(token, method, path, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	f.router.ServeHTTP(w, req)
+	return w
+}
+
+func TestPolicy_Cashier(t *testing.T) {
+	f := newPolicyFixture(t)
+	key, token, _ := f.keyring.Create(tenant.DefaultId, "till", []auth.Scope{auth.ScopeDevicesRead, auth.ScopeDevicesWrite, auth.ScopeSign})
+	_, _ = f.policy.Bind(rbac.Binding{Tenant: tenant.DefaultId, Subject: key.Id, Role: "cashier", Resources: []string{"label:till-*"}})
+
+	w := f.do(token, "POST", "/api/v0/devices/"+tillDeviceId+":sign", `{"data_to_be_signed":"test"}`)
+	assertEqual(t, http.StatusOK, w.Result().StatusCode)
+	w = f.do(token, "POST", "/api/v0/devices/"+officeDeviceId+":sign", `{"data_to_be_signed":"test"}`)
+	assertEqual(t, http.StatusForbidden, w.Result().StatusCode)
+	w = f.do(token, "POST", "/api/v0/devices/"+tillDeviceId+":decommission", "")
+	assertEqual(t, http.StatusForbidden, w.Result().StatusCode)
+	w = f.do(token, "POST", "/api/v0/devices", `{"id":"550e8400-e29b-11d4-a716-446655440002","algorithm":"ECC","label":"till-2"}`)
+	assertEqual(t, http.StatusForbidden, w.Result().StatusCode)
+	w = f.do(token, "GET", "/api/v0/jobs/"+tillDeviceId, "")
+	assertEqual(t, http.StatusOK, w.Result().StatusCode)
+	w = f.do(token, "GET", "/api/v0/jobs/"+officeDeviceId, "")
+	assertEqual(t, http.StatusNotFound, w.Result().StatusCode)
+	assertJSONEqual(t, []byte(`{"type":"about:blank","title":"Not Found","status":404,"detail":"not found","code":"job_not_found"}`), w.Body.Bytes())
+
+	w = f.do(token, "GET", "/api/v0/devices", "")
+	var devices struct {
+		Data []CreateSignatureDeviceResponse `json:"data"`
+	}
+	_ = json.Unmarshal(w.Body.Bytes(), &devices)
+	assertEqual(t, 1, len(devices.Data))
+	assertEqual(t, tillDeviceId, devices.Data[0].Id)
+}
+
+func TestPolicy_ListPagesReadableDevices(t *testing.T) {
+	f := newPolicyFixture(t)
+	key, token, _ := f.keyring.Create(tenant.DefaultId, "office", []auth.Scope{auth.ScopeDevicesRead})
+	_, _ = f.policy.Bind(rbac.Binding{Tenant: tenant.DefaultId, Subject: key.Id, Role: "auditor", Resources: []string{"label:office"}})
+
+	w := f.do(token, "GET", "/api/v0/devices?limit=1", "")
+	var devices struct {
+		Data []CreateSignatureDeviceResponse `json:"data"`
+	}
+	_ = json.Unmarshal(w.Body.Bytes(), &devices)
+	assertEqual(t, http.StatusOK, w.Result().StatusCode)
+	assertEqual(t, 1, len(devices.Data))
+	assertEqual(t, officeDeviceId, devices.Data[0].Id)
+}
+
+func TestPolicy_UnboundKeyIsForbidden(t *testing.T) {
+	f := newPolicyFixture(t)
+	_, token, _ := f.keyring.Create(tenant.DefaultId, "pos", []auth.Scope{auth.ScopeDevicesRead, auth.ScopeSign})
+
+	w := f.do(token, "GET", "/api/v0/devices/"+tillDeviceId, "")
+	assertEqual(t, http.StatusForbidden, w.Result().StatusCode)
+	w = f.do(token, "POST", "/api/v0/devices/"+tillDeviceId+":sign", `{"data_to_be_signed":"test"}`)
+	assertEqual(t, http.StatusForbidden, w.Result().StatusCode)
+}
+
+func TestPolicy_Management(t *testing.T) {
+	f := newPolicyFixture(t)
+	key, token, _ := f.keyring.Create(tenant.DefaultId, "supervisor", []auth.Scope{auth.ScopeDevicesWrite})
+
+	w := f.do(f.admin, "POST", "/api/v0/roles", `{"name":"supervisor","actions":["devices:decommission"]}`)
+	assertEqual(t, http.StatusCreated, w.Result().StatusCode)
+	w = f.do(f.admin, "POST", "/api/v0/roles", `{"name":"cashier","actions":["devices:sign"]}`)
+	assertEqual(t, http.StatusConflict, w.Result().StatusCode)
+	w = f.do(f.admin, "POST", "/api/v0/role-bindings", `{"subject":"`+key.Id+`","role":"unknown","resources":["*"]}`)
+	assertEqual(t, http.StatusBadRequest, w.Result().StatusCode)
+
+	w = f.do(f.admin, "POST", "/api/v0/role-bindings", `{"subject":"`+key.Id+`","role":"supervisor","resources":["device:`+officeDeviceId+`"]}`)
+	var binding struct {
+		Data RoleBindingResponse `json:"data"`
+	}
+	_ = json.Unmarshal(w.Body.Bytes(), &binding)
+	assertEqual(t, http.StatusCreated, w.Result().StatusCode)
+
+	w = f.do(token, "POST", "/api/v0/devices/"+tillDeviceId+":decommission", "")
+	assertEqual(t, http.StatusForbidden, w.Result().StatusCode)
+	w = f.do(token, "POST", "/api/v0/devices/"+officeDeviceId+":decommission", "")
+	assertEqual(t, http.StatusOK, w.Result().StatusCode)
+
+	w = f.do(f.admin, "DELETE", "/api/v0/role-bindings/"+binding.Data.Id, "")
+	assertEqual(t, http.StatusNoContent, w.Result().StatusCode)
+	w = f.do(f.admin, "GET", "/api/v0/role-bindings", "")
+	assertJSONEqual(t, []byte(`{"data":[]}`), w.Body.Bytes())
+	w = f.do(token, "GET", "/api/v0/roles", "")
+	assertEqual(t, http.StatusForbidden, w.Result().StatusCode)
+}
@@ -0,0 +1,176 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/auth"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/jobs"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/ratelimit"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/rbac"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/stream"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/tenant"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/webhook"
+)
+
+const problemContentType = "application/problem+json"
+
+// Codes of the problems the API reports itself. Errors of the domain carry
+// their own codes.
+const (
+	CodeInvalidJSON          domain.ErrorCode = "invalid_json"
+	CodeValidationFailed     domain.ErrorCode = "validation_failed"
+	CodeInvalidBody          domain.ErrorCode = "invalid_body"
+	CodeImmutableField       domain.ErrorCode = "immutable_field"
+	CodeMissingScope         domain.ErrorCode = "missing_scope"
+	CodeMissingId            domain.ErrorCode = "missing_id"
+	CodeAsyncDisabled        domain.ErrorCode = "async_disabled"
+	CodeUnsupportedMediaType domain.ErrorCode = "unsupported_media_type"
+	CodeMethodNotAllowed     domain.ErrorCode = "method_not_allowed"
+	CodeRouteNotFound        domain.ErrorCode = "route_not_found"
+	CodeBodyTooLarge         domain.ErrorCode = "body_too_large"
+	CodeInternal             domain.ErrorCode = "internal_error"
+)
+
+// Problem is the RFC 7807 problem details object of every error response.
+// Code is the stable machine-readable identifier clients should match on,
+// Detail is meant for humans and may change.
+type Problem struct {
+	Type      string           `json:"type"`
+	Title     string           `json:"title"`
+	Status    int              `json:"status"`
+	Detail    string           `json:"detail,omitempty"`
+	Code      domain.ErrorCode `json:"code"`
+	RequestId string           `json:"request_id,omitempty"`
+	Errors    []FieldError     `json:"errors,omitempty"`
+}
+
+// FieldError locates an invalid part of a request, either by a JSON Pointer
+// (RFC 6901) into the body or by the name of a query parameter.
+type FieldError struct {
+	Pointer   string `json:"pointer,omitempty"`
+	Parameter string `json:"parameter,omitempty"`
+	Detail    string `json:"detail"`
+}
+
+// errorCodes are the codes of the errors of packages other than the domain.
+var errorCodes = []struct {
+	err  error
+	code domain.ErrorCode
+}{
+	{auth.ErrNotFound, "key_not_found"},
+	{auth.ErrUnauthorized, "invalid_api_key"},
+	{auth.ErrInvalidScope, "invalid_scope"},
+	{tenant.ErrNotFound, "tenant_not_found"},
+	{tenant.ErrInvalidLimit, "invalid_device_limit"},
+	{rbac.ErrNotFound, "role_binding_not_found"},
+	{rbac.ErrForbidden, "forbidden_by_policy"},
+	{rbac.ErrExists, "already_exists"},
+	{rbac.ErrInvalidRole, "invalid_role"},
+	{rbac.ErrUnknownRole, "unknown_role"},
+	{rbac.ErrInvalidBinding, "invalid_role_binding"},
+	{rbac.ErrInvalidResource, "invalid_resource"},
+	{ratelimit.ErrExceedsBurst, "rate_limit_burst_exceeded"},
+	{ratelimit.ErrLimited, "rate_limited"},
+	{ratelimit.ErrInvalidLimit, "invalid_rate_limit"},
+	{ratelimit.ErrInvalidScope, "invalid_rate_limit_scope"},
+	{webhook.ErrNotFound, "webhook_not_found"},
+	{webhook.ErrInvalidURL, "invalid_webhook_url"},
+	{webhook.ErrInvalidEventType, "invalid_event_type"},
+	{jobs.ErrNotFound, "job_not_found"},
+	{jobs.ErrQueueFull, "queue_full"},
+	{jobs.ErrInvalidCallback, "invalid_callback_url"},
+	{jobs.ErrClosed, "queue_closed"},
+	{stream.ErrInvalidEventId, "invalid_last_event_id"},
+}
+
+// statusCodes are the codes of problems without a more specific cause.
+var statusCodes = map[int]domain.ErrorCode{
+	http.StatusBadRequest:            "bad_request",
+	http.StatusUnauthorized:          "unauthorized",
+	http.StatusForbidden:             "forbidden",
+	http.StatusNotFound:              "not_found",
+	http.StatusMethodNotAllowed:      CodeMethodNotAllowed,
+	http.StatusConflict:              "conflict",
+	http.StatusPreconditionFailed:    "precondition_failed",
+	http.StatusRequestEntityTooLarge: CodeBodyTooLarge,
+	http.StatusUnsupportedMediaType:  CodeUnsupportedMediaType,
+	http.StatusTooManyRequests:       "rate_limited",
+	http.StatusInternalServerError:   CodeInternal,
+	http.StatusServiceUnavailable:    "service_unavailable",
+}
+
+// errorCode returns the code of a domain error, of a known error of another
+// package or else of the status.
+func errorCode(status int, err error) domain.ErrorCode {
+	if code := domain.CodeOf(err); code != "" {
+		return code
+	}
+	for _, known := range errorCodes {
+		if errors.Is(err, known.err) {
+			return known.code
+		}
+	}
+	if code, ok := statusCodes[status]; ok {
+		return code
+	}
+	return "error"
+}
+
+// WriteProblem writes a problem with the given status, code and detail as
+// an HTTP response. The request id is taken from the X-Request-ID header
+// of the response if it is set.
+func WriteProblem(w http.ResponseWriter, status int, code domain.ErrorCode, detail string, fieldErrors ...FieldError) {
+	problem := Problem{
+		Type:      "about:blank",
+		Title:     http.StatusText(status),
+		Status:    status,
+		Detail:    detail,
+		Code:      code,
+		RequestId: w.Header().Get(requestIdHeader),
+		Errors:    fieldErrors,
+	}
+
+	bytes, err := json.Marshal(problem)
+	if err != nil {
+		WriteInternalError(w)
+		return
+	}
+
+	w.Header().Set("Content-Type", problemContentType)
+	w.WriteHeader(status)
+	w.Write(bytes)
+}
+
+// writeError writes an error as a problem with the code of the error.
+func writeError(w http.ResponseWriter, status int, err error) {
+	WriteProblem(w, status, errorCode(status, err), err.Error())
+}
+
+// WriteInternalError writes a problem that hides the cause of an internal
+// error.
+func WriteInternalError(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", problemContentType)
+	w.WriteHeader(http.StatusInternalServerError)
+	bytes, _ := json.Marshal(Problem{
+		Type:      "about:blank",
+		Title:     http.StatusText(http.StatusInternalServerError),
+		Status:    http.StatusInternalServerError,
+		Code:      CodeInternal,
+		RequestId: w.Header().Get(requestIdHeader),
+	})
+	w.Write(bytes)
+}
+
+// routeNotFound answers requests to paths without a route.
+func routeNotFound(response http.ResponseWriter, request *http.Request) {
+	WriteProblem(response, http.StatusNotFound, CodeRouteNotFound, "no route for "+request.URL.Path)
+}
+
+// methodNotAllowed answers requests to a route with a method it does not
+// accept.
+func methodNotAllowed(response http.ResponseWriter, request *http.Request) {
+	WriteProblem(response, http.StatusMethodNotAllowed, CodeMethodNotAllowed, request.Method+" is not allowed on "+request.URL.Path)
+}
@@ -0,0 +1,95 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/audit"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/tenant"
+)
+
+func TestWriteProblem_RequestId(t *testing.T) {
+	log, _ := audit.NewLog(audit.NewMemorySink())
+	s := NewServer("", &SignatureDeviceDomainStub{
+		DecommissionFunc: func(tenant, id string, expectedVersion int) (domain.SignatureDevice, error) {
+			return domain.SignatureDevice{}, domain.ErrNotFound
+		},
+	}, WithAuditLog(log))
+
+	req := httptest.NewRequest("POST", "/api/v0/devices/550e8400-e29b-11d4-a716-446655440000:decommission", nil)
+	req.Header.Set("X-Request-ID", "request")
+	w := httptest.NewRecorder()
+	s.Router().ServeHTTP(w, req)
+
+	assertEqual(t, http.StatusNotFound, w.Result().StatusCode)
+	assertEqual(t, "application/problem+json", w.Result().Header.Get("Content-Type"))
+	assertJSONEqual(t, []byte(`{
+		"type": "about:blank",
+		"title": "Not Found",
+		"status": 404,
+		"detail": "not found",
+		"code": "device_not_found",
+		"request_id": "request"
+	}`), w.Body.Bytes())
+}
+
+func TestRouter_UnmatchedRequestsAreProblems(t *testing.T) {
+	router := NewServer("", &SignatureDeviceDomainStub{}).Router()
+
+	w := serve(router, "GET", "/api/v0/unknown", "")
+	assertEqual(t, "application/problem+json", w.Result().Header.Get("Content-Type"))
+	assertJSONEqual(t, []byte(`{
+		"type": "about:blank",
+		"title": "Not Found",
+		"status": 404,
+		"detail": "no route for /api/v0/unknown",
+		"code": "route_not_found"
+	}`), w.Body.Bytes())
+
+	w = serve(router, "DELETE", "/api/v0/devices", "")
+	assertEqual(t, "application/problem+json", w.Result().Header.Get("Content-Type"))
+	assertJSONEqual(t, []byte(`{
+		"type": "about:blank",
+		"title": "Method Not Allowed",
+		"status": 405,
+		"detail": "DELETE is not allowed on /api/v0/devices",
+		"code": "method_not_allowed"
+	}`), w.Body.Bytes())
+}
+
+func TestErrorCode(t *testing.T) {
+	cases := []struct {
+		status   int
+		err      error
+		expected domain.ErrorCode
+	}{
+		{http.StatusBadRequest, fmt.Errorf("%w: tag", domain.ErrInvalidQuery), domain.CodeInvalidQuery},
+		{http.StatusNotFound, domain.ErrNotFound, domain.CodeNotFound},
+		{http.StatusNotFound, tenant.ErrNotFound, "tenant_not_found"},
+		{http.StatusServiceUnavailable, errors.New("unavailable"), "service_unavailable"},
+		{http.StatusTeapot, errors.New("teapot"), "error"},
+	}
+	for _, c := range cases {
+		assertEqual(t, c.expected, errorCode(c.status, c.err))
+	}
+}
+
+func TestUpdateSignatureDevice_ImmutableFieldPointer(t *testing.T) {
+	router := NewServer("", &SignatureDeviceDomainStub{}).Router()
+
+	w := serve(router, "PATCH", "/api/v0/devices/"+tillDeviceId, `{"algorithm": "RSA"}`)
+
+	assertEqual(t, http.StatusBadRequest, w.Result().StatusCode)
+	assertJSONEqual(t, []byte(`{
+		"type": "about:blank",
+		"title": "Bad Request",
+		"status": 400,
+		"detail": "algorithm cannot be changed",
+		"code": "immutable_field",
+		"errors": [{"pointer": "/algorithm", "detail": "cannot be changed"}]
+	}`), w.Body.Bytes())
+}
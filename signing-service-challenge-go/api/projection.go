@@ -0,0 +1,22 @@
+package api
+
+import (
+	"log"
+	"net/http"
+)
+
+// IProjections rebuilds the read models of the devices from the event log.
+type IProjections interface {
+	Rebuild() error
+}
+
+// RebuildProjections discards the projections of all tenants and replays
+// the event log, e.g. after the projection code has changed.
+func (s *Server) RebuildProjections(response http.ResponseWriter, request *http.Request) {
+	if err := s.projections.Rebuild(); err != nil {
+		log.Printf("rebuilding projections: %v", err)
+		WriteInternalError(response)
+		return
+	}
+	response.WriteHeader(http.StatusNoContent)
+}
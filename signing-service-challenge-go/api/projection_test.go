@@ -0,0 +1,44 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type ProjectionsStub struct {
+	RebuildFunc func() error
+}
+
+func (p *ProjectionsStub) Rebuild() error {
+	return p.RebuildFunc()
+}
+
+func TestRebuildProjections_Ok(t *testing.T) {
+	rebuilt := false
+	s := NewServer("", &SignatureDeviceDomainStub{}, WithProjections(&ProjectionsStub{
+		RebuildFunc: func() error {
+			rebuilt = true
+			return nil
+		},
+	}))
+
+	w := serve(s.Router(), "POST", "/api/v0/projections:rebuild", "")
+
+	assertEqual(t, http.StatusNoContent, w.Code)
+	assertEqual(t, true, rebuilt)
+}
+
+func TestRebuildProjections_Error(t *testing.T) {
+	s := NewServer("", &SignatureDeviceDomainStub{}, WithProjections(&ProjectionsStub{
+		RebuildFunc: func() error {
+			return errors.New("event log out of order")
+		},
+	}))
+	w := httptest.NewRecorder()
+
+	s.RebuildProjections(w, httptest.NewRequest("POST", "/api/v0/projections:rebuild", nil))
+
+	assertEqual(t, http.StatusInternalServerError, w.Code)
+}
@@ -0,0 +1,121 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/auth"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/ratelimit"
+)
+
+// limit takes a token per signature from the global bucket and the buckets
+// of the device and the calling key before a device signs, and writes 429
+// if one of them holds too few. The limit is checked before the domain, so
+// a limited request never moves the signature counter.
+func (s *Server) limit(response http.ResponseWriter, request *http.Request, deviceId string, signatures int) bool {
+	if s.limiter == nil {
+		return true
+	}
+	tenant := requestTenant(request)
+	keys := []ratelimit.Key{
+		ratelimit.Global,
+		{Scope: ratelimit.ScopeDevice, Tenant: tenant, Id: deviceId},
+	}
+	if key, ok := auth.KeyFromContext(request.Context()); ok {
+		keys = append(keys, ratelimit.Key{Scope: ratelimit.ScopeKey, Tenant: tenant, Id: key.Id})
+	}
+
+	wait, err := s.limiter.Take(signatures, keys...)
+	if err != nil {
+		if wait > 0 {
+			response.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		}
+		writeError(response, http.StatusTooManyRequests, err)
+		return false
+	}
+	return true
+}
+
+type SetRateLimitRequest struct {
+	Scope string  `json:"scope"`
+	Id    string  `json:"id,omitempty"`
+	Rate  float64 `json:"rate"`
+	Burst int     `json:"burst"`
+}
+
+type RateLimitResponse struct {
+	Scope  string  `json:"scope"`
+	Id     string  `json:"id,omitempty"`
+	Rate   float64 `json:"rate"`
+	Burst  int     `json:"burst"`
+	Tokens float64 `json:"tokens"`
+	Custom bool    `json:"custom"`
+}
+
+func newRateLimitResponse(state ratelimit.State) RateLimitResponse {
+	return RateLimitResponse{
+		Scope:  string(state.Key.Scope),
+		Id:     state.Key.Id,
+		Rate:   state.Limit.Rate,
+		Burst:  state.Limit.Burst,
+		Tokens: state.Tokens,
+		Custom: state.Custom,
+	}
+}
+
+// ReadRateLimits returns the global bucket and the buckets of the devices
+// and keys of the caller's tenant. A zero rate is unlimited.
+func (s *Server) ReadRateLimits(response http.ResponseWriter, request *http.Request) {
+	states := s.limiter.States(requestTenant(request))
+	readResponse := make([]RateLimitResponse, 0, len(states))
+	for _, state := range states {
+		readResponse = append(readResponse, newRateLimitResponse(state))
+	}
+	WriteAPIResponse(response, http.StatusOK, readResponse)
+}
+
+// SetRateLimit overrides the limit of a device or key of the caller's
+// tenant. The global limit spans all tenants and requires the platform scope.
+func (s *Server) SetRateLimit(response http.ResponseWriter, request *http.Request) {
+	var setRequest SetRateLimitRequest
+	if err := json.NewDecoder(request.Body).Decode(&setRequest); err != nil {
+		WriteProblem(response, http.StatusBadRequest, CodeInvalidJSON, "invalid json body")
+		return
+	}
+
+	key := ratelimit.Key{
+		Scope:  ratelimit.Scope(setRequest.Scope),
+		Tenant: requestTenant(request),
+		Id:     setRequest.Id,
+	}
+	if key.Scope == ratelimit.ScopeGlobal {
+		if caller, ok := auth.KeyFromContext(request.Context()); ok && !caller.HasScope(auth.ScopePlatform) {
+			WriteProblem(response, http.StatusForbidden, CodeMissingScope, "missing scope "+string(auth.ScopePlatform))
+			return
+		}
+		key = ratelimit.Global
+	} else if key.Id == "" {
+		WriteProblem(response, http.StatusBadRequest, CodeMissingId, "missing id")
+		return
+	}
+
+	limit := ratelimit.Limit{Rate: setRequest.Rate, Burst: setRequest.Burst}
+	if err := s.limiter.SetLimit(key, limit); err != nil {
+		if errors.Is(err, ratelimit.ErrInvalidLimit) || errors.Is(err, ratelimit.ErrInvalidScope) {
+			writeError(response, http.StatusBadRequest, err)
+			return
+		}
+		WriteInternalError(response)
+		return
+	}
+	for _, state := range s.limiter.States(key.Tenant) {
+		if state.Key == key {
+			WriteAPIResponse(response, http.StatusOK, newRateLimitResponse(state))
+			return
+		}
+	}
+	WriteInternalError(response)
+}
@@ -0,0 +1,121 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/persistence"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/ratelimit"
+)
+
+func newRateLimitedRouter(t *testing.T, defaults map[ratelimit.Scope]ratelimit.Limit) http.Handler {
+	t.Helper()
+	clock := domain.NewSystemClock()
+	limiter, err := ratelimit.NewLimiter(clock, defaults)
+	assertEqual(t, nil, err)
+	signatureDeviceDomain := domain.NewSignatureDeviceDomain(persistence.NewSignatureDeviceDb(), clock)
+	router := NewServer("", signatureDeviceDomain, WithRateLimits(limiter)).Router()
+
+	for _, id := range []string{tillDeviceId, officeDeviceId} {
+		w := serve(router, "POST", "/api/v0/devices", `{"id":"`+id+`","algorithm":"ECC"}`)
+		assertEqual(t, http.StatusCreated, w.Result().StatusCode)
+	}
+	return router
+}
+
+func serve(router http.Handler, method, path, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestRateLimit_Device(t *testing.T) {
+	router := newRateLimitedRouter(t, map[ratelimit.Scope]ratelimit.Limit{
+		ratelimit.ScopeDevice: {Rate: 0.001, Burst: 1},
+	})
+
+	w := serve(router, "POST", "/api/v0/devices/"+tillDeviceId+":sign", `{"data_to_be_signed":"test"}`)
+	assertEqual(t, http.StatusOK, w.Result().StatusCode)
+	w = serve(router, "POST", "/api/v0/devices/"+tillDeviceId+":sign", `{"data_to_be_signed":"test"}`)
+	assertEqual(t, http.StatusTooManyRequests, w.Result().StatusCode)
+	assertEqual(t, "1000", w.Result().Header.Get("Retry-After"))
+	w = serve(router, "POST", "/api/v0/devices/"+tillDeviceId+":sign-batch", `{"data_to_be_signed":["a","b"]}`)
+	assertEqual(t, http.StatusTooManyRequests, w.Result().StatusCode)
+	w = serve(router, "POST", "/api/v0/devices/"+officeDeviceId+":sign", `{"data_to_be_signed":"test"}`)
+	assertEqual(t, http.StatusOK, w.Result().StatusCode)
+
+	w = serve(router, "GET", "/api/v0/devices/"+tillDeviceId, "")
+	var device struct {
+		Data CreateSignatureDeviceResponse `json:"data"`
+	}
+	_ = json.Unmarshal(w.Body.Bytes(), &device)
+	assertEqual(t, 1, device.Data.SignatureCounter)
+}
+
+func TestRateLimit_BatchTakesTokenPerItem(t *testing.T) {
+	router := newRateLimitedRouter(t, map[ratelimit.Scope]ratelimit.Limit{
+		ratelimit.ScopeDevice: {Rate: 0.001, Burst: 5},
+	})
+
+	w := serve(router, "POST", "/api/v0/devices/"+tillDeviceId+":sign-batch", `{"data_to_be_signed":["a","b"]}`)
+	assertEqual(t, http.StatusOK, w.Result().StatusCode)
+	w = serve(router, "POST", "/api/v0/devices/"+tillDeviceId+":sign-batch", `{"data_to_be_signed":["a","b","c","d"]}`)
+	assertEqual(t, http.StatusTooManyRequests, w.Result().StatusCode)
+	assertEqual(t, "1000", w.Result().Header.Get("Retry-After"))
+	w = serve(router, "POST", "/api/v0/devices/"+tillDeviceId+":sign-batch", `{"data_to_be_signed":["a","b","c","d","e","f"]}`)
+	assertEqual(t, http.StatusTooManyRequests, w.Result().StatusCode)
+	assertEqual(t, "", w.Result().Header.Get("Retry-After"))
+	var problem Problem
+	_ = json.Unmarshal(w.Body.Bytes(), &problem)
+	assertEqual(t, domain.ErrorCode("rate_limit_burst_exceeded"), problem.Code)
+
+	w = serve(router, "GET", "/api/v0/devices/"+tillDeviceId, "")
+	var device struct {
+		Data CreateSignatureDeviceResponse `json:"data"`
+	}
+	_ = json.Unmarshal(w.Body.Bytes(), &device)
+	assertEqual(t, 2, device.Data.SignatureCounter)
+}
+
+func TestRateLimit_Global(t *testing.T) {
+	router := newRateLimitedRouter(t, map[ratelimit.Scope]ratelimit.Limit{
+		ratelimit.ScopeGlobal: {Rate: 0.001, Burst: 1},
+	})
+
+	w := serve(router, "POST", "/api/v0/devices/"+tillDeviceId+":sign", `{"data_to_be_signed":"test"}`)
+	assertEqual(t, http.StatusOK, w.Result().StatusCode)
+	w = serve(router, "POST", "/api/v0/devices/"+officeDeviceId+":sign", `{"data_to_be_signed":"test"}`)
+	assertEqual(t, http.StatusTooManyRequests, w.Result().StatusCode)
+}
+
+func TestRateLimit_Management(t *testing.T) {
+	router := newRateLimitedRouter(t, nil)
+
+	w := serve(router, "POST", "/api/v0/rate-limits", `{"scope":"device","id":"`+tillDeviceId+`","rate":0.001,"burst":1}`)
+	assertEqual(t, http.StatusOK, w.Result().StatusCode)
+	w = serve(router, "POST", "/api/v0/rate-limits", `{"scope":"device","id":"`+tillDeviceId+`","rate":1,"burst":0}`)
+	assertEqual(t, http.StatusBadRequest, w.Result().StatusCode)
+	w = serve(router, "POST", "/api/v0/rate-limits", `{"scope":"device","rate":1,"burst":1}`)
+	assertEqual(t, http.StatusBadRequest, w.Result().StatusCode)
+
+	w = serve(router, "POST", "/api/v0/devices/"+tillDeviceId+":sign", `{"data_to_be_signed":"test"}`)
+	assertEqual(t, http.StatusOK, w.Result().StatusCode)
+	w = serve(router, "POST", "/api/v0/devices/"+tillDeviceId+":sign", `{"data_to_be_signed":"test"}`)
+	assertEqual(t, http.StatusTooManyRequests, w.Result().StatusCode)
+
+	w = serve(router, "GET", "/api/v0/rate-limits", "")
+	var limits struct {
+		Data []RateLimitResponse `json:"data"`
+	}
+	_ = json.Unmarshal(w.Body.Bytes(), &limits)
+	assertEqual(t, 2, len(limits.Data))
+	assertEqual(t, "global", limits.Data[0].Scope)
+	assertEqual(t, tillDeviceId, limits.Data[1].Id)
+	assertEqual(t, true, limits.Data[1].Custom)
+	assertEqual(t, true, limits.Data[1].Tokens < 1)
+}
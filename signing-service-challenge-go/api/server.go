@@ -1,10 +1,27 @@
 package api
 
 import (
+	"context"
+	"crypto/tls"
 	"encoding/json"
+	"errors"
+	"io"
+	"net"
 	"net/http"
+	"sync"
+	"time"
 
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/audit"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/auth"
 	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/jobs"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/metrics"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/ratelimit"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/rbac"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/stream"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/tenant"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/tracing"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/webhook"
 	"github.com/gorilla/mux"
 )
 
@@ -13,59 +30,353 @@ type Response struct {
 	Data interface{} `json:"data"`
 }
 
-// ErrorResponse is the generic error API response container.
-type ErrorResponse struct {
-	Errors []string `json:"errors"`
+// PageResponse is the API response container of a page of a list.
+type PageResponse struct {
+	Data       interface{} `json:"data"`
+	NextCursor string      `json:"next_cursor,omitempty"`
 }
 
 // Server manages HTTP requests and dispatches them to the appropriate services.
 type Server struct {
 	listenAddress string
 	domain        domain.ISignatureDeviceDomain
+	tsa           domain.ITimeStampAuthority
+	jobs          jobs.IJobQueue
+	webhooks      webhook.IDispatcher
+	signatures    stream.IBroker
+	audit         audit.IAuditLog
+	keys          auth.IKeyring
+	identities    auth.IIdentityMap
+	tenants       tenant.IRegistry
+	policy        rbac.IPolicy
+	limiter       ratelimit.ILimiter
+	projections   IProjections
+	accessLog     *accessLogger
+	metrics       *httpMetrics
+	tracer        tracing.ITracer
+	tls           *tls.Config
+	checks        map[string]Check
+	shutdownDelay time.Duration
+
+	mu         sync.Mutex
+	httpServer *http.Server
+	// draining is closed once Shutdown is called.
+	draining chan struct{}
+	drain    sync.Once
+}
+
+// Option configures optional services of a Server.
+type Option func(s *Server)
+
+// WithTimeStampAuthority enables the RFC 3161 time-stamp endpoints.
+func WithTimeStampAuthority(tsa domain.ITimeStampAuthority) Option {
+	return func(s *Server) {
+		s.tsa = tsa
+	}
+}
+
+// WithJobQueue enables asynchronous signing with ?async=true and the job endpoints.
+func WithJobQueue(queue jobs.IJobQueue) Option {
+	return func(s *Server) {
+		s.jobs = queue
+	}
+}
+
+// WithWebhooks enables the webhook subscription and dead-letter endpoints.
+func WithWebhooks(dispatcher webhook.IDispatcher) Option {
+	return func(s *Server) {
+		s.webhooks = dispatcher
+	}
+}
+
+// WithSignatureStream enables the Server-Sent Events streams of signatures.
+func WithSignatureStream(broker stream.IBroker) Option {
+	return func(s *Server) {
+		s.signatures = broker
+	}
+}
+
+// WithAuditLog records every mutating call in the audit log and exposes it.
+func WithAuditLog(log audit.IAuditLog) Option {
+	return func(s *Server) {
+		s.audit = log
+	}
+}
+
+// WithAuthentication requires an API key with the matching scope on every
+// route except the health checks and the time-stamp certificate.
+func WithAuthentication(keyring auth.IKeyring) Option {
+	return func(s *Server) {
+		s.keys = keyring
+	}
+}
+
+// WithClientIdentities authenticates callers by the identity of their
+// verified client certificate if they present no API key.
+func WithClientIdentities(identities auth.IIdentityMap) Option {
+	return func(s *Server) {
+		s.identities = identities
+	}
+}
+
+// WithTLS serves HTTPS with the given configuration instead of plain HTTP.
+func WithTLS(config *tls.Config) Option {
+	return func(s *Server) {
+		s.tls = config
+	}
+}
+
+// WithTenants enables the management of tenants. Keys can then be issued
+// for other tenants than the one of the caller.
+func WithTenants(registry tenant.IRegistry) Option {
+	return func(s *Server) {
+		s.tenants = registry
+	}
+}
+
+// WithPolicy sets the policy that restricts the device operations of
+// authenticated callers without the admin scope to those granted by their
+// role bindings. The Server starts with an empty policy, which the admin
+// API adds roles and bindings to.
+func WithPolicy(policy rbac.IPolicy) Option {
+	return func(s *Server) {
+		s.policy = policy
+	}
+}
+
+// WithProjections enables rebuilding the projections of the event log.
+func WithProjections(projections IProjections) Option {
+	return func(s *Server) {
+		s.projections = projections
+	}
+}
+
+// WithRateLimits limits signing per device, per key and globally.
+func WithRateLimits(limiter ratelimit.ILimiter) Option {
+	return func(s *Server) {
+		s.limiter = limiter
+	}
+}
+
+// WithAccessLog writes a JSON AccessLogEntry per request to the writer.
+func WithAccessLog(writer io.Writer) Option {
+	return func(s *Server) {
+		s.accessLog = &accessLogger{writer: writer}
+	}
+}
+
+// WithMetrics serves the metrics of the registry at /metrics to keys with
+// the platform scope and adds the HTTP metrics of every route to it.
+func WithMetrics(registry metrics.IRegistry) Option {
+	return func(s *Server) {
+		s.metrics = newHTTPMetrics(registry)
+	}
+}
+
+// WithTracer traces every request. The traces of callers sending a
+// traceparent header are continued.
+func WithTracer(tracer tracing.ITracer) Option {
+	return func(s *Server) {
+		s.tracer = tracer
+	}
+}
+
+// WithReadinessCheck adds a check to /readyz. The service is not ready
+// while the check fails.
+func WithReadinessCheck(name string, check Check) Option {
+	return func(s *Server) {
+		if s.checks == nil {
+			s.checks = make(map[string]Check)
+		}
+		s.checks[name] = check
+	}
+}
+
+// WithShutdownDelay keeps accepting requests for the delay after Shutdown
+// is called while /readyz already fails, so that load balancers stop
+// routing to the Server before it closes its listener.
+func WithShutdownDelay(delay time.Duration) Option {
+	return func(s *Server) {
+		s.shutdownDelay = delay
+	}
 }
 
 // NewServer is a factory to instantiate a new Server.
-func NewServer(listenAddress string, domain domain.ISignatureDeviceDomain) *Server {
-	return &Server{
+func NewServer(listenAddress string, domain domain.ISignatureDeviceDomain, options ...Option) *Server {
+	s := &Server{
 		listenAddress: listenAddress,
 		domain:        domain,
+		policy:        rbac.NewPolicy(),
+		draining:      make(chan struct{}),
 	}
+	for _, option := range options {
+		option(s)
+	}
+	return s
 }
 
-// Run registers all HandlerFuncs for the existing HTTP routes and starts the Server.
+// Run starts the Server with all registered routes. It returns nil once
+// the Server is shut down.
 func (s *Server) Run() error {
-	r := mux.NewRouter()
+	listener, err := net.Listen("tcp", s.listenAddress)
+	if err != nil {
+		return err
+	}
+	return s.Serve(listener)
+}
 
-	r.Handle("/api/v0/health", http.HandlerFunc(s.Health))
-	r.Handle("/api/v0/devices", http.HandlerFunc(s.ReadSignatureDevices)).Methods("GET")
-	r.Handle("/api/v0/devices", http.HandlerFunc(s.CreateSignatureDevice)).Methods("POST")
-	r.Handle("/api/v0/devices/{id}", http.HandlerFunc(s.ReadSignatureDevice)).Methods("GET")
-	r.Handle("/api/v0/devices/{id}:sign", http.HandlerFunc(s.SignTransaction)).Methods("POST")
+// Serve serves the registered routes on the listener until the Server is
+// shut down.
+func (s *Server) Serve(listener net.Listener) error {
+	server := &http.Server{
+		Handler:   s.Handler(),
+		TLSConfig: s.tls,
+	}
+	s.mu.Lock()
+	if s.Draining() {
+		s.mu.Unlock()
+		listener.Close()
+		return nil
+	}
+	s.httpServer = server
+	s.mu.Unlock()
 
-	return http.ListenAndServe(s.listenAddress, r)
+	if s.tls == nil {
+		err := server.Serve(listener)
+		if errors.Is(err, http.ErrServerClosed) {
+			return nil
+		}
+		return err
+	}
+	// The certificates are provided by the TLS configuration.
+	err := server.ServeTLS(listener, "", "")
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
 }
 
-// WriteInternalError writes a default internal error message as an HTTP response.
-func WriteInternalError(w http.ResponseWriter) {
-	w.WriteHeader(http.StatusInternalServerError)
-	w.Write([]byte(http.StatusText(http.StatusInternalServerError)))
+// Shutdown fails /readyz, waits for the shutdown delay and then stops
+// accepting connections. It waits for the requests in flight until ctx is
+// done. Signature streams are ended, their clients resume elsewhere with
+// Last-Event-ID.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.drain.Do(func() {
+		close(s.draining)
+	})
+	if s.shutdownDelay > 0 {
+		delay := time.NewTimer(s.shutdownDelay)
+		defer delay.Stop()
+		select {
+		case <-delay.C:
+		case <-ctx.Done():
+		}
+	}
+
+	s.mu.Lock()
+	server := s.httpServer
+	s.mu.Unlock()
+	if server == nil {
+		return nil
+	}
+	return server.Shutdown(ctx)
 }
 
-// WriteErrorResponse takes an HTTP status code and a slice of errors
-// and writes those as an HTTP error response in a structured format.
-func WriteErrorResponse(w http.ResponseWriter, code int, errors []string) {
-	w.WriteHeader(code)
+// Draining reports whether Shutdown has been called.
+func (s *Server) Draining() bool {
+	select {
+	case <-s.draining:
+		return true
+	default:
+		return false
+	}
+}
+
+// Router registers all HandlerFuncs for the existing HTTP routes.
+func (s *Server) Router() http.Handler {
+	r := mux.NewRouter()
+	r.NotFoundHandler = http.HandlerFunc(routeNotFound)
+	r.MethodNotAllowedHandler = http.HandlerFunc(methodNotAllowed)
+	if s.accessLog != nil {
+		r.Use(logRoute)
+	}
+	if s.tracer != nil {
+		r.Use(s.traced)
+	}
+	if s.metrics != nil {
+		r.Use(s.metrics.measure)
+		r.Handle("/metrics", s.scoped(auth.ScopePlatform, s.ReadMetrics)).Methods("GET")
+	}
+	if s.authenticates() {
+		// Authentication runs first, so that the audit log records the caller.
+		r.Use(s.authenticate)
+	}
+	if s.keys != nil {
+		r.Handle("/api/v0/keys", s.scoped(auth.ScopeAdmin, s.ReadKeys)).Methods("GET")
+		r.Handle("/api/v0/keys", s.scoped(auth.ScopeAdmin, s.CreateKey)).Methods("POST")
+		r.Handle("/api/v0/keys/{keyId}:revoke", s.scoped(auth.ScopeAdmin, s.RevokeKey)).Methods("POST")
+	}
+	if s.audit != nil {
+		r.Use(s.auditMiddleware)
+		r.Handle("/api/v0/audit", s.scoped(auth.ScopePlatform, s.ReadAuditLog)).Methods("GET")
+	}
+	if s.tenants != nil {
+		r.Handle("/api/v0/tenants", s.scoped(auth.ScopePlatform, s.ReadTenants)).Methods("GET")
+		r.Handle("/api/v0/tenants", s.scoped(auth.ScopePlatform, s.CreateTenant)).Methods("POST")
+		r.Handle("/api/v0/tenants/{tenantId}", s.scoped(auth.ScopePlatform, s.ReadTenant)).Methods("GET")
+		r.Handle("/api/v0/tenants/{tenantId}:set-limits", s.scoped(auth.ScopePlatform, s.SetTenantLimits)).Methods("POST")
+	}
+	r.Handle("/api/v0/roles", s.scoped(auth.ScopeAdmin, s.ReadRoles)).Methods("GET")
+	r.Handle("/api/v0/roles", s.scoped(auth.ScopeAdmin, s.CreateRole)).Methods("POST")
+	r.Handle("/api/v0/role-bindings", s.scoped(auth.ScopeAdmin, s.ReadRoleBindings)).Methods("GET")
+	r.Handle("/api/v0/role-bindings", s.scoped(auth.ScopeAdmin, s.CreateRoleBinding)).Methods("POST")
+	r.Handle("/api/v0/role-bindings/{bindingId}", s.scoped(auth.ScopeAdmin, s.DeleteRoleBinding)).Methods("DELETE")
+	if s.projections != nil {
+		r.Handle("/api/v0/projections:rebuild", s.scoped(auth.ScopePlatform, s.RebuildProjections)).Methods("POST")
+	}
+	if s.limiter != nil {
+		r.Handle("/api/v0/rate-limits", s.scoped(auth.ScopeAdmin, s.ReadRateLimits)).Methods("GET")
+		r.Handle("/api/v0/rate-limits", s.scoped(auth.ScopeAdmin, s.SetRateLimit)).Methods("POST")
+	}
 
-	errorResponse := ErrorResponse{
-		Errors: errors,
+	r.Handle("/api/v0/health", http.HandlerFunc(s.Health))
+	r.Handle("/livez", http.HandlerFunc(s.Livez)).Methods("GET")
+	r.Handle("/readyz", http.HandlerFunc(s.Readyz)).Methods("GET")
+	r.Handle("/api/v0/openapi.json", http.HandlerFunc(s.ReadOpenAPI)).Methods("GET")
+	r.Handle("/api/v0/devices", s.scoped(auth.ScopeDevicesRead, s.ReadSignatureDevices)).Methods("GET")
+	r.Handle("/api/v0/devices", s.scoped(auth.ScopeDevicesWrite, s.CreateSignatureDevice)).Methods("POST")
+	r.Handle("/api/v0/devices/{id}", s.scoped(auth.ScopeDevicesRead, s.ReadSignatureDevice)).Methods("GET")
+	r.Handle("/api/v0/devices/{id}", s.scoped(auth.ScopeDevicesWrite, s.UpdateSignatureDevice)).Methods("PATCH")
+	r.Handle("/api/v0/devices/{id}:sign", s.scoped(auth.ScopeSign, s.SignTransaction)).Methods("POST")
+	r.Handle("/api/v0/devices/{id}:sign-batch", s.scoped(auth.ScopeSign, s.SignTransactions)).Methods("POST")
+	r.Handle("/api/v0/devices/{id}:verify", s.scoped(auth.ScopeDevicesRead, s.VerifySignature)).Methods("POST")
+	r.Handle("/api/v0/devices/{id}:verify-inclusion", s.scoped(auth.ScopeDevicesRead, s.VerifyInclusion)).Methods("POST")
+	r.Handle("/api/v0/devices/{id}:decommission", s.scoped(auth.ScopeDevicesWrite, s.DecommissionSignatureDevice)).Methods("POST")
+	r.Handle("/api/v0/devices/{id}:rotate-key", s.scoped(auth.ScopeDevicesWrite, s.RotateSignatureDeviceKey)).Methods("POST")
+
+	if s.tsa != nil {
+		r.Handle("/api/v0/tsa", s.scoped(auth.ScopeSign, s.TimeStamp)).Methods("POST")
+		r.Handle("/api/v0/tsa/certificate", http.HandlerFunc(s.ReadTimeStampCertificate)).Methods("GET")
 	}
 
-	bytes, err := json.Marshal(errorResponse)
-	if err != nil {
-		WriteInternalError(w)
+	if s.jobs != nil {
+		r.Handle("/api/v0/jobs/{jobId}", s.scoped(auth.ScopeSign, s.ReadJob)).Methods("GET")
 	}
 
-	w.Write(bytes)
+	if s.signatures != nil {
+		r.Handle("/api/v0/devices/{id}/signatures:stream", s.scoped(auth.ScopeDevicesRead, s.StreamDeviceSignatures)).Methods("GET")
+		r.Handle("/api/v0/signatures:stream", s.scoped(auth.ScopeDevicesRead, s.StreamSignatures)).Methods("GET")
+	}
+
+	if s.webhooks != nil {
+		r.Handle("/api/v0/webhooks", s.scoped(auth.ScopeAdmin, s.ReadWebhooks)).Methods("GET")
+		r.Handle("/api/v0/webhooks", s.scoped(auth.ScopeAdmin, s.CreateWebhook)).Methods("POST")
+		r.Handle("/api/v0/webhooks/dead-letters", s.scoped(auth.ScopeAdmin, s.ReadDeadLetters)).Methods("GET")
+		r.Handle("/api/v0/webhooks/dead-letters/{deliveryId}:replay", s.scoped(auth.ScopeAdmin, s.ReplayDeadLetter)).Methods("POST")
+		r.Handle("/api/v0/webhooks/{webhookId}", s.scoped(auth.ScopeAdmin, s.DeleteWebhook)).Methods("DELETE")
+	}
+
+	return r
 }
 
 // WriteAPIResponse takes an HTTP status code and a generic data struct
@@ -84,3 +395,22 @@ func WriteAPIResponse(w http.ResponseWriter, code int, data interface{}) {
 
 	w.Write(bytes)
 }
+
+// WritePageResponse takes an HTTP status code, a page of a list and the
+// cursor of the next page and writes those as an HTTP response in a
+// structured format.
+func WritePageResponse(w http.ResponseWriter, code int, data interface{}, nextCursor string) {
+	w.WriteHeader(code)
+
+	response := PageResponse{
+		Data:       data,
+		NextCursor: nextCursor,
+	}
+
+	bytes, err := json.MarshalIndent(response, "", "  ")
+	if err != nil {
+		WriteInternalError(w)
+	}
+
+	w.Write(bytes)
+}
@@ -0,0 +1,147 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/rbac"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/stream"
+	"github.com/gorilla/mux"
+)
+
+// streamHeartbeat keeps idle streams from being closed by proxies.
+const streamHeartbeat = 15 * time.Second
+
+type SignatureEventResponse struct {
+	DeviceId   string   `json:"device_id"`
+	Counter    int      `json:"counter"`
+	LeafKeyIds []string `json:"leaf_key_ids,omitempty"`
+	SignTransactionResponse
+}
+
+// StreamDeviceSignatures streams the signatures of a device as Server-Sent
+// Events. The event id is the signature counter. A reset event precedes the
+// replay if the signatures after Last-Event-ID are no longer retained.
+func (s *Server) StreamDeviceSignatures(response http.ResponseWriter, request *http.Request) {
+	vars := mux.Vars(request)
+	id := vars["id"]
+	if !s.authorizeDevice(response, request, rbac.ActionReadJournal, id) {
+		return
+	}
+
+	if _, err := s.domain.ReadSignatureDevice(request.Context(), requestTenant(request), id); err != nil {
+		if errors.Is(err, domain.ErrNotFound) {
+			writeError(response, http.StatusNotFound, err)
+			return
+		}
+		WriteInternalError(response)
+		return
+	}
+	s.streamSignatures(response, request, id, stream.Entry.DeviceEventId)
+}
+
+// StreamSignatures streams the signatures of all devices as Server-Sent
+// Events. The event id is <device id>:<signature counter>.
+func (s *Server) StreamSignatures(response http.ResponseWriter, request *http.Request) {
+	if !s.authorize(response, request, rbac.ActionReadJournal, rbac.AllDevices) {
+		return
+	}
+	s.streamSignatures(response, request, "", stream.Entry.GlobalEventId)
+}
+
+func (s *Server) streamSignatures(response http.ResponseWriter, request *http.Request, deviceId string, eventId func(stream.Entry) string) {
+	flusher, ok := response.(http.Flusher)
+	if !ok {
+		WriteInternalError(response)
+		return
+	}
+
+	// EventSource cannot set headers on the first connection, so the id
+	// may also be passed as a query parameter.
+	lastEventId := request.Header.Get("Last-Event-ID")
+	if lastEventId == "" {
+		lastEventId = request.URL.Query().Get("last_event_id")
+	}
+	replay, subscription, err := s.signatures.Subscribe(requestTenant(request), deviceId, lastEventId)
+	if err != nil {
+		if errors.Is(err, stream.ErrInvalidEventId) {
+			writeError(response, http.StatusBadRequest, err)
+			return
+		}
+		WriteInternalError(response)
+		return
+	}
+	defer subscription.Close()
+
+	response.Header().Set("Content-Type", "text/event-stream")
+	response.Header().Set("Cache-Control", "no-cache")
+	response.Header().Set("Connection", "keep-alive")
+	response.WriteHeader(http.StatusOK)
+
+	if replay.Reset {
+		if err := writeResetEvent(response, lastEventId); err != nil {
+			return
+		}
+	}
+	for _, entry := range replay.Entries {
+		if err := writeSignatureEvent(response, eventId(entry), entry); err != nil {
+			return
+		}
+	}
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-request.Context().Done():
+			return
+		case <-s.draining:
+			return
+		case entry, open := <-subscription.C:
+			if !open {
+				return
+			}
+			if err := writeSignatureEvent(response, eventId(entry), entry); err != nil {
+				return
+			}
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(response, ": heartbeat\n\n"); err != nil {
+				return
+			}
+		}
+		flusher.Flush()
+	}
+}
+
+func writeSignatureEvent(response http.ResponseWriter, id string, entry stream.Entry) error {
+	data, err := json.Marshal(SignatureEventResponse{
+		DeviceId:                entry.DeviceId,
+		Counter:                 entry.Signature.Counter,
+		LeafKeyIds:              entry.Signature.LeafKeyIds,
+		SignTransactionResponse: newSignTransactionResponse(entry.Signature),
+	})
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(response, "id: %s\nevent: signature\ndata: %s\n\n", id, data)
+	return err
+}
+
+// writeResetEvent tells the client that signatures after its last event id
+// may be missing from the replay and have to be read from the API. It has
+// no id, so that the client keeps its last event id until the next event.
+func writeResetEvent(response http.ResponseWriter, lastEventId string) error {
+	data, err := json.Marshal(struct {
+		LastEventId string `json:"last_event_id"`
+	}{LastEventId: lastEventId})
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(response, "event: reset\ndata: %s\n\n", data)
+	return err
+}
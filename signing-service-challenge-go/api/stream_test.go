@@ -0,0 +1,137 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/stream"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/tenant"
+	"github.com/gorilla/mux"
+)
+
+func signatureCreatedEvent(counter int) domain.Event {
+	return domain.Event{
+		Type:   domain.EventSignatureCreated,
+		Device: domain.SignatureDevice{Tenant: tenant.DefaultId, Id: "550e8400-e29b-11d4-a716-446655440000"},
+		Signature: &domain.Signature{
+			Counter:    counter,
+			Signature:  "c2lnbmF0dXJl",
+			SignedData: "signed",
+			SignedAt:   time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		},
+	}
+}
+
+var deviceFound = &SignatureDeviceDomainStub{
+	ReadSignatureDeviceFunc: func(tenant, id string) (domain.SignatureDevice, error) {
+		return domain.SignatureDevice{Id: id}, nil
+	},
+}
+
+func TestStreamDeviceSignatures_OkResume(t *testing.T) {
+	broker := stream.NewBroker()
+	broker.Publish(signatureCreatedEvent(0))
+	broker.Publish(signatureCreatedEvent(1))
+	s := NewServer("", deviceFound, WithSignatureStream(broker))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	req := httptest.NewRequest("GET", "/api/v0/devices/550e8400-e29b-11d4-a716-446655440000/signatures:stream", nil).WithContext(ctx)
+	req = mux.SetURLVars(req, map[string]string{"id": "550e8400-e29b-11d4-a716-446655440000"})
+	req.Header.Set("Last-Event-ID", "0")
+	w := httptest.NewRecorder()
+	s.StreamDeviceSignatures(w, req)
+
+	resp := w.Result()
+
+	assertEqual(t, http.StatusOK, resp.StatusCode)
+	assertEqual(t, "text/event-stream", resp.Header.Get("Content-Type"))
+	assertEqual(t, "id: 1\n"+
+		"event: signature\n"+
+		`data: {"device_id":"550e8400-e29b-11d4-a716-446655440000","counter":1,"signature":"c2lnbmF0dXJl","signed_data":"signed","signed_at":"2024-01-02T03:04:05Z"}`+"\n\n",
+		w.Body.String())
+}
+
+func TestStreamDeviceSignatures_OkReset(t *testing.T) {
+	broker := stream.NewBroker()
+	broker.Publish(signatureCreatedEvent(5))
+	s := NewServer("", deviceFound, WithSignatureStream(broker))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	req := httptest.NewRequest("GET", "/api/v0/devices/550e8400-e29b-11d4-a716-446655440000/signatures:stream", nil).WithContext(ctx)
+	req = mux.SetURLVars(req, map[string]string{"id": "550e8400-e29b-11d4-a716-446655440000"})
+	req.Header.Set("Last-Event-ID", "3")
+	w := httptest.NewRecorder()
+	s.StreamDeviceSignatures(w, req)
+
+	assertEqual(t, http.StatusOK, w.Result().StatusCode)
+	assertEqual(t, "event: reset\n"+
+		`data: {"last_event_id":"3"}`+"\n\n"+
+		"id: 5\n"+
+		"event: signature\n"+
+		`data: {"device_id":"550e8400-e29b-11d4-a716-446655440000","counter":5,"signature":"c2lnbmF0dXJl","signed_data":"signed","signed_at":"2024-01-02T03:04:05Z"}`+"\n\n",
+		w.Body.String())
+}
+
+func TestStreamDeviceSignatures_ErrNotFound(t *testing.T) {
+	s := NewServer("", &SignatureDeviceDomainStub{
+		ReadSignatureDeviceFunc: func(tenant, id string) (domain.SignatureDevice, error) {
+			return domain.SignatureDevice{}, domain.ErrNotFound
+		},
+	}, WithSignatureStream(stream.NewBroker()))
+	req := httptest.NewRequest("GET", "/api/v0/devices/unknown/signatures:stream", nil)
+	w := httptest.NewRecorder()
+	s.StreamDeviceSignatures(w, req)
+
+	assertEqual(t, http.StatusNotFound, w.Result().StatusCode)
+}
+
+func TestStreamSignatures_ErrInvalidEventId(t *testing.T) {
+	s := NewServer("", deviceFound, WithSignatureStream(stream.NewBroker()))
+	req := httptest.NewRequest("GET", "/api/v0/signatures:stream", nil)
+	req.Header.Set("Last-Event-ID", "nonsense")
+	w := httptest.NewRecorder()
+	s.StreamSignatures(w, req)
+
+	assertEqual(t, http.StatusBadRequest, w.Result().StatusCode)
+}
+
+func TestStreamSignatures_OkLive(t *testing.T) {
+	broker := stream.NewBroker()
+	s := NewServer("", deviceFound, WithSignatureStream(broker))
+	server := httptest.NewServer(s.Router())
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/api/v0/signatures:stream")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	broker.Publish(signatureCreatedEvent(7))
+
+	reader := bufio.NewReader(resp.Body)
+	line, _ := reader.ReadString('\n')
+
+	assertEqual(t, "id: 550e8400-e29b-11d4-a716-446655440000:7", strings.TrimSpace(line))
+}
+
+func TestRouter_StreamRoutes(t *testing.T) {
+	s := NewServer("", deviceFound, WithSignatureStream(stream.NewBroker()))
+	router := s.Router().(*mux.Router)
+
+	for _, path := range []string{
+		"/api/v0/devices/550e8400-e29b-11d4-a716-446655440000/signatures:stream",
+		"/api/v0/signatures:stream",
+	} {
+		var match mux.RouteMatch
+		req := httptest.NewRequest("GET", path, nil)
+		assertEqual(t, true, router.Match(req, &match))
+	}
+}
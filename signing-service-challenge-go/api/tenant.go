@@ -0,0 +1,108 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/auth"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/tenant"
+	"github.com/gorilla/mux"
+)
+
+// requestTenant returns the tenant of the key that authenticated the
+// request. Without authentication every request acts for the default tenant.
+func requestTenant(request *http.Request) string {
+	if key, ok := auth.KeyFromContext(request.Context()); ok {
+		return key.Tenant
+	}
+	return tenant.DefaultId
+}
+
+type CreateTenantRequest struct {
+	Name       string `json:"name"`
+	MaxDevices int    `json:"max_devices"`
+}
+
+type SetTenantLimitsRequest struct {
+	MaxDevices int `json:"max_devices"`
+}
+
+type TenantResponse struct {
+	Id         string    `json:"id"`
+	Name       string    `json:"name"`
+	MaxDevices int       `json:"max_devices"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+func newTenantResponse(t tenant.Tenant) TenantResponse {
+	return TenantResponse{
+		Id:         t.Id,
+		Name:       t.Name,
+		MaxDevices: t.MaxDevices,
+		CreatedAt:  t.CreatedAt,
+	}
+}
+
+func (s *Server) CreateTenant(response http.ResponseWriter, request *http.Request) {
+	var createRequest CreateTenantRequest
+	if err := json.NewDecoder(request.Body).Decode(&createRequest); err != nil {
+		WriteProblem(response, http.StatusBadRequest, CodeInvalidJSON, "invalid json body")
+		return
+	}
+
+	created, err := s.tenants.Create(createRequest.Name, createRequest.MaxDevices)
+	if err != nil {
+		writeTenantError(response, err)
+		return
+	}
+	WriteAPIResponse(response, http.StatusCreated, newTenantResponse(created))
+}
+
+func (s *Server) ReadTenants(response http.ResponseWriter, _ *http.Request) {
+	tenants := s.tenants.List()
+	readResponse := make([]TenantResponse, 0, len(tenants))
+	for _, t := range tenants {
+		readResponse = append(readResponse, newTenantResponse(t))
+	}
+	WriteAPIResponse(response, http.StatusOK, readResponse)
+}
+
+func (s *Server) ReadTenant(response http.ResponseWriter, request *http.Request) {
+	vars := mux.Vars(request)
+	found, err := s.tenants.Find(vars["tenantId"])
+	if err != nil {
+		writeTenantError(response, err)
+		return
+	}
+	WriteAPIResponse(response, http.StatusOK, newTenantResponse(found))
+}
+
+func (s *Server) SetTenantLimits(response http.ResponseWriter, request *http.Request) {
+	vars := mux.Vars(request)
+	var limitsRequest SetTenantLimitsRequest
+	if err := json.NewDecoder(request.Body).Decode(&limitsRequest); err != nil {
+		WriteProblem(response, http.StatusBadRequest, CodeInvalidJSON, "invalid json body")
+		return
+	}
+
+	updated, err := s.tenants.SetMaxDevices(vars["tenantId"], limitsRequest.MaxDevices)
+	if err != nil {
+		writeTenantError(response, err)
+		return
+	}
+	WriteAPIResponse(response, http.StatusOK, newTenantResponse(updated))
+}
+
+func writeTenantError(response http.ResponseWriter, err error) {
+	if errors.Is(err, tenant.ErrNotFound) {
+		writeError(response, http.StatusNotFound, err)
+		return
+	}
+	if errors.Is(err, tenant.ErrInvalidLimit) {
+		writeError(response, http.StatusBadRequest, err)
+		return
+	}
+	WriteInternalError(response)
+}
@@ -0,0 +1,126 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/auth"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/persistence"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/rbac"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/tenant"
+)
+
+type tenantFixture struct {
+	router  http.Handler
+	keyring auth.IKeyring
+	policy  rbac.IPolicy
+	tenants tenant.IRegistry
+}
+
+func newTenantFixture(t *testing.T) tenantFixture {
+	t.Helper()
+	tenants, _ := tenant.NewRegistry(0)
+	keyring := auth.NewKeyring()
+	clock := domain.NewSystemClock()
+	signatureDeviceDomain := domain.NewSignatureDeviceDomain(persistence.NewSignatureDeviceDb(), clock, domain.WithTenantLimits(tenants))
+	policy := rbac.NewPolicy()
+	s := NewServer("", signatureDeviceDomain, WithAuthentication(keyring), WithPolicy(policy), WithTenants(tenants))
+	return tenantFixture{router: s.Router(), keyring: keyring, policy: policy, tenants: tenants}
+}
+
+// createKey creates a key of the tenant that is bound to the roles on all
+// of its devices and returns its token.
+func (f tenantFixture) createKey(tenantId string, scopes []auth.Scope, roles ...string) string {
+	key, token, _ := f.keyring.Create(tenantId, "pos", scopes)
+	for _, role := range roles {
+		_, _ = f.policy.Bind(rbac.Binding{Tenant: tenantId, Subject: key.Id, Role: role, Resources: []string{"*"}})
+	}
+	return token
+}
+
+func (f tenantFixture) do(token, method, path, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, bytes.NewReader([]byte(body)))
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	f.router.ServeHTTP(w, req)
+	return w
+}
+
+func TestTenants_CrossTenantAccessIsNotFound(t *testing.T) {
+	f := newTenantFixture(t)
+	merchant1, _ := f.tenants.Create("merchant1", 0)
+	merchant2, _ := f.tenants.Create("merchant2", 0)
+	token1 := f.createKey(merchant1.Id, []auth.Scope{auth.ScopeDevicesRead, auth.ScopeDevicesWrite, auth.ScopeSign}, "operator", "cashier")
+	token2 := f.createKey(merchant2.Id, []auth.Scope{auth.ScopeDevicesRead, auth.ScopeDevicesWrite, auth.ScopeSign}, "operator", "cashier")
+
+	w := f.do(token1, "POST", "/api/v0/devices", `{"id":"550e8400-e29b-11d4-a716-446655440000","algorithm":"ECC"}`)
+	assertEqual(t, http.StatusCreated, w.Result().StatusCode)
+
+	w = f.do(token2, "GET", "/api/v0/devices/550e8400-e29b-11d4-a716-446655440000", "")
+	assertEqual(t, http.StatusNotFound, w.Result().StatusCode)
+	w = f.do(token2, "POST", "/api/v0/devices/550e8400-e29b-11d4-a716-446655440000:sign", `{"data_to_be_signed":"test"}`)
+	assertEqual(t, http.StatusNotFound, w.Result().StatusCode)
+	w = f.do(token2, "GET", "/api/v0/devices", "")
+	assertJSONEqual(t, []byte(`{"data":[]}`), w.Body.Bytes())
+
+	w = f.do(token1, "POST", "/api/v0/devices/550e8400-e29b-11d4-a716-446655440000:sign", `{"data_to_be_signed":"test"}`)
+	assertEqual(t, http.StatusOK, w.Result().StatusCode)
+}
+
+func TestTenants_DeviceLimit(t *testing.T) {
+	f := newTenantFixture(t)
+	merchant, _ := f.tenants.Create("merchant", 1)
+	token := f.createKey(merchant.Id, []auth.Scope{auth.ScopeDevicesWrite}, "operator")
+
+	w := f.do(token, "POST", "/api/v0/devices", `{"id":"550e8400-e29b-11d4-a716-446655440000","algorithm":"ECC"}`)
+	assertEqual(t, http.StatusCreated, w.Result().StatusCode)
+	w = f.do(token, "POST", "/api/v0/devices", `{"id":"550e8400-e29b-11d4-a716-446655440001","algorithm":"ECC"}`)
+	assertEqual(t, http.StatusForbidden, w.Result().StatusCode)
+}
+
+func TestTenants_Management(t *testing.T) {
+	f := newTenantFixture(t)
+	_, platform, _ := f.keyring.Create(tenant.DefaultId, "platform", auth.Scopes)
+
+	w := f.do(platform, "POST", "/api/v0/tenants", `{"name":"merchant","max_devices":5}`)
+	var created struct {
+		Data TenantResponse `json:"data"`
+	}
+	_ = json.Unmarshal(w.Body.Bytes(), &created)
+	assertEqual(t, http.StatusCreated, w.Result().StatusCode)
+	assertEqual(t, 5, created.Data.MaxDevices)
+
+	w = f.do(platform, "POST", "/api/v0/tenants/"+created.Data.Id+":set-limits", `{"max_devices":7}`)
+	assertEqual(t, http.StatusOK, w.Result().StatusCode)
+	assertEqual(t, 7, f.tenants.MaxDevices(created.Data.Id))
+	w = f.do(platform, "POST", "/api/v0/tenants/"+created.Data.Id+":set-limits", `{"max_devices":-1}`)
+	assertEqual(t, http.StatusBadRequest, w.Result().StatusCode)
+	w = f.do(platform, "GET", "/api/v0/tenants/unknown", "")
+	assertEqual(t, http.StatusNotFound, w.Result().StatusCode)
+
+	w = f.do(platform, "POST", "/api/v0/keys", `{"name":"admin","scopes":["admin"],"tenant":"`+created.Data.Id+`"}`)
+	var key struct {
+		Data KeyResponse `json:"data"`
+	}
+	_ = json.Unmarshal(w.Body.Bytes(), &key)
+	assertEqual(t, http.StatusCreated, w.Result().StatusCode)
+	assertEqual(t, created.Data.Id, key.Data.Tenant)
+
+	admin := key.Data.Token
+	w = f.do(admin, "GET", "/api/v0/tenants", "")
+	assertEqual(t, http.StatusForbidden, w.Result().StatusCode)
+	w = f.do(admin, "POST", "/api/v0/keys", `{"name":"pos","scopes":["sign"]}`)
+	assertEqual(t, http.StatusForbidden, w.Result().StatusCode)
+	w = f.do(admin, "POST", "/api/v0/keys", `{"name":"admin","scopes":["admin"],"tenant":"default"}`)
+	assertEqual(t, http.StatusForbidden, w.Result().StatusCode)
+	w = f.do(admin, "GET", "/api/v0/keys", "")
+	var keys struct {
+		Data []KeyResponse `json:"data"`
+	}
+	_ = json.Unmarshal(w.Body.Bytes(), &keys)
+	assertEqual(t, 1, len(keys.Data))
+}
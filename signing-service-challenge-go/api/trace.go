@@ -0,0 +1,55 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/tracing"
+	"github.com/gorilla/mux"
+)
+
+// traced starts the server span of a request. A valid traceparent header
+// makes the span a child of the caller. It runs as a middleware of the
+// Router, so that the span is named after the route.
+func (s *Server) traced(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		ctx := request.Context()
+		if parent, ok := tracing.Extract(request.Header); ok {
+			ctx = tracing.ContextWithRemoteParent(ctx, parent)
+		}
+		route := request.URL.Path
+		if current := mux.CurrentRoute(request); current != nil {
+			route, _ = current.GetPathTemplate()
+		}
+		ctx, span := s.tracer.Start(ctx, request.Method+" "+route)
+		span.SetKind(tracing.KindServer)
+		span.SetAttribute("http.method", request.Method)
+		span.SetAttribute("http.route", route)
+		if requestId, ok := RequestIdFromContext(ctx); ok {
+			span.SetAttribute("http.request_id", requestId)
+		}
+		if id := mux.Vars(request)["id"]; id != "" {
+			span.SetAttribute("device.id", id)
+		}
+		if entry := accessLogEntry(request); entry != nil {
+			entry.TraceId = span.SpanContext().TraceId.String()
+		}
+
+		recorder := &responseRecorder{ResponseWriter: response, status: http.StatusOK}
+		defer func() {
+			span.SetAttribute("http.status_code", strconv.Itoa(recorder.status))
+			if recorder.status >= http.StatusInternalServerError {
+				span.SetError(errorStatus(recorder.status))
+			}
+			span.End()
+		}()
+		next.ServeHTTP(recorder, request.WithContext(ctx))
+	})
+}
+
+// errorStatus is the error of a span whose response failed.
+type errorStatus int
+
+func (e errorStatus) Error() string {
+	return http.StatusText(int(e))
+}
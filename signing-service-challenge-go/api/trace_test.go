@@ -0,0 +1,76 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/persistence"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/tracing"
+)
+
+type ExporterStub struct {
+	mu    sync.Mutex
+	Spans []tracing.SpanData
+}
+
+func (e *ExporterStub) Export(spans []tracing.SpanData) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.Spans = append(e.Spans, spans...)
+	return nil
+}
+
+func TestTraced_SpansEveryStageOfSigning(t *testing.T) {
+	exporter := &ExporterStub{}
+	tracer := tracing.NewTracer(exporter)
+	signatureDeviceDomain := domain.NewSignatureDeviceDomain(persistence.NewSignatureDeviceDb(), domain.NewSystemClock())
+	router := NewServer("", signatureDeviceDomain, WithTracer(tracer)).Router()
+	serve(router, "POST", "/api/v0/devices", `{"id": "`+tillDeviceId+`", "algorithm": "ECC"}`)
+
+	req := httptest.NewRequest("POST", "/api/v0/devices/"+tillDeviceId+":sign", strings.NewReader(`{"data_to_be_signed": "a"}`))
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	_ = tracer.Close()
+
+	assertEqual(t, http.StatusOK, w.Code)
+	spans := make(map[string]tracing.SpanData)
+	for _, span := range exporter.Spans {
+		if span.Context.TraceId.String() == "4bf92f3577b34da6a3ce929d0e0e4736" {
+			spans[span.Name] = span
+		}
+	}
+	server := spans["POST /api/v0/devices/{id}:sign"]
+	assertEqual(t, "00f067aa0ba902b7", server.Parent.String())
+	assertEqual(t, tracing.KindServer, server.Kind)
+	sign := spans["domain.SignTransaction"]
+	assertEqual(t, server.Context.SpanId, sign.Parent)
+	for _, name := range []string{"persistence.FindById", "crypto.NewSigner", "crypto.Sign", "persistence.CompareAndSwap"} {
+		assertEqual(t, sign.Context.SpanId, spans[name].Parent)
+	}
+}
+
+func TestTraced_SpansEveryStageOfBatchSigningOnce(t *testing.T) {
+	exporter := &ExporterStub{}
+	tracer := tracing.NewTracer(exporter)
+	signatureDeviceDomain := domain.NewSignatureDeviceDomain(persistence.NewSignatureDeviceDb(), domain.NewSystemClock())
+	router := NewServer("", signatureDeviceDomain, WithTracer(tracer)).Router()
+	serve(router, "POST", "/api/v0/devices", `{"id": "`+tillDeviceId+`", "algorithm": "ECC"}`)
+
+	w := serve(router, "POST", "/api/v0/devices/"+tillDeviceId+":sign-batch", `{"data_to_be_signed": ["a", "b", "c"]}`)
+	_ = tracer.Close()
+
+	assertEqual(t, http.StatusOK, w.Code)
+	counts := make(map[string]int)
+	for _, span := range exporter.Spans {
+		if span.Name == "crypto.NewSigner" || span.Name == "crypto.Sign" {
+			counts[span.Name]++
+			assertEqual(t, []tracing.Attribute{{Key: "items", Value: "3"}}, span.Attributes)
+		}
+	}
+	assertEqual(t, map[string]int{"crypto.NewSigner": 1, "crypto.Sign": 1}, counts)
+}
@@ -0,0 +1,85 @@
+package api
+
+import (
+	"encoding/pem"
+	"io"
+	"mime"
+	"net/http"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/tsa"
+)
+
+const (
+	timeStampQueryContentType = "application/timestamp-query"
+	timeStampReplyContentType = "application/timestamp-reply"
+
+	// maxTimeStampQuerySize is far above any valid TimeStampReq.
+	maxTimeStampQuerySize = 1 << 16
+)
+
+// TimeStamp handles RFC 3161 time-stamp requests. Protocol level failures are
+// reported inside the TimeStampResp as the RFC demands, not as HTTP errors.
+func (s *Server) TimeStamp(response http.ResponseWriter, request *http.Request) {
+	mediaType, _, _ := mime.ParseMediaType(request.Header.Get("Content-Type"))
+	if mediaType != timeStampQueryContentType {
+		WriteProblem(response, http.StatusUnsupportedMediaType, CodeUnsupportedMediaType, "")
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(request.Body, maxTimeStampQuerySize))
+	if err != nil {
+		WriteProblem(response, http.StatusBadRequest, CodeInvalidBody, "invalid body")
+		return
+	}
+
+	timeStampRequest, err := tsa.ParseRequest(body)
+	if err != nil {
+		writeTimeStampRejection(response, err)
+		return
+	}
+
+	token, err := s.tsa.TimeStamp(request.Context(), timeStampRequest)
+	if err != nil {
+		writeTimeStampRejection(response, err)
+		return
+	}
+
+	reply, err := tsa.NewResponse(token)
+	if err != nil {
+		WriteInternalError(response)
+		return
+	}
+	writeTimeStampReply(response, reply)
+}
+
+// ReadTimeStampCertificate returns the PEM encoded certificate of the TSA,
+// which clients need to verify the tokens.
+func (s *Server) ReadTimeStampCertificate(response http.ResponseWriter, request *http.Request) {
+	certificate, err := s.tsa.Certificate(request.Context())
+	if err != nil {
+		WriteInternalError(response)
+		return
+	}
+
+	response.Header().Set("Content-Type", "application/pem-certificate-chain")
+	response.WriteHeader(http.StatusOK)
+	response.Write(pem.EncodeToMemory(&pem.Block{
+		Type:  "CERTIFICATE",
+		Bytes: certificate,
+	}))
+}
+
+func writeTimeStampRejection(response http.ResponseWriter, err error) {
+	reply, err := tsa.NewRejection(err)
+	if err != nil {
+		WriteInternalError(response)
+		return
+	}
+	writeTimeStampReply(response, reply)
+}
+
+func writeTimeStampReply(response http.ResponseWriter, reply []byte) {
+	response.Header().Set("Content-Type", timeStampReplyContentType)
+	response.WriteHeader(http.StatusOK)
+	response.Write(reply)
+}
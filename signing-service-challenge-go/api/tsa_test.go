@@ -0,0 +1,68 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/tsa"
+)
+
+type TimeStampAuthorityStub struct {
+	TimeStampFunc   func(request tsa.Request) (tsa.Token, error)
+	CertificateFunc func() ([]byte, error)
+}
+
+func (s *TimeStampAuthorityStub) TimeStamp(_ context.Context, request tsa.Request) (tsa.Token, error) {
+	return s.TimeStampFunc(request)
+}
+
+func (s *TimeStampAuthorityStub) Certificate(_ context.Context) ([]byte, error) {
+	return s.CertificateFunc()
+}
+
+func TestTimeStamp_ErrUnsupportedMediaType(t *testing.T) {
+	s := NewServer("", &SignatureDeviceDomainStub{}, WithTimeStampAuthority(&TimeStampAuthorityStub{}))
+	req := httptest.NewRequest("POST", "/api/v0/tsa", bytes.NewReader([]byte(`{}`)))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	s.TimeStamp(w, req)
+
+	resp := w.Result()
+
+	assertEqual(t, http.StatusUnsupportedMediaType, resp.StatusCode)
+}
+
+func TestTimeStamp_OkRejection(t *testing.T) {
+	s := NewServer("", &SignatureDeviceDomainStub{}, WithTimeStampAuthority(&TimeStampAuthorityStub{}))
+	req := httptest.NewRequest("POST", "/api/v0/tsa", bytes.NewReader([]byte(`invalid`)))
+	req.Header.Set("Content-Type", "application/timestamp-query")
+	w := httptest.NewRecorder()
+	s.TimeStamp(w, req)
+
+	resp := w.Result()
+	body := w.Body.Bytes()
+
+	expected, _ := tsa.NewRejection(tsa.ErrBadRequest)
+	assertEqual(t, http.StatusOK, resp.StatusCode)
+	assertEqual(t, "application/timestamp-reply", resp.Header.Get("Content-Type"))
+	assertEqual(t, expected, body)
+}
+
+func TestReadTimeStampCertificate_Ok(t *testing.T) {
+	s := NewServer("", &SignatureDeviceDomainStub{}, WithTimeStampAuthority(&TimeStampAuthorityStub{
+		CertificateFunc: func() ([]byte, error) {
+			return []byte("certificate"), nil
+		},
+	}))
+	req := httptest.NewRequest("GET", "/api/v0/tsa/certificate", nil)
+	w := httptest.NewRecorder()
+	s.ReadTimeStampCertificate(w, req)
+
+	resp := w.Result()
+
+	assertEqual(t, http.StatusOK, resp.StatusCode)
+	assertEqual(t, "-----BEGIN CERTIFICATE-----\nY2VydGlmaWNhdGU=\n-----END CERTIFICATE-----\n", w.Body.String())
+}
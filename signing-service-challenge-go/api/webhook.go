@@ -0,0 +1,143 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/webhook"
+	"github.com/gorilla/mux"
+)
+
+type CreateWebhookRequest struct {
+	URL        string   `json:"url"`
+	EventTypes []string `json:"event_types"`
+	// Secret keys the HMAC of the payloads. A random secret is generated if empty.
+	Secret string `json:"secret,omitempty"`
+}
+
+type WebhookResponse struct {
+	Id         string    `json:"id"`
+	URL        string    `json:"url"`
+	EventTypes []string  `json:"event_types"`
+	Secret     string    `json:"secret,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+func newWebhookResponse(subscription webhook.Subscription) WebhookResponse {
+	eventTypes := make([]string, 0, len(subscription.EventTypes))
+	for _, eventType := range subscription.EventTypes {
+		eventTypes = append(eventTypes, string(eventType))
+	}
+	return WebhookResponse{
+		Id:         subscription.Id,
+		URL:        subscription.URL,
+		EventTypes: eventTypes,
+		CreatedAt:  subscription.CreatedAt,
+	}
+}
+
+type DeliveryResponse struct {
+	Id        string          `json:"id"`
+	WebhookId string          `json:"webhook_id"`
+	EventId   string          `json:"event_id"`
+	EventType string          `json:"event_type"`
+	Status    string          `json:"status"`
+	Attempts  int             `json:"attempts"`
+	LastError string          `json:"last_error,omitempty"`
+	Payload   json.RawMessage `json:"payload"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+func newDeliveryResponse(delivery webhook.Delivery) DeliveryResponse {
+	return DeliveryResponse{
+		Id:        delivery.Id,
+		WebhookId: delivery.SubscriptionId,
+		EventId:   delivery.EventId,
+		EventType: string(delivery.EventType),
+		Status:    string(delivery.Status),
+		Attempts:  delivery.Attempts,
+		LastError: delivery.LastError,
+		Payload:   delivery.Payload,
+		CreatedAt: delivery.CreatedAt,
+	}
+}
+
+// CreateWebhook subscribes a URL to events. The secret is only returned here.
+func (s *Server) CreateWebhook(response http.ResponseWriter, request *http.Request) {
+	var createRequest CreateWebhookRequest
+	if err := json.NewDecoder(request.Body).Decode(&createRequest); err != nil {
+		WriteProblem(response, http.StatusBadRequest, CodeInvalidJSON, "invalid json body")
+		return
+	}
+
+	eventTypes := make([]domain.EventType, 0, len(createRequest.EventTypes))
+	for _, eventType := range createRequest.EventTypes {
+		eventTypes = append(eventTypes, domain.EventType(eventType))
+	}
+	subscription, err := s.webhooks.Subscribe(requestTenant(request), createRequest.URL, eventTypes, createRequest.Secret)
+	if err != nil {
+		if errors.Is(err, webhook.ErrInvalidURL) || errors.Is(err, webhook.ErrInvalidEventType) {
+			writeError(response, http.StatusBadRequest, err)
+			return
+		}
+		WriteInternalError(response)
+		return
+	}
+
+	webhookResponse := newWebhookResponse(subscription)
+	webhookResponse.Secret = subscription.Secret
+	WriteAPIResponse(response, http.StatusCreated, webhookResponse)
+}
+
+func (s *Server) ReadWebhooks(response http.ResponseWriter, request *http.Request) {
+	subscriptions := s.webhooks.Subscriptions(requestTenant(request))
+	readResponse := make([]WebhookResponse, 0, len(subscriptions))
+	for _, subscription := range subscriptions {
+		readResponse = append(readResponse, newWebhookResponse(subscription))
+	}
+	WriteAPIResponse(response, http.StatusOK, readResponse)
+}
+
+func (s *Server) DeleteWebhook(response http.ResponseWriter, request *http.Request) {
+	vars := mux.Vars(request)
+	id := vars["webhookId"]
+
+	if err := s.webhooks.Unsubscribe(requestTenant(request), id); err != nil {
+		if errors.Is(err, webhook.ErrNotFound) {
+			writeError(response, http.StatusNotFound, err)
+			return
+		}
+		WriteInternalError(response)
+		return
+	}
+	response.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) ReadDeadLetters(response http.ResponseWriter, request *http.Request) {
+	deadLetters := s.webhooks.DeadLetters(requestTenant(request))
+	readResponse := make([]DeliveryResponse, 0, len(deadLetters))
+	for _, delivery := range deadLetters {
+		readResponse = append(readResponse, newDeliveryResponse(delivery))
+	}
+	WriteAPIResponse(response, http.StatusOK, readResponse)
+}
+
+// ReplayDeadLetter queues a dead letter for delivery again.
+func (s *Server) ReplayDeadLetter(response http.ResponseWriter, request *http.Request) {
+	vars := mux.Vars(request)
+	id := vars["deliveryId"]
+
+	delivery, err := s.webhooks.Replay(requestTenant(request), id)
+	if err != nil {
+		if errors.Is(err, webhook.ErrNotFound) {
+			writeError(response, http.StatusNotFound, err)
+			return
+		}
+		WriteInternalError(response)
+		return
+	}
+	WriteAPIResponse(response, http.StatusAccepted, newDeliveryResponse(delivery))
+}
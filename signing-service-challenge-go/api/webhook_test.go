@@ -0,0 +1,186 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/webhook"
+	"github.com/gorilla/mux"
+)
+
+type DispatcherStub struct {
+	SubscribeFunc     func(tenant, url string, eventTypes []domain.EventType, secret string) (webhook.Subscription, error)
+	SubscriptionsFunc func(tenant string) []webhook.Subscription
+	UnsubscribeFunc   func(tenant, id string) error
+	DeadLettersFunc   func(tenant string) []webhook.Delivery
+	ReplayFunc        func(tenant, deliveryId string) (webhook.Delivery, error)
+}
+
+func (s *DispatcherStub) Publish(domain.Event) {}
+
+func (s *DispatcherStub) Subscribe(tenant, url string, eventTypes []domain.EventType, secret string) (webhook.Subscription, error) {
+	return s.SubscribeFunc(tenant, url, eventTypes, secret)
+}
+
+func (s *DispatcherStub) Subscriptions(tenant string) []webhook.Subscription {
+	return s.SubscriptionsFunc(tenant)
+}
+
+func (s *DispatcherStub) Unsubscribe(tenant, id string) error {
+	return s.UnsubscribeFunc(tenant, id)
+}
+
+func (s *DispatcherStub) DeadLetters(tenant string) []webhook.Delivery {
+	return s.DeadLettersFunc(tenant)
+}
+
+func (s *DispatcherStub) Replay(tenant, deliveryId string) (webhook.Delivery, error) {
+	return s.ReplayFunc(tenant, deliveryId)
+}
+
+func (s *DispatcherStub) Close(ctx context.Context) error { return nil }
+
+var subscription1 = webhook.Subscription{
+	Id:         "webhook",
+	URL:        "https://erp.example.com/hooks",
+	EventTypes: []domain.EventType{domain.EventSignatureCreated},
+	Secret:     "secret",
+	CreatedAt:  time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+}
+
+func TestCreateWebhook_Ok(t *testing.T) {
+	s := NewServer("", &SignatureDeviceDomainStub{}, WithWebhooks(&DispatcherStub{
+		SubscribeFunc: func(tenant, url string, eventTypes []domain.EventType, secret string) (webhook.Subscription, error) {
+			assertEqual(t, subscription1.URL, url)
+			assertEqual(t, subscription1.EventTypes, eventTypes)
+			return subscription1, nil
+		},
+	}))
+	req := httptest.NewRequest("POST", "/api/v0/webhooks", bytes.NewReader([]byte(`{
+		"url": "https://erp.example.com/hooks",
+		"event_types": ["signature.created"]
+	}`)))
+	w := httptest.NewRecorder()
+	s.CreateWebhook(w, req)
+
+	resp := w.Result()
+	body, _ := io.ReadAll(resp.Body)
+
+	assertEqual(t, http.StatusCreated, resp.StatusCode)
+	assertJSONEqual(t, []byte(`{
+	  "data": {
+		"id": "webhook",
+		"url": "https://erp.example.com/hooks",
+		"event_types": ["signature.created"],
+		"secret": "secret",
+		"created_at": "2024-01-02T03:04:05Z"
+	  }
+	}`), body)
+}
+
+func TestCreateWebhook_ErrInvalidEventType(t *testing.T) {
+	s := NewServer("", &SignatureDeviceDomainStub{}, WithWebhooks(&DispatcherStub{
+		SubscribeFunc: func(tenant, url string, eventTypes []domain.EventType, secret string) (webhook.Subscription, error) {
+			return webhook.Subscription{}, webhook.ErrInvalidEventType
+		},
+	}))
+	req := httptest.NewRequest("POST", "/api/v0/webhooks", bytes.NewReader([]byte(`{
+		"url": "https://erp.example.com/hooks",
+		"event_types": ["device.exploded"]
+	}`)))
+	w := httptest.NewRecorder()
+	s.CreateWebhook(w, req)
+
+	resp := w.Result()
+	body, _ := io.ReadAll(resp.Body)
+
+	assertEqual(t, http.StatusBadRequest, resp.StatusCode)
+	assertJSONEqual(t, []byte(`{
+		"type": "about:blank",
+		"title": "Bad Request",
+		"status": 400,
+		"detail": "invalid event type",
+		"code": "invalid_event_type"
+	}`), body)
+}
+
+func TestReadWebhooks_OkHidesSecret(t *testing.T) {
+	s := NewServer("", &SignatureDeviceDomainStub{}, WithWebhooks(&DispatcherStub{
+		SubscriptionsFunc: func(tenant string) []webhook.Subscription {
+			return []webhook.Subscription{subscription1}
+		},
+	}))
+	req := httptest.NewRequest("GET", "/api/v0/webhooks", nil)
+	w := httptest.NewRecorder()
+	s.ReadWebhooks(w, req)
+
+	resp := w.Result()
+	body, _ := io.ReadAll(resp.Body)
+
+	assertEqual(t, http.StatusOK, resp.StatusCode)
+	assertJSONEqual(t, []byte(`{
+	  "data": [{
+		"id": "webhook",
+		"url": "https://erp.example.com/hooks",
+		"event_types": ["signature.created"],
+		"created_at": "2024-01-02T03:04:05Z"
+	  }]
+	}`), body)
+}
+
+func TestDeleteWebhook_ErrNotFound(t *testing.T) {
+	s := NewServer("", &SignatureDeviceDomainStub{}, WithWebhooks(&DispatcherStub{
+		UnsubscribeFunc: func(tenant, id string) error {
+			return webhook.ErrNotFound
+		},
+	}))
+	req := httptest.NewRequest("DELETE", "/api/v0/webhooks/unknown", nil)
+	w := httptest.NewRecorder()
+	s.DeleteWebhook(w, req)
+
+	assertEqual(t, http.StatusNotFound, w.Result().StatusCode)
+}
+
+func TestReplayDeadLetter_Ok(t *testing.T) {
+	s := NewServer("", &SignatureDeviceDomainStub{}, WithWebhooks(&DispatcherStub{
+		ReplayFunc: func(tenant, deliveryId string) (webhook.Delivery, error) {
+			assertEqual(t, "delivery", deliveryId)
+			return webhook.Delivery{
+				Id:             "delivery",
+				SubscriptionId: "webhook",
+				EventId:        "event",
+				EventType:      domain.EventDeviceCreated,
+				Payload:        []byte(`{"id":"event"}`),
+				Status:         webhook.DeliveryPending,
+				CreatedAt:      time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+			}, nil
+		},
+	}))
+	req := httptest.NewRequest("POST", "/api/v0/webhooks/dead-letters/delivery:replay", nil)
+	req = mux.SetURLVars(req, map[string]string{"deliveryId": "delivery"})
+	w := httptest.NewRecorder()
+	s.ReplayDeadLetter(w, req)
+
+	resp := w.Result()
+	body, _ := io.ReadAll(resp.Body)
+
+	assertEqual(t, http.StatusAccepted, resp.StatusCode)
+	assertJSONEqual(t, []byte(`{
+	  "data": {
+		"id": "delivery",
+		"webhook_id": "webhook",
+		"event_id": "event",
+		"event_type": "device.created",
+		"status": "pending",
+		"attempts": 0,
+		"payload": {"id": "event"},
+		"created_at": "2024-01-02T03:04:05Z"
+	  }
+	}`), body)
+}
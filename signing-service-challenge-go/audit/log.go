@@ -0,0 +1,142 @@
+package audit
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrTampered  = errors.New("audit log tampered")
+	ErrTruncated = errors.New("audit log truncated")
+)
+
+// GenesisHash is the previous hash of the first entry.
+var GenesisHash = strings.Repeat("0", 64)
+
+// Entry records a single mutating API call. Hash covers every other field,
+// including the hash of the previous entry, which chains the entries together.
+type Entry struct {
+	Sequence     int       `json:"sequence"`
+	Timestamp    time.Time `json:"timestamp"`
+	Actor        string    `json:"actor"`
+	Action       string    `json:"action"`
+	Target       string    `json:"target"`
+	RequestId    string    `json:"request_id"`
+	Status       int       `json:"status"`
+	PreviousHash string    `json:"previous_hash"`
+	Hash         string    `json:"hash"`
+}
+
+// computeHash returns the hex encoded SHA-256 over the JSON of the entry
+// without its hash.
+func (e Entry) computeHash() string {
+	e.Hash = ""
+	encoded, _ := json.Marshal(e)
+	digest := sha256.Sum256(encoded)
+	return hex.EncodeToString(digest[:])
+}
+
+// Record is the part of an Entry supplied by the caller.
+type Record struct {
+	Actor     string
+	Action    string
+	Target    string
+	RequestId string
+	Status    int
+}
+
+type IAuditLog interface {
+	Append(record Record) (Entry, error)
+	Entries() ([]Entry, error)
+}
+
+// Log appends hash-chained entries to an ISink.
+type Log struct {
+	sink ISink
+
+	mu   sync.Mutex
+	head Entry
+}
+
+// NewLog continues the chain already stored in the sink. It refuses to
+// append to a chain that does not verify.
+func NewLog(sink ISink) (IAuditLog, error) {
+	entries, err := sink.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if err := Verify(entries, ""); err != nil {
+		return nil, err
+	}
+	log := &Log{sink: sink}
+	if len(entries) > 0 {
+		log.head = entries[len(entries)-1]
+	}
+	return log, nil
+}
+
+func (l *Log) Append(record Record) (Entry, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	previousHash := l.head.Hash
+	if previousHash == "" {
+		previousHash = GenesisHash
+	}
+	entry := Entry{
+		Sequence:     l.head.Sequence + 1,
+		Timestamp:    time.Now().UTC().Round(0),
+		Actor:        record.Actor,
+		Action:       record.Action,
+		Target:       record.Target,
+		RequestId:    record.RequestId,
+		Status:       record.Status,
+		PreviousHash: previousHash,
+	}
+	entry.Hash = entry.computeHash()
+
+	if err := l.sink.Append(entry); err != nil {
+		return Entry{}, err
+	}
+	l.head = entry
+	return entry, nil
+}
+
+func (l *Log) Entries() ([]Entry, error) {
+	return l.sink.ReadAll()
+}
+
+// Verify checks that the entries form an unbroken chain from the genesis
+// hash. Removing entries from the end keeps the chain intact, so truncation
+// can only be detected against a head hash obtained earlier, which is
+// checked if not empty.
+func Verify(entries []Entry, head string) error {
+	previousHash := GenesisHash
+	for i, entry := range entries {
+		if entry.Sequence != i+1 {
+			return fmt.Errorf("%w: entry %d has sequence %d", ErrTampered, i+1, entry.Sequence)
+		}
+		if entry.PreviousHash != previousHash {
+			return fmt.Errorf("%w: entry %d does not chain to its predecessor", ErrTampered, entry.Sequence)
+		}
+		if entry.Hash != entry.computeHash() {
+			return fmt.Errorf("%w: entry %d does not match its hash", ErrTampered, entry.Sequence)
+		}
+		previousHash = entry.Hash
+	}
+	if head == "" {
+		return nil
+	}
+	for _, entry := range entries {
+		if entry.Hash == head {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: head %s is missing", ErrTruncated, head)
+}
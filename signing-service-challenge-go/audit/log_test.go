@@ -0,0 +1,107 @@
+package audit
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func assertEqual(t *testing.T, expected interface{}, actual interface{}) {
+	t.Helper()
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("Expected %v, actual %v", expected, actual)
+	}
+}
+
+func assertErrorIs(t *testing.T, expected error, actual error) {
+	t.Helper()
+	if !errors.Is(actual, expected) {
+		t.Errorf("Expected %v, actual %v", expected, actual)
+	}
+}
+
+var record1 = Record{
+	Actor:     "anonymous",
+	Action:    "POST /api/v0/devices",
+	Target:    "/api/v0/devices",
+	RequestId: "request",
+	Status:    201,
+}
+
+func newChain(t *testing.T, length int) []Entry {
+	log, _ := NewLog(NewMemorySink())
+	for i := 0; i < length; i++ {
+		if _, err := log.Append(record1); err != nil {
+			t.Fatal(err)
+		}
+	}
+	entries, _ := log.Entries()
+	return entries
+}
+
+func TestAppend_ChainsEntries(t *testing.T) {
+	entries := newChain(t, 3)
+
+	assertEqual(t, 3, len(entries))
+	assertEqual(t, GenesisHash, entries[0].PreviousHash)
+	assertEqual(t, entries[0].Hash, entries[1].PreviousHash)
+	assertEqual(t, entries[1].Hash, entries[2].PreviousHash)
+	assertEqual(t, 3, entries[2].Sequence)
+	assertEqual(t, "request", entries[2].RequestId)
+	assertEqual(t, nil, Verify(entries, entries[2].Hash))
+}
+
+func TestVerify_DetectsEdit(t *testing.T) {
+	entries := newChain(t, 3)
+	entries[1].Actor = "someone else"
+
+	assertErrorIs(t, ErrTampered, Verify(entries, ""))
+}
+
+func TestVerify_DetectsRehashedEdit(t *testing.T) {
+	entries := newChain(t, 3)
+	entries[1].Actor = "someone else"
+	entries[1].Hash = entries[1].computeHash()
+
+	assertErrorIs(t, ErrTampered, Verify(entries, ""))
+}
+
+func TestVerify_DetectsRemovedEntry(t *testing.T) {
+	entries := newChain(t, 3)
+
+	assertErrorIs(t, ErrTampered, Verify(append(entries[:1:1], entries[2]), ""))
+	assertErrorIs(t, ErrTampered, Verify(entries[1:], ""))
+}
+
+func TestVerify_DetectsTruncationWithHead(t *testing.T) {
+	entries := newChain(t, 3)
+
+	assertEqual(t, nil, Verify(entries[:2], ""))
+	assertErrorIs(t, ErrTruncated, Verify(entries[:2], entries[2].Hash))
+}
+
+func TestNewLog_ContinuesChain(t *testing.T) {
+	sink := NewMemorySink()
+	first, _ := NewLog(sink)
+	entry, _ := first.Append(record1)
+
+	second, err := NewLog(sink)
+	next, _ := second.Append(record1)
+
+	assertEqual(t, nil, err)
+	assertEqual(t, 2, next.Sequence)
+	assertEqual(t, entry.Hash, next.PreviousHash)
+}
+
+func TestNewLog_ErrTampered(t *testing.T) {
+	sink := NewMemorySink()
+	entries := newChain(t, 2)
+	entries[0].Target = "/elsewhere"
+	for _, entry := range entries {
+		_ = sink.Append(entry)
+	}
+
+	_, err := NewLog(sink)
+
+	assertErrorIs(t, ErrTampered, err)
+}
@@ -0,0 +1,40 @@
+package audit
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFileSink_RoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	sink, err := NewFileSink(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	log, _ := NewLog(sink)
+	first, _ := log.Append(record1)
+	second, _ := log.Append(record1)
+
+	entries, err := sink.ReadAll()
+
+	assertEqual(t, nil, err)
+	assertEqual(t, []Entry{first, second}, entries)
+	assertEqual(t, nil, Verify(entries, second.Hash))
+}
+
+func TestReadEntries_DetectsFileEdit(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	sink, _ := NewFileSink(path)
+	log, _ := NewLog(sink)
+	_, _ = log.Append(record1)
+
+	content, _ := os.ReadFile(path)
+	edited := strings.Replace(string(content), `"status":201`, `"status":200`, 1)
+	entries, err := ReadEntries(strings.NewReader(edited))
+
+	assertEqual(t, nil, err)
+	assertEqual(t, 200, entries[0].Status)
+	assertErrorIs(t, ErrTampered, Verify(entries, ""))
+}
@@ -0,0 +1,82 @@
+package auth
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+)
+
+// IdentityBinding grants the scopes of a tenant to the identity of a client
+// certificate, which is its SPIFFE ID or its subject.
+type IdentityBinding struct {
+	Identity string  `json:"identity"`
+	Tenant   string  `json:"tenant"`
+	Scopes   []Scope `json:"scopes"`
+}
+
+type IIdentityMap interface {
+	Bind(binding IdentityBinding) (Key, error)
+	// Resolve returns the key of a bound identity.
+	Resolve(identity string) (Key, error)
+}
+
+type IdentityMap struct {
+	mu         sync.RWMutex
+	identities map[string]Key
+}
+
+func NewIdentityMap() IIdentityMap {
+	return &IdentityMap{
+		identities: make(map[string]Key),
+	}
+}
+
+// ReadIdentityMap creates an IdentityMap from a JSON array of bindings.
+func ReadIdentityMap(reader io.Reader) (IIdentityMap, error) {
+	var bindings []IdentityBinding
+	if err := json.NewDecoder(reader).Decode(&bindings); err != nil {
+		return nil, err
+	}
+	identities := NewIdentityMap()
+	for _, binding := range bindings {
+		if _, err := identities.Bind(binding); err != nil {
+			return nil, err
+		}
+	}
+	return identities, nil
+}
+
+func (m *IdentityMap) Bind(binding IdentityBinding) (Key, error) {
+	if binding.Identity == "" || binding.Tenant == "" {
+		return Key{}, ErrInvalidIdentity
+	}
+	if err := validateScopes(binding.Scopes); err != nil {
+		return Key{}, err
+	}
+
+	key := Key{
+		Id:        binding.Identity,
+		Kind:      KindCertificate,
+		Tenant:    binding.Tenant,
+		Scopes:    binding.Scopes,
+		CreatedAt: time.Now().UTC(),
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, exists := m.identities[binding.Identity]; exists {
+		return Key{}, ErrExists
+	}
+	m.identities[binding.Identity] = key
+	return key, nil
+}
+
+func (m *IdentityMap) Resolve(identity string) (Key, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	key, exists := m.identities[identity]
+	if !exists {
+		return Key{}, ErrUnauthorized
+	}
+	return key, nil
+}
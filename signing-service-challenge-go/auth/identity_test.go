@@ -0,0 +1,37 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestReadIdentityMap_Ok(t *testing.T) {
+	identities, err := ReadIdentityMap(strings.NewReader(`[
+		{"identity": "spiffe://mesh.example.com/ns/pos/sa/terminal", "tenant": "tenant1", "scopes": ["sign"]}
+	]`))
+	assertEqual(t, nil, err)
+
+	key, err := identities.Resolve("spiffe://mesh.example.com/ns/pos/sa/terminal")
+
+	assertEqual(t, nil, err)
+	assertEqual(t, KindCertificate, key.Kind)
+	assertEqual(t, "tenant1", key.Tenant)
+	assertEqual(t, true, key.HasScope(ScopeSign))
+}
+
+func TestReadIdentityMap_Invalid(t *testing.T) {
+	_, err := ReadIdentityMap(strings.NewReader(`[{"identity": "CN=pos", "tenant": "tenant1", "scopes": ["everything"]}]`))
+	assertErrorIs(t, ErrInvalidScope, err)
+
+	_, err = ReadIdentityMap(strings.NewReader(`[
+		{"identity": "CN=pos", "tenant": "tenant1", "scopes": ["sign"]},
+		{"identity": "CN=pos", "tenant": "tenant2", "scopes": ["sign"]}
+	]`))
+	assertErrorIs(t, ErrExists, err)
+}
+
+func TestResolve_ErrUnauthorized(t *testing.T) {
+	_, err := NewIdentityMap().Resolve("CN=unknown")
+
+	assertErrorIs(t, ErrUnauthorized, err)
+}
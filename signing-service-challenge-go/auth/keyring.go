@@ -0,0 +1,218 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrNotFound        = errors.New("not found")
+	ErrUnauthorized    = errors.New("invalid api key")
+	ErrInvalidScope    = errors.New("invalid scope")
+	ErrInvalidToken    = errors.New("malformed api key")
+	ErrExists          = errors.New("already exists")
+	ErrInvalidIdentity = errors.New("invalid identity binding")
+)
+
+type Scope string
+
+const (
+	ScopeDevicesRead  Scope = "devices:read"
+	ScopeDevicesWrite Scope = "devices:write"
+	ScopeSign         Scope = "sign"
+	// ScopeAdmin grants the management of the keys and webhooks of a tenant.
+	ScopeAdmin Scope = "admin"
+	// ScopePlatform grants the management of tenants and the audit log,
+	// which span all tenants.
+	ScopePlatform Scope = "platform"
+)
+
+// Scopes lists every known scope.
+var Scopes = []Scope{ScopeDevicesRead, ScopeDevicesWrite, ScopeSign, ScopeAdmin, ScopePlatform}
+
+// IsValid reports whether the scope is known.
+func (s Scope) IsValid() bool {
+	for _, known := range Scopes {
+		if known == s {
+			return true
+		}
+	}
+	return false
+}
+
+// Kind tells how a caller authenticated.
+type Kind string
+
+const (
+	KindAPIKey      Kind = "key"
+	KindCertificate Kind = "cert"
+)
+
+// Key is an API key of a tenant, or the identity of a client certificate
+// bound to a tenant. Only the SHA-256 of the secret of an API key is kept.
+type Key struct {
+	Id         string
+	Kind       Kind
+	Tenant     string
+	Name       string
+	Scopes     []Scope
+	SecretHash string
+	CreatedAt  time.Time
+	RevokedAt  time.Time
+}
+
+// HasScope reports whether the key grants scope.
+func (k Key) HasScope(scope Scope) bool {
+	for _, granted := range k.Scopes {
+		if granted == scope {
+			return true
+		}
+	}
+	return false
+}
+
+type IKeyring interface {
+	// Create issues a new key and returns it together with its token, which
+	// is not stored and cannot be retrieved again.
+	Create(tenant, name string, scopes []Scope) (Key, string, error)
+	// Import adds a key for a token that was issued elsewhere.
+	Import(tenant, name string, token string, scopes []Scope) (Key, error)
+	List(tenant string) []Key
+	Revoke(tenant, id string) (Key, error)
+	// Authenticate returns the active key matching a token.
+	Authenticate(token string) (Key, error)
+}
+
+type Keyring struct {
+	mu   sync.RWMutex
+	keys map[string]Key
+}
+
+func NewKeyring() IKeyring {
+	return &Keyring{
+		keys: make(map[string]Key),
+	}
+}
+
+// A token has the form <key id>.<secret>, so that the key can be looked up
+// before its hash is compared.
+func splitToken(token string) (string, string, error) {
+	id, secret, found := strings.Cut(token, ".")
+	if !found || id == "" || secret == "" {
+		return "", "", ErrInvalidToken
+	}
+	return id, secret, nil
+}
+
+func hashSecret(secret string) string {
+	digest := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(digest[:])
+}
+
+func validateScopes(scopes []Scope) error {
+	if len(scopes) == 0 {
+		return ErrInvalidScope
+	}
+	for _, scope := range scopes {
+		if !scope.IsValid() {
+			return ErrInvalidScope
+		}
+	}
+	return nil
+}
+
+func (k *Keyring) Create(tenant, name string, scopes []Scope) (Key, string, error) {
+	random := make([]byte, 32)
+	if _, err := rand.Read(random); err != nil {
+		return Key{}, "", err
+	}
+	token := uuid.NewString() + "." + base64.RawURLEncoding.EncodeToString(random)
+	key, err := k.Import(tenant, name, token, scopes)
+	if err != nil {
+		return Key{}, "", err
+	}
+	return key, token, nil
+}
+
+func (k *Keyring) Import(tenant, name string, token string, scopes []Scope) (Key, error) {
+	if err := validateScopes(scopes); err != nil {
+		return Key{}, err
+	}
+	id, secret, err := splitToken(token)
+	if err != nil {
+		return Key{}, err
+	}
+
+	key := Key{
+		Id:         id,
+		Kind:       KindAPIKey,
+		Tenant:     tenant,
+		Name:       name,
+		Scopes:     scopes,
+		SecretHash: hashSecret(secret),
+		CreatedAt:  time.Now().UTC(),
+	}
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if _, exists := k.keys[id]; exists {
+		return Key{}, ErrExists
+	}
+	k.keys[id] = key
+	return key, nil
+}
+
+func (k *Keyring) List(tenant string) []Key {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	keys := make([]Key, 0)
+	for _, key := range k.keys {
+		if key.Tenant == tenant {
+			keys = append(keys, key)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].CreatedAt.Before(keys[j].CreatedAt)
+	})
+	return keys
+}
+
+func (k *Keyring) Revoke(tenant, id string) (Key, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	key, exists := k.keys[id]
+	if !exists || key.Tenant != tenant {
+		return Key{}, ErrNotFound
+	}
+	if key.RevokedAt.IsZero() {
+		key.RevokedAt = time.Now().UTC()
+		k.keys[id] = key
+	}
+	return key, nil
+}
+
+func (k *Keyring) Authenticate(token string) (Key, error) {
+	id, secret, err := splitToken(token)
+	if err != nil {
+		return Key{}, ErrUnauthorized
+	}
+	k.mu.RLock()
+	key, exists := k.keys[id]
+	k.mu.RUnlock()
+	if !exists || !key.RevokedAt.IsZero() {
+		return Key{}, ErrUnauthorized
+	}
+	if subtle.ConstantTimeCompare([]byte(key.SecretHash), []byte(hashSecret(secret))) != 1 {
+		return Key{}, ErrUnauthorized
+	}
+	return key, nil
+}
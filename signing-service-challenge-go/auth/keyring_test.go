@@ -0,0 +1,93 @@
+package auth
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func assertEqual(t *testing.T, expected interface{}, actual interface{}) {
+	t.Helper()
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("Expected %v, actual %v", expected, actual)
+	}
+}
+
+func assertErrorIs(t *testing.T, expected error, actual error) {
+	t.Helper()
+	if !errors.Is(actual, expected) {
+		t.Errorf("Expected %v, actual %v", expected, actual)
+	}
+}
+
+func TestCreate_AuthenticatesToken(t *testing.T) {
+	keyring := NewKeyring()
+	key, token, err := keyring.Create("tenant1", "pos", []Scope{ScopeSign})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	authenticated, err := keyring.Authenticate(token)
+
+	assertEqual(t, nil, err)
+	assertEqual(t, key.Id, authenticated.Id)
+	assertEqual(t, "tenant1", authenticated.Tenant)
+	assertEqual(t, true, authenticated.HasScope(ScopeSign))
+	assertEqual(t, false, authenticated.HasScope(ScopeAdmin))
+	assertEqual(t, false, strings.Contains(key.SecretHash, strings.SplitN(token, ".", 2)[1]))
+}
+
+func TestCreate_InvalidScope(t *testing.T) {
+	keyring := NewKeyring()
+
+	_, _, err := keyring.Create("tenant1", "pos", []Scope{"devices:delete"})
+	assertErrorIs(t, ErrInvalidScope, err)
+
+	_, _, err = keyring.Create("tenant1", "pos", nil)
+	assertErrorIs(t, ErrInvalidScope, err)
+}
+
+func TestAuthenticate_Rejects(t *testing.T) {
+	keyring := NewKeyring()
+	key, token, _ := keyring.Create("tenant1", "pos", []Scope{ScopeSign})
+
+	for _, invalid := range []string{"", "garbage", key.Id + ".wrong", "unknown." + strings.SplitN(token, ".", 2)[1]} {
+		_, err := keyring.Authenticate(invalid)
+		assertErrorIs(t, ErrUnauthorized, err)
+	}
+}
+
+func TestRevoke(t *testing.T) {
+	keyring := NewKeyring()
+	key, token, _ := keyring.Create("tenant1", "pos", []Scope{ScopeSign})
+
+	revoked, err := keyring.Revoke("tenant1", key.Id)
+
+	assertEqual(t, nil, err)
+	assertEqual(t, false, revoked.RevokedAt.IsZero())
+	_, err = keyring.Authenticate(token)
+	assertErrorIs(t, ErrUnauthorized, err)
+	_, err = keyring.Revoke("tenant1", "unknown")
+	assertErrorIs(t, ErrNotFound, err)
+}
+
+func TestImport_Duplicate(t *testing.T) {
+	keyring := NewKeyring()
+	_, err := keyring.Import("tenant1", "admin", "admin.secret", Scopes)
+	assertEqual(t, nil, err)
+
+	_, err = keyring.Import("tenant1", "other", "admin.other", Scopes)
+	assertErrorIs(t, ErrExists, err)
+}
+
+func TestKeys_AreScopedByTenant(t *testing.T) {
+	keyring := NewKeyring()
+	key, _, _ := keyring.Create("tenant1", "pos", []Scope{ScopeSign})
+	_, _, _ = keyring.Create("tenant2", "pos", []Scope{ScopeSign})
+
+	assertEqual(t, 1, len(keyring.List("tenant1")))
+	assertEqual(t, key.Id, keyring.List("tenant1")[0].Id)
+	_, err := keyring.Revoke("tenant2", key.Id)
+	assertErrorIs(t, ErrNotFound, err)
+}
@@ -0,0 +1,42 @@
+// Command audit-verify checks the hash chain of an audit log written by the
+// file sink. Pass the head hash returned by GET /api/v0/audit to also detect
+// entries removed from the end of the log.
+//
+//	audit-verify [-head <hash>] [audit.log]
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/audit"
+)
+
+func main() {
+	head := flag.String("head", "", "hash of the newest entry known to be in the log")
+	flag.Parse()
+
+	var input io.Reader = os.Stdin
+	if flag.NArg() > 0 {
+		file, err := os.Open(flag.Arg(0))
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		defer file.Close()
+		input = file
+	}
+
+	entries, err := audit.ReadEntries(input)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "could not read audit log:", err)
+		os.Exit(2)
+	}
+	if err := audit.Verify(entries, *head); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	fmt.Printf("OK: %d entries verified\n", len(entries))
+}
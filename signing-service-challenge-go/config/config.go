@@ -0,0 +1,240 @@
+// Package config loads the configuration of the service. Every setting has
+// a default and can be set in a YAML or JSON file, by an environment
+// variable and by a flag, each source overriding the ones before it:
+//
+//	defaults < file < environment < flags
+//
+// The file is given with --config or CONFIG_FILE.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"time"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/crypto"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/ratelimit"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/transport"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/tsa"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrInvalid        = errors.New("invalid configuration")
+	ErrUnknownSetting = errors.New("unknown setting")
+	ErrUnknownFormat  = errors.New("unknown configuration file format")
+)
+
+const (
+	// BackendEventSourced persists devices as events in memory.
+	BackendEventSourced = "eventsourced"
+	// BackendMemory keeps the latest state of every device in memory.
+	BackendMemory = "memory"
+
+	// KeyProviderSoftware generates device keys in process from the
+	// system random source.
+	KeyProviderSoftware = "software"
+)
+
+type Config struct {
+	ListenAddress      string
+	Persistence        Persistence
+	Keys               Keys
+	TimeStampAuthority TimeStampAuthority
+	TLS                TLS
+	Limits             Limits
+	Auth               Auth
+	Audit              Audit
+	Tracing            Tracing
+	Shutdown           Shutdown
+}
+
+type Persistence struct {
+	Backend string
+}
+
+type Keys struct {
+	Provider string
+}
+
+type TimeStampAuthority struct {
+	// Id identifies the signature device dedicated to RFC 3161 time stamps.
+	Id        string
+	Algorithm string
+	// Policy is the OID of the TSA policy the tokens are issued under.
+	// Deployments should replace the default with one from their own arc.
+	Policy string
+}
+
+// TLS serves HTTPS if CertFile is set. Client certificates are verified
+// against ClientCAFile if ClientAuth is optional or require, and the
+// identities in ClientIdentitiesFile are authenticated.
+type TLS struct {
+	CertFile             string
+	KeyFile              string
+	ClientCAFile         string
+	ClientAuth           string
+	MinVersion           string
+	ClientIdentitiesFile string
+}
+
+// Limits holds the default rate limits as "<rate>:<burst>", empty for
+// unlimited, and the sizes of the worker pools.
+type Limits struct {
+	RateGlobal    string
+	RatePerKey    string
+	RatePerDevice string
+	// DefaultTenantMaxDevices limits the devices of the default tenant, 0
+	// means unlimited.
+	DefaultTenantMaxDevices int
+	JobWorkers              int
+	WebhookWorkers          int
+}
+
+type Auth struct {
+	// AdminAPIKey is imported as the admin key of the default tenant. If it
+	// is empty the token in AdminAPIKeyFile is imported, or one is issued
+	// and written to that file, readable by the owner only.
+	AdminAPIKey     string
+	AdminAPIKeyFile string
+	// PolicyFile holds the initial roles and role bindings. Without it the
+	// policy starts empty, so that callers without the admin scope are
+	// denied device operations until they are bound to a role.
+	PolicyFile string
+}
+
+// Audit keeps the audit log in memory unless LogFile is set.
+type Audit struct {
+	LogFile string
+}
+
+// Tracing exports spans to the OTLP/HTTP collector at OTLPEndpoint if it
+// is set.
+type Tracing struct {
+	OTLPEndpoint string
+	ServiceName  string
+}
+
+type Shutdown struct {
+	// Delay is how long /readyz fails before the listener is closed.
+	Delay time.Duration
+	// Timeout bounds the delay and the draining of requests.
+	Timeout time.Duration
+}
+
+// Default returns the configuration used for unset settings.
+func Default() Config {
+	return Config{
+		ListenAddress: ":8080",
+		Persistence:   Persistence{Backend: BackendEventSourced},
+		Keys:          Keys{Provider: KeyProviderSoftware},
+		TimeStampAuthority: TimeStampAuthority{
+			Id:        "00000000-0000-4000-8000-000000000001",
+			Algorithm: "ECC",
+			Policy:    "1.2.3.4.1",
+		},
+		Limits: Limits{
+			JobWorkers:     8,
+			WebhookWorkers: 4,
+		},
+		Auth:    Auth{AdminAPIKeyFile: "admin-api-key"},
+		Tracing: Tracing{ServiceName: "signing-service"},
+		Shutdown: Shutdown{
+			Delay:   5 * time.Second,
+			Timeout: 30 * time.Second,
+		},
+	}
+}
+
+// Validate reports every invalid setting at once.
+func (c Config) Validate() error {
+	var errs []error
+	invalid := func(format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf("%w: "+format, append([]interface{}{ErrInvalid}, args...)...))
+	}
+
+	if _, _, err := net.SplitHostPort(c.ListenAddress); err != nil {
+		invalid("listen_address: %q is not a host and port", c.ListenAddress)
+	}
+	switch c.Persistence.Backend {
+	case BackendEventSourced, BackendMemory:
+	default:
+		invalid("persistence.backend: %q is neither %q nor %q", c.Persistence.Backend, BackendEventSourced, BackendMemory)
+	}
+	if c.Keys.Provider != KeyProviderSoftware {
+		invalid("keys.provider: %q is not %q", c.Keys.Provider, KeyProviderSoftware)
+	}
+	if _, err := uuid.Parse(c.TimeStampAuthority.Id); err != nil {
+		invalid("time_stamp_authority.id: %q is not a UUID", c.TimeStampAuthority.Id)
+	}
+	if _, err := crypto.NewJOSEAlgorithm(c.TimeStampAuthority.Algorithm); err != nil {
+		invalid("time_stamp_authority.algorithm: %q is not supported", c.TimeStampAuthority.Algorithm)
+	}
+	if _, err := tsa.ParsePolicy(c.TimeStampAuthority.Policy); err != nil {
+		invalid("time_stamp_authority.policy: %q is not an OID", c.TimeStampAuthority.Policy)
+	}
+
+	if c.TLS.CertFile == "" {
+		if c.TLS != (TLS{}) {
+			invalid("tls: tls.cert_file is required by the other tls settings")
+		}
+	} else {
+		if c.TLS.KeyFile == "" {
+			invalid("tls.key_file: required with tls.cert_file")
+		}
+		switch transport.ClientAuth(c.TLS.ClientAuth) {
+		case "", transport.ClientAuthNone:
+		case transport.ClientAuthOptional, transport.ClientAuthRequire:
+			if c.TLS.ClientCAFile == "" {
+				invalid("tls.client_ca_file: required with tls.client_auth %q", c.TLS.ClientAuth)
+			}
+		default:
+			invalid("tls.client_auth: %q is not none, optional or require", c.TLS.ClientAuth)
+		}
+		if _, err := transport.ParseVersion(c.TLS.MinVersion); err != nil {
+			invalid("tls.min_version: %q is not 1.2 or 1.3", c.TLS.MinVersion)
+		}
+	}
+
+	for _, limit := range []struct{ name, value string }{
+		{"limits.rate_global", c.Limits.RateGlobal},
+		{"limits.rate_per_key", c.Limits.RatePerKey},
+		{"limits.rate_per_device", c.Limits.RatePerDevice},
+	} {
+		if _, err := ratelimit.ParseLimit(limit.value); err != nil {
+			invalid("%s: %q is not <rate>:<burst>", limit.name, limit.value)
+		}
+	}
+	if c.Auth.AdminAPIKey == "" && c.Auth.AdminAPIKeyFile == "" {
+		invalid("auth.admin_api_key_file: required without auth.admin_api_key")
+	}
+	if c.Limits.DefaultTenantMaxDevices < 0 {
+		invalid("limits.default_tenant_max_devices: %d is negative", c.Limits.DefaultTenantMaxDevices)
+	}
+	if c.Limits.JobWorkers < 1 {
+		invalid("limits.job_workers: %d is not positive", c.Limits.JobWorkers)
+	}
+	if c.Limits.WebhookWorkers < 1 {
+		invalid("limits.webhook_workers: %d is not positive", c.Limits.WebhookWorkers)
+	}
+
+	if c.Tracing.OTLPEndpoint != "" {
+		endpoint, err := url.Parse(c.Tracing.OTLPEndpoint)
+		if err != nil || (endpoint.Scheme != "http" && endpoint.Scheme != "https") || endpoint.Host == "" {
+			invalid("tracing.otlp_endpoint: %q is not an http or https URL", c.Tracing.OTLPEndpoint)
+		}
+	}
+	if c.Tracing.ServiceName == "" {
+		invalid("tracing.service_name: required")
+	}
+
+	if c.Shutdown.Delay < 0 {
+		invalid("shutdown.delay: %s is negative", c.Shutdown.Delay)
+	}
+	if c.Shutdown.Timeout <= c.Shutdown.Delay {
+		invalid("shutdown.timeout: %s leaves no time to drain after shutdown.delay %s", c.Shutdown.Timeout, c.Shutdown.Delay)
+	}
+	return errors.Join(errs...)
+}
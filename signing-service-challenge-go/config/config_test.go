@@ -0,0 +1,168 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func assertEqual(t *testing.T, expected interface{}, actual interface{}) {
+	t.Helper()
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("Expected %v, actual %v", expected, actual)
+	}
+}
+
+func environment(variables map[string]string) func(string) string {
+	return func(name string) string {
+		return variables[name]
+	}
+}
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoad_Defaults(t *testing.T) {
+	c, printConfig, err := Load(nil, environment(nil))
+
+	assertEqual(t, nil, err)
+	assertEqual(t, false, printConfig)
+	assertEqual(t, Default(), c)
+}
+
+func TestLoad_Precedence(t *testing.T) {
+	path := writeFile(t, "config.yaml", `
+listen_address: ":9000"
+limits:
+  job_workers: 2
+  webhook_workers: 3
+  rate_global: "1:1"
+`)
+
+	c, _, err := Load([]string{"--config", path, "--limits.job-workers", "5"}, environment(map[string]string{
+		"JOB_WORKERS":     "4",
+		"WEBHOOK_WORKERS": "6",
+	}))
+
+	assertEqual(t, nil, err)
+	assertEqual(t, ":9000", c.ListenAddress)
+	assertEqual(t, 5, c.Limits.JobWorkers)
+	assertEqual(t, 6, c.Limits.WebhookWorkers)
+	assertEqual(t, "1:1", c.Limits.RateGlobal)
+	assertEqual(t, Default().Shutdown, c.Shutdown)
+}
+
+func TestLoad_JSONFileFromEnvironment(t *testing.T) {
+	path := writeFile(t, "config.json", `{
+  "persistence": {"backend": "memory"},
+  "limits": {"default_tenant_max_devices": 10},
+  "shutdown": {"delay": "1s", "timeout": "10s"}
+}`)
+
+	c, _, err := Load(nil, environment(map[string]string{"CONFIG_FILE": path}))
+
+	assertEqual(t, nil, err)
+	assertEqual(t, BackendMemory, c.Persistence.Backend)
+	assertEqual(t, 10, c.Limits.DefaultTenantMaxDevices)
+	assertEqual(t, Shutdown{Delay: time.Second, Timeout: 10 * time.Second}, c.Shutdown)
+}
+
+func TestLoad_PrintConfig(t *testing.T) {
+	_, printConfig, err := Load([]string{"--print-config"}, environment(nil))
+
+	assertEqual(t, nil, err)
+	assertEqual(t, true, printConfig)
+}
+
+func TestLoad_UnknownSetting(t *testing.T) {
+	path := writeFile(t, "config.yaml", "tls:\n  cert: a\n")
+
+	_, _, err := Load([]string{"--config", path}, environment(nil))
+
+	assertEqual(t, true, errors.Is(err, ErrUnknownSetting))
+}
+
+func TestLoad_UnknownFormat(t *testing.T) {
+	path := writeFile(t, "config.toml", "")
+
+	_, _, err := Load([]string{"--config", path}, environment(nil))
+
+	assertEqual(t, true, errors.Is(err, ErrUnknownFormat))
+}
+
+func TestLoad_InvalidValue(t *testing.T) {
+	_, _, err := Load(nil, environment(map[string]string{"SHUTDOWN_DELAY": "5"}))
+
+	assertEqual(t, true, errors.Is(err, ErrInvalid))
+	assertEqual(t, true, strings.Contains(err.Error(), "$SHUTDOWN_DELAY"))
+}
+
+func TestValidate_ReportsEveryError(t *testing.T) {
+	c := Default()
+	c.ListenAddress = "8080"
+	c.Persistence.Backend = "postgres"
+	c.TimeStampAuthority.Policy = "policy"
+	c.TLS.ClientAuth = "require"
+	c.Auth.AdminAPIKeyFile = ""
+	c.Limits.JobWorkers = 0
+	c.Shutdown.Timeout = c.Shutdown.Delay
+
+	err := c.Validate()
+
+	assertEqual(t, true, errors.Is(err, ErrInvalid))
+	for _, name := range []string{"listen_address", "persistence.backend", "time_stamp_authority.policy", "tls:", "auth.admin_api_key_file", "limits.job_workers", "shutdown.timeout"} {
+		if !strings.Contains(err.Error(), name) {
+			t.Errorf("Expected an error for %s, actual %v", name, err)
+		}
+	}
+}
+
+func TestValidate_TLS(t *testing.T) {
+	c := Default()
+	c.TLS = TLS{CertFile: "cert.pem", ClientAuth: "optional", MinVersion: "1.1"}
+
+	err := c.Validate()
+
+	for _, name := range []string{"tls.key_file", "tls.client_ca_file", "tls.min_version"} {
+		if !strings.Contains(err.Error(), name) {
+			t.Errorf("Expected an error for %s, actual %v", name, err)
+		}
+	}
+}
+
+func TestWrite_RedactsSecrets(t *testing.T) {
+	c := Default()
+	c.Auth.AdminAPIKey = "secret"
+	var written bytes.Buffer
+
+	assertEqual(t, nil, c.Write(&written))
+
+	assertEqual(t, false, strings.Contains(written.String(), "secret"))
+	assertEqual(t, true, strings.Contains(written.String(), `"admin_api_key": "REDACTED"`))
+}
+
+func TestWrite_CanBeLoaded(t *testing.T) {
+	c := Default()
+	c.TLS = TLS{CertFile: "cert.pem", KeyFile: "key.pem", MinVersion: "1.3"}
+	c.Limits.RatePerDevice = "5:10"
+	c.Shutdown.Delay = 0
+	var written bytes.Buffer
+	assertEqual(t, nil, c.Write(&written))
+	path := writeFile(t, "config.json", written.String())
+
+	loaded, _, err := Load([]string{"--config", path}, environment(nil))
+
+	assertEqual(t, nil, err)
+	assertEqual(t, c, loaded)
+}
@@ -0,0 +1,282 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FileEnv names the configuration file if --config is not given.
+const FileEnv = "CONFIG_FILE"
+
+// redacted replaces the value of secrets when the configuration is written.
+const redacted = "REDACTED"
+
+// setting binds a field of the Config to its name in files, e.g.
+// "tls.cert_file", its environment variable and its flag.
+type setting struct {
+	name   string
+	env    string
+	usage  string
+	secret bool
+	// field returns a *string, *int or *time.Duration into c.
+	field func(c *Config) interface{}
+}
+
+var settings = []setting{
+	{name: "listen_address", env: "LISTEN_ADDRESS", usage: "address the API listens on",
+		field: func(c *Config) interface{} { return &c.ListenAddress }},
+	{name: "persistence.backend", env: "PERSISTENCE_BACKEND", usage: "device storage: eventsourced or memory",
+		field: func(c *Config) interface{} { return &c.Persistence.Backend }},
+	{name: "keys.provider", env: "KEY_PROVIDER", usage: "source of device keys: software",
+		field: func(c *Config) interface{} { return &c.Keys.Provider }},
+	{name: "time_stamp_authority.id", env: "TSA_ID", usage: "id of the time-stamp authority device",
+		field: func(c *Config) interface{} { return &c.TimeStampAuthority.Id }},
+	{name: "time_stamp_authority.algorithm", env: "TSA_ALGORITHM", usage: "algorithm of the time-stamp authority: ECC or RSA",
+		field: func(c *Config) interface{} { return &c.TimeStampAuthority.Algorithm }},
+	{name: "time_stamp_authority.policy", env: "TSA_POLICY", usage: "OID of the TSA policy the time stamps are issued under",
+		field: func(c *Config) interface{} { return &c.TimeStampAuthority.Policy }},
+	{name: "tls.cert_file", env: "TLS_CERT_FILE", usage: "PEM certificate, serves HTTPS if set",
+		field: func(c *Config) interface{} { return &c.TLS.CertFile }},
+	{name: "tls.key_file", env: "TLS_KEY_FILE", usage: "PEM private key of the certificate",
+		field: func(c *Config) interface{} { return &c.TLS.KeyFile }},
+	{name: "tls.client_ca_file", env: "TLS_CLIENT_CA_FILE", usage: "PEM CAs client certificates are verified against",
+		field: func(c *Config) interface{} { return &c.TLS.ClientCAFile }},
+	{name: "tls.client_auth", env: "TLS_CLIENT_AUTH", usage: "client certificates: none, optional or require",
+		field: func(c *Config) interface{} { return &c.TLS.ClientAuth }},
+	{name: "tls.min_version", env: "TLS_MIN_VERSION", usage: "minimum TLS version: 1.2 or 1.3",
+		field: func(c *Config) interface{} { return &c.TLS.MinVersion }},
+	{name: "tls.client_identities_file", env: "CLIENT_IDENTITIES_FILE", usage: "identities of client certificates",
+		field: func(c *Config) interface{} { return &c.TLS.ClientIdentitiesFile }},
+	{name: "limits.rate_global", env: "RATE_LIMIT_GLOBAL", usage: "global signing rate limit as <rate>:<burst>",
+		field: func(c *Config) interface{} { return &c.Limits.RateGlobal }},
+	{name: "limits.rate_per_key", env: "RATE_LIMIT_PER_KEY", usage: "signing rate limit per API key as <rate>:<burst>",
+		field: func(c *Config) interface{} { return &c.Limits.RatePerKey }},
+	{name: "limits.rate_per_device", env: "RATE_LIMIT_PER_DEVICE", usage: "signing rate limit per device as <rate>:<burst>",
+		field: func(c *Config) interface{} { return &c.Limits.RatePerDevice }},
+	{name: "limits.default_tenant_max_devices", env: "DEFAULT_TENANT_MAX_DEVICES", usage: "device limit of the default tenant, 0 is unlimited",
+		field: func(c *Config) interface{} { return &c.Limits.DefaultTenantMaxDevices }},
+	{name: "limits.job_workers", env: "JOB_WORKERS", usage: "workers signing asynchronous jobs",
+		field: func(c *Config) interface{} { return &c.Limits.JobWorkers }},
+	{name: "limits.webhook_workers", env: "WEBHOOK_WORKERS", usage: "workers delivering webhooks",
+		field: func(c *Config) interface{} { return &c.Limits.WebhookWorkers }},
+	{name: "auth.admin_api_key", env: "ADMIN_API_KEY", usage: "admin API key of the default tenant", secret: true,
+		field: func(c *Config) interface{} { return &c.Auth.AdminAPIKey }},
+	{name: "auth.admin_api_key_file", env: "ADMIN_API_KEY_FILE", usage: "file the issued admin API key is written to if none is given",
+		field: func(c *Config) interface{} { return &c.Auth.AdminAPIKeyFile }},
+	{name: "auth.policy_file", env: "POLICY_FILE", usage: "roles and role bindings of the access policy",
+		field: func(c *Config) interface{} { return &c.Auth.PolicyFile }},
+	{name: "audit.log_file", env: "AUDIT_LOG_FILE", usage: "audit log file, kept in memory if unset",
+		field: func(c *Config) interface{} { return &c.Audit.LogFile }},
+	{name: "tracing.otlp_endpoint", env: "OTEL_EXPORTER_OTLP_ENDPOINT", usage: "OTLP/HTTP collector spans are exported to",
+		field: func(c *Config) interface{} { return &c.Tracing.OTLPEndpoint }},
+	{name: "tracing.service_name", env: "OTEL_SERVICE_NAME", usage: "service name of the exported spans",
+		field: func(c *Config) interface{} { return &c.Tracing.ServiceName }},
+	{name: "shutdown.delay", env: "SHUTDOWN_DELAY", usage: "how long /readyz fails before the listener is closed",
+		field: func(c *Config) interface{} { return &c.Shutdown.Delay }},
+	{name: "shutdown.timeout", env: "SHUTDOWN_TIMEOUT", usage: "bound of the shutdown delay and the draining of requests",
+		field: func(c *Config) interface{} { return &c.Shutdown.Timeout }},
+}
+
+// flagName turns "tls.cert_file" into "tls.cert-file".
+func (s setting) flagName() string {
+	return strings.ReplaceAll(s.name, "_", "-")
+}
+
+func (s setting) set(c *Config, value string) error {
+	switch field := s.field(c).(type) {
+	case *string:
+		*field = value
+	case *int:
+		parsed, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("%w: %s: %q is not an integer", ErrInvalid, s.name, value)
+		}
+		*field = parsed
+	case *time.Duration:
+		parsed, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("%w: %s: %q is not a duration", ErrInvalid, s.name, value)
+		}
+		*field = parsed
+	}
+	return nil
+}
+
+func (s setting) get(c *Config) interface{} {
+	switch field := s.field(c).(type) {
+	case *string:
+		if s.secret && *field != "" {
+			return redacted
+		}
+		return *field
+	case *int:
+		return *field
+	case *time.Duration:
+		return field.String()
+	}
+	return nil
+}
+
+func lookup(name string) (setting, bool) {
+	for _, s := range settings {
+		if s.name == name {
+			return s, true
+		}
+	}
+	return setting{}, false
+}
+
+// Load parses the command line arguments, reads the file they or the
+// environment name and returns the validated configuration. printConfig
+// reports whether --print-config was given.
+func Load(args []string, getenv func(string) string) (c Config, printConfig bool, err error) {
+	flags := flag.NewFlagSet("signing-service", flag.ContinueOnError)
+	file := flags.String("config", "", "YAML or JSON configuration file ($"+FileEnv+")")
+	flags.BoolVar(&printConfig, "print-config", false, "print the configuration with secrets redacted and exit")
+	type flagValue struct {
+		setting setting
+		value   string
+	}
+	values := make([]flagValue, 0)
+	for _, s := range settings {
+		s := s
+		flags.Func(s.flagName(), s.usage+" ($"+s.env+")", func(value string) error {
+			values = append(values, flagValue{setting: s, value: value})
+			return nil
+		})
+	}
+	if err := flags.Parse(args); err != nil {
+		return Config{}, false, err
+	}
+	if flags.NArg() > 0 {
+		return Config{}, false, fmt.Errorf("%w: unexpected argument %q", ErrInvalid, flags.Arg(0))
+	}
+
+	c = Default()
+	if *file == "" {
+		*file = getenv(FileEnv)
+	}
+	if *file != "" {
+		if err := c.readFile(*file); err != nil {
+			return Config{}, false, err
+		}
+	}
+	for _, s := range settings {
+		if value := getenv(s.env); value != "" {
+			if err := s.set(&c, value); err != nil {
+				return Config{}, false, fmt.Errorf("%w (from $%s)", err, s.env)
+			}
+		}
+	}
+	for _, value := range values {
+		if err := value.setting.set(&c, value.value); err != nil {
+			return Config{}, false, err
+		}
+	}
+	if err := c.Validate(); err != nil {
+		return Config{}, false, err
+	}
+	return c, printConfig, nil
+}
+
+// readFile sets the settings of a YAML or JSON file, told apart by its
+// extension.
+func (c *Config) readFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var document map[string]interface{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.UseNumber()
+		err = decoder.Decode(&document)
+	case ".yaml", ".yml":
+		document, err = parseYAML(data)
+	default:
+		return fmt.Errorf("%w: %s", ErrUnknownFormat, path)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	values := make(map[string]string)
+	if err := flatten("", document, values); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		s, ok := lookup(name)
+		if !ok {
+			return fmt.Errorf("%s: %w: %s", path, ErrUnknownSetting, name)
+		}
+		if err := s.set(c, values[name]); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	return nil
+}
+
+// flatten names the scalars of nested objects by their path, e.g.
+// {"tls": {"cert_file": "a"}} becomes "tls.cert_file": "a". Nulls are left
+// unset.
+func flatten(prefix string, document map[string]interface{}, values map[string]string) error {
+	for key, value := range document {
+		name := prefix + key
+		switch value := value.(type) {
+		case map[string]interface{}:
+			if err := flatten(name+".", value, values); err != nil {
+				return err
+			}
+		case string:
+			values[name] = value
+		case json.Number:
+			values[name] = value.String()
+		case bool:
+			values[name] = strconv.FormatBool(value)
+		case nil:
+		default:
+			return fmt.Errorf("%w: %s: lists are not supported", ErrInvalid, name)
+		}
+	}
+	return nil
+}
+
+// Write writes the configuration as a JSON configuration file. Secrets
+// are redacted.
+func (c Config) Write(writer io.Writer) error {
+	document := make(map[string]interface{})
+	for _, s := range settings {
+		object := document
+		path := strings.Split(s.name, ".")
+		for _, key := range path[:len(path)-1] {
+			nested, ok := object[key].(map[string]interface{})
+			if !ok {
+				nested = make(map[string]interface{})
+				object[key] = nested
+			}
+			object = nested
+		}
+		object[path[len(path)-1]] = s.get(&c)
+	}
+	encoded, err := json.MarshalIndent(document, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = writer.Write(append(encoded, '\n'))
+	return err
+}
@@ -0,0 +1,191 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+var ErrInvalidYAML = errors.New("invalid yaml")
+
+// parseYAML parses the subset of YAML configuration files need: nested
+// mappings of plain, single- and double-quoted scalars, and comments.
+// Scalars are returned as strings, null scalars as nil. Lists, flow
+// collections, block scalars, anchors and tags are rejected.
+func parseYAML(data []byte) (map[string]interface{}, error) {
+	type level struct {
+		indent int
+		values map[string]interface{}
+	}
+	root := make(map[string]interface{})
+	levels := []level{{indent: 0, values: root}}
+	// parent is the key of the mapping the next line may open.
+	parent := ""
+
+	for number, line := range strings.Split(string(data), "\n") {
+		number++
+		line = stripComment(strings.TrimRight(line, "\r"))
+		text := strings.TrimSpace(line)
+		if text == "" || (text == "---" && number == 1) {
+			continue
+		}
+		indent := len(line) - len(strings.TrimLeft(line, " "))
+		if strings.HasPrefix(line[indent:], "\t") {
+			return nil, fmt.Errorf("%w: line %d: tabs are not allowed for indentation", ErrInvalidYAML, number)
+		}
+		if text == "-" || strings.HasPrefix(text, "- ") {
+			return nil, fmt.Errorf("%w: line %d: lists are not supported", ErrInvalidYAML, number)
+		}
+
+		current := levels[len(levels)-1]
+		if parent != "" && indent > current.indent {
+			nested := make(map[string]interface{})
+			current.values[parent] = nested
+			current = level{indent: indent, values: nested}
+			levels = append(levels, current)
+		}
+		parent = ""
+		for indent < current.indent {
+			levels = levels[:len(levels)-1]
+			current = levels[len(levels)-1]
+		}
+		if indent != current.indent {
+			return nil, fmt.Errorf("%w: line %d: unexpected indentation", ErrInvalidYAML, number)
+		}
+
+		key, value, ok := splitMapping(text)
+		if !ok {
+			return nil, fmt.Errorf("%w: line %d: expected <key>: <value>", ErrInvalidYAML, number)
+		}
+		if _, exists := current.values[key]; exists {
+			return nil, fmt.Errorf("%w: line %d: duplicate key %q", ErrInvalidYAML, number, key)
+		}
+		if value == "" {
+			current.values[key] = nil
+			parent = key
+			continue
+		}
+		scalar, err := parseScalar(value)
+		if err != nil {
+			return nil, fmt.Errorf("%w: line %d: %v", ErrInvalidYAML, number, err)
+		}
+		current.values[key] = scalar
+	}
+	return root, nil
+}
+
+// stripComment removes a comment, which starts with a # at the start of the
+// line or after a space, outside of quoted scalars. Only quotes at the start
+// of a key or value begin a quoted scalar.
+func stripComment(line string) string {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		switch {
+		case quote == '"' && line[i] == '\\':
+			i++
+		case quote != 0:
+			if line[i] == quote {
+				quote = 0
+			}
+		case line[i] == '"' || line[i] == '\'':
+			if before := strings.TrimRight(line[:i], " "); before == "" || strings.HasSuffix(before, ":") {
+				quote = line[i]
+			}
+		case line[i] == '#' && (i == 0 || line[i-1] == ' '):
+			return line[:i]
+		}
+	}
+	return line
+}
+
+// splitMapping splits "key: value" and "key:".
+func splitMapping(text string) (string, string, bool) {
+	key, value, found := strings.Cut(text, ": ")
+	if !found {
+		if !strings.HasSuffix(text, ":") {
+			return "", "", false
+		}
+		key = strings.TrimSuffix(text, ":")
+	}
+	key = strings.TrimSpace(key)
+	if key == "" || strings.ContainsAny(key[:1], "\"'{[&*!|>-") {
+		return "", "", false
+	}
+	return key, strings.TrimSpace(value), true
+}
+
+func parseScalar(value string) (interface{}, error) {
+	switch {
+	case value[0] == '"':
+		unquoted, ok := unquoteDouble(value)
+		if !ok {
+			return nil, fmt.Errorf("invalid double-quoted scalar %s", value)
+		}
+		return unquoted, nil
+	case value[0] == '\'':
+		if len(value) < 2 || value[len(value)-1] != '\'' {
+			return nil, fmt.Errorf("invalid single-quoted scalar %s", value)
+		}
+		return strings.ReplaceAll(value[1:len(value)-1], "''", "'"), nil
+	case strings.ContainsAny(value[:1], "[{|>&*!"):
+		return nil, fmt.Errorf("unsupported value %s", value)
+	case value == "~" || value == "null":
+		return nil, nil
+	default:
+		return value, nil
+	}
+}
+
+// yamlEscapes maps the single character escapes of double-quoted scalars
+// to what they stand for.
+var yamlEscapes = map[byte]string{
+	'0': "\x00", 'a': "\a", 'b': "\b", 't': "\t", '\t': "\t", 'n': "\n",
+	'v': "\v", 'f': "\f", 'r': "\r", 'e': "\x1b", ' ': " ", '"': "\"",
+	'/': "/", '\\': "\\", 'N': "\u0085", '_': "\u00a0", 'L': "\u2028",
+	'P': "\u2029",
+}
+
+// yamlHexEscapes maps the escapes of code points to their number of hex
+// digits.
+var yamlHexEscapes = map[byte]int{'x': 2, 'u': 4, 'U': 8}
+
+// unquoteDouble decodes a double-quoted scalar with the escapes of YAML
+// 1.2, which differ from those of Go. Scalars cannot span lines.
+func unquoteDouble(value string) (string, bool) {
+	if len(value) < 2 || value[len(value)-1] != '"' {
+		return "", false
+	}
+	value = value[1 : len(value)-1]
+	var unquoted strings.Builder
+	for i := 0; i < len(value); i++ {
+		switch value[i] {
+		case '"':
+			return "", false
+		case '\\':
+		default:
+			unquoted.WriteByte(value[i])
+			continue
+		}
+		i++
+		if i == len(value) {
+			return "", false
+		}
+		if escaped, ok := yamlEscapes[value[i]]; ok {
+			unquoted.WriteString(escaped)
+			continue
+		}
+		digits, ok := yamlHexEscapes[value[i]]
+		if !ok || i+digits >= len(value) {
+			return "", false
+		}
+		code, err := strconv.ParseUint(value[i+1:i+1+digits], 16, 32)
+		if err != nil || !utf8.ValidRune(rune(code)) {
+			return "", false
+		}
+		unquoted.WriteRune(rune(code))
+		i += digits
+	}
+	return unquoted.String(), true
+}
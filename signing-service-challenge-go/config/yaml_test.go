@@ -0,0 +1,83 @@
+package config
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseYAML_Ok(t *testing.T) {
+	document, err := parseYAML([]byte(`---
+# Signing service
+listen_address: ":8443"   # quoted, a plain scalar may not start with ":"
+tls:
+  cert_file: /etc/tls/cert.pem
+  min_version: 1.3
+  client_auth: 'require'
+limits:
+
+    job_workers: 16 # deeper indentation is fine
+    rate_global: "10:20"
+audit:
+tracing:
+  service_name: it's # a comment
+  otlp_endpoint: ~
+`))
+
+	assertEqual(t, nil, err)
+	assertEqual(t, map[string]interface{}{
+		"listen_address": ":8443",
+		"tls": map[string]interface{}{
+			"cert_file":   "/etc/tls/cert.pem",
+			"min_version": "1.3",
+			"client_auth": "require",
+		},
+		"limits": map[string]interface{}{
+			"job_workers": "16",
+			"rate_global": "10:20",
+		},
+		"audit": nil,
+		"tracing": map[string]interface{}{
+			"service_name":  "it's",
+			"otlp_endpoint": nil,
+		},
+	}, document)
+}
+
+func TestParseYAML_DoubleQuotedEscapes(t *testing.T) {
+	document, err := parseYAML([]byte(`path: "a\/b\\c\"d"
+control: "\0\a\b\t\	\n\v\f\r\e\ "
+unicode: "\N\_\L\P"
+code_points: "\x41\u00e9\U0001F600"
+`))
+
+	assertEqual(t, nil, err)
+	assertEqual(t, map[string]interface{}{
+		"path":        "a/b\\c\"d",
+		"control":     "\x00\a\b\t\t\n\v\f\r\x1b ",
+		"unicode":     "\u0085\u00a0\u2028\u2029",
+		"code_points": "A\u00e9\U0001F600",
+	}, document)
+}
+
+func TestParseYAML_Invalid(t *testing.T) {
+	for _, document := range []string{
+		"tls:\n  - a\n",
+		"tls:\n\tcert_file: a\n",
+		"tls:\n  cert_file: a\n   key_file: b\n",
+		"listen_address\n",
+		"listen_address: a\nlisten_address: b\n",
+		"tls: {cert_file: a}\n",
+		"auth:\n  admin_api_key: |\n",
+		"listen_address: \"unterminated\n",
+		"listen_address: \"a\"b\"\n",
+		"listen_address: \"\\q\"\n",
+		"listen_address: \"\\x4\"\n",
+		"listen_address: \"\\uD800\"\n",
+		"listen_address: \"\\\"\n",
+	} {
+		_, err := parseYAML([]byte(document))
+		if !errors.Is(err, ErrInvalidYAML) {
+			t.Errorf("Expected %v for %q, actual %v", ErrInvalidYAML, document, err)
+		}
+	}
+}
@@ -0,0 +1,196 @@
+package crypto
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+)
+
+// This file implements the small subset of CBOR (RFC 8949) needed for COSE:
+// integers, byte and text strings, arrays, maps and tags of definite length.
+
+const (
+	cborUnsigned = 0
+	cborNegative = 1
+	cborBytes    = 2
+	cborText     = 3
+	cborArray    = 4
+	cborMap      = 5
+	cborTag      = 6
+
+	// cborMaxDepth bounds the nesting of decoded items.
+	cborMaxDepth = 16
+)
+
+var errCBOR = errors.New("invalid cbor")
+
+type cborEncoder struct {
+	buffer bytes.Buffer
+}
+
+func (e *cborEncoder) head(major byte, n uint64) {
+	switch {
+	case n < 24:
+		e.buffer.WriteByte(major<<5 | byte(n))
+	case n <= 0xff:
+		e.buffer.Write([]byte{major<<5 | 24, byte(n)})
+	case n <= 0xffff:
+		e.buffer.WriteByte(major<<5 | 25)
+		e.buffer.Write(binary.BigEndian.AppendUint16(nil, uint16(n)))
+	case n <= 0xffffffff:
+		e.buffer.WriteByte(major<<5 | 26)
+		e.buffer.Write(binary.BigEndian.AppendUint32(nil, uint32(n)))
+	default:
+		e.buffer.WriteByte(major<<5 | 27)
+		e.buffer.Write(binary.BigEndian.AppendUint64(nil, n))
+	}
+}
+
+func (e *cborEncoder) int(n int64) {
+	if n < 0 {
+		e.head(cborNegative, uint64(-1-n))
+		return
+	}
+	e.head(cborUnsigned, uint64(n))
+}
+
+func (e *cborEncoder) bytes(b []byte) {
+	e.head(cborBytes, uint64(len(b)))
+	e.buffer.Write(b)
+}
+
+func (e *cborEncoder) text(s string) {
+	e.head(cborText, uint64(len(s)))
+	e.buffer.WriteString(s)
+}
+
+func (e *cborEncoder) array(length int) {
+	e.head(cborArray, uint64(length))
+}
+
+func (e *cborEncoder) mapHeader(length int) {
+	e.head(cborMap, uint64(length))
+}
+
+func (e *cborEncoder) tag(number uint64) {
+	e.head(cborTag, number)
+}
+
+func (e *cborEncoder) Bytes() []byte {
+	return e.buffer.Bytes()
+}
+
+// cborTagged is a decoded tagged item.
+type cborTagged struct {
+	Number  uint64
+	Content any
+}
+
+// cborDecode decodes a single item. Integers are returned as int64, maps as
+// map[any]any keyed by int64 or string.
+func cborDecode(data []byte) (any, error) {
+	value, rest, err := cborDecodeItem(data, 0)
+	if err != nil {
+		return nil, err
+	}
+	if len(rest) > 0 {
+		return nil, errCBOR
+	}
+	return value, nil
+}
+
+func cborDecodeItem(data []byte, depth int) (any, []byte, error) {
+	if len(data) == 0 || depth > cborMaxDepth {
+		return nil, nil, errCBOR
+	}
+	major := data[0] >> 5
+	n, data, err := cborDecodeArgument(data)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	switch major {
+	case cborUnsigned:
+		if n > 1<<63-1 {
+			return nil, nil, errCBOR
+		}
+		return int64(n), data, nil
+	case cborNegative:
+		if n > 1<<63-1 {
+			return nil, nil, errCBOR
+		}
+		return -1 - int64(n), data, nil
+	case cborBytes, cborText:
+		if uint64(len(data)) < n {
+			return nil, nil, errCBOR
+		}
+		if major == cborText {
+			return string(data[:n]), data[n:], nil
+		}
+		return append([]byte{}, data[:n]...), data[n:], nil
+	case cborArray:
+		if uint64(len(data)) < n {
+			return nil, nil, errCBOR
+		}
+		items := make([]any, 0, n)
+		for i := uint64(0); i < n; i++ {
+			var item any
+			item, data, err = cborDecodeItem(data, depth+1)
+			if err != nil {
+				return nil, nil, err
+			}
+			items = append(items, item)
+		}
+		return items, data, nil
+	case cborMap:
+		if uint64(len(data)) < 2*n {
+			return nil, nil, errCBOR
+		}
+		items := make(map[any]any, n)
+		for i := uint64(0); i < n; i++ {
+			var key, value any
+			key, data, err = cborDecodeItem(data, depth+1)
+			if err != nil {
+				return nil, nil, err
+			}
+			switch key.(type) {
+			case int64, string:
+			default:
+				return nil, nil, errCBOR
+			}
+			value, data, err = cborDecodeItem(data, depth+1)
+			if err != nil {
+				return nil, nil, err
+			}
+			items[key] = value
+		}
+		return items, data, nil
+	case cborTag:
+		content, data, err := cborDecodeItem(data, depth+1)
+		if err != nil {
+			return nil, nil, err
+		}
+		return cborTagged{Number: n, Content: content}, data, nil
+	default:
+		return nil, nil, errCBOR
+	}
+}
+
+func cborDecodeArgument(data []byte) (uint64, []byte, error) {
+	info := data[0] & 0x1f
+	data = data[1:]
+	switch {
+	case info < 24:
+		return uint64(info), data, nil
+	case info == 24 && len(data) >= 1:
+		return uint64(data[0]), data[1:], nil
+	case info == 25 && len(data) >= 2:
+		return uint64(binary.BigEndian.Uint16(data)), data[2:], nil
+	case info == 26 && len(data) >= 4:
+		return uint64(binary.BigEndian.Uint32(data)), data[4:], nil
+	case info == 27 && len(data) >= 8:
+		return binary.BigEndian.Uint64(data), data[8:], nil
+	default:
+		return 0, nil, errCBOR
+	}
+}
@@ -0,0 +1,90 @@
+package crypto
+
+import (
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"math/big"
+	"time"
+)
+
+var (
+	oidExtensionExtendedKeyUsage = asn1.ObjectIdentifier{2, 5, 29, 37}
+	oidExtKeyUsageTimeStamping   = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 3, 8}
+	oidSignatureECDSAWithSHA256  = asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 2}
+	oidSignatureSHA256WithRSA    = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 11}
+)
+
+// SignatureAlgorithmIdentifier returns the X.509 algorithm identifier
+// matching the signatures produced by the Signer of the given algorithm.
+func SignatureAlgorithmIdentifier(algorithm string) (pkix.AlgorithmIdentifier, error) {
+	switch algorithm {
+	case "ECC":
+		return pkix.AlgorithmIdentifier{
+			Algorithm: oidSignatureECDSAWithSHA256,
+		}, nil
+	case "RSA":
+		return pkix.AlgorithmIdentifier{
+			Algorithm:  oidSignatureSHA256WithRSA,
+			Parameters: asn1.NullRawValue,
+		}, nil
+	default:
+		return pkix.AlgorithmIdentifier{}, ErrInvalidAlgorithm
+	}
+}
+
+// NewTimeStampingCertificate issues a self-signed certificate for an encoded
+// private key that is restricted to time stamping as required by RFC 3161.
+// It returns the DER encoded certificate.
+func NewTimeStampingCertificate(algorithm string, privateKey []byte, commonName string, notBefore time.Time) ([]byte, error) {
+	var public, private any
+	switch algorithm {
+	case "ECC":
+		marshaller := NewECCMarshaler()
+		keyPair, err := marshaller.Decode(privateKey)
+		if err != nil {
+			return nil, ErrDecode
+		}
+		public, private = keyPair.Public, keyPair.Private
+	case "RSA":
+		marshaller := NewRSAMarshaler()
+		keyPair, err := marshaller.Unmarshal(privateKey)
+		if err != nil {
+			return nil, ErrDecode
+		}
+		public, private = keyPair.Public, keyPair.Private
+	default:
+		return nil, ErrInvalidAlgorithm
+	}
+
+	serialNumber, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 127))
+	if err != nil {
+		return nil, err
+	}
+
+	// The extended key usage has to be critical for time stamping certificates,
+	// which x509.CreateCertificate does not do for us.
+	extendedKeyUsage, err := asn1.Marshal([]asn1.ObjectIdentifier{oidExtKeyUsageTimeStamping})
+	if err != nil {
+		return nil, err
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          serialNumber,
+		Subject:               pkix.Name{CommonName: commonName},
+		NotBefore:             notBefore,
+		NotAfter:              notBefore.AddDate(10, 0, 0),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		ExtraExtensions: []pkix.Extension{
+			{
+				Id:       oidExtensionExtendedKeyUsage,
+				Critical: true,
+				Value:    extendedKeyUsage,
+			},
+		},
+	}
+
+	return x509.CreateCertificate(rand.Reader, template, template, public, private)
+}
@@ -0,0 +1,139 @@
+package crypto
+
+const (
+	coseSign1Tag = 18
+
+	coseHeaderAlgorithm = 1
+	coseHeaderKeyId     = 4
+	// coseHeaderCounter is a private text label for the signature counter.
+	coseHeaderCounter = "ctr"
+)
+
+// COSEHeader is the protected header of the COSE_Sign1 created for a transaction.
+type COSEHeader struct {
+	Algorithm int
+	KeyId     string
+	Counter   int
+}
+
+// COSESign1 is a decoded COSE_Sign1 structure (RFC 9052).
+type COSESign1 struct {
+	protected []byte
+	payload   []byte
+	signature []byte
+}
+
+// NewCOSESign1 signs the payload with the header as protected header.
+// The algorithm header is always taken from the algorithm.
+func NewCOSESign1(header COSEHeader, payload []byte, algorithm JOSEAlgorithm, signer Signer) (COSESign1, error) {
+	protected := cborEncoder{}
+	protected.mapHeader(3)
+	protected.int(coseHeaderAlgorithm)
+	protected.int(int64(algorithm.COSE))
+	protected.int(coseHeaderKeyId)
+	protected.bytes([]byte(header.KeyId))
+	protected.text(coseHeaderCounter)
+	protected.int(int64(header.Counter))
+
+	message := COSESign1{
+		protected: protected.Bytes(),
+		payload:   payload,
+	}
+	signature, err := algorithm.sign(signer, message.sigStructure())
+	if err != nil {
+		return COSESign1{}, err
+	}
+	message.signature = signature
+	return message, nil
+}
+
+// ParseCOSESign1 decodes a tagged or untagged COSE_Sign1.
+func ParseCOSESign1(data []byte) (COSESign1, error) {
+	value, err := cborDecode(data)
+	if err != nil {
+		return COSESign1{}, ErrMalformed
+	}
+	if tagged, ok := value.(cborTagged); ok {
+		if tagged.Number != coseSign1Tag {
+			return COSESign1{}, ErrMalformed
+		}
+		value = tagged.Content
+	}
+
+	items, ok := value.([]any)
+	if !ok || len(items) != 4 {
+		return COSESign1{}, ErrMalformed
+	}
+	protected, ok := items[0].([]byte)
+	if !ok {
+		return COSESign1{}, ErrMalformed
+	}
+	payload, ok := items[2].([]byte)
+	if !ok {
+		return COSESign1{}, ErrMalformed
+	}
+	signature, ok := items[3].([]byte)
+	if !ok {
+		return COSESign1{}, ErrMalformed
+	}
+	return COSESign1{
+		protected: protected,
+		payload:   payload,
+		signature: signature,
+	}, nil
+}
+
+// Encode returns the tagged CBOR encoding of the COSE_Sign1.
+func (m COSESign1) Encode() []byte {
+	encoder := cborEncoder{}
+	encoder.tag(coseSign1Tag)
+	encoder.array(4)
+	encoder.bytes(m.protected)
+	encoder.mapHeader(0)
+	encoder.bytes(m.payload)
+	encoder.bytes(m.signature)
+	return encoder.Bytes()
+}
+
+// Signature returns the raw signature of the COSE_Sign1.
+func (m COSESign1) Signature() []byte {
+	return m.signature
+}
+
+// Verify checks the signature and returns the decoded protected header and payload.
+func (m COSESign1) Verify(algorithm JOSEAlgorithm, verifier Verifier) (COSEHeader, []byte, error) {
+	value, err := cborDecode(m.protected)
+	if err != nil {
+		return COSEHeader{}, nil, ErrMalformed
+	}
+	headers, ok := value.(map[any]any)
+	if !ok {
+		return COSEHeader{}, nil, ErrMalformed
+	}
+	alg, _ := headers[int64(coseHeaderAlgorithm)].(int64)
+	kid, _ := headers[int64(coseHeaderKeyId)].([]byte)
+	counter, _ := headers[coseHeaderCounter].(int64)
+
+	if alg != int64(algorithm.COSE) {
+		return COSEHeader{}, nil, ErrInvalidSignature
+	}
+	if err := algorithm.verify(verifier, m.sigStructure(), m.signature); err != nil {
+		return COSEHeader{}, nil, err
+	}
+	return COSEHeader{
+		Algorithm: int(alg),
+		KeyId:     string(kid),
+		Counter:   int(counter),
+	}, m.payload, nil
+}
+
+// sigStructure returns the Sig_structure that is signed for a COSE_Sign1.
+func (m COSESign1) sigStructure() []byte {
+	encoder := cborEncoder{}
+	encoder.array(4)
+	encoder.text("Signature1")
+	encoder.bytes(m.protected)
+	encoder.bytes([]byte{})
+	encoder.bytes(m.payload)
+	return encoder.Bytes()
+}
@@ -0,0 +1,54 @@
+package crypto
+
+import (
+	"testing"
+)
+
+func TestCOSESign1Verify_OkECC(t *testing.T) {
+	algorithm, _ := NewJOSEAlgorithm("ECC")
+	signer, _ := NewSignerWithHash("ECC", []byte(privateKeyEcc), algorithm.Hash)
+	verifier, _ := NewVerifierWithHash("ECC", []byte(publicKeyEcc), algorithm.Hash)
+	message, _ := NewCOSESign1(COSEHeader{KeyId: "device", Counter: 300}, []byte("data"), algorithm, signer)
+	parsed, err := ParseCOSESign1(message.Encode())
+
+	header, payload, verifyErr := parsed.Verify(algorithm, verifier)
+
+	assertEqual(t, nil, err)
+	assertEqual(t, nil, verifyErr)
+	assertEqual(t, COSEHeader{Algorithm: -35, KeyId: "device", Counter: 300}, header)
+	assertEqual(t, []byte("data"), payload)
+	assertEqual(t, byte(0xd2), message.Encode()[0])
+}
+
+func TestCOSESign1Verify_OkRSA(t *testing.T) {
+	algorithm, _ := NewJOSEAlgorithm("RSA")
+	signer, _ := NewSignerWithHash("RSA", []byte(privateKeyRsa), algorithm.Hash)
+	message, _ := NewCOSESign1(COSEHeader{KeyId: "device"}, []byte("data"), algorithm, signer)
+	parsed, _ := ParseCOSESign1(message.Encode())
+	marshaller := NewRSAMarshaler()
+	keyPair, _ := marshaller.Unmarshal([]byte(privateKeyRsa))
+	verifier := &RSAVerifier{publicKey: keyPair.Public, hash: algorithm.Hash}
+
+	header, _, err := parsed.Verify(algorithm, verifier)
+
+	assertEqual(t, nil, err)
+	assertEqual(t, -257, header.Algorithm)
+}
+
+func TestCOSESign1Verify_ErrInvalidSignature(t *testing.T) {
+	algorithm, _ := NewJOSEAlgorithm("ECC")
+	signer, _ := NewSignerWithHash("ECC", []byte(privateKeyEcc), algorithm.Hash)
+	verifier, _ := NewVerifierWithHash("ECC", []byte(publicKeyEcc), algorithm.Hash)
+	message, _ := NewCOSESign1(COSEHeader{KeyId: "device"}, []byte("data"), algorithm, signer)
+	message.payload = []byte("other data")
+
+	_, _, err := message.Verify(algorithm, verifier)
+
+	assertEqual(t, ErrInvalidSignature, err)
+}
+
+func TestParseCOSESign1_ErrMalformed(t *testing.T) {
+	_, err := ParseCOSESign1([]byte{0xd2, 0x84, 0x40})
+
+	assertEqual(t, ErrMalformed, err)
+}
@@ -5,6 +5,7 @@ import (
 	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/rsa"
+	"io"
 )
 
 // RSAGenerator generates a RSA key pair.
@@ -63,3 +64,11 @@ func NewKeyPair(algorithm string) ([]byte, []byte, error) {
 		return []byte{}, []byte{}, ErrInvalidAlgorithm
 	}
 }
+
+// CheckRandom reports whether the random source keys are generated from
+// can be read.
+func CheckRandom() error {
+	var sample [32]byte
+	_, err := io.ReadFull(rand.Reader, sample[:])
+	return err
+}
@@ -0,0 +1,72 @@
+package crypto
+
+import (
+	"crypto"
+	"encoding/asn1"
+	"math/big"
+)
+
+// JOSEAlgorithm describes how a device algorithm is expressed in JWS and COSE.
+type JOSEAlgorithm struct {
+	// Name is the JWS "alg" header value.
+	Name string
+	// COSE is the COSE algorithm identifier.
+	COSE int
+	Hash crypto.Hash
+	// ECDSASize is the byte length of r and s in the fixed width signature
+	// encoding required by JOSE and COSE. It is zero for RSA.
+	ECDSASize int
+}
+
+// NewJOSEAlgorithm returns the JOSEAlgorithm for a device algorithm.
+// ECC devices use P-384 keys and therefore have to sign SHA-384 digests.
+func NewJOSEAlgorithm(algorithm string) (JOSEAlgorithm, error) {
+	switch algorithm {
+	case "ECC":
+		return JOSEAlgorithm{Name: "ES384", COSE: -35, Hash: crypto.SHA384, ECDSASize: 48}, nil
+	case "RSA":
+		return JOSEAlgorithm{Name: "RS256", COSE: -257, Hash: crypto.SHA256}, nil
+	default:
+		return JOSEAlgorithm{}, ErrInvalidAlgorithm
+	}
+}
+
+type ecdsaSignature struct {
+	R, S *big.Int
+}
+
+// sign creates a JOSE/COSE signature, converting ASN.1 ECDSA signatures to r || s.
+func (a JOSEAlgorithm) sign(signer Signer, data []byte) ([]byte, error) {
+	signature, err := signer.Sign(data)
+	if err != nil || a.ECDSASize == 0 {
+		return signature, err
+	}
+
+	var parsed ecdsaSignature
+	if _, err := asn1.Unmarshal(signature, &parsed); err != nil {
+		return nil, err
+	}
+	raw := make([]byte, 2*a.ECDSASize)
+	parsed.R.FillBytes(raw[:a.ECDSASize])
+	parsed.S.FillBytes(raw[a.ECDSASize:])
+	return raw, nil
+}
+
+// verify checks a JOSE/COSE signature, converting r || s back to ASN.1 for ECDSA.
+func (a JOSEAlgorithm) verify(verifier Verifier, data []byte, signature []byte) error {
+	if a.ECDSASize == 0 {
+		return verifier.Verify(data, signature)
+	}
+	if len(signature) != 2*a.ECDSASize {
+		return ErrInvalidSignature
+	}
+
+	der, err := asn1.Marshal(ecdsaSignature{
+		R: new(big.Int).SetBytes(signature[:a.ECDSASize]),
+		S: new(big.Int).SetBytes(signature[a.ECDSASize:]),
+	})
+	if err != nil {
+		return err
+	}
+	return verifier.Verify(data, der)
+}
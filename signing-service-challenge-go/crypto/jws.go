@@ -0,0 +1,106 @@
+package crypto
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+)
+
+var ErrMalformed = errors.New("malformed signature")
+
+// JWSHeader is the protected header of the JWS created for a transaction.
+type JWSHeader struct {
+	Algorithm string `json:"alg"`
+	KeyId     string `json:"kid"`
+	Counter   int    `json:"ctr"`
+}
+
+// JWS is a JSON Web Signature (RFC 7515). All fields are base64url encoded
+// and the struct marshals to the flattened JSON serialization.
+type JWS struct {
+	Protected string `json:"protected"`
+	Payload   string `json:"payload"`
+	Signature string `json:"signature"`
+}
+
+// NewJWS signs the payload with the header as protected header.
+// The "alg" header is always taken from the algorithm.
+func NewJWS(header JWSHeader, payload []byte, algorithm JOSEAlgorithm, signer Signer) (JWS, error) {
+	header.Algorithm = algorithm.Name
+	encodedHeader, err := json.Marshal(header)
+	if err != nil {
+		return JWS{}, err
+	}
+
+	jws := JWS{
+		Protected: base64.RawURLEncoding.EncodeToString(encodedHeader),
+		Payload:   base64.RawURLEncoding.EncodeToString(payload),
+	}
+	signature, err := algorithm.sign(signer, jws.signingInput())
+	if err != nil {
+		return JWS{}, err
+	}
+	jws.Signature = base64.RawURLEncoding.EncodeToString(signature)
+	return jws, nil
+}
+
+// ParseJWS parses a JWS in compact or flattened JSON serialization.
+func ParseJWS(serialized string) (JWS, error) {
+	var jws JWS
+	if strings.HasPrefix(strings.TrimSpace(serialized), "{") {
+		if err := json.Unmarshal([]byte(serialized), &jws); err != nil {
+			return JWS{}, ErrMalformed
+		}
+	} else {
+		parts := strings.Split(serialized, ".")
+		if len(parts) != 3 {
+			return JWS{}, ErrMalformed
+		}
+		jws = JWS{Protected: parts[0], Payload: parts[1], Signature: parts[2]}
+	}
+	return jws, nil
+}
+
+// Compact returns the JWS compact serialization.
+func (j JWS) Compact() string {
+	return j.Protected + "." + j.Payload + "." + j.Signature
+}
+
+// JSON returns the JWS flattened JSON serialization.
+func (j JWS) JSON() (string, error) {
+	bytes, err := json.Marshal(j)
+	return string(bytes), err
+}
+
+// Verify checks the signature and returns the decoded header and payload.
+func (j JWS) Verify(algorithm JOSEAlgorithm, verifier Verifier) (JWSHeader, []byte, error) {
+	encodedHeader, err := base64.RawURLEncoding.DecodeString(j.Protected)
+	if err != nil {
+		return JWSHeader{}, nil, ErrMalformed
+	}
+	var header JWSHeader
+	if err := json.Unmarshal(encodedHeader, &header); err != nil {
+		return JWSHeader{}, nil, ErrMalformed
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(j.Payload)
+	if err != nil {
+		return JWSHeader{}, nil, ErrMalformed
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(j.Signature)
+	if err != nil {
+		return JWSHeader{}, nil, ErrMalformed
+	}
+
+	if header.Algorithm != algorithm.Name {
+		return JWSHeader{}, nil, ErrInvalidSignature
+	}
+	if err := algorithm.verify(verifier, j.signingInput(), signature); err != nil {
+		return JWSHeader{}, nil, err
+	}
+	return header, payload, nil
+}
+
+func (j JWS) signingInput() []byte {
+	return []byte(j.Protected + "." + j.Payload)
+}
@@ -0,0 +1,66 @@
+package crypto
+
+import (
+	"encoding/base64"
+	"testing"
+)
+
+func TestNewJWS_Ok(t *testing.T) {
+	algorithm, _ := NewJOSEAlgorithm("ECC")
+	signer, _ := NewSignerWithHash("ECC", []byte(privateKeyEcc), algorithm.Hash)
+
+	jws, err := NewJWS(JWSHeader{KeyId: "device", Counter: 3}, []byte("data"), algorithm, signer)
+
+	header, _ := base64.RawURLEncoding.DecodeString(jws.Protected)
+	signature, _ := base64.RawURLEncoding.DecodeString(jws.Signature)
+	assertEqual(t, nil, err)
+	assertEqual(t, `{"alg":"ES384","kid":"device","ctr":3}`, string(header))
+	assertEqual(t, "ZGF0YQ", jws.Payload)
+	assertEqual(t, 96, len(signature))
+}
+
+func TestJWSVerify_OkCompact(t *testing.T) {
+	algorithm, _ := NewJOSEAlgorithm("ECC")
+	signer, _ := NewSignerWithHash("ECC", []byte(privateKeyEcc), algorithm.Hash)
+	verifier, _ := NewVerifierWithHash("ECC", []byte(publicKeyEcc), algorithm.Hash)
+	jws, _ := NewJWS(JWSHeader{KeyId: "device", Counter: 3}, []byte("data"), algorithm, signer)
+	parsed, _ := ParseJWS(jws.Compact())
+
+	header, payload, err := parsed.Verify(algorithm, verifier)
+
+	assertEqual(t, nil, err)
+	assertEqual(t, JWSHeader{Algorithm: "ES384", KeyId: "device", Counter: 3}, header)
+	assertEqual(t, []byte("data"), payload)
+}
+
+func TestJWSVerify_OkJSON(t *testing.T) {
+	algorithm, _ := NewJOSEAlgorithm("ECC")
+	signer, _ := NewSignerWithHash("ECC", []byte(privateKeyEcc), algorithm.Hash)
+	verifier, _ := NewVerifierWithHash("ECC", []byte(publicKeyEcc), algorithm.Hash)
+	jws, _ := NewJWS(JWSHeader{KeyId: "device", Counter: 3}, []byte("data"), algorithm, signer)
+	serialized, _ := jws.JSON()
+	parsed, _ := ParseJWS(serialized)
+
+	_, payload, err := parsed.Verify(algorithm, verifier)
+
+	assertEqual(t, nil, err)
+	assertEqual(t, []byte("data"), payload)
+}
+
+func TestJWSVerify_ErrInvalidSignature(t *testing.T) {
+	algorithm, _ := NewJOSEAlgorithm("ECC")
+	signer, _ := NewSignerWithHash("ECC", []byte(privateKeyEcc), algorithm.Hash)
+	verifier, _ := NewVerifierWithHash("ECC", []byte(publicKeyEcc), algorithm.Hash)
+	jws, _ := NewJWS(JWSHeader{KeyId: "device", Counter: 3}, []byte("data"), algorithm, signer)
+	jws.Payload = base64.RawURLEncoding.EncodeToString([]byte("other data"))
+
+	_, _, err := jws.Verify(algorithm, verifier)
+
+	assertEqual(t, ErrInvalidSignature, err)
+}
+
+func TestParseJWS_ErrMalformed(t *testing.T) {
+	_, err := ParseJWS("a.b")
+
+	assertEqual(t, ErrMalformed, err)
+}
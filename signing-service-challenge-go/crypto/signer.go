@@ -5,7 +5,8 @@ import (
 	"crypto/ecdsa"
 	"crypto/rand"
 	"crypto/rsa"
-	"crypto/sha256"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
 	"errors"
 )
 
@@ -20,7 +21,13 @@ type Signer interface {
 
 var ErrInvalidAlgorithm = errors.New("invalid algorithm")
 
+// NewSigner creates a Signer that hashes the data with SHA-256 before signing.
 func NewSigner(algorithm string, privateKey []byte) (Signer, error) {
+	return NewSignerWithHash(algorithm, privateKey, crypto.SHA256)
+}
+
+// NewSignerWithHash creates a Signer that hashes the data with the given hash before signing.
+func NewSignerWithHash(algorithm string, privateKey []byte, hash crypto.Hash) (Signer, error) {
 	switch algorithm {
 	case "ECC":
 		marshaller := NewECCMarshaler()
@@ -30,6 +37,7 @@ func NewSigner(algorithm string, privateKey []byte) (Signer, error) {
 		}
 		return &ECCSigner{
 			privateKey: keyPair.Private,
+			hash:       hash,
 		}, nil
 	case "RSA":
 		marshaller := NewRSAMarshaler()
@@ -39,6 +47,7 @@ func NewSigner(algorithm string, privateKey []byte) (Signer, error) {
 		}
 		return &RSASigner{
 			privateKey: keyPair.Private,
+			hash:       hash,
 		}, nil
 	default:
 		return nil, ErrInvalidAlgorithm
@@ -47,18 +56,24 @@ func NewSigner(algorithm string, privateKey []byte) (Signer, error) {
 
 type RSASigner struct {
 	privateKey *rsa.PrivateKey
+	hash       crypto.Hash
 }
 
 type ECCSigner struct {
 	privateKey *ecdsa.PrivateKey
+	hash       crypto.Hash
 }
 
 func (s *RSASigner) Sign(dataToBeSigned []byte) ([]byte, error) {
-	bytes := sha256.Sum256(dataToBeSigned)
-	return rsa.SignPKCS1v15(nil, s.privateKey, crypto.SHA256, bytes[:])
+	return rsa.SignPKCS1v15(nil, s.privateKey, s.hash, digest(s.hash, dataToBeSigned))
 }
 
 func (s *ECCSigner) Sign(dataToBeSigned []byte) ([]byte, error) {
-	bytes := sha256.Sum256(dataToBeSigned)
-	return ecdsa.SignASN1(rand.Reader, s.privateKey, bytes[:])
+	return ecdsa.SignASN1(rand.Reader, s.privateKey, digest(s.hash, dataToBeSigned))
+}
+
+func digest(hash crypto.Hash, data []byte) []byte {
+	h := hash.New()
+	h.Write(data)
+	return h.Sum(nil)
 }
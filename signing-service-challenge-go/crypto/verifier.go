@@ -0,0 +1,81 @@
+package crypto
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+)
+
+var ErrInvalidSignature = errors.New("invalid signature")
+
+// Verifier defines a contract for checking signatures created by a Signer.
+type Verifier interface {
+	Verify(data []byte, signature []byte) error
+}
+
+// NewVerifier creates a Verifier for signatures over SHA-256 digests.
+func NewVerifier(algorithm string, publicKey []byte) (Verifier, error) {
+	return NewVerifierWithHash(algorithm, publicKey, crypto.SHA256)
+}
+
+// NewVerifierWithHash creates a Verifier for signatures over digests of the given hash.
+func NewVerifierWithHash(algorithm string, publicKey []byte, hash crypto.Hash) (Verifier, error) {
+	block, _ := pem.Decode(publicKey)
+	if block == nil {
+		return nil, ErrDecode
+	}
+
+	switch algorithm {
+	case "ECC":
+		key, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, ErrDecode
+		}
+		eccKey, ok := key.(*ecdsa.PublicKey)
+		if !ok {
+			return nil, ErrDecode
+		}
+		return &ECCVerifier{
+			publicKey: eccKey,
+			hash:      hash,
+		}, nil
+	case "RSA":
+		key, err := x509.ParsePKCS1PublicKey(block.Bytes)
+		if err != nil {
+			return nil, ErrDecode
+		}
+		return &RSAVerifier{
+			publicKey: key,
+			hash:      hash,
+		}, nil
+	default:
+		return nil, ErrInvalidAlgorithm
+	}
+}
+
+type RSAVerifier struct {
+	publicKey *rsa.PublicKey
+	hash      crypto.Hash
+}
+
+type ECCVerifier struct {
+	publicKey *ecdsa.PublicKey
+	hash      crypto.Hash
+}
+
+func (v *RSAVerifier) Verify(data []byte, signature []byte) error {
+	if err := rsa.VerifyPKCS1v15(v.publicKey, v.hash, digest(v.hash, data), signature); err != nil {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+func (v *ECCVerifier) Verify(data []byte, signature []byte) error {
+	if !ecdsa.VerifyASN1(v.publicKey, digest(v.hash, data), signature) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
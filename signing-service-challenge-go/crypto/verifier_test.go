@@ -0,0 +1,49 @@
+package crypto
+
+import (
+	"crypto"
+	"testing"
+)
+
+var publicKeyEcc = `-----BEGIN PUBLIC_KEY-----
+MHYwEAYHKoZIzj0CAQYFK4EEACIDYgAEqSgKeSCQnv/zGms6aPTQLwobQPZzdbRD
+UlCUSZX/7szy2JJMo11Z2HLUILXqk6Tb7cJNXlDZROusqAuS3sT8ozyfMacUg/qb
+vwso5rJ2csBlUuNqm9lKd5zHWL4CjIgQ
+-----END PUBLIC_KEY-----`
+
+func TestVerify_OkECC(t *testing.T) {
+	signer, _ := NewSigner("ECC", []byte(privateKeyEcc))
+	verifier, _ := NewVerifier("ECC", []byte(publicKeyEcc))
+	signature, _ := signer.Sign([]byte("data"))
+
+	err := verifier.Verify([]byte("data"), signature)
+
+	assertEqual(t, nil, err)
+}
+
+func TestVerify_OkRSA(t *testing.T) {
+	publicKey, privateKey, _ := NewKeyPair("RSA")
+	signer, _ := NewSignerWithHash("RSA", privateKey, crypto.SHA256)
+	verifier, _ := NewVerifierWithHash("RSA", publicKey, crypto.SHA256)
+	signature, _ := signer.Sign([]byte("data"))
+
+	err := verifier.Verify([]byte("data"), signature)
+
+	assertEqual(t, nil, err)
+}
+
+func TestVerify_ErrInvalidSignature(t *testing.T) {
+	signer, _ := NewSigner("ECC", []byte(privateKeyEcc))
+	verifier, _ := NewVerifier("ECC", []byte(publicKeyEcc))
+	signature, _ := signer.Sign([]byte("data"))
+
+	err := verifier.Verify([]byte("other data"), signature)
+
+	assertEqual(t, ErrInvalidSignature, err)
+}
+
+func TestNewVerifier_ErrDecode(t *testing.T) {
+	_, err := NewVerifier("RSA", []byte(publicKeyEcc))
+
+	assertEqual(t, ErrDecode, err)
+}
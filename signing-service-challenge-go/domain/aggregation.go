@@ -0,0 +1,344 @@
+package domain
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/merkle"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/persistence"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/tracing"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrInvalidMode        = newError(CodeInvalidMode, "invalid mode")
+	ErrInvalidAggregation = newError(CodeInvalidAggregation, "invalid aggregation settings")
+	// ErrAggregationPending is returned to callers that stop waiting for
+	// their tree. The transaction stays in the tree and may still be signed,
+	// so it must not be submitted again.
+	ErrAggregationPending = newError(CodeAggregationPending, "transaction is pending in a tree and may still be signed")
+)
+
+// DeviceMode defines how a device signs incoming transactions.
+type DeviceMode string
+
+const (
+	// ModeSingle signs every transaction individually.
+	ModeSingle DeviceMode = "single"
+	// ModeMerkle buffers transactions and only signs the root of a Merkle tree over them.
+	ModeMerkle DeviceMode = "merkle"
+)
+
+const (
+	DefaultAggregationWindow = 100 * time.Millisecond
+	DefaultAggregationSize   = 256
+	MaxAggregationWindow     = 10 * time.Second
+	MaxAggregationSize       = 65536
+
+	// treeSignAttempts bounds how often a root is re-signed when the counter
+	// of the device was modified concurrently.
+	treeSignAttempts = 3
+)
+
+// DeviceOptions configures a signature device at creation.
+type DeviceOptions struct {
+	Mode DeviceMode
+	// AggregationWindow is the longest time a transaction waits for a tree in ModeMerkle.
+	AggregationWindow time.Duration
+	// AggregationSize is the number of transactions that completes a tree in ModeMerkle.
+	AggregationSize int
+}
+
+func (o DeviceOptions) validate() (DeviceOptions, error) {
+	switch o.Mode {
+	case "", ModeSingle:
+		return DeviceOptions{Mode: ModeSingle}, nil
+	case ModeMerkle:
+		if o.AggregationWindow == 0 {
+			o.AggregationWindow = DefaultAggregationWindow
+		}
+		if o.AggregationSize == 0 {
+			o.AggregationSize = DefaultAggregationSize
+		}
+		if o.AggregationWindow < 0 || o.AggregationWindow > MaxAggregationWindow ||
+			o.AggregationSize < 0 || o.AggregationSize > MaxAggregationSize {
+			return DeviceOptions{}, ErrInvalidAggregation
+		}
+		return o, nil
+	default:
+		return DeviceOptions{}, ErrInvalidMode
+	}
+}
+
+// InclusionProof proves that a transaction is a leaf of a signed Merkle root.
+// All hashes are hex encoded.
+type InclusionProof struct {
+	Root      string
+	LeafIndex int
+	TreeSize  int
+	Proof     []string
+}
+
+// InclusionVerification is a transaction presented together with its
+// inclusion proof and the signature over the root.
+type InclusionVerification struct {
+	Data       string
+	Inclusion  InclusionProof
+	Signature  string
+	SignedData string
+}
+
+type aggregateResult struct {
+	signature Signature
+	err       error
+}
+
+type pendingLeaf struct {
+	data   string
+	keyId  string
+	result chan aggregateResult
+}
+
+// aggregator buffers the transactions of a device in ModeMerkle until either
+// the window expires or the size is reached. It is retired once a flush
+// leaves it empty, so that idle devices do not keep one.
+type aggregator struct {
+	window time.Duration
+	size   int
+	flush  func(leaves []pendingLeaf)
+
+	mu         sync.Mutex
+	pending    []pendingLeaf
+	generation int
+	retired    bool
+}
+
+// add buffers a transaction and returns the channel its result is sent to,
+// nil if the aggregator has been retired.
+func (a *aggregator) add(data, keyId string) <-chan aggregateResult {
+	result := make(chan aggregateResult, 1)
+
+	a.mu.Lock()
+	if a.retired {
+		a.mu.Unlock()
+		return nil
+	}
+	a.pending = append(a.pending, pendingLeaf{data: data, keyId: keyId, result: result})
+	if len(a.pending) == 1 {
+		generation := a.generation
+		time.AfterFunc(a.window, func() {
+			a.flushGeneration(generation)
+		})
+	}
+	var leaves []pendingLeaf
+	if len(a.pending) >= a.size {
+		leaves = a.take()
+	}
+	a.mu.Unlock()
+
+	if leaves != nil {
+		a.flush(leaves)
+	}
+	return result
+}
+
+// flushGeneration flushes the buffer unless it has already been flushed
+// since the timer of the given generation was started.
+func (a *aggregator) flushGeneration(generation int) {
+	a.mu.Lock()
+	var leaves []pendingLeaf
+	if a.generation == generation && len(a.pending) > 0 {
+		leaves = a.take()
+	}
+	a.mu.Unlock()
+
+	if leaves != nil {
+		a.flush(leaves)
+	}
+}
+
+func (a *aggregator) take() []pendingLeaf {
+	leaves := a.pending
+	a.pending = nil
+	a.generation++
+	return leaves
+}
+
+func (d *SignatureDeviceDomain) aggregate(ctx context.Context, device persistence.SignatureDevice, data string, options SignOptions) (Signature, error) {
+	if options.Format != "" && options.Format != FormatRaw {
+		return Signature{}, ErrInvalidFormat
+	}
+
+	_, span := tracing.Start(ctx, "domain.aggregate")
+	var results <-chan aggregateResult
+	for results == nil {
+		results = d.aggregatorFor(device).add(data, options.KeyId)
+	}
+	// A caller that gives up does not take its transaction out of the
+	// tree, it is signed with the others all the same.
+	var result aggregateResult
+	select {
+	case result = <-results:
+	case <-ctx.Done():
+		result.err = fmt.Errorf("%w: %w", ErrAggregationPending, ctx.Err())
+	}
+	span.SetError(result.err)
+	span.End()
+	// The root covers the transactions of several callers, whose keys are
+	// recorded as the leaf key ids of the root.
+	result.signature.KeyId = options.KeyId
+	return result.signature, result.err
+}
+
+func (d *SignatureDeviceDomain) aggregatorFor(device persistence.SignatureDevice) *aggregator {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	key := deviceKey{tenant: device.Tenant, id: device.Id}
+	if a, exists := d.aggregators[key]; exists {
+		return a
+	}
+	a := &aggregator{
+		window: device.AggregationWindow,
+		size:   device.AggregationSize,
+	}
+	a.flush = func(leaves []pendingLeaf) {
+		defer d.retireAggregator(key, a)
+		data := make([]string, 0, len(leaves))
+		keyIds := make([]string, 0, len(leaves))
+		for _, leaf := range leaves {
+			data = append(data, leaf.data)
+			keyIds = append(keyIds, leaf.keyId)
+		}
+		// The tree is signed on behalf of several callers, so it is
+		// not part of the trace of any of them.
+		ctx := context.Background()
+		device, err := d.findDevice(ctx, key.tenant, key.id)
+		var signatures []Signature
+		if err == nil {
+			signatures, err = d.signTree(ctx, device, data, SignOptions{leafKeyIds: keyIds})
+		}
+		for i, leaf := range leaves {
+			if err != nil {
+				leaf.result <- aggregateResult{err: err}
+				continue
+			}
+			leaf.result <- aggregateResult{signature: signatures[i]}
+		}
+	}
+	d.aggregators[key] = a
+	return a
+}
+
+// retireAggregator removes the aggregator of a device unless transactions
+// were added to it while it flushed. The next transaction of the device
+// starts a new one with the then current aggregation settings.
+func (d *SignatureDeviceDomain) retireAggregator(key deviceKey, a *aggregator) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if len(a.pending) > 0 || d.aggregators[key] != a {
+		return
+	}
+	a.retired = true
+	delete(d.aggregators, key)
+}
+
+// signTree builds a Merkle tree over the data and signs its root, which
+// consumes a single signature counter. Every returned signature carries the
+// inclusion proof of its transaction. The key ids of the leaves default to
+// options.KeyId and are recorded with the signature of the root.
+func (d *SignatureDeviceDomain) signTree(ctx context.Context, device persistence.SignatureDevice, data []string, options SignOptions) ([]Signature, error) {
+	leaves := make([][]byte, 0, len(data))
+	for _, item := range data {
+		leaves = append(leaves, []byte(item))
+	}
+	tree := merkle.NewTree(leaves)
+	root := hex.EncodeToString(tree.Root())
+
+	keyIds := options.leafKeyIds
+	if keyIds == nil {
+		keyIds = make([]string, 0, len(data))
+		for range data {
+			keyIds = append(keyIds, options.KeyId)
+		}
+	}
+
+	var signatures []Signature
+	var err error
+	for attempt := 1; ; attempt++ {
+		signatures, err = d.signTransactions(ctx, device, []string{root}, SignOptions{KeyId: options.KeyId, leafKeyIds: keyIds})
+		if !errors.Is(err, ErrModified) || attempt == treeSignAttempts {
+			break
+		}
+		// The root is signed again at the state the device changed to.
+		if device, err = d.findDevice(ctx, device.Tenant, device.Id); err != nil {
+			break
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]Signature, 0, len(data))
+	for index := range data {
+		proof := make([]string, 0)
+		for _, hash := range tree.Proof(index) {
+			proof = append(proof, hex.EncodeToString(hash))
+		}
+		signature := signatures[0]
+		signature.LeafKeyIds = nil
+		signature.Inclusion = &InclusionProof{
+			Root:      root,
+			LeafIndex: index,
+			TreeSize:  tree.Size(),
+			Proof:     proof,
+		}
+		result = append(result, signature)
+	}
+	return result, nil
+}
+
+func (d *SignatureDeviceDomain) VerifyInclusion(ctx context.Context, tenant, id string, verification InclusionVerification) error {
+	device, err := d.db.FindById(ctx, persistence.TenantId(tenant), persistence.Id(id))
+	if err != nil {
+		if errors.Is(err, persistence.ErrNotFound) {
+			return ErrNotFound
+		}
+		return err
+	}
+
+	root, err := hex.DecodeString(verification.Inclusion.Root)
+	if err != nil {
+		return ErrMalformed
+	}
+	proof := make([][]byte, 0, len(verification.Inclusion.Proof))
+	for _, item := range verification.Inclusion.Proof {
+		hash, err := hex.DecodeString(item)
+		if err != nil {
+			return ErrMalformed
+		}
+		proof = append(proof, hash)
+	}
+
+	inclusion := verification.Inclusion
+	if !merkle.VerifyInclusion([]byte(verification.Data), inclusion.LeafIndex, inclusion.TreeSize, proof, root) {
+		return ErrInvalidSignature
+	}
+
+	// The signed data has the format <counter>_<root>_<last_signature>_<signed_at>.
+	parts := strings.SplitN(verification.SignedData, "_", 3)
+	if len(parts) != 3 || parts[1] != inclusion.Root {
+		return ErrInvalidSignature
+	}
+	_, err = verifySignature(device, Verification{
+		Format:     FormatRaw,
+		Signature:  verification.Signature,
+		SignedData: verification.SignedData,
+	})
+	return err
+}
@@ -0,0 +1,167 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/persistence"
+)
+
+func newMerkleDomain(t *testing.T, window time.Duration, size int) (ISignatureDeviceDomain, persistence.ISignatureDeviceDb) {
+	db := persistence.NewSignatureDeviceDb()
+	domain := NewSignatureDeviceDomain(db, clock)
+	_, err := domain.CreateSignatureDevice(context.Background(), "tenant1", "550e8400-e29b-11d4-a716-446655440000", "ECC", "", DeviceOptions{
+		Mode:              ModeMerkle,
+		AggregationWindow: window,
+		AggregationSize:   size,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return domain, db
+}
+
+func TestSignTransaction_OkMerkleSize(t *testing.T) {
+	domain, db := newMerkleDomain(t, time.Second, 4)
+
+	var wg sync.WaitGroup
+	signatures := make([]Signature, 4)
+	for i := range signatures {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			signatures[i], _ = domain.SignTransaction(context.Background(), "tenant1", "550e8400-e29b-11d4-a716-446655440000", string(rune('a'+i)), SignOptions{})
+		}(i)
+	}
+	wg.Wait()
+
+	device, _ := db.FindById(context.Background(), "tenant1", "550e8400-e29b-11d4-a716-446655440000")
+	assertEqual(t, 1, device.SignatureCounter)
+	for i, signature := range signatures {
+		err := domain.VerifyInclusion(context.Background(), "tenant1", "550e8400-e29b-11d4-a716-446655440000", InclusionVerification{
+			Data:       string(rune('a' + i)),
+			Inclusion:  *signature.Inclusion,
+			Signature:  signature.Signature,
+			SignedData: signature.SignedData,
+		})
+
+		assertEqual(t, nil, err)
+		assertEqual(t, 4, signature.Inclusion.TreeSize)
+	}
+}
+
+func TestSignTransaction_OkMerkleWindow(t *testing.T) {
+	domain, db := newMerkleDomain(t, 10*time.Millisecond, 100)
+
+	signature, err := domain.SignTransaction(context.Background(), "tenant1", "550e8400-e29b-11d4-a716-446655440000", "test", SignOptions{})
+
+	assertEqual(t, nil, err)
+	assertEqual(t, 1, signature.Inclusion.TreeSize)
+	device, _ := db.FindById(context.Background(), "tenant1", "550e8400-e29b-11d4-a716-446655440000")
+	assertEqual(t, 1, device.SignatureCounter)
+}
+
+func TestSignTransaction_MerkleRetiresIdleAggregator(t *testing.T) {
+	domain, db := newMerkleDomain(t, time.Millisecond, 100)
+	aggregators := func() int {
+		d := domain.(*SignatureDeviceDomain)
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		return len(d.aggregators)
+	}
+
+	_, err := domain.SignTransaction(context.Background(), "tenant1", "550e8400-e29b-11d4-a716-446655440000", "first", SignOptions{})
+	assertEqual(t, nil, err)
+	deadline := time.Now().Add(time.Second)
+	for aggregators() > 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	assertEqual(t, 0, aggregators())
+
+	_, err = domain.SignTransaction(context.Background(), "tenant1", "550e8400-e29b-11d4-a716-446655440000", "second", SignOptions{})
+	assertEqual(t, nil, err)
+	device, _ := db.FindById(context.Background(), "tenant1", "550e8400-e29b-11d4-a716-446655440000")
+	assertEqual(t, 2, device.SignatureCounter)
+}
+
+func TestSignTransaction_MerkleRecordsLeafKeyIds(t *testing.T) {
+	store := persistence.NewEventStore()
+	db, _ := persistence.NewEventSourcedSignatureDeviceDb(store)
+	domain := NewSignatureDeviceDomain(db, clock)
+	_, _ = domain.CreateSignatureDevice(context.Background(), "tenant1", "550e8400-e29b-11d4-a716-446655440000", "ECC", "", DeviceOptions{
+		Mode:              ModeMerkle,
+		AggregationWindow: time.Second,
+		AggregationSize:   2,
+	})
+
+	var wg sync.WaitGroup
+	signatures := make([]Signature, 2)
+	for i := range signatures {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			signatures[i], _ = domain.SignTransaction(context.Background(), "tenant1", "550e8400-e29b-11d4-a716-446655440000", "test", SignOptions{KeyId: []string{"key1", "key2"}[i]})
+		}(i)
+	}
+	wg.Wait()
+	events, _ := store.Load("tenant1", "550e8400-e29b-11d4-a716-446655440000")
+
+	assertEqual(t, 2, len(events))
+	signed := events[1].Data.(persistence.TransactionSigned)
+	assertEqual(t, "", signed.KeyId)
+	assertEqual(t, 2, len(signed.LeafKeyIds))
+	for _, signature := range signatures {
+		assertEqual(t, signature.KeyId, signed.LeafKeyIds[signature.Inclusion.LeafIndex])
+		assertEqual(t, 0, len(signature.LeafKeyIds))
+	}
+}
+
+func TestSignTransaction_MerkleCancelled(t *testing.T) {
+	domain, _ := newMerkleDomain(t, MaxAggregationWindow, 100)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := domain.SignTransaction(ctx, "tenant1", "550e8400-e29b-11d4-a716-446655440000", "test", SignOptions{})
+
+	assertEqual(t, true, errors.Is(err, ErrAggregationPending))
+	assertEqual(t, true, errors.Is(err, context.Canceled))
+}
+
+func TestSignTransactions_OkMerkle(t *testing.T) {
+	domain, db := newMerkleDomain(t, time.Second, 100)
+
+	signatures, err := domain.SignTransactions(context.Background(), "tenant1", "550e8400-e29b-11d4-a716-446655440000", []string{"a", "b", "c"}, SignOptions{})
+
+	assertEqual(t, nil, err)
+	assertEqual(t, 3, len(signatures))
+	assertEqual(t, 2, signatures[2].Inclusion.LeafIndex)
+	device, _ := db.FindById(context.Background(), "tenant1", "550e8400-e29b-11d4-a716-446655440000")
+	assertEqual(t, 1, device.SignatureCounter)
+}
+
+func TestVerifyInclusion_ErrInvalidSignature(t *testing.T) {
+	domain, _ := newMerkleDomain(t, time.Second, 100)
+	signatures, _ := domain.SignTransactions(context.Background(), "tenant1", "550e8400-e29b-11d4-a716-446655440000", []string{"a", "b"}, SignOptions{})
+
+	err := domain.VerifyInclusion(context.Background(), "tenant1", "550e8400-e29b-11d4-a716-446655440000", InclusionVerification{
+		Data:       "c",
+		Inclusion:  *signatures[1].Inclusion,
+		Signature:  signatures[1].Signature,
+		SignedData: signatures[1].SignedData,
+	})
+
+	assertEqual(t, ErrInvalidSignature, err)
+}
+
+func TestCreateSignatureDevice_ErrInvalidMode(t *testing.T) {
+	domain := NewSignatureDeviceDomain(persistence.NewSignatureDeviceDb(), clock)
+
+	_, err := domain.CreateSignatureDevice(context.Background(), "tenant1", "550e8400-e29b-11d4-a716-446655440000", "ECC", "", DeviceOptions{
+		Mode: "tree",
+	})
+
+	assertEqual(t, ErrInvalidMode, err)
+}
@@ -0,0 +1,24 @@
+package domain
+
+import "time"
+
+// IClock is the source of signing times used by the domain.
+type IClock interface {
+	Now() time.Time
+}
+
+// SystemClock reads the wall clock on every call, so that corrections by
+// NTP are picked up. Signing refuses times before the last signature of a
+// device with ErrClockRegression, which keeps the times of a device
+// monotonic when the wall clock is stepped back.
+type SystemClock struct{}
+
+// NewSystemClock creates a new SystemClock.
+func NewSystemClock() IClock {
+	return &SystemClock{}
+}
+
+// Now returns the current wall time in UTC without a monotonic reading.
+func (c *SystemClock) Now() time.Time {
+	return time.Now().Round(0).UTC()
+}
@@ -0,0 +1,17 @@
+package domain
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSystemClock_Now(t *testing.T) {
+	clock := NewSystemClock()
+
+	before := time.Now().Round(0)
+	now := clock.Now()
+	after := time.Now().Round(0)
+
+	assertEqual(t, false, now.Before(before) || now.After(after))
+	assertEqual(t, time.UTC, now.Location())
+}
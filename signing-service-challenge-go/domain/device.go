@@ -1,58 +1,135 @@
 package domain
 
 import (
+	"context"
 	"encoding/base64"
 	"errors"
 	"fmt"
 	"github.com/fiskaly/coding-challenges/signing-service-challenge/crypto"
 	"github.com/fiskaly/coding-challenges/signing-service-challenge/persistence"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/tracing"
 	"github.com/google/uuid"
+	"sync"
+	"time"
 )
 
 var (
-	ErrExists           = errors.New("already exists")
-	ErrNotFound         = errors.New("not found")
-	ErrModified         = errors.New("concurrent modifications")
-	ErrInvalidUUID      = errors.New("invalid uuid")
-	ErrInvalidAlgorithm = errors.New("invalid algorithm")
+	ErrExists           = newError(CodeExists, "already exists")
+	ErrNotFound         = newError(CodeNotFound, "not found")
+	ErrModified         = newError(CodeModified, "concurrent modifications")
+	ErrInvalidUUID      = newError(CodeInvalidUUID, "invalid uuid")
+	ErrInvalidAlgorithm = newError(CodeInvalidAlgorithm, "invalid algorithm")
+	ErrClockRegression  = newError(CodeClockRegression, "clock regression")
+	ErrEmptyBatch       = newError(CodeEmptyBatch, "empty batch")
+	ErrBatchTooLarge    = newError(CodeBatchTooLarge, "batch too large")
+	ErrDecommissioned   = newError(CodeDecommissioned, "device decommissioned")
+	ErrDeviceLimit      = newError(CodeDeviceLimit, "device limit reached")
+	// ErrPreconditionFailed is returned if a device does not have the
+	// version a change expects.
+	ErrPreconditionFailed = newError(CodePreconditionFailed, "precondition failed")
 )
 
+// MaxBatchSize limits the number of transactions signed in a single batch.
+const MaxBatchSize = 1000
+
+// ISignatureDeviceDomain manages the devices of many tenants. Every call is
+// scoped to the devices of the given tenant, the devices of other tenants
+// are not found.
 type ISignatureDeviceDomain interface {
-	CreateSignatureDevice(id string, algorithm string, label string) (SignatureDevice, error)
-	ReadSignatureDevice(id string) (SignatureDevice, error)
-	SignTransaction(id, data string) (Signature, error)
-	ReadSignatureDevices() []SignatureDevice
+	CreateSignatureDevice(ctx context.Context, tenant, id string, algorithm string, label string, options DeviceOptions) (SignatureDevice, error)
+	ReadSignatureDevice(ctx context.Context, tenant, id string) (SignatureDevice, error)
+	SignTransaction(ctx context.Context, tenant, id, data string, options SignOptions) (Signature, error)
+	SignTransactions(ctx context.Context, tenant, id string, data []string, options SignOptions) ([]Signature, error)
+	VerifySignature(ctx context.Context, tenant, id string, verification Verification) (string, error)
+	VerifyInclusion(ctx context.Context, tenant, id string, verification InclusionVerification) error
+	// DecommissionSignatureDevice fails with ErrPreconditionFailed unless
+	// the device has the expected version, 0 decommissions any version.
+	DecommissionSignatureDevice(ctx context.Context, tenant, id string, expectedVersion int) (SignatureDevice, error)
+	// RotateSignatureDeviceKey fails with ErrPreconditionFailed unless the
+	// device has the expected version, 0 rotates any version.
+	RotateSignatureDeviceKey(ctx context.Context, tenant, id string, expectedVersion int) (SignatureDevice, error)
+	UpdateSignatureDevice(ctx context.Context, tenant, id string, patch DevicePatch) (SignatureDevice, error)
+	ReadSignatureDevices(ctx context.Context, tenant string, query DeviceQuery) (DevicePage, error)
 }
 
 type SignatureDeviceDomain struct {
-	db persistence.ISignatureDeviceDb
+	db         persistence.ISignatureDeviceDb
+	clock      IClock
+	publishers []IEventPublisher
+	limits     ITenantLimits
+	metrics    ISigningMetrics
+	// commitMu orders the writes of the domain with the events they publish.
+	commitMu sync.Mutex
+
+	mu          sync.Mutex
+	aggregators map[deviceKey]*aggregator
 }
 
-func NewSignatureDeviceDomain(db persistence.ISignatureDeviceDb) ISignatureDeviceDomain {
-	return &SignatureDeviceDomain{
-		db: db,
+// deviceKey identifies a device across tenants.
+type deviceKey struct {
+	tenant persistence.TenantId
+	id     persistence.Id
+}
+
+func NewSignatureDeviceDomain(db persistence.ISignatureDeviceDb, clock IClock, options ...Option) ISignatureDeviceDomain {
+	d := &SignatureDeviceDomain{
+		db:          db,
+		clock:       clock,
+		aggregators: make(map[deviceKey]*aggregator),
+	}
+	for _, option := range options {
+		option(d)
 	}
+	return d
 }
 
 type SignatureDevice struct {
-	Id               string
-	Algorithm        string
-	Label            string
-	SignatureCounter int
-	LastSignature    string
+	Tenant            string
+	Id                string
+	Algorithm         string
+	Label             string
+	SignatureCounter  int
+	LastSignature     string
+	Mode              DeviceMode
+	AggregationWindow time.Duration
+	AggregationSize   int
+	// DecommissionedAt is zero while the device can still sign.
+	DecommissionedAt time.Time
+	CreatedAt        time.Time
+	Tags             map[string]string
+	Metadata         map[string]interface{}
+	// Version is incremented by every change of the device.
+	Version int
 }
 
 type Signature struct {
+	// Counter is the signature counter the transaction was signed with.
+	Counter int
+	// KeyId identifies the API key the transaction was signed for.
+	KeyId string
+	// LeafKeyIds identifies the API key of every transaction in the tree
+	// of a ModeMerkle root, in leaf order. It is only set on the published
+	// signature of the root.
+	LeafKeyIds []string
 	Signature  string
 	SignedData string
+	SignedAt   time.Time
+	// Inclusion is set for devices in ModeMerkle, where Signature and
+	// SignedData cover the root of the tree containing the transaction.
+	Inclusion *InclusionProof
 }
 
-func (d *SignatureDeviceDomain) CreateSignatureDevice(id, algorithm, label string) (SignatureDevice, error) {
+func (d *SignatureDeviceDomain) CreateSignatureDevice(ctx context.Context, tenant, id, algorithm, label string, options DeviceOptions) (SignatureDevice, error) {
 	err := uuid.Validate(id)
 	if err != nil {
 		return SignatureDevice{}, ErrInvalidUUID
 	}
 
+	options, err = options.validate()
+	if err != nil {
+		return SignatureDevice{}, err
+	}
+
 	publicKey, privateKey, err := crypto.NewKeyPair(algorithm)
 	if err != nil {
 		if errors.Is(err, crypto.ErrInvalidAlgorithm) {
@@ -61,96 +138,288 @@ func (d *SignatureDeviceDomain) CreateSignatureDevice(id, algorithm, label strin
 		return SignatureDevice{}, err
 	}
 
-	device := persistence.SignatureDevice{
-		Id:            persistence.Id(id),
-		Algorithm:     algorithm,
-		Label:         label,
-		PublicKey:     publicKey,
-		PrivateKey:    privateKey,
-		LastSignature: base64.StdEncoding.EncodeToString([]byte(id)),
-	}
+	device := persistence.SignatureDevice{Tenant: persistence.TenantId(tenant), Id: persistence.Id(id)}.Record(d.clock.Now(), persistence.DeviceCreated{
+		Algorithm:         algorithm,
+		Label:             label,
+		PublicKey:         publicKey,
+		PrivateKey:        privateKey,
+		LastSignature:     base64.StdEncoding.EncodeToString([]byte(id)),
+		Mode:              string(options.Mode),
+		AggregationWindow: options.AggregationWindow,
+		AggregationSize:   options.AggregationSize,
+	})
 
-	err = d.db.Store(device)
+	created := toSignatureDevice(device)
+	d.commitMu.Lock()
+	err = d.checkDeviceLimit(ctx, device.Tenant)
+	if err == nil {
+		err = d.db.Store(ctx, device)
+	}
+	if err == nil {
+		d.publish(EventDeviceCreated, device.Uncommitted[0].OccurredAt, created, nil)
+	}
+	d.commitMu.Unlock()
 	if err != nil {
 		if errors.Is(err, persistence.ErrExists) {
 			return SignatureDevice{}, ErrExists
 		}
 		return SignatureDevice{}, err
 	}
-	return d.ReadSignatureDevice(id)
+	return created, nil
 }
 
-func (d *SignatureDeviceDomain) ReadSignatureDevice(id string) (SignatureDevice, error) {
-	device, err := d.db.FindById(persistence.Id(id))
+func (d *SignatureDeviceDomain) ReadSignatureDevice(ctx context.Context, tenant, id string) (SignatureDevice, error) {
+	device, err := d.db.FindById(ctx, persistence.TenantId(tenant), persistence.Id(id))
 	if err != nil {
 		if errors.Is(err, persistence.ErrNotFound) {
 			return SignatureDevice{}, ErrNotFound
 		}
 		return SignatureDevice{}, err
 	}
-	return SignatureDevice{
-		Id:               string(device.Id),
-		Algorithm:        device.Algorithm,
-		Label:            device.Label,
-		SignatureCounter: device.SignatureCounter,
-		LastSignature:    device.LastSignature,
-	}, nil
+	return toSignatureDevice(device), nil
 }
 
-func (d *SignatureDeviceDomain) SignTransaction(id, data string) (Signature, error) {
-	device, err := d.db.FindById(persistence.Id(id))
+func (d *SignatureDeviceDomain) SignTransaction(ctx context.Context, tenant, id, data string, options SignOptions) (signature Signature, err error) {
+	ctx, span := tracing.Start(ctx, "domain.SignTransaction")
+	span.SetAttribute("tenant", tenant)
+	span.SetAttribute("device.id", id)
+	start := time.Now()
+	algorithm := ""
+	defer func() {
+		span.SetAttribute("device.algorithm", algorithm)
+		span.SetError(err)
+		span.End()
+		d.observeSign(algorithm, start, err)
+	}()
+
+	device, err := d.db.FindById(ctx, persistence.TenantId(tenant), persistence.Id(id))
 	if err != nil {
 		if errors.Is(err, persistence.ErrNotFound) {
 			return Signature{}, ErrNotFound
 		}
 		return Signature{}, err
 	}
+	algorithm = device.Algorithm
+	if !device.DecommissionedAt.IsZero() {
+		return Signature{}, ErrDecommissioned
+	}
+	if err := checkVersion(device, options.ExpectedVersion); err != nil {
+		return Signature{}, err
+	}
+	if DeviceMode(device.Mode) == ModeMerkle {
+		return d.aggregate(ctx, device, data, options)
+	}
 
-	signedData := fmt.Sprintf("%d_%s_%s", device.SignatureCounter, data, device.LastSignature)
-	signer, err := crypto.NewSigner(device.Algorithm, device.PrivateKey)
+	signatures, err := d.signTransactions(ctx, device, []string{data}, options)
 	if err != nil {
 		return Signature{}, err
 	}
-	signature, err := signer.Sign([]byte(signedData))
+	return signatures[0], nil
+}
+
+func (d *SignatureDeviceDomain) SignTransactions(ctx context.Context, tenant, id string, data []string, options SignOptions) (signatures []Signature, err error) {
+	ctx, span := tracing.Start(ctx, "domain.SignTransactions")
+	span.SetAttribute("tenant", tenant)
+	span.SetAttribute("device.id", id)
+	start := time.Now()
+	algorithm := ""
+	defer func() {
+		span.SetAttribute("device.algorithm", algorithm)
+		span.SetError(err)
+		span.End()
+		d.observeSign(algorithm, start, err)
+	}()
+
+	if len(data) == 0 {
+		return nil, ErrEmptyBatch
+	}
+	if len(data) > MaxBatchSize {
+		return nil, ErrBatchTooLarge
+	}
+
+	device, err := d.db.FindById(ctx, persistence.TenantId(tenant), persistence.Id(id))
 	if err != nil {
-		return Signature{}, err
+		if errors.Is(err, persistence.ErrNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	algorithm = device.Algorithm
+	if err := checkVersion(device, options.ExpectedVersion); err != nil {
+		return nil, err
+	}
+	if DeviceMode(device.Mode) == ModeMerkle {
+		// A batch already is an aggregate, so it is signed as a tree of its own.
+		if options.Format != "" && options.Format != FormatRaw {
+			return nil, ErrInvalidFormat
+		}
+		return d.signTree(ctx, device, data, options)
+	}
+	return d.signTransactions(ctx, device, data, options)
+}
+
+// findDevice loads a device for a change.
+func (d *SignatureDeviceDomain) findDevice(ctx context.Context, tenant persistence.TenantId, id persistence.Id) (persistence.SignatureDevice, error) {
+	device, err := d.db.FindById(ctx, tenant, id)
+	if errors.Is(err, persistence.ErrNotFound) {
+		return persistence.SignatureDevice{}, ErrNotFound
+	}
+	return device, err
+}
+
+// signTransactions signs the data as one consecutive range of the signature
+// counter of the loaded device. Nothing is stored unless every element has
+// been signed, and ErrModified is returned if the device changed meanwhile.
+func (d *SignatureDeviceDomain) signTransactions(ctx context.Context, device persistence.SignatureDevice, data []string, options SignOptions) ([]Signature, error) {
+	if !device.DecommissionedAt.IsZero() {
+		return nil, ErrDecommissioned
+	}
+	if err := checkVersion(device, options.ExpectedVersion); err != nil {
+		return nil, err
 	}
-	base64Signature := base64.StdEncoding.EncodeToString(signature)
 
-	newDevice := persistence.SignatureDevice{
-		Id:               persistence.Id(id),
-		Algorithm:        device.Algorithm,
-		Label:            device.Label,
-		PublicKey:        device.PublicKey,
-		PrivateKey:       device.PrivateKey,
-		SignatureCounter: device.SignatureCounter + 1,
-		LastSignature:    base64Signature,
+	signedAt := d.clock.Now()
+	if signedAt.Before(device.LastSignedAt) {
+		return nil, ErrClockRegression
 	}
 
-	err = d.db.CompareAndSwap(device, newDevice)
+	var encode signatureEncoder
+	err := traced(ctx, "crypto.NewSigner", len(data), func() (err error) {
+		encode, err = newSignatureEncoder(options.Format, device)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	newDevice := device
+	signatures := make([]Signature, 0, len(data))
+	err = traced(ctx, "crypto.Sign", len(data), func() error {
+		for _, item := range data {
+			signedData := fmt.Sprintf("%d_%s_%s_%s", newDevice.SignatureCounter, item, newDevice.LastSignature, signedAt.Format(time.RFC3339Nano))
+			serializedSignature, signature, err := encode(newDevice, signedData)
+			if err != nil {
+				return err
+			}
+
+			signatures = append(signatures, Signature{
+				Counter:    newDevice.SignatureCounter,
+				KeyId:      options.KeyId,
+				LeafKeyIds: options.leafKeyIds,
+				Signature:  serializedSignature,
+				SignedData: signedData,
+				SignedAt:   signedAt,
+			})
+			newDevice = newDevice.Record(signedAt, persistence.TransactionSigned{
+				Counter:    newDevice.SignatureCounter,
+				KeyId:      options.KeyId,
+				LeafKeyIds: options.leafKeyIds,
+				SignedData: signedData,
+				Signature:  base64.StdEncoding.EncodeToString(signature),
+				SignedAt:   signedAt,
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	signed := toSignatureDevice(newDevice)
+	err = d.commit(ctx, device, newDevice, func() {
+		for i := range signatures {
+			d.publish(EventSignatureCreated, signedAt, signed, &signatures[i])
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return signatures, nil
+}
+
+// DecommissionSignatureDevice permanently stops a device from signing. Its
+// signatures can still be verified.
+func (d *SignatureDeviceDomain) DecommissionSignatureDevice(ctx context.Context, tenant, id string, expectedVersion int) (SignatureDevice, error) {
+	device, err := d.db.FindById(ctx, persistence.TenantId(tenant), persistence.Id(id))
+	if err != nil {
+		if errors.Is(err, persistence.ErrNotFound) {
+			return SignatureDevice{}, ErrNotFound
+		}
+		return SignatureDevice{}, err
+	}
+	if err := checkVersion(device, expectedVersion); err != nil {
+		return SignatureDevice{}, err
+	}
+	if !device.DecommissionedAt.IsZero() {
+		return SignatureDevice{}, ErrDecommissioned
+	}
+
+	newDevice := device.Record(d.clock.Now(), persistence.Decommissioned{})
+	decommissioned := toSignatureDevice(newDevice)
+	err = d.commit(ctx, device, newDevice, func() {
+		d.publish(EventDeviceDecommissioned, newDevice.DecommissionedAt, decommissioned, nil)
+	})
+	if err != nil {
+		return SignatureDevice{}, err
+	}
+	return decommissioned, nil
+}
+
+// checkVersion returns ErrPreconditionFailed unless the device has the
+// expected version. An expected version of 0 matches every device.
+func checkVersion(device persistence.SignatureDevice, expectedVersion int) error {
+	if expectedVersion != 0 && device.Version != expectedVersion {
+		return ErrPreconditionFailed
+	}
+	return nil
+}
+
+// commit swaps the device and publishes the events of the change while
+// holding commitMu, so that subscribers see the events of a device in the
+// order of its signature counter.
+func (d *SignatureDeviceDomain) commit(ctx context.Context, old, new persistence.SignatureDevice, publish func()) error {
+	d.commitMu.Lock()
+	defer d.commitMu.Unlock()
+	err := d.db.CompareAndSwap(ctx, old, new)
 	if err != nil {
 		if errors.Is(err, persistence.ErrModified) {
-			return Signature{}, ErrModified
+			if d.metrics != nil {
+				d.metrics.ObserveConflict()
+			}
+			return ErrModified
 		}
-		return Signature{}, err
+		return err
+	}
+	publish()
+	return nil
+}
+
+func (d *SignatureDeviceDomain) VerifySignature(ctx context.Context, tenant, id string, verification Verification) (string, error) {
+	device, err := d.db.FindById(ctx, persistence.TenantId(tenant), persistence.Id(id))
+	if err != nil {
+		if errors.Is(err, persistence.ErrNotFound) {
+			return "", ErrNotFound
+		}
+		return "", err
+	}
+	return verifySignature(device, verification)
+}
+
+func toSignatureDevice(device persistence.SignatureDevice) SignatureDevice {
+	return SignatureDevice{
+		Tenant:            string(device.Tenant),
+		Id:                string(device.Id),
+		Algorithm:         device.Algorithm,
+		Label:             device.Label,
+		SignatureCounter:  device.SignatureCounter,
+		LastSignature:     device.LastSignature,
+		Mode:              DeviceMode(device.Mode),
+		AggregationWindow: device.AggregationWindow,
+		AggregationSize:   device.AggregationSize,
+		DecommissionedAt:  device.DecommissionedAt,
+		CreatedAt:         device.CreatedAt,
+		Tags:              device.Tags,
+		Metadata:          device.Metadata,
+		Version:           device.Version,
 	}
-	return Signature{
-		Signature:  base64Signature,
-		SignedData: signedData,
-	}, nil
-}
-
-func (d *SignatureDeviceDomain) ReadSignatureDevices() []SignatureDevice {
-	devices := d.db.FindAll()
-	result := make([]SignatureDevice, 0)
-	for _, device := range devices {
-		result = append(result, SignatureDevice{
-			Id:               string(device.Id),
-			Algorithm:        device.Algorithm,
-			Label:            device.Label,
-			SignatureCounter: device.SignatureCounter,
-			LastSignature:    device.LastSignature,
-		})
-	}
-	return result
 }
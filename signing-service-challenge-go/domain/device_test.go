@@ -1,9 +1,11 @@
 package domain
 
 import (
+	"context"
 	"github.com/fiskaly/coding-challenges/signing-service-challenge/persistence"
 	"reflect"
 	"testing"
+	"time"
 )
 
 func assertEqual(t *testing.T, expected any, actual any) {
@@ -18,30 +20,58 @@ func assertNotEmpty(t *testing.T, actual []byte) {
 	}
 }
 
+type FakeClock struct {
+	Time time.Time
+}
+
+func (c *FakeClock) Now() time.Time {
+	return c.Time
+}
+
+var clock = &FakeClock{
+	Time: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+}
+
 type SignatureDeviceInMemoryDbStub struct {
 	StoreFunc          func(device persistence.SignatureDevice) error
-	FindByIdFunc       func(id persistence.Id) (persistence.SignatureDevice, error)
+	FindByIdFunc       func(tenant persistence.TenantId, id persistence.Id) (persistence.SignatureDevice, error)
 	CompareAndSwapFunc func(old, new persistence.SignatureDevice) error
-	FindAllFunc        func() []persistence.SignatureDevice
+	FindAllFunc        func(tenant persistence.TenantId) []persistence.SignatureDevice
+	QueryFunc          func(tenant persistence.TenantId, query persistence.DeviceQuery) (persistence.DevicePage, error)
+	CountByStateFunc   func() map[persistence.DeviceState]int
+	CountActiveFunc    func(tenant persistence.TenantId) int
 }
 
-func (s *SignatureDeviceInMemoryDbStub) Store(device persistence.SignatureDevice) error {
+func (s *SignatureDeviceInMemoryDbStub) Store(_ context.Context, device persistence.SignatureDevice) error {
 	return s.StoreFunc(device)
 }
 
-func (s *SignatureDeviceInMemoryDbStub) FindById(id persistence.Id) (persistence.SignatureDevice, error) {
-	return s.FindByIdFunc(id)
+func (s *SignatureDeviceInMemoryDbStub) FindById(_ context.Context, tenant persistence.TenantId, id persistence.Id) (persistence.SignatureDevice, error) {
+	return s.FindByIdFunc(tenant, id)
 }
 
-func (s *SignatureDeviceInMemoryDbStub) CompareAndSwap(old, new persistence.SignatureDevice) error {
+func (s *SignatureDeviceInMemoryDbStub) CompareAndSwap(_ context.Context, old, new persistence.SignatureDevice) error {
 	return s.CompareAndSwapFunc(old, new)
 }
 
-func (s *SignatureDeviceInMemoryDbStub) FindAll() []persistence.SignatureDevice {
-	return s.FindAllFunc()
+func (s *SignatureDeviceInMemoryDbStub) FindAll(_ context.Context, tenant persistence.TenantId) []persistence.SignatureDevice {
+	return s.FindAllFunc(tenant)
+}
+
+func (s *SignatureDeviceInMemoryDbStub) Query(_ context.Context, tenant persistence.TenantId, query persistence.DeviceQuery) (persistence.DevicePage, error) {
+	return s.QueryFunc(tenant, query)
+}
+
+func (s *SignatureDeviceInMemoryDbStub) CountByState(_ context.Context) map[persistence.DeviceState]int {
+	return s.CountByStateFunc()
+}
+
+func (s *SignatureDeviceInMemoryDbStub) CountActive(_ context.Context, tenant persistence.TenantId) int {
+	return s.CountActiveFunc(tenant)
 }
 
 var device1 = persistence.SignatureDevice{
+	Tenant:    "tenant1",
 	Id:        "550e8400-e29b-11d4-a716-446655440000",
 	Algorithm: "ECC",
 	Label:     "device1",
@@ -63,7 +93,7 @@ CyjmsnZywGVS42qb2Up3nMdYvgKMiBA=
 func TestCreateSignatureDevice_Ok(t *testing.T) {
 	var storeDevice persistence.SignatureDevice
 	db := &SignatureDeviceInMemoryDbStub{
-		FindByIdFunc: func(id persistence.Id) (persistence.SignatureDevice, error) {
+		FindByIdFunc: func(tenant persistence.TenantId, id persistence.Id) (persistence.SignatureDevice, error) {
 			return storeDevice, nil
 		},
 		StoreFunc: func(device persistence.SignatureDevice) error {
@@ -71,17 +101,21 @@ func TestCreateSignatureDevice_Ok(t *testing.T) {
 			return nil
 		},
 	}
-	domain := NewSignatureDeviceDomain(db)
+	domain := NewSignatureDeviceDomain(db, clock)
 
-	device, err := domain.CreateSignatureDevice("550e8400-e29b-11d4-a716-446655440000", "ECC", "")
+	device, err := domain.CreateSignatureDevice(context.Background(), "tenant1", "550e8400-e29b-11d4-a716-446655440000", "ECC", "", DeviceOptions{})
 
 	assertEqual(t, nil, err)
 	assertEqual(t, SignatureDevice{
+		Tenant:           "tenant1",
 		Id:               "550e8400-e29b-11d4-a716-446655440000",
 		Algorithm:        "ECC",
 		Label:            "",
 		SignatureCounter: 0,
 		LastSignature:    "NTUwZTg0MDAtZTI5Yi0xMWQ0LWE3MTYtNDQ2NjU1NDQwMDAw",
+		Mode:             ModeSingle,
+		CreatedAt:        clock.Time,
+		Version:          1,
 	}, device)
 	assertNotEmpty(t, storeDevice.PrivateKey)
 	assertNotEmpty(t, storeDevice.PublicKey)
@@ -93,25 +127,26 @@ func TestCreateSignatureDevice_ErrExists(t *testing.T) {
 			return ErrExists
 		},
 	}
-	domain := NewSignatureDeviceDomain(db)
+	domain := NewSignatureDeviceDomain(db, clock)
 
-	_, err := domain.CreateSignatureDevice("550e8400-e29b-11d4-a716-446655440000", "ECC", "")
+	_, err := domain.CreateSignatureDevice(context.Background(), "tenant1", "550e8400-e29b-11d4-a716-446655440000", "ECC", "", DeviceOptions{})
 
 	assertEqual(t, ErrExists, err)
 }
 
 func TestReadSignatureDevice_Ok(t *testing.T) {
 	db := &SignatureDeviceInMemoryDbStub{
-		FindByIdFunc: func(key persistence.Id) (persistence.SignatureDevice, error) {
+		FindByIdFunc: func(tenant persistence.TenantId, key persistence.Id) (persistence.SignatureDevice, error) {
 			return device1, nil
 		},
 	}
-	domain := NewSignatureDeviceDomain(db)
+	domain := NewSignatureDeviceDomain(db, clock)
 
-	device, err := domain.ReadSignatureDevice("550e8400-e29b-11d4-a716-446655440000")
+	device, err := domain.ReadSignatureDevice(context.Background(), "tenant1", "550e8400-e29b-11d4-a716-446655440000")
 
 	assertEqual(t, nil, err)
 	assertEqual(t, SignatureDevice{
+		Tenant:           "tenant1",
 		Id:               "550e8400-e29b-11d4-a716-446655440000",
 		Algorithm:        "ECC",
 		Label:            "device1",
@@ -122,21 +157,23 @@ func TestReadSignatureDevice_Ok(t *testing.T) {
 
 func TestReadSignatureDevice_ErrNotFound(t *testing.T) {
 	db := &SignatureDeviceInMemoryDbStub{
-		FindByIdFunc: func(key persistence.Id) (persistence.SignatureDevice, error) {
+		FindByIdFunc: func(tenant persistence.TenantId, key persistence.Id) (persistence.SignatureDevice, error) {
 			return persistence.SignatureDevice{}, ErrNotFound
 		},
 	}
-	domain := NewSignatureDeviceDomain(db)
+	domain := NewSignatureDeviceDomain(db, clock)
 
-	_, err := domain.ReadSignatureDevice("550e8400-e29b-11d4-a716-446655440000")
+	_, err := domain.ReadSignatureDevice(context.Background(), "tenant1", "550e8400-e29b-11d4-a716-446655440000")
 
 	assertEqual(t, ErrNotFound, err)
 }
 
 func TestSignTransaction_Ok(t *testing.T) {
 	var newDevice persistence.SignatureDevice
+	loads := 0
 	db := &SignatureDeviceInMemoryDbStub{
-		FindByIdFunc: func(key persistence.Id) (persistence.SignatureDevice, error) {
+		FindByIdFunc: func(tenant persistence.TenantId, key persistence.Id) (persistence.SignatureDevice, error) {
+			loads++
 			return device1, nil
 		},
 		CompareAndSwapFunc: func(old, new persistence.SignatureDevice) error {
@@ -144,40 +181,118 @@ func TestSignTransaction_Ok(t *testing.T) {
 			return nil
 		},
 	}
-	domain := NewSignatureDeviceDomain(db)
+	domain := NewSignatureDeviceDomain(db, clock)
 
-	signature, err := domain.SignTransaction("550e8400-e29b-11d4-a716-446655440000", "test")
+	signature, err := domain.SignTransaction(context.Background(), "tenant1", "550e8400-e29b-11d4-a716-446655440000", "test", SignOptions{})
 
 	assertEqual(t, nil, err)
-	assertEqual(t, "0_test_NTUwZTg0MDAtZTI5Yi0xMWQ0LWE3MTYtNDQ2NjU1NDQwMDAw", signature.SignedData)
+	assertEqual(t, "0_test_NTUwZTg0MDAtZTI5Yi0xMWQ0LWE3MTYtNDQ2NjU1NDQwMDAw_2024-01-02T03:04:05Z", signature.SignedData)
+	assertEqual(t, clock.Time, signature.SignedAt)
 	assertEqual(t, signature.Signature, newDevice.LastSignature)
+	assertEqual(t, clock.Time, newDevice.LastSignedAt)
 	assertEqual(t, 1, newDevice.SignatureCounter)
+	assertEqual(t, 1, loads)
+}
+
+func TestSignTransaction_ErrClockRegression(t *testing.T) {
+	device := device1
+	device.LastSignedAt = clock.Time.Add(time.Second)
+	db := &SignatureDeviceInMemoryDbStub{
+		FindByIdFunc: func(tenant persistence.TenantId, key persistence.Id) (persistence.SignatureDevice, error) {
+			return device, nil
+		},
+	}
+	domain := NewSignatureDeviceDomain(db, clock)
+
+	_, err := domain.SignTransaction(context.Background(), "tenant1", "550e8400-e29b-11d4-a716-446655440000", "test", SignOptions{})
+
+	assertEqual(t, ErrClockRegression, err)
+}
+
+func TestSignTransactions_Ok(t *testing.T) {
+	var newDevice persistence.SignatureDevice
+	db := &SignatureDeviceInMemoryDbStub{
+		FindByIdFunc: func(tenant persistence.TenantId, key persistence.Id) (persistence.SignatureDevice, error) {
+			return device1, nil
+		},
+		CompareAndSwapFunc: func(old, new persistence.SignatureDevice) error {
+			newDevice = new
+			return nil
+		},
+	}
+	domain := NewSignatureDeviceDomain(db, clock)
+
+	signatures, err := domain.SignTransactions(context.Background(), "tenant1", "550e8400-e29b-11d4-a716-446655440000", []string{"a", "b"}, SignOptions{})
+
+	assertEqual(t, nil, err)
+	assertEqual(t, 2, len(signatures))
+	assertEqual(t, "0_a_NTUwZTg0MDAtZTI5Yi0xMWQ0LWE3MTYtNDQ2NjU1NDQwMDAw_2024-01-02T03:04:05Z", signatures[0].SignedData)
+	assertEqual(t, "1_b_"+signatures[0].Signature+"_2024-01-02T03:04:05Z", signatures[1].SignedData)
+	assertEqual(t, signatures[1].Signature, newDevice.LastSignature)
+	assertEqual(t, 2, newDevice.SignatureCounter)
+}
+
+func TestSignTransactions_ErrNoCountersConsumed(t *testing.T) {
+	swapped := false
+	db := &SignatureDeviceInMemoryDbStub{
+		FindByIdFunc: func(tenant persistence.TenantId, key persistence.Id) (persistence.SignatureDevice, error) {
+			return device1, nil
+		},
+		CompareAndSwapFunc: func(old, new persistence.SignatureDevice) error {
+			swapped = true
+			return nil
+		},
+	}
+	domain := NewSignatureDeviceDomain(db, clock)
+
+	_, err := domain.SignTransactions(context.Background(), "tenant1", "550e8400-e29b-11d4-a716-446655440000", []string{"a", "b"}, SignOptions{
+		Format: "xml",
+	})
+
+	assertEqual(t, ErrInvalidFormat, err)
+	assertEqual(t, false, swapped)
+}
+
+func TestSignTransactions_ErrEmptyBatch(t *testing.T) {
+	domain := NewSignatureDeviceDomain(&SignatureDeviceInMemoryDbStub{}, clock)
+
+	_, err := domain.SignTransactions(context.Background(), "tenant1", "550e8400-e29b-11d4-a716-446655440000", []string{}, SignOptions{})
+
+	assertEqual(t, ErrEmptyBatch, err)
 }
 
 func TestSignTransaction_ErrNotFound(t *testing.T) {
 	db := &SignatureDeviceInMemoryDbStub{
-		FindByIdFunc: func(key persistence.Id) (persistence.SignatureDevice, error) {
+		FindByIdFunc: func(tenant persistence.TenantId, key persistence.Id) (persistence.SignatureDevice, error) {
 			return persistence.SignatureDevice{}, ErrNotFound
 		},
 	}
-	domain := NewSignatureDeviceDomain(db)
+	domain := NewSignatureDeviceDomain(db, clock)
 
-	_, err := domain.SignTransaction("550e8400-e29b-11d4-a716-446655440000", "test")
+	_, err := domain.SignTransaction(context.Background(), "tenant1", "550e8400-e29b-11d4-a716-446655440000", "test", SignOptions{})
 
 	assertEqual(t, ErrNotFound, err)
 }
 
 func TestReadSignatureDevices_Ok(t *testing.T) {
+	var dbQuery persistence.DeviceQuery
 	db := &SignatureDeviceInMemoryDbStub{
-		FindAllFunc: func() []persistence.SignatureDevice {
-			return []persistence.SignatureDevice{device1}
+		QueryFunc: func(tenant persistence.TenantId, query persistence.DeviceQuery) (persistence.DevicePage, error) {
+			dbQuery = query
+			return persistence.DevicePage{Devices: []persistence.SignatureDevice{device1}}, nil
 		},
 	}
-	domain := NewSignatureDeviceDomain(db)
+	domain := NewSignatureDeviceDomain(db, clock)
 
-	devices := domain.ReadSignatureDevices()
+	page, err := domain.ReadSignatureDevices(context.Background(), "tenant1", DeviceQuery{})
+	devices := page.Devices
 
+	assertEqual(t, nil, err)
+	assertEqual(t, persistence.SortByCreatedAt, dbQuery.SortBy)
+	assertEqual(t, DefaultPageSize, dbQuery.Limit)
+	assertEqual(t, "", page.NextCursor)
 	assertEqual(t, SignatureDevice{
+		Tenant:           "tenant1",
 		Id:               "550e8400-e29b-11d4-a716-446655440000",
 		Algorithm:        "ECC",
 		Label:            "device1",
@@ -185,3 +300,60 @@ func TestReadSignatureDevices_Ok(t *testing.T) {
 		LastSignature:    "NTUwZTg0MDAtZTI5Yi0xMWQ0LWE3MTYtNDQ2NjU1NDQwMDAw",
 	}, devices[0])
 }
+
+func TestSignTransactions_RecordsEvents(t *testing.T) {
+	store := persistence.NewEventStore()
+	db, _ := persistence.NewEventSourcedSignatureDeviceDb(store)
+	domain := NewSignatureDeviceDomain(db, clock)
+	_, _ = domain.CreateSignatureDevice(context.Background(), "tenant1", "550e8400-e29b-11d4-a716-446655440000", "ECC", "", DeviceOptions{})
+
+	signatures, err := domain.SignTransactions(context.Background(), "tenant1", "550e8400-e29b-11d4-a716-446655440000", []string{"a", "b"}, SignOptions{KeyId: "key"})
+	_, _ = domain.DecommissionSignatureDevice(context.Background(), "tenant1", "550e8400-e29b-11d4-a716-446655440000", 0)
+	events, _ := store.Load("tenant1", "550e8400-e29b-11d4-a716-446655440000")
+
+	assertEqual(t, nil, err)
+	assertEqual(t, 4, len(events))
+	assertEqual(t, persistence.EventDeviceCreated, events[0].Type())
+	for i, signature := range signatures {
+		signed := events[i+1].Data.(persistence.TransactionSigned)
+		assertEqual(t, i, signed.Counter)
+		assertEqual(t, "key", signature.KeyId)
+		assertEqual(t, "key", signed.KeyId)
+		assertEqual(t, signature.SignedData, signed.SignedData)
+		assertEqual(t, signature.Signature, signed.Signature)
+	}
+	assertEqual(t, persistence.EventDecommissioned, events[3].Type())
+}
+
+func TestExpectedVersion_ErrPreconditionFailed(t *testing.T) {
+	domain := NewSignatureDeviceDomain(persistence.NewSignatureDeviceDb(), clock)
+	created, _ := domain.CreateSignatureDevice(context.Background(), "tenant1", "550e8400-e29b-11d4-a716-446655440000", "ECC", "", DeviceOptions{})
+	label := "till"
+
+	_, signErr := domain.SignTransaction(context.Background(), "tenant1", "550e8400-e29b-11d4-a716-446655440000", "a", SignOptions{ExpectedVersion: 2})
+	_, batchErr := domain.SignTransactions(context.Background(), "tenant1", "550e8400-e29b-11d4-a716-446655440000", []string{"a"}, SignOptions{ExpectedVersion: 2})
+	_, updateErr := domain.UpdateSignatureDevice(context.Background(), "tenant1", "550e8400-e29b-11d4-a716-446655440000", DevicePatch{Label: &label, ExpectedVersion: 2})
+	_, decommissionErr := domain.DecommissionSignatureDevice(context.Background(), "tenant1", "550e8400-e29b-11d4-a716-446655440000", 2)
+
+	assertEqual(t, 1, created.Version)
+	assertEqual(t, ErrPreconditionFailed, signErr)
+	assertEqual(t, ErrPreconditionFailed, batchErr)
+	assertEqual(t, ErrPreconditionFailed, updateErr)
+	assertEqual(t, ErrPreconditionFailed, decommissionErr)
+}
+
+func TestExpectedVersion_Ok(t *testing.T) {
+	domain := NewSignatureDeviceDomain(persistence.NewSignatureDeviceDb(), clock)
+	_, _ = domain.CreateSignatureDevice(context.Background(), "tenant1", "550e8400-e29b-11d4-a716-446655440000", "ECC", "", DeviceOptions{})
+	label := "till"
+
+	updated, updateErr := domain.UpdateSignatureDevice(context.Background(), "tenant1", "550e8400-e29b-11d4-a716-446655440000", DevicePatch{Label: &label, ExpectedVersion: 1})
+	_, signErr := domain.SignTransaction(context.Background(), "tenant1", "550e8400-e29b-11d4-a716-446655440000", "a", SignOptions{ExpectedVersion: 2})
+	device, decommissionErr := domain.DecommissionSignatureDevice(context.Background(), "tenant1", "550e8400-e29b-11d4-a716-446655440000", 3)
+
+	assertEqual(t, nil, updateErr)
+	assertEqual(t, 2, updated.Version)
+	assertEqual(t, nil, signErr)
+	assertEqual(t, nil, decommissionErr)
+	assertEqual(t, 4, device.Version)
+}
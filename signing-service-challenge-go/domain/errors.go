@@ -0,0 +1,58 @@
+package domain
+
+import "errors"
+
+// ErrorCode identifies an error independently of its message. Codes are
+// stable, so that clients can rely on them.
+type ErrorCode string
+
+const (
+	CodeExists                ErrorCode = "device_exists"
+	CodeNotFound              ErrorCode = "device_not_found"
+	CodeModified              ErrorCode = "concurrent_modification"
+	CodeInvalidUUID           ErrorCode = "invalid_uuid"
+	CodeInvalidAlgorithm      ErrorCode = "invalid_algorithm"
+	CodeClockRegression       ErrorCode = "clock_regression"
+	CodeEmptyBatch            ErrorCode = "empty_batch"
+	CodeBatchTooLarge         ErrorCode = "batch_too_large"
+	CodeDecommissioned        ErrorCode = "device_decommissioned"
+	CodeDeviceLimit           ErrorCode = "device_limit_reached"
+	CodePreconditionFailed    ErrorCode = "precondition_failed"
+	CodeInvalidMode           ErrorCode = "invalid_mode"
+	CodeInvalidAggregation    ErrorCode = "invalid_aggregation"
+	CodeInvalidFormat         ErrorCode = "invalid_signature_format"
+	CodeMalformed             ErrorCode = "malformed_signature"
+	CodeInvalidSignature      ErrorCode = "invalid_signature"
+	CodeInvalidPatch          ErrorCode = "invalid_patch"
+	CodeInvalidTags           ErrorCode = "invalid_tags"
+	CodeInvalidMetadata       ErrorCode = "invalid_metadata"
+	CodeInvalidQuery          ErrorCode = "invalid_query"
+	CodeInvalidCursor         ErrorCode = "invalid_cursor"
+	CodeNotTimeStampAuthority ErrorCode = "not_time_stamp_authority"
+	CodeAggregationPending    ErrorCode = "aggregation_pending"
+)
+
+// Error is a domain error with a stable code. The sentinel errors of the
+// domain are Errors, so errors.Is keeps working on them.
+type Error struct {
+	Code    ErrorCode
+	Message string
+}
+
+func newError(code ErrorCode, message string) *Error {
+	return &Error{Code: code, Message: message}
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// CodeOf returns the code of the first Error in the chain of err, or an
+// empty code if there is none.
+func CodeOf(err error) ErrorCode {
+	var domainErr *Error
+	if errors.As(err, &domainErr) {
+		return domainErr.Code
+	}
+	return ""
+}
@@ -0,0 +1,14 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestCodeOf(t *testing.T) {
+	assertEqual(t, CodeNotFound, CodeOf(ErrNotFound))
+	assertEqual(t, CodeInvalidQuery, CodeOf(fmt.Errorf("%w: limit", ErrInvalidQuery)))
+	assertEqual(t, ErrorCode(""), CodeOf(errors.New("unknown")))
+	assertEqual(t, true, errors.Is(fmt.Errorf("wrapped: %w", ErrDecommissioned), ErrDecommissioned))
+}
@@ -0,0 +1,69 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type EventType string
+
+const (
+	EventDeviceCreated        EventType = "device.created"
+	EventSignatureCreated     EventType = "signature.created"
+	EventDeviceDecommissioned EventType = "device.decommissioned"
+	// EventDeviceUpdated is published when the label, tags or metadata change.
+	EventDeviceUpdated EventType = "device.updated"
+	// EventDeviceKeyRotated is published when the key pair is replaced.
+	EventDeviceKeyRotated EventType = "device.key_rotated"
+)
+
+// EventTypes lists every event the domain publishes.
+var EventTypes = []EventType{
+	EventDeviceCreated,
+	EventSignatureCreated,
+	EventDeviceDecommissioned,
+	EventDeviceUpdated,
+	EventDeviceKeyRotated,
+}
+
+// Event describes a state change of a signature device. Signature is only
+// set for EventSignatureCreated.
+type Event struct {
+	Id         string
+	Type       EventType
+	OccurredAt time.Time
+	Device     SignatureDevice
+	Signature  *Signature
+}
+
+// IEventPublisher receives the events of the domain after they have been
+// persisted, in the order they were committed. Publish must not block the
+// caller.
+type IEventPublisher interface {
+	Publish(event Event)
+}
+
+// Option configures optional collaborators of a SignatureDeviceDomain.
+type Option func(d *SignatureDeviceDomain)
+
+// WithEventPublisher publishes the events of the domain to publisher.
+// It can be passed several times to publish to more than one publisher.
+func WithEventPublisher(publisher IEventPublisher) Option {
+	return func(d *SignatureDeviceDomain) {
+		d.publishers = append(d.publishers, publisher)
+	}
+}
+
+func (d *SignatureDeviceDomain) publish(eventType EventType, occurredAt time.Time, device SignatureDevice, signature *Signature) {
+	event := Event{
+		Id:         uuid.NewString(),
+		Type:       eventType,
+		OccurredAt: occurredAt,
+		Device:     device,
+		Signature:  signature,
+	}
+	for _, publisher := range d.publishers {
+		publisher.Publish(event)
+	}
+}
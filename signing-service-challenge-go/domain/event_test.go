@@ -0,0 +1,143 @@
+package domain
+
+import (
+	"context"
+	"testing"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/persistence"
+)
+
+type EventPublisherStub struct {
+	Events []Event
+}
+
+func (p *EventPublisherStub) Publish(event Event) {
+	p.Events = append(p.Events, event)
+}
+
+func TestCreateSignatureDevice_PublishesDeviceCreated(t *testing.T) {
+	publisher := &EventPublisherStub{}
+	db := &SignatureDeviceInMemoryDbStub{
+		StoreFunc: func(device persistence.SignatureDevice) error {
+			return nil
+		},
+	}
+	domain := NewSignatureDeviceDomain(db, clock, WithEventPublisher(publisher))
+
+	device, _ := domain.CreateSignatureDevice(context.Background(), "tenant1", "550e8400-e29b-11d4-a716-446655440000", "ECC", "", DeviceOptions{})
+
+	assertEqual(t, 1, len(publisher.Events))
+	assertEqual(t, EventDeviceCreated, publisher.Events[0].Type)
+	assertEqual(t, clock.Time, publisher.Events[0].OccurredAt)
+	assertEqual(t, device, publisher.Events[0].Device)
+}
+
+func TestSignTransactions_PublishesSignatureCreated(t *testing.T) {
+	publisher := &EventPublisherStub{}
+	db := &SignatureDeviceInMemoryDbStub{
+		FindByIdFunc: func(tenant persistence.TenantId, key persistence.Id) (persistence.SignatureDevice, error) {
+			return device1, nil
+		},
+		CompareAndSwapFunc: func(old, new persistence.SignatureDevice) error {
+			return nil
+		},
+	}
+	domain := NewSignatureDeviceDomain(db, clock, WithEventPublisher(publisher))
+
+	signatures, _ := domain.SignTransactions(context.Background(), "tenant1", "550e8400-e29b-11d4-a716-446655440000", []string{"a", "b"}, SignOptions{})
+
+	assertEqual(t, 2, len(publisher.Events))
+	for i, event := range publisher.Events {
+		assertEqual(t, EventSignatureCreated, event.Type)
+		assertEqual(t, signatures[i], *event.Signature)
+		assertEqual(t, i, event.Signature.Counter)
+		assertEqual(t, 2, event.Device.SignatureCounter)
+	}
+}
+
+func TestSignTransactions_NoEventOnModified(t *testing.T) {
+	publisher := &EventPublisherStub{}
+	db := &SignatureDeviceInMemoryDbStub{
+		FindByIdFunc: func(tenant persistence.TenantId, key persistence.Id) (persistence.SignatureDevice, error) {
+			return device1, nil
+		},
+		CompareAndSwapFunc: func(old, new persistence.SignatureDevice) error {
+			return persistence.ErrModified
+		},
+	}
+	domain := NewSignatureDeviceDomain(db, clock, WithEventPublisher(publisher))
+
+	_, err := domain.SignTransactions(context.Background(), "tenant1", "550e8400-e29b-11d4-a716-446655440000", []string{"a"}, SignOptions{})
+
+	assertEqual(t, ErrModified, err)
+	assertEqual(t, 0, len(publisher.Events))
+}
+
+func TestDecommissionSignatureDevice_Ok(t *testing.T) {
+	publisher := &EventPublisherStub{}
+	var newDevice persistence.SignatureDevice
+	db := &SignatureDeviceInMemoryDbStub{
+		FindByIdFunc: func(tenant persistence.TenantId, key persistence.Id) (persistence.SignatureDevice, error) {
+			return device1, nil
+		},
+		CompareAndSwapFunc: func(old, new persistence.SignatureDevice) error {
+			newDevice = new
+			return nil
+		},
+	}
+	domain := NewSignatureDeviceDomain(db, clock, WithEventPublisher(publisher))
+
+	device, err := domain.DecommissionSignatureDevice(context.Background(), "tenant1", "550e8400-e29b-11d4-a716-446655440000", 0)
+
+	assertEqual(t, nil, err)
+	assertEqual(t, clock.Time, device.DecommissionedAt)
+	assertEqual(t, clock.Time, newDevice.DecommissionedAt)
+	assertEqual(t, 1, len(publisher.Events))
+	assertEqual(t, EventDeviceDecommissioned, publisher.Events[0].Type)
+	assertEqual(t, device, publisher.Events[0].Device)
+}
+
+func TestDecommissionSignatureDevice_ErrDecommissioned(t *testing.T) {
+	device := device1
+	device.DecommissionedAt = clock.Time
+	db := &SignatureDeviceInMemoryDbStub{
+		FindByIdFunc: func(tenant persistence.TenantId, key persistence.Id) (persistence.SignatureDevice, error) {
+			return device, nil
+		},
+	}
+	domain := NewSignatureDeviceDomain(db, clock)
+
+	_, err := domain.DecommissionSignatureDevice(context.Background(), "tenant1", "550e8400-e29b-11d4-a716-446655440000", 0)
+
+	assertEqual(t, ErrDecommissioned, err)
+}
+
+func TestSignTransaction_ErrDecommissioned(t *testing.T) {
+	device := device1
+	device.DecommissionedAt = clock.Time
+	db := &SignatureDeviceInMemoryDbStub{
+		FindByIdFunc: func(tenant persistence.TenantId, key persistence.Id) (persistence.SignatureDevice, error) {
+			return device, nil
+		},
+	}
+	domain := NewSignatureDeviceDomain(db, clock)
+
+	_, err := domain.SignTransaction(context.Background(), "tenant1", "550e8400-e29b-11d4-a716-446655440000", "test", SignOptions{})
+
+	assertEqual(t, ErrDecommissioned, err)
+}
+
+func TestWithEventPublisher_PublishesToEveryPublisher(t *testing.T) {
+	first, second := &EventPublisherStub{}, &EventPublisherStub{}
+	db := &SignatureDeviceInMemoryDbStub{
+		StoreFunc: func(device persistence.SignatureDevice) error {
+			return nil
+		},
+	}
+	domain := NewSignatureDeviceDomain(db, clock, WithEventPublisher(first), WithEventPublisher(second))
+
+	_, _ = domain.CreateSignatureDevice(context.Background(), "tenant1", "550e8400-e29b-11d4-a716-446655440000", "ECC", "", DeviceOptions{})
+
+	assertEqual(t, 1, len(first.Events))
+	assertEqual(t, first.Events, second.Events)
+}
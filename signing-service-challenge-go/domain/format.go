@@ -0,0 +1,257 @@
+package domain
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/crypto"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/persistence"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/tracing"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrInvalidFormat    = newError(CodeInvalidFormat, "invalid signature format")
+	ErrMalformed        = newError(CodeMalformed, "malformed signature")
+	ErrInvalidSignature = newError(CodeInvalidSignature, "invalid signature")
+)
+
+// SignatureFormat selects how a signature is serialized for the client.
+type SignatureFormat string
+
+const (
+	// FormatRaw is a base64 encoded signature over the secured data.
+	FormatRaw SignatureFormat = "raw"
+	// FormatJWS is a JWS in compact serialization with the secured data as payload.
+	FormatJWS SignatureFormat = "jws"
+	// FormatJWSJSON is a JWS in flattened JSON serialization with the secured data as payload.
+	FormatJWSJSON SignatureFormat = "jws-json"
+	// FormatCOSE is a base64 encoded COSE_Sign1 with the secured data as payload.
+	FormatCOSE SignatureFormat = "cose"
+)
+
+// SignOptions controls optional aspects of a signature.
+type SignOptions struct {
+	Format SignatureFormat
+	// KeyId identifies the API key the transaction is signed for. It is
+	// recorded with the signature.
+	KeyId string
+	// ExpectedVersion fails the signing with ErrPreconditionFailed unless
+	// the device has this version, 0 signs at any version. Devices in
+	// ModeMerkle check it when the transaction is queued for a tree.
+	ExpectedVersion int
+	// leafKeyIds identifies the API key of every leaf of the tree whose
+	// root is signed.
+	leafKeyIds []string
+}
+
+// Verification is a signature presented for verification. SignedData is only
+// required for raw signatures, the other formats carry it as payload.
+type Verification struct {
+	Format     SignatureFormat
+	Signature  string
+	SignedData string
+}
+
+// signatureEncoder signs the secured data of the device at its current
+// counter. Besides the serialized signature it returns the raw signature
+// bytes, which are chained into the next signature of the device.
+type signatureEncoder func(device persistence.SignatureDevice, signedData string) (string, []byte, error)
+
+// newSignatureEncoder creates the signer of the device once for all
+// signatures encoded in the given format.
+func newSignatureEncoder(format SignatureFormat, device persistence.SignatureDevice) (signatureEncoder, error) {
+	switch format {
+	case "", FormatRaw:
+		signer, err := crypto.NewSigner(device.Algorithm, device.PrivateKey)
+		if err != nil {
+			return nil, err
+		}
+		return func(_ persistence.SignatureDevice, signedData string) (string, []byte, error) {
+			signature, err := signer.Sign([]byte(signedData))
+			if err != nil {
+				return "", nil, err
+			}
+			return base64.StdEncoding.EncodeToString(signature), signature, nil
+		}, nil
+	case FormatJWS, FormatJWSJSON:
+		algorithm, signer, err := newJOSESigner(device)
+		if err != nil {
+			return nil, err
+		}
+		return func(device persistence.SignatureDevice, signedData string) (string, []byte, error) {
+			header := crypto.JWSHeader{
+				KeyId:   string(device.Id),
+				Counter: device.SignatureCounter,
+			}
+			jws, err := crypto.NewJWS(header, []byte(signedData), algorithm, signer)
+			if err != nil {
+				return "", nil, err
+			}
+			signature, _ := base64.RawURLEncoding.DecodeString(jws.Signature)
+			if format == FormatJWS {
+				return jws.Compact(), signature, nil
+			}
+			serialized, err := jws.JSON()
+			return serialized, signature, err
+		}, nil
+	case FormatCOSE:
+		algorithm, signer, err := newJOSESigner(device)
+		if err != nil {
+			return nil, err
+		}
+		return func(device persistence.SignatureDevice, signedData string) (string, []byte, error) {
+			header := crypto.COSEHeader{
+				KeyId:   string(device.Id),
+				Counter: device.SignatureCounter,
+			}
+			message, err := crypto.NewCOSESign1(header, []byte(signedData), algorithm, signer)
+			if err != nil {
+				return "", nil, err
+			}
+			return base64.StdEncoding.EncodeToString(message.Encode()), message.Signature(), nil
+		}, nil
+	default:
+		return nil, ErrInvalidFormat
+	}
+}
+
+// traced runs fn in a span of the given name covering the given number of
+// items and records the error of fn on it.
+func traced(ctx context.Context, name string, items int, fn func() error) error {
+	_, span := tracing.Start(ctx, name)
+	span.SetAttribute("items", strconv.Itoa(items))
+	err := fn()
+	span.SetError(err)
+	span.End()
+	return err
+}
+
+// verifySignature checks a signature of the device and returns the secured
+// data it covers. A signature is verified with the key that was current
+// at its signature counter, so that a retired key cannot sign for counters
+// after its rotation.
+func verifySignature(device persistence.SignatureDevice, verification Verification) (string, error) {
+	var err error = ErrInvalidSignature
+	for _, key := range verificationKeys(device) {
+		keyed := device
+		keyed.PublicKey = key.publicKey
+		var securedData string
+		securedData, err = verifyWithKey(keyed, verification)
+		if err == nil {
+			if !key.signed(securedData) {
+				return "", ErrInvalidSignature
+			}
+			return securedData, nil
+		}
+		if !errors.Is(err, ErrInvalidSignature) {
+			return "", err
+		}
+	}
+	return "", err
+}
+
+// verificationKey is a public key of a device and the range of signature
+// counters it signed, until is -1 for the current key.
+type verificationKey struct {
+	publicKey []byte
+	from      int
+	until     int
+}
+
+// verificationKeys returns the current key of the device followed by the
+// retired ones, newest first.
+func verificationKeys(device persistence.SignatureDevice) []verificationKey {
+	keys := []verificationKey{{publicKey: device.PublicKey, until: -1}}
+	for i := len(device.RetiredKeys) - 1; i >= 0; i-- {
+		keys[len(keys)-1].from = device.RetiredKeys[i].UntilCounter
+		keys = append(keys, verificationKey{publicKey: device.RetiredKeys[i].PublicKey, until: device.RetiredKeys[i].UntilCounter})
+	}
+	return keys
+}
+
+// signed reports whether the key signed the counter the secured data,
+// <counter>_<data>_<last_signature>_<signed_at>, starts with.
+func (k verificationKey) signed(securedData string) bool {
+	if k.from == 0 && k.until < 0 {
+		return true
+	}
+	prefix, _, _ := strings.Cut(securedData, "_")
+	counter, err := strconv.Atoi(prefix)
+	return err == nil && counter >= k.from && (k.until < 0 || counter < k.until)
+}
+
+func verifyWithKey(device persistence.SignatureDevice, verification Verification) (string, error) {
+	switch verification.Format {
+	case "", FormatRaw:
+		signature, err := base64.StdEncoding.DecodeString(verification.Signature)
+		if err != nil {
+			return "", ErrMalformed
+		}
+		verifier, err := crypto.NewVerifier(device.Algorithm, device.PublicKey)
+		if err != nil {
+			return "", err
+		}
+		if err := verifier.Verify([]byte(verification.SignedData), signature); err != nil {
+			return "", ErrInvalidSignature
+		}
+		return verification.SignedData, nil
+	case FormatJWS, FormatJWSJSON:
+		algorithm, verifier, err := newJOSEVerifier(device)
+		if err != nil {
+			return "", err
+		}
+		jws, err := crypto.ParseJWS(verification.Signature)
+		if err != nil {
+			return "", ErrMalformed
+		}
+		header, payload, err := jws.Verify(algorithm, verifier)
+		return verifiedPayload(device, header.KeyId, payload, err)
+	case FormatCOSE:
+		algorithm, verifier, err := newJOSEVerifier(device)
+		if err != nil {
+			return "", err
+		}
+		data, err := base64.StdEncoding.DecodeString(verification.Signature)
+		if err != nil {
+			return "", ErrMalformed
+		}
+		message, err := crypto.ParseCOSESign1(data)
+		if err != nil {
+			return "", ErrMalformed
+		}
+		header, payload, err := message.Verify(algorithm, verifier)
+		return verifiedPayload(device, header.KeyId, payload, err)
+	default:
+		return "", ErrInvalidFormat
+	}
+}
+
+func verifiedPayload(device persistence.SignatureDevice, keyId string, payload []byte, err error) (string, error) {
+	if errors.Is(err, crypto.ErrMalformed) {
+		return "", ErrMalformed
+	}
+	if err != nil || keyId != string(device.Id) {
+		return "", ErrInvalidSignature
+	}
+	return string(payload), nil
+}
+
+func newJOSESigner(device persistence.SignatureDevice) (crypto.JOSEAlgorithm, crypto.Signer, error) {
+	algorithm, err := crypto.NewJOSEAlgorithm(device.Algorithm)
+	if err != nil {
+		return crypto.JOSEAlgorithm{}, nil, err
+	}
+	signer, err := crypto.NewSignerWithHash(device.Algorithm, device.PrivateKey, algorithm.Hash)
+	return algorithm, signer, err
+}
+
+func newJOSEVerifier(device persistence.SignatureDevice) (crypto.JOSEAlgorithm, crypto.Verifier, error) {
+	algorithm, err := crypto.NewJOSEAlgorithm(device.Algorithm)
+	if err != nil {
+		return crypto.JOSEAlgorithm{}, nil, err
+	}
+	verifier, err := crypto.NewVerifierWithHash(device.Algorithm, device.PublicKey, algorithm.Hash)
+	return algorithm, verifier, err
+}
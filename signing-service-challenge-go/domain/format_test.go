@@ -0,0 +1,67 @@
+package domain
+
+import (
+	"context"
+	"testing"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/persistence"
+)
+
+func TestVerifySignature_Ok(t *testing.T) {
+	for _, format := range []SignatureFormat{FormatRaw, FormatJWS, FormatJWSJSON, FormatCOSE} {
+		db := persistence.NewSignatureDeviceDb()
+		_ = db.Store(context.Background(), device1)
+		domain := NewSignatureDeviceDomain(db, clock)
+		signature, _ := domain.SignTransaction(context.Background(), "tenant1", "550e8400-e29b-11d4-a716-446655440000", "test", SignOptions{
+			Format: format,
+		})
+
+		signedData, err := domain.VerifySignature(context.Background(), "tenant1", "550e8400-e29b-11d4-a716-446655440000", Verification{
+			Format:     format,
+			Signature:  signature.Signature,
+			SignedData: signature.SignedData,
+		})
+
+		assertEqual(t, nil, err)
+		assertEqual(t, signature.SignedData, signedData)
+	}
+}
+
+func TestVerifySignature_ErrInvalidSignature(t *testing.T) {
+	db := persistence.NewSignatureDeviceDb()
+	_ = db.Store(context.Background(), device1)
+	domain := NewSignatureDeviceDomain(db, clock)
+	signature, _ := domain.SignTransaction(context.Background(), "tenant1", "550e8400-e29b-11d4-a716-446655440000", "test", SignOptions{})
+
+	_, err := domain.VerifySignature(context.Background(), "tenant1", "550e8400-e29b-11d4-a716-446655440000", Verification{
+		Signature:  signature.Signature,
+		SignedData: "tampered",
+	})
+
+	assertEqual(t, ErrInvalidSignature, err)
+}
+
+func TestVerifySignature_ErrMalformed(t *testing.T) {
+	db := persistence.NewSignatureDeviceDb()
+	_ = db.Store(context.Background(), device1)
+	domain := NewSignatureDeviceDomain(db, clock)
+
+	_, err := domain.VerifySignature(context.Background(), "tenant1", "550e8400-e29b-11d4-a716-446655440000", Verification{
+		Format:    FormatJWS,
+		Signature: "not a jws",
+	})
+
+	assertEqual(t, ErrMalformed, err)
+}
+
+func TestSignTransaction_ErrInvalidFormat(t *testing.T) {
+	db := persistence.NewSignatureDeviceDb()
+	_ = db.Store(context.Background(), device1)
+	domain := NewSignatureDeviceDomain(db, clock)
+
+	_, err := domain.SignTransaction(context.Background(), "tenant1", "550e8400-e29b-11d4-a716-446655440000", "test", SignOptions{
+		Format: "xml",
+	})
+
+	assertEqual(t, ErrInvalidFormat, err)
+}
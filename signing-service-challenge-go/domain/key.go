@@ -0,0 +1,47 @@
+package domain
+
+import (
+	"context"
+	"errors"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/crypto"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/persistence"
+)
+
+// RotateSignatureDeviceKey replaces the key pair of a device with a new one
+// of the same algorithm. Signatures made before the rotation are still
+// verified with the key that made them. It fails with ErrPreconditionFailed
+// unless the device has the expected version, 0 rotates any version.
+func (d *SignatureDeviceDomain) RotateSignatureDeviceKey(ctx context.Context, tenant, id string, expectedVersion int) (SignatureDevice, error) {
+	device, err := d.db.FindById(ctx, persistence.TenantId(tenant), persistence.Id(id))
+	if err != nil {
+		if errors.Is(err, persistence.ErrNotFound) {
+			return SignatureDevice{}, ErrNotFound
+		}
+		return SignatureDevice{}, err
+	}
+	if err := checkVersion(device, expectedVersion); err != nil {
+		return SignatureDevice{}, err
+	}
+	if !device.DecommissionedAt.IsZero() {
+		return SignatureDevice{}, ErrDecommissioned
+	}
+
+	publicKey, privateKey, err := crypto.NewKeyPair(device.Algorithm)
+	if err != nil {
+		return SignatureDevice{}, err
+	}
+	now := d.clock.Now()
+	newDevice := device.Record(now, persistence.KeyRotated{
+		PublicKey:  publicKey,
+		PrivateKey: privateKey,
+	})
+	rotated := toSignatureDevice(newDevice)
+	err = d.commit(ctx, device, newDevice, func() {
+		d.publish(EventDeviceKeyRotated, now, rotated, nil)
+	})
+	if err != nil {
+		return SignatureDevice{}, err
+	}
+	return rotated, nil
+}
@@ -0,0 +1,69 @@
+package domain
+
+import (
+	"context"
+	"encoding/base64"
+	"testing"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/crypto"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/persistence"
+)
+
+func TestRotateSignatureDeviceKey_VerifiesWithOldKey(t *testing.T) {
+	for _, format := range []SignatureFormat{FormatRaw, FormatJWS, FormatCOSE} {
+		publisher := &EventPublisherStub{}
+		db := persistence.NewSignatureDeviceDb()
+		_ = db.Store(context.Background(), device1)
+		domain := NewSignatureDeviceDomain(db, clock, WithEventPublisher(publisher))
+		before, _ := domain.SignTransaction(context.Background(), "tenant1", string(device1.Id), "before", SignOptions{Format: format})
+
+		rotated, err := domain.RotateSignatureDeviceKey(context.Background(), "tenant1", string(device1.Id), 0)
+		assertEqual(t, nil, err)
+		assertEqual(t, EventDeviceKeyRotated, publisher.Events[len(publisher.Events)-1].Type)
+		assertEqual(t, rotated, publisher.Events[len(publisher.Events)-1].Device)
+		after, _ := domain.SignTransaction(context.Background(), "tenant1", string(device1.Id), "after", SignOptions{Format: format})
+
+		for _, signature := range []Signature{before, after} {
+			signedData, err := domain.VerifySignature(context.Background(), "tenant1", string(device1.Id), Verification{
+				Format:     format,
+				Signature:  signature.Signature,
+				SignedData: signature.SignedData,
+			})
+			assertEqual(t, nil, err)
+			assertEqual(t, signature.SignedData, signedData)
+		}
+	}
+}
+
+func TestVerifySignature_RetiredKeyCannotSignLaterCounters(t *testing.T) {
+	db := persistence.NewSignatureDeviceDb()
+	_ = db.Store(context.Background(), device1)
+	domain := NewSignatureDeviceDomain(db, clock)
+	_, _ = domain.SignTransaction(context.Background(), "tenant1", string(device1.Id), "before", SignOptions{})
+	_, _ = domain.RotateSignatureDeviceKey(context.Background(), "tenant1", string(device1.Id), 0)
+
+	signer, _ := crypto.NewSigner(device1.Algorithm, device1.PrivateKey)
+	signedData := "1_forged_last_2024-01-02T03:04:05Z"
+	forged, _ := signer.Sign([]byte(signedData))
+	_, err := domain.VerifySignature(context.Background(), "tenant1", string(device1.Id), Verification{
+		Signature:  base64.StdEncoding.EncodeToString(forged),
+		SignedData: signedData,
+	})
+
+	assertEqual(t, ErrInvalidSignature, err)
+}
+
+func TestRotateSignatureDeviceKey_ErrDecommissioned(t *testing.T) {
+	device := device1
+	device.DecommissionedAt = clock.Time
+	db := &SignatureDeviceInMemoryDbStub{
+		FindByIdFunc: func(tenant persistence.TenantId, key persistence.Id) (persistence.SignatureDevice, error) {
+			return device, nil
+		},
+	}
+	domain := NewSignatureDeviceDomain(db, clock)
+
+	_, err := domain.RotateSignatureDeviceKey(context.Background(), "tenant1", string(device1.Id), 0)
+
+	assertEqual(t, ErrDecommissioned, err)
+}
@@ -0,0 +1,184 @@
+package domain
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/persistence"
+)
+
+var (
+	ErrInvalidPatch    = newError(CodeInvalidPatch, "invalid patch")
+	ErrInvalidTags     = newError(CodeInvalidTags, "invalid tags")
+	ErrInvalidMetadata = newError(CodeInvalidMetadata, "invalid metadata")
+)
+
+const (
+	// MaxTags limits the number of tags of a device.
+	MaxTags = 50
+	// MaxMetadataSize limits the size of the JSON encoded metadata of a device.
+	MaxMetadataSize = 16 * 1024
+)
+
+// DevicePatch changes the mutable attributes of a device. Tags and Metadata
+// are JSON Merge Patches (RFC 7386): members of an object are merged, null
+// members are removed and null removes everything. Absent attributes stay
+// unchanged.
+type DevicePatch struct {
+	Label    *string
+	Tags     json.RawMessage
+	Metadata json.RawMessage
+	// ExpectedVersion fails the patch with ErrPreconditionFailed unless the
+	// device has this version, 0 patches any version.
+	ExpectedVersion int
+}
+
+// UpdateSignatureDevice applies a patch to the label, tags and metadata of
+// a device. It records an event for every attribute that changed.
+func (d *SignatureDeviceDomain) UpdateSignatureDevice(ctx context.Context, tenant, id string, patch DevicePatch) (SignatureDevice, error) {
+	device, err := d.db.FindById(ctx, persistence.TenantId(tenant), persistence.Id(id))
+	if err != nil {
+		if errors.Is(err, persistence.ErrNotFound) {
+			return SignatureDevice{}, ErrNotFound
+		}
+		return SignatureDevice{}, err
+	}
+	if err := checkVersion(device, patch.ExpectedVersion); err != nil {
+		return SignatureDevice{}, err
+	}
+
+	tags, err := patchTags(device.Tags, patch.Tags)
+	if err != nil {
+		return SignatureDevice{}, err
+	}
+	metadata, err := patchMetadata(device.Metadata, patch.Metadata)
+	if err != nil {
+		return SignatureDevice{}, err
+	}
+
+	now := d.clock.Now()
+	newDevice := device
+	if patch.Label != nil && *patch.Label != device.Label {
+		newDevice = newDevice.Record(now, persistence.LabelChanged{Label: *patch.Label})
+	}
+	if !reflect.DeepEqual(tags, device.Tags) {
+		newDevice = newDevice.Record(now, persistence.TagsChanged{Tags: tags})
+	}
+	if !reflect.DeepEqual(metadata, device.Metadata) {
+		newDevice = newDevice.Record(now, persistence.MetadataChanged{Metadata: metadata})
+	}
+	if len(newDevice.Uncommitted) == 0 {
+		return toSignatureDevice(device), nil
+	}
+
+	updated := toSignatureDevice(newDevice)
+	err = d.commit(ctx, device, newDevice, func() {
+		d.publish(EventDeviceUpdated, now, updated, nil)
+	})
+	if err != nil {
+		return SignatureDevice{}, err
+	}
+	return updated, nil
+}
+
+// patchTags returns the merged tags, or nil if there are none.
+func patchTags(tags map[string]string, patch json.RawMessage) (map[string]string, error) {
+	if patch == nil {
+		return tags, nil
+	}
+	var target interface{}
+	if tags != nil {
+		object := make(map[string]interface{}, len(tags))
+		for key, value := range tags {
+			object[key] = value
+		}
+		target = object
+	}
+	merged, err := mergePatch(target, patch)
+	if err != nil {
+		return nil, ErrInvalidPatch
+	}
+	if merged == nil {
+		return nil, nil
+	}
+	object, ok := merged.(map[string]interface{})
+	if !ok || len(object) > MaxTags {
+		return nil, ErrInvalidTags
+	}
+	if len(object) == 0 {
+		return nil, nil
+	}
+	result := make(map[string]string, len(object))
+	for key, value := range object {
+		tag, ok := value.(string)
+		if !ok || key == "" || strings.Contains(key, ":") {
+			return nil, ErrInvalidTags
+		}
+		result[key] = tag
+	}
+	return result, nil
+}
+
+// patchMetadata returns the merged metadata, or nil if there is none.
+func patchMetadata(metadata map[string]interface{}, patch json.RawMessage) (map[string]interface{}, error) {
+	if patch == nil {
+		return metadata, nil
+	}
+	var target interface{}
+	if metadata != nil {
+		target = metadata
+	}
+	merged, err := mergePatch(target, patch)
+	if err != nil {
+		return nil, ErrInvalidPatch
+	}
+	if merged == nil {
+		return nil, nil
+	}
+	object, ok := merged.(map[string]interface{})
+	if !ok {
+		return nil, ErrInvalidMetadata
+	}
+	if len(object) == 0 {
+		return nil, nil
+	}
+	encoded, _ := json.Marshal(object)
+	if len(encoded) > MaxMetadataSize {
+		return nil, ErrInvalidMetadata
+	}
+	return object, nil
+}
+
+func mergePatch(target interface{}, patch json.RawMessage) (interface{}, error) {
+	var decoded interface{}
+	if err := json.Unmarshal(patch, &decoded); err != nil {
+		return nil, err
+	}
+	return merge(target, decoded), nil
+}
+
+// merge implements the MergePatch function of RFC 7386. The target is not
+// modified.
+func merge(target interface{}, patch interface{}) interface{} {
+	patchObject, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+	result := make(map[string]interface{})
+	if targetObject, ok := target.(map[string]interface{}); ok {
+		for key, value := range targetObject {
+			result[key] = value
+		}
+	}
+	for key, value := range patchObject {
+		if value == nil {
+			delete(result, key)
+		} else {
+			result[key] = merge(result[key], value)
+		}
+	}
+	return result
+}
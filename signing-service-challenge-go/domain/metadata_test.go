@@ -0,0 +1,103 @@
+package domain
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/persistence"
+)
+
+func TestUpdateSignatureDevice_MergesPatch(t *testing.T) {
+	store := persistence.NewEventStore()
+	db, _ := persistence.NewEventSourcedSignatureDeviceDb(store)
+	domain := NewSignatureDeviceDomain(db, clock)
+	_, _ = domain.CreateSignatureDevice(context.Background(), "tenant1", "550e8400-e29b-11d4-a716-446655440000", "ECC", "till", DeviceOptions{})
+
+	label := "till 1"
+	_, err := domain.UpdateSignatureDevice(context.Background(), "tenant1", "550e8400-e29b-11d4-a716-446655440000", DevicePatch{
+		Label:    &label,
+		Tags:     json.RawMessage(`{"store": "12", "till": "1"}`),
+		Metadata: json.RawMessage(`{"location": {"city": "Munich", "floor": 1}}`),
+	})
+	assertEqual(t, nil, err)
+	device, err := domain.UpdateSignatureDevice(context.Background(), "tenant1", "550e8400-e29b-11d4-a716-446655440000", DevicePatch{
+		Tags:     json.RawMessage(`{"till": null}`),
+		Metadata: json.RawMessage(`{"location": {"floor": null}, "serial": "A-1"}`),
+	})
+
+	assertEqual(t, nil, err)
+	assertEqual(t, "till 1", device.Label)
+	assertEqual(t, map[string]string{"store": "12"}, device.Tags)
+	assertEqual(t, map[string]interface{}{
+		"location": map[string]interface{}{"city": "Munich"},
+		"serial":   "A-1",
+	}, device.Metadata)
+	assertEqual(t, 0, device.SignatureCounter)
+
+	events, _ := store.Load("tenant1", "550e8400-e29b-11d4-a716-446655440000")
+	types := make([]persistence.EventType, 0, len(events))
+	for _, event := range events {
+		types = append(types, event.Type())
+	}
+	assertEqual(t, []persistence.EventType{
+		persistence.EventDeviceCreated,
+		persistence.EventLabelChanged, persistence.EventTagsChanged, persistence.EventMetadataChanged,
+		persistence.EventTagsChanged, persistence.EventMetadataChanged,
+	}, types)
+}
+
+func TestUpdateSignatureDevice_NullRemovesAll(t *testing.T) {
+	domain := NewSignatureDeviceDomain(persistence.NewSignatureDeviceDb(), clock)
+	_, _ = domain.CreateSignatureDevice(context.Background(), "tenant1", "550e8400-e29b-11d4-a716-446655440000", "ECC", "", DeviceOptions{})
+	_, _ = domain.UpdateSignatureDevice(context.Background(), "tenant1", "550e8400-e29b-11d4-a716-446655440000", DevicePatch{
+		Tags:     json.RawMessage(`{"store": "12"}`),
+		Metadata: json.RawMessage(`{"serial": "A-1"}`),
+	})
+
+	device, err := domain.UpdateSignatureDevice(context.Background(), "tenant1", "550e8400-e29b-11d4-a716-446655440000", DevicePatch{
+		Tags:     json.RawMessage(`null`),
+		Metadata: json.RawMessage(`null`),
+	})
+
+	assertEqual(t, nil, err)
+	assertEqual(t, map[string]string(nil), device.Tags)
+	assertEqual(t, map[string]interface{}(nil), device.Metadata)
+}
+
+func TestUpdateSignatureDevice_Errors(t *testing.T) {
+	domain := NewSignatureDeviceDomain(persistence.NewSignatureDeviceDb(), clock)
+	_, _ = domain.CreateSignatureDevice(context.Background(), "tenant1", "550e8400-e29b-11d4-a716-446655440000", "ECC", "", DeviceOptions{})
+
+	cases := []struct {
+		patch    DevicePatch
+		expected error
+	}{
+		{DevicePatch{Tags: json.RawMessage(`{"store": 12}`)}, ErrInvalidTags},
+		{DevicePatch{Tags: json.RawMessage(`{"store:id": "12"}`)}, ErrInvalidTags},
+		{DevicePatch{Tags: json.RawMessage(`["store"]`)}, ErrInvalidTags},
+		{DevicePatch{Metadata: json.RawMessage(`"serial"`)}, ErrInvalidMetadata},
+		{DevicePatch{Metadata: json.RawMessage(`{`)}, ErrInvalidPatch},
+	}
+	for _, c := range cases {
+		_, err := domain.UpdateSignatureDevice(context.Background(), "tenant1", "550e8400-e29b-11d4-a716-446655440000", c.patch)
+		assertEqual(t, c.expected, err)
+	}
+	_, err := domain.UpdateSignatureDevice(context.Background(), "tenant2", "550e8400-e29b-11d4-a716-446655440000", DevicePatch{})
+	assertEqual(t, ErrNotFound, err)
+}
+
+func TestReadSignatureDevices_FiltersByTag(t *testing.T) {
+	domain := NewSignatureDeviceDomain(persistence.NewSignatureDeviceDb(), clock)
+	_, _ = domain.CreateSignatureDevice(context.Background(), "tenant1", "550e8400-e29b-11d4-a716-446655440000", "ECC", "", DeviceOptions{})
+	_, _ = domain.CreateSignatureDevice(context.Background(), "tenant1", "550e8400-e29b-11d4-a716-446655440001", "ECC", "", DeviceOptions{})
+	_, _ = domain.UpdateSignatureDevice(context.Background(), "tenant1", "550e8400-e29b-11d4-a716-446655440001", DevicePatch{
+		Tags: json.RawMessage(`{"store": "12"}`),
+	})
+
+	page, err := domain.ReadSignatureDevices(context.Background(), "tenant1", DeviceQuery{Tags: map[string]string{"store": "12"}})
+
+	assertEqual(t, nil, err)
+	assertEqual(t, 1, len(page.Devices))
+	assertEqual(t, "550e8400-e29b-11d4-a716-446655440001", page.Devices[0].Id)
+}
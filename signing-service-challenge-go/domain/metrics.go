@@ -0,0 +1,37 @@
+package domain
+
+import "time"
+
+// OutcomeSuccess is the outcome of a signing call without error. Failed
+// calls have the ErrorCode of their error as outcome.
+const OutcomeSuccess = "success"
+
+// ISigningMetrics observes the signing calls of the domain.
+type ISigningMetrics interface {
+	// ObserveSign is called once per SignTransaction and SignTransactions
+	// call. The algorithm is empty if the device was not found.
+	ObserveSign(algorithm string, outcome string, duration time.Duration)
+	// ObserveConflict is called whenever a change fails with ErrModified.
+	ObserveConflict()
+}
+
+// WithSigningMetrics reports the signing calls and conflicts to metrics.
+func WithSigningMetrics(metrics ISigningMetrics) Option {
+	return func(d *SignatureDeviceDomain) {
+		d.metrics = metrics
+	}
+}
+
+func (d *SignatureDeviceDomain) observeSign(algorithm string, start time.Time, err error) {
+	if d.metrics == nil {
+		return
+	}
+	outcome := OutcomeSuccess
+	if err != nil {
+		outcome = string(CodeOf(err))
+		if outcome == "" {
+			outcome = "error"
+		}
+	}
+	d.metrics.ObserveSign(algorithm, outcome, time.Since(start))
+}
@@ -0,0 +1,56 @@
+package domain
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/persistence"
+)
+
+type SigningMetricsStub struct {
+	Outcomes  []string
+	Conflicts int
+}
+
+func (s *SigningMetricsStub) ObserveSign(algorithm string, outcome string, _ time.Duration) {
+	s.Outcomes = append(s.Outcomes, algorithm+" "+outcome)
+}
+
+func (s *SigningMetricsStub) ObserveConflict() {
+	s.Conflicts++
+}
+
+func TestWithSigningMetrics_ObservesSignCalls(t *testing.T) {
+	metrics := &SigningMetricsStub{}
+	domain := NewSignatureDeviceDomain(persistence.NewSignatureDeviceDb(), clock, WithSigningMetrics(metrics))
+	_, _ = domain.CreateSignatureDevice(context.Background(), "tenant1", "550e8400-e29b-11d4-a716-446655440000", "ECC", "", DeviceOptions{})
+
+	_, _ = domain.SignTransaction(context.Background(), "tenant1", "550e8400-e29b-11d4-a716-446655440000", "data", SignOptions{})
+	_, _ = domain.SignTransactions(context.Background(), "tenant1", "550e8400-e29b-11d4-a716-446655440000", []string{"a", "b"}, SignOptions{ExpectedVersion: 1})
+	_, _ = domain.SignTransaction(context.Background(), "tenant1", "550e8400-e29b-11d4-a716-446655440001", "data", SignOptions{})
+
+	assertEqual(t, []string{"ECC success", "ECC precondition_failed", " device_not_found"}, metrics.Outcomes)
+}
+
+// conflictingDb fails every CompareAndSwap as if the device had been changed
+// concurrently.
+type conflictingDb struct {
+	persistence.ISignatureDeviceDb
+}
+
+func (db conflictingDb) CompareAndSwap(_ context.Context, _, _ persistence.SignatureDevice) error {
+	return persistence.ErrModified
+}
+
+func TestWithSigningMetrics_ObservesConflicts(t *testing.T) {
+	metrics := &SigningMetricsStub{}
+	domain := NewSignatureDeviceDomain(conflictingDb{persistence.NewSignatureDeviceDb()}, clock, WithSigningMetrics(metrics))
+	_, _ = domain.CreateSignatureDevice(context.Background(), "tenant1", "550e8400-e29b-11d4-a716-446655440000", "ECC", "", DeviceOptions{})
+
+	_, err := domain.SignTransaction(context.Background(), "tenant1", "550e8400-e29b-11d4-a716-446655440000", "data", SignOptions{})
+
+	assertEqual(t, ErrModified, err)
+	assertEqual(t, 1, metrics.Conflicts)
+	assertEqual(t, []string{"ECC concurrent_modification"}, metrics.Outcomes)
+}
@@ -0,0 +1,171 @@
+package domain
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/persistence"
+)
+
+var (
+	ErrInvalidQuery  = newError(CodeInvalidQuery, "invalid query")
+	ErrInvalidCursor = newError(CodeInvalidCursor, "invalid cursor")
+)
+
+const (
+	// DefaultPageSize is the page size of queries without a limit.
+	DefaultPageSize = 100
+	// MaxPageSize limits the page size of a query.
+	MaxPageSize = 1000
+)
+
+// DeviceState filters devices by whether they can still sign.
+type DeviceState string
+
+const (
+	DeviceStateActive         DeviceState = "active"
+	DeviceStateDecommissioned DeviceState = "decommissioned"
+)
+
+// SortField orders the devices of a query. Ties are ordered by id.
+type SortField string
+
+const (
+	SortByCreatedAt        SortField = "created_at"
+	SortByLabel            SortField = "label"
+	SortBySignatureCounter SortField = "signature_counter"
+)
+
+// DeviceQuery filters, orders and pages the devices of a tenant. The zero
+// DeviceQuery returns the first DefaultPageSize devices by creation time.
+type DeviceQuery struct {
+	Algorithm string
+	// LabelContains matches a substring of the label.
+	LabelContains string
+	State         DeviceState
+	// Tags must all be set on a device with equal values.
+	Tags map[string]string
+	// MinCounter and MaxCounter bound the signature counter inclusively
+	// if they are set.
+	MinCounter *int
+	MaxCounter *int
+	// Resources restricts the devices to the resource patterns of the
+	// access policy. Nil matches every device, an empty list none.
+	Resources  []string
+	SortBy     SortField
+	Descending bool
+	// Cursor is the NextCursor of the previous page of the same query.
+	Cursor string
+	Limit  int
+}
+
+type DevicePage struct {
+	Devices []SignatureDevice
+	// NextCursor is empty on the last page.
+	NextCursor string
+}
+
+// cursor is the opaque position behind a page. It repeats the order, so
+// that it cannot continue a query sorted differently.
+type cursor struct {
+	SortBy           SortField `json:"s"`
+	Descending       bool      `json:"d,omitempty"`
+	CreatedAt        time.Time `json:"t"`
+	Label            string    `json:"l,omitempty"`
+	SignatureCounter int       `json:"c,omitempty"`
+	Id               string    `json:"i"`
+}
+
+func encodeCursor(query persistence.DeviceQuery, device persistence.SignatureDevice) string {
+	position := device.Cursor()
+	bytes, _ := json.Marshal(cursor{
+		SortBy:           SortField(query.SortBy),
+		Descending:       query.Descending,
+		CreatedAt:        position.CreatedAt,
+		Label:            position.Label,
+		SignatureCounter: position.SignatureCounter,
+		Id:               string(position.Id),
+	})
+	return base64.RawURLEncoding.EncodeToString(bytes)
+}
+
+func decodeCursor(query persistence.DeviceQuery, value string) (*persistence.DeviceCursor, error) {
+	bytes, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var position cursor
+	if err := json.Unmarshal(bytes, &position); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	if position.SortBy != SortField(query.SortBy) || position.Descending != query.Descending {
+		return nil, ErrInvalidCursor
+	}
+	return &persistence.DeviceCursor{
+		CreatedAt:        position.CreatedAt,
+		Label:            position.Label,
+		SignatureCounter: position.SignatureCounter,
+		Id:               persistence.Id(position.Id),
+	}, nil
+}
+
+func (q DeviceQuery) toPersistence() (persistence.DeviceQuery, error) {
+	if q.Limit < 0 || q.Limit > MaxPageSize {
+		return persistence.DeviceQuery{}, ErrInvalidQuery
+	}
+	query := persistence.DeviceQuery{
+		Algorithm:     q.Algorithm,
+		LabelContains: q.LabelContains,
+		State:         persistence.DeviceState(q.State),
+		Tags:          q.Tags,
+		MinCounter:    q.MinCounter,
+		MaxCounter:    q.MaxCounter,
+		Resources:     q.Resources,
+		SortBy:        persistence.SortField(q.SortBy),
+		Descending:    q.Descending,
+		Limit:         q.Limit,
+	}
+	if query.SortBy == "" {
+		query.SortBy = persistence.SortByCreatedAt
+	}
+	if query.Limit == 0 {
+		query.Limit = DefaultPageSize
+	}
+	if err := query.Validate(); err != nil {
+		return persistence.DeviceQuery{}, ErrInvalidQuery
+	}
+	if q.Cursor != "" {
+		after, err := decodeCursor(query, q.Cursor)
+		if err != nil {
+			return persistence.DeviceQuery{}, err
+		}
+		query.After = after
+	}
+	return query, nil
+}
+
+func (d *SignatureDeviceDomain) ReadSignatureDevices(ctx context.Context, tenant string, query DeviceQuery) (DevicePage, error) {
+	dbQuery, err := query.toPersistence()
+	if err != nil {
+		return DevicePage{}, err
+	}
+	page, err := d.db.Query(ctx, persistence.TenantId(tenant), dbQuery)
+	if err != nil {
+		if errors.Is(err, persistence.ErrInvalidQuery) {
+			return DevicePage{}, ErrInvalidQuery
+		}
+		return DevicePage{}, err
+	}
+
+	result := DevicePage{Devices: make([]SignatureDevice, 0, len(page.Devices))}
+	for _, device := range page.Devices {
+		result.Devices = append(result.Devices, toSignatureDevice(device))
+	}
+	if page.More {
+		result.NextCursor = encodeCursor(dbQuery, page.Devices[len(page.Devices)-1])
+	}
+	return result, nil
+}
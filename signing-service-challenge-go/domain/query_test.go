@@ -0,0 +1,50 @@
+package domain
+
+import (
+	"context"
+	"testing"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/persistence"
+)
+
+func TestReadSignatureDevices_Pages(t *testing.T) {
+	domain := NewSignatureDeviceDomain(persistence.NewSignatureDeviceDb(), clock)
+	ids := []string{
+		"550e8400-e29b-11d4-a716-446655440000",
+		"550e8400-e29b-11d4-a716-446655440001",
+		"550e8400-e29b-11d4-a716-446655440002",
+	}
+	for _, id := range ids {
+		_, err := domain.CreateSignatureDevice(context.Background(), "tenant1", id, "ECC", "", DeviceOptions{})
+		assertEqual(t, nil, err)
+	}
+
+	read := make([]string, 0)
+	query := DeviceQuery{Limit: 2}
+	for {
+		page, err := domain.ReadSignatureDevices(context.Background(), "tenant1", query)
+		assertEqual(t, nil, err)
+		for _, device := range page.Devices {
+			read = append(read, device.Id)
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		query.Cursor = page.NextCursor
+	}
+	assertEqual(t, ids, read)
+}
+
+func TestReadSignatureDevices_ErrInvalidCursor(t *testing.T) {
+	domain := NewSignatureDeviceDomain(persistence.NewSignatureDeviceDb(), clock)
+	_, _ = domain.CreateSignatureDevice(context.Background(), "tenant1", "550e8400-e29b-11d4-a716-446655440000", "ECC", "", DeviceOptions{})
+	_, _ = domain.CreateSignatureDevice(context.Background(), "tenant1", "550e8400-e29b-11d4-a716-446655440001", "ECC", "", DeviceOptions{})
+	page, _ := domain.ReadSignatureDevices(context.Background(), "tenant1", DeviceQuery{Limit: 1})
+
+	_, err := domain.ReadSignatureDevices(context.Background(), "tenant1", DeviceQuery{Limit: 1, SortBy: SortByLabel, Cursor: page.NextCursor})
+	assertEqual(t, ErrInvalidCursor, err)
+	_, err = domain.ReadSignatureDevices(context.Background(), "tenant1", DeviceQuery{Cursor: "not a cursor"})
+	assertEqual(t, ErrInvalidCursor, err)
+	_, err = domain.ReadSignatureDevices(context.Background(), "tenant1", DeviceQuery{Limit: MaxPageSize + 1})
+	assertEqual(t, ErrInvalidQuery, err)
+}
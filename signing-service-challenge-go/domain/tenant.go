@@ -0,0 +1,37 @@
+package domain
+
+import (
+	"context"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/persistence"
+)
+
+// ITenantLimits provides the limits of a tenant.
+type ITenantLimits interface {
+	// MaxDevices returns the number of active devices a tenant may own, or 0
+	// if the number is not limited.
+	MaxDevices(tenant string) int
+}
+
+// WithTenantLimits enforces the limits of the tenants on device creation.
+func WithTenantLimits(limits ITenantLimits) Option {
+	return func(d *SignatureDeviceDomain) {
+		d.limits = limits
+	}
+}
+
+// checkDeviceLimit has to be called with commitMu held, so that concurrent
+// creations cannot exceed the limit. Decommissioned devices do not count.
+func (d *SignatureDeviceDomain) checkDeviceLimit(ctx context.Context, tenant persistence.TenantId) error {
+	if d.limits == nil {
+		return nil
+	}
+	max := d.limits.MaxDevices(string(tenant))
+	if max <= 0 {
+		return nil
+	}
+	if d.db.CountActive(ctx, tenant) >= max {
+		return ErrDeviceLimit
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package domain
+
+import (
+	"context"
+	"testing"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/persistence"
+)
+
+type TenantLimitsStub map[string]int
+
+func (s TenantLimitsStub) MaxDevices(tenant string) int {
+	return s[tenant]
+}
+
+func TestTenants_AreIsolated(t *testing.T) {
+	domain := NewSignatureDeviceDomain(persistence.NewSignatureDeviceDb(), clock)
+	_, _ = domain.CreateSignatureDevice(context.Background(), "tenant1", "550e8400-e29b-11d4-a716-446655440000", "ECC", "", DeviceOptions{})
+
+	_, err := domain.ReadSignatureDevice(context.Background(), "tenant2", "550e8400-e29b-11d4-a716-446655440000")
+	assertEqual(t, ErrNotFound, err)
+	_, err = domain.SignTransaction(context.Background(), "tenant2", "550e8400-e29b-11d4-a716-446655440000", "test", SignOptions{})
+	assertEqual(t, ErrNotFound, err)
+	_, err = domain.DecommissionSignatureDevice(context.Background(), "tenant2", "550e8400-e29b-11d4-a716-446655440000", 0)
+	assertEqual(t, ErrNotFound, err)
+	page, _ := domain.ReadSignatureDevices(context.Background(), "tenant2", DeviceQuery{})
+	assertEqual(t, 0, len(page.Devices))
+
+	_, err = domain.CreateSignatureDevice(context.Background(), "tenant2", "550e8400-e29b-11d4-a716-446655440000", "ECC", "", DeviceOptions{})
+	assertEqual(t, nil, err)
+}
+
+func TestCreateSignatureDevice_ErrDeviceLimit(t *testing.T) {
+	domain := NewSignatureDeviceDomain(persistence.NewSignatureDeviceDb(), clock, WithTenantLimits(TenantLimitsStub{"tenant1": 1}))
+	_, _ = domain.CreateSignatureDevice(context.Background(), "tenant1", "550e8400-e29b-11d4-a716-446655440000", "ECC", "", DeviceOptions{})
+
+	_, err := domain.CreateSignatureDevice(context.Background(), "tenant1", "550e8400-e29b-11d4-a716-446655440001", "ECC", "", DeviceOptions{})
+	assertEqual(t, ErrDeviceLimit, err)
+
+	_, err = domain.CreateSignatureDevice(context.Background(), "tenant2", "550e8400-e29b-11d4-a716-446655440001", "ECC", "", DeviceOptions{})
+	assertEqual(t, nil, err)
+
+	_, _ = domain.DecommissionSignatureDevice(context.Background(), "tenant1", "550e8400-e29b-11d4-a716-446655440000", 0)
+	_, err = domain.CreateSignatureDevice(context.Background(), "tenant1", "550e8400-e29b-11d4-a716-446655440001", "ECC", "", DeviceOptions{})
+	assertEqual(t, nil, err)
+}
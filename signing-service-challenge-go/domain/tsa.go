@@ -0,0 +1,154 @@
+package domain
+
+import (
+	"context"
+	"encoding/asn1"
+	"encoding/base64"
+	"errors"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/crypto"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/persistence"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/tsa"
+)
+
+var ErrNotTimeStampAuthority = newError(CodeNotTimeStampAuthority, "not a time-stamp authority")
+
+// TimeStampAuthorityTenant owns the device of the time-stamp authority. No
+// API key belongs to it, so the device is not visible to any tenant.
+const TimeStampAuthorityTenant = "time-stamp-authority"
+
+// timeStampAttempts bounds how often a time stamp is retried when concurrent
+// requests race for the same serial number.
+const timeStampAttempts = 3
+
+type ITimeStampAuthority interface {
+	TimeStamp(ctx context.Context, request tsa.Request) (tsa.Token, error)
+	Certificate(ctx context.Context) ([]byte, error)
+}
+
+// TimeStampAuthority issues RFC 3161 time stamps with a dedicated signature
+// device. The serial numbers of the tokens are taken from its signature counter.
+type TimeStampAuthority struct {
+	db     persistence.ISignatureDeviceDb
+	clock  IClock
+	id     persistence.Id
+	policy asn1.ObjectIdentifier
+}
+
+// NewTimeStampAuthority creates the signature device backing the
+// TimeStampAuthority unless it already exists. The tokens are issued under
+// the policy, an OID in dotted notation.
+func NewTimeStampAuthority(db persistence.ISignatureDeviceDb, clock IClock, id, algorithm, policy string) (ITimeStampAuthority, error) {
+	oid, err := tsa.ParsePolicy(policy)
+	if err != nil {
+		return nil, err
+	}
+	authority := &TimeStampAuthority{
+		db:     db,
+		clock:  clock,
+		id:     persistence.Id(id),
+		policy: oid,
+	}
+
+	publicKey, privateKey, err := crypto.NewKeyPair(algorithm)
+	if err != nil {
+		if errors.Is(err, crypto.ErrInvalidAlgorithm) {
+			return nil, ErrInvalidAlgorithm
+		}
+		return nil, err
+	}
+	certificate, err := crypto.NewTimeStampingCertificate(algorithm, privateKey, "Time-Stamp Authority "+id, clock.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Store(context.Background(), persistence.SignatureDevice{Tenant: TimeStampAuthorityTenant, Id: persistence.Id(id)}.Record(clock.Now(), persistence.DeviceCreated{
+		Algorithm:     algorithm,
+		Label:         "Time-Stamp Authority",
+		PublicKey:     publicKey,
+		PrivateKey:    privateKey,
+		LastSignature: base64.StdEncoding.EncodeToString([]byte(id)),
+		Certificate:   certificate,
+	}))
+	if err != nil && !errors.Is(err, persistence.ErrExists) {
+		return nil, err
+	}
+	return authority, nil
+}
+
+func (a *TimeStampAuthority) TimeStamp(ctx context.Context, request tsa.Request) (tsa.Token, error) {
+	var err error
+	for attempt := 0; attempt < timeStampAttempts; attempt++ {
+		var token tsa.Token
+		token, err = a.timeStamp(ctx, request)
+		if !errors.Is(err, ErrModified) {
+			return token, err
+		}
+	}
+	return tsa.Token{}, err
+}
+
+func (a *TimeStampAuthority) timeStamp(ctx context.Context, request tsa.Request) (tsa.Token, error) {
+	device, err := a.findDevice(ctx)
+	if err != nil {
+		return tsa.Token{}, err
+	}
+
+	signedAt := a.clock.Now()
+	if signedAt.Before(device.LastSignedAt) {
+		return tsa.Token{}, ErrClockRegression
+	}
+
+	var signer crypto.Signer
+	err = traced(ctx, "crypto.NewSigner", 1, func() (err error) {
+		signer, err = crypto.NewSigner(device.Algorithm, device.PrivateKey)
+		return err
+	})
+	if err != nil {
+		return tsa.Token{}, err
+	}
+	var token tsa.Token
+	err = traced(ctx, "crypto.Sign", 1, func() (err error) {
+		token, err = tsa.NewToken(request, a.policy, device.SignatureCounter, signedAt, device.Algorithm, device.Certificate, signer)
+		return err
+	})
+	if err != nil {
+		return tsa.Token{}, err
+	}
+
+	newDevice := device.Record(signedAt, persistence.TransactionSigned{
+		Counter:   device.SignatureCounter,
+		Signature: base64.StdEncoding.EncodeToString(token.Signature),
+		SignedAt:  signedAt,
+	})
+
+	err = a.db.CompareAndSwap(ctx, device, newDevice)
+	if err != nil {
+		if errors.Is(err, persistence.ErrModified) {
+			return tsa.Token{}, ErrModified
+		}
+		return tsa.Token{}, err
+	}
+	return token, nil
+}
+
+func (a *TimeStampAuthority) Certificate(ctx context.Context) ([]byte, error) {
+	device, err := a.findDevice(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return device.Certificate, nil
+}
+
+func (a *TimeStampAuthority) findDevice(ctx context.Context) (persistence.SignatureDevice, error) {
+	device, err := a.db.FindById(ctx, TimeStampAuthorityTenant, a.id)
+	if err != nil {
+		if errors.Is(err, persistence.ErrNotFound) {
+			return persistence.SignatureDevice{}, ErrNotFound
+		}
+		return persistence.SignatureDevice{}, err
+	}
+	if len(device.Certificate) == 0 {
+		return persistence.SignatureDevice{}, ErrNotTimeStampAuthority
+	}
+	return device, nil
+}
@@ -0,0 +1,71 @@
+package domain
+
+import (
+	"context"
+	"testing"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/persistence"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/tsa"
+)
+
+func TestNewTimeStampAuthority_Ok(t *testing.T) {
+	var storeDevice persistence.SignatureDevice
+	db := &SignatureDeviceInMemoryDbStub{
+		StoreFunc: func(device persistence.SignatureDevice) error {
+			storeDevice = device
+			return nil
+		},
+	}
+
+	_, err := NewTimeStampAuthority(db, clock, "550e8400-e29b-11d4-a716-446655440000", "ECC", "1.2.3.4.1")
+
+	assertEqual(t, nil, err)
+	assertNotEmpty(t, storeDevice.Certificate)
+}
+
+func TestNewTimeStampAuthority_OkExists(t *testing.T) {
+	db := &SignatureDeviceInMemoryDbStub{
+		StoreFunc: func(device persistence.SignatureDevice) error {
+			return persistence.ErrExists
+		},
+	}
+
+	_, err := NewTimeStampAuthority(db, clock, "550e8400-e29b-11d4-a716-446655440000", "ECC", "1.2.3.4.1")
+
+	assertEqual(t, nil, err)
+}
+
+func TestNewTimeStampAuthority_ErrInvalidPolicy(t *testing.T) {
+	_, err := NewTimeStampAuthority(&SignatureDeviceInMemoryDbStub{}, clock, "550e8400-e29b-11d4-a716-446655440000", "ECC", "policy")
+
+	assertEqual(t, tsa.ErrInvalidPolicy, err)
+}
+
+func TestTimeStamp_Ok(t *testing.T) {
+	db := persistence.NewSignatureDeviceDb()
+	authority, _ := NewTimeStampAuthority(db, clock, "550e8400-e29b-11d4-a716-446655440000", "ECC", "1.2.3.4.1")
+
+	token, err := authority.TimeStamp(context.Background(), tsa.Request{})
+
+	assertEqual(t, nil, err)
+	assertNotEmpty(t, token.DER)
+	device, _ := db.FindById(context.Background(), TimeStampAuthorityTenant, "550e8400-e29b-11d4-a716-446655440000")
+	assertEqual(t, 1, device.SignatureCounter)
+	assertEqual(t, clock.Time, device.LastSignedAt)
+}
+
+func TestTimeStamp_ErrNotTimeStampAuthority(t *testing.T) {
+	db := &SignatureDeviceInMemoryDbStub{
+		StoreFunc: func(device persistence.SignatureDevice) error {
+			return persistence.ErrExists
+		},
+		FindByIdFunc: func(tenant persistence.TenantId, id persistence.Id) (persistence.SignatureDevice, error) {
+			return device1, nil
+		},
+	}
+	authority, _ := NewTimeStampAuthority(db, clock, "550e8400-e29b-11d4-a716-446655440000", "ECC", "1.2.3.4.1")
+
+	_, err := authority.TimeStamp(context.Background(), tsa.Request{})
+
+	assertEqual(t, ErrNotTimeStampAuthority, err)
+}
@@ -3,6 +3,6 @@ module github.com/fiskaly/coding-challenges/signing-service-challenge
 go 1.20
 
 require (
-	github.com/google/uuid v1.6.0 // indirect
-	github.com/gorilla/mux v1.8.1 // indirect
+	github.com/google/uuid v1.6.0
+	github.com/gorilla/mux v1.8.1
 )
@@ -0,0 +1,101 @@
+package jobs
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/tracing"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/webhook"
+)
+
+const (
+	// CallbackTimeout bounds a single callback request.
+	CallbackTimeout = 10 * time.Second
+	// SignatureHeader carries the timestamped HMAC of the callback body keyed
+	// with the callback secret of the job, in the format of
+	// webhook.SignatureHeader. webhook.Verify checks it.
+	SignatureHeader = "X-Callback-Signature"
+)
+
+// INotifier informs the submitter of a job that it has finished.
+type INotifier interface {
+	Notify(job Job)
+}
+
+// CallbackPayload is the JSON body POSTed to the callback URL of a job.
+type CallbackPayload struct {
+	JobId       string `json:"job_id"`
+	DeviceId    string `json:"device_id"`
+	Status      Status `json:"status"`
+	Signature   string `json:"signature,omitempty"`
+	SignedData  string `json:"signed_data,omitempty"`
+	Error       string `json:"error,omitempty"`
+	CompletedAt string `json:"completed_at"`
+}
+
+// HTTPNotifier POSTs the result of a job to its callback URL.
+// Delivery is attempted once; clients can always fall back to polling.
+// Callbacks to loopback, link-local and private addresses are refused when
+// the connection is dialed, so that host names resolving to them are
+// refused as well.
+type HTTPNotifier struct {
+	client *http.Client
+}
+
+func NewHTTPNotifier() *HTTPNotifier {
+	return &HTTPNotifier{client: webhook.NewPublicClient(CallbackTimeout)}
+}
+
+// checkCallbackHost refuses host names and IP literals of the callback URL
+// that can only address this host or its private network.
+func checkCallbackHost(host string) error {
+	if err := webhook.CheckHost(host); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidCallback, err)
+	}
+	return nil
+}
+
+func (n *HTTPNotifier) Notify(job Job) {
+	payload := CallbackPayload{
+		JobId:       job.Id,
+		DeviceId:    job.DeviceId,
+		Status:      job.Status,
+		Error:       job.Error,
+		CompletedAt: job.CompletedAt.Format(time.RFC3339Nano),
+	}
+	if job.Signature != nil {
+		payload.Signature = job.Signature.Signature
+		payload.SignedData = job.Signature.SignedData
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		log.Printf("job %s: encoding callback: %v", job.Id, err)
+		return
+	}
+	ctx, span := tracing.Start(job.context(), "jobs.callback")
+	span.SetKind(tracing.KindClient)
+	defer span.End()
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, job.CallbackURL, bytes.NewReader(body))
+	if err != nil {
+		log.Printf("job %s: callback failed: %v", job.Id, err)
+		return
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set(SignatureHeader, webhook.Sign(job.CallbackSecret, time.Now(), body))
+	tracing.Inject(ctx, request.Header)
+	response, err := n.client.Do(request)
+	if err != nil {
+		span.SetError(err)
+		log.Printf("job %s: callback failed: %v", job.Id, err)
+		return
+	}
+	response.Body.Close()
+	if response.StatusCode >= 300 {
+		log.Printf("job %s: callback returned %s", job.Id, response.Status)
+	}
+}
@@ -0,0 +1,307 @@
+package jobs
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"hash/fnv"
+	"log"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/tracing"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrNotFound        = errors.New("not found")
+	ErrQueueFull       = errors.New("queue full")
+	ErrInvalidCallback = errors.New("invalid callback url")
+	ErrClosed          = errors.New("queue closed")
+)
+
+const (
+	// QueueSize is the number of jobs each worker buffers before submissions are refused.
+	QueueSize = 1024
+	// Retention is how long finished jobs can be polled.
+	Retention = time.Hour
+
+	// signAttempts bounds how often a job is retried on concurrent modifications,
+	// as there is no client waiting that could retry it.
+	signAttempts = 3
+	// callbackWorkers deliver the callbacks, so that slow callback URLs do
+	// not hold up the signing workers.
+	callbackWorkers = 4
+)
+
+type Status string
+
+const (
+	StatusQueued    Status = "queued"
+	StatusRunning   Status = "running"
+	StatusSucceeded Status = "succeeded"
+	StatusFailed    Status = "failed"
+)
+
+// Job is an asynchronous SignTransaction call.
+type Job struct {
+	Id          string
+	Tenant      string
+	DeviceId    string
+	Data        string
+	Options     domain.SignOptions
+	CallbackURL string
+	// CallbackSecret keys the HMAC of the callback body.
+	CallbackSecret string
+	Status         Status
+	Signature      *domain.Signature
+	Error          string
+	CreatedAt      time.Time
+	CompletedAt    time.Time
+
+	// trace carries the span of the request that submitted the job, so that
+	// signing and callback become part of its trace.
+	trace context.Context
+}
+
+type IJobQueue interface {
+	// Submit queues a job. A random callback secret is generated if a
+	// callback URL is given without one.
+	Submit(ctx context.Context, tenant, deviceId, data string, options domain.SignOptions, callbackURL, callbackSecret string) (Job, error)
+	// Find returns a job of the tenant. Jobs of other tenants are not found.
+	Find(tenant, id string) (Job, error)
+	// Close stops accepting jobs and waits for the queued ones until ctx
+	// expires. Jobs still queued then fail without being signed.
+	Close(ctx context.Context) error
+}
+
+// Queue runs signing jobs on a pool of workers. Jobs of the same device are
+// always dispatched to the same worker, which keeps them in submission order
+// and therefore keeps the signature counters in that order as well.
+type Queue struct {
+	domain   domain.ISignatureDeviceDomain
+	notifier INotifier
+	workers  []chan string
+	wg       sync.WaitGroup
+
+	callbacks chan Job
+	notifying sync.WaitGroup
+
+	// abandoned is closed once Close gives up on the remaining jobs.
+	abandoned chan struct{}
+	abandon   sync.Once
+
+	mu     sync.RWMutex
+	jobs   map[string]*Job
+	closed bool
+}
+
+// NewQueue creates a Queue and starts its workers.
+func NewQueue(domain domain.ISignatureDeviceDomain, workers int, notifier INotifier) IJobQueue {
+	q := &Queue{
+		domain:   domain,
+		notifier: notifier,
+		workers:  make([]chan string, workers),
+		jobs:     make(map[string]*Job),
+
+		callbacks: make(chan Job, QueueSize),
+		abandoned: make(chan struct{}),
+	}
+	for i := range q.workers {
+		q.workers[i] = make(chan string, QueueSize)
+		q.wg.Add(1)
+		go q.work(q.workers[i])
+	}
+	for i := 0; i < callbackWorkers; i++ {
+		q.notifying.Add(1)
+		go q.notify()
+	}
+	return q
+}
+
+func (q *Queue) Submit(ctx context.Context, tenant, deviceId, data string, options domain.SignOptions, callbackURL, callbackSecret string) (Job, error) {
+	if callbackURL != "" {
+		parsed, err := url.Parse(callbackURL)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			return Job{}, ErrInvalidCallback
+		}
+		if err := checkCallbackHost(parsed.Hostname()); err != nil {
+			return Job{}, err
+		}
+		if callbackSecret == "" {
+			random := make([]byte, 32)
+			if _, err := rand.Read(random); err != nil {
+				return Job{}, err
+			}
+			callbackSecret = hex.EncodeToString(random)
+		}
+	}
+
+	job := &Job{
+		Id:             uuid.NewString(),
+		Tenant:         tenant,
+		DeviceId:       deviceId,
+		Data:           data,
+		Options:        options,
+		CallbackURL:    callbackURL,
+		CallbackSecret: callbackSecret,
+		Status:         StatusQueued,
+		CreatedAt:      time.Now().UTC(),
+		trace:          tracing.Detach(ctx),
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		return Job{}, ErrClosed
+	}
+	select {
+	case q.workers[q.shard(tenant, deviceId)] <- job.Id:
+	default:
+		return Job{}, ErrQueueFull
+	}
+	q.jobs[job.Id] = job
+	return *job, nil
+}
+
+func (q *Queue) Find(tenant, id string) (Job, error) {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	job, exists := q.jobs[id]
+	if !exists || job.Tenant != tenant {
+		return Job{}, ErrNotFound
+	}
+	return *job, nil
+}
+
+// Close stops accepting jobs and waits until the queued jobs are done and
+// their callbacks delivered. Once ctx expires the jobs still queued fail
+// with ErrClosed and their callbacks are dropped, only the jobs being
+// signed are waited for.
+func (q *Queue) Close(ctx context.Context) error {
+	q.mu.Lock()
+	closing := !q.closed
+	if closing {
+		q.closed = true
+		for _, worker := range q.workers {
+			close(worker)
+		}
+	}
+	q.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		q.wg.Wait()
+		if closing {
+			close(q.callbacks)
+		}
+		q.notifying.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+	}
+	q.abandon.Do(func() { close(q.abandoned) })
+	<-done
+	return ctx.Err()
+}
+
+func (q *Queue) shard(tenant, deviceId string) int {
+	h := fnv.New32a()
+	h.Write([]byte(tenant))
+	h.Write([]byte{0})
+	h.Write([]byte(deviceId))
+	return int(h.Sum32() % uint32(len(q.workers)))
+}
+
+func (q *Queue) work(ids <-chan string) {
+	defer q.wg.Done()
+	for id := range ids {
+		select {
+		case <-q.abandoned:
+			q.update(id, func(job *Job) {
+				job.CompletedAt = time.Now().UTC()
+				job.Status = StatusFailed
+				job.Error = ErrClosed.Error()
+			})
+		default:
+			q.run(id)
+		}
+	}
+}
+
+func (q *Queue) notify() {
+	defer q.notifying.Done()
+	for job := range q.callbacks {
+		select {
+		case <-q.abandoned:
+			log.Printf("job %s: dropping callback at shutdown", job.Id)
+		default:
+			q.notifier.Notify(job)
+		}
+	}
+}
+
+func (q *Queue) run(id string) {
+	job := q.update(id, func(job *Job) {
+		job.Status = StatusRunning
+	})
+
+	ctx, span := tracing.Start(job.context(), "jobs.run")
+	span.SetAttribute("job.id", job.Id)
+	var signature domain.Signature
+	var err error
+	for attempt := 0; attempt < signAttempts; attempt++ {
+		signature, err = q.domain.SignTransaction(ctx, job.Tenant, job.DeviceId, job.Data, job.Options)
+		if !errors.Is(err, domain.ErrModified) {
+			break
+		}
+	}
+	span.SetError(err)
+	span.End()
+
+	job = q.update(id, func(job *Job) {
+		job.CompletedAt = time.Now().UTC()
+		if err != nil {
+			job.Status = StatusFailed
+			job.Error = err.Error()
+			return
+		}
+		job.Status = StatusSucceeded
+		job.Signature = &signature
+	})
+	time.AfterFunc(Retention, func() {
+		q.mu.Lock()
+		defer q.mu.Unlock()
+		delete(q.jobs, id)
+	})
+
+	if job.CallbackURL != "" {
+		select {
+		case q.callbacks <- job:
+		default:
+			log.Printf("job %s: callback queue full, dropping callback", job.Id)
+		}
+	}
+}
+
+// context returns the context the job is traced with.
+func (j Job) context() context.Context {
+	if j.trace == nil {
+		return context.Background()
+	}
+	return j.trace
+}
+
+func (q *Queue) update(id string, apply func(job *Job)) Job {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	job := q.jobs[id]
+	apply(job)
+	return *job
+}
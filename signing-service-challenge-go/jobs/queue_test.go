@@ -0,0 +1,373 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/tracing"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/webhook"
+)
+
+func assertEqual(t *testing.T, expected interface{}, actual interface{}) {
+	t.Helper()
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("Expected %v, actual %v", expected, actual)
+	}
+}
+
+// SignatureDeviceDomainStub only implements SignTransaction, which is all the queue uses.
+type SignatureDeviceDomainStub struct {
+	domain.ISignatureDeviceDomain
+	SignTransactionFunc func(tenant, id, data string, options domain.SignOptions) (domain.Signature, error)
+}
+
+func (s *SignatureDeviceDomainStub) SignTransaction(_ context.Context, tenant, id, data string, options domain.SignOptions) (domain.Signature, error) {
+	return s.SignTransactionFunc(tenant, id, data, options)
+}
+
+type NotifierStub struct {
+	NotifyFunc func(job Job)
+}
+
+func (n *NotifierStub) Notify(job Job) {
+	n.NotifyFunc(job)
+}
+
+func waitFor(t *testing.T, queue IJobQueue, id string) Job {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		job, err := queue.Find("tenant", id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if job.Status == StatusSucceeded || job.Status == StatusFailed {
+			return job
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("job %s did not finish", id)
+	return Job{}
+}
+
+func TestSubmit_Succeeded(t *testing.T) {
+	queue := NewQueue(&SignatureDeviceDomainStub{
+		SignTransactionFunc: func(tenant, id, data string, options domain.SignOptions) (domain.Signature, error) {
+			return domain.Signature{Signature: "signature", SignedData: "0_" + data}, nil
+		},
+	}, 2, &NotifierStub{})
+	defer queue.Close(context.Background())
+
+	job, err := queue.Submit(context.Background(), "tenant", "device", "test", domain.SignOptions{}, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, StatusQueued, job.Status)
+
+	job = waitFor(t, queue, job.Id)
+	assertEqual(t, StatusSucceeded, job.Status)
+	assertEqual(t, &domain.Signature{Signature: "signature", SignedData: "0_test"}, job.Signature)
+}
+
+func TestSubmit_Failed(t *testing.T) {
+	queue := NewQueue(&SignatureDeviceDomainStub{
+		SignTransactionFunc: func(tenant, id, data string, options domain.SignOptions) (domain.Signature, error) {
+			return domain.Signature{}, domain.ErrNotFound
+		},
+	}, 1, &NotifierStub{})
+	defer queue.Close(context.Background())
+
+	job, _ := queue.Submit(context.Background(), "tenant", "device", "test", domain.SignOptions{}, "", "")
+	job = waitFor(t, queue, job.Id)
+
+	assertEqual(t, StatusFailed, job.Status)
+	assertEqual(t, "not found", job.Error)
+}
+
+func TestSubmit_RetriesModified(t *testing.T) {
+	attempts := 0
+	queue := NewQueue(&SignatureDeviceDomainStub{
+		SignTransactionFunc: func(tenant, id, data string, options domain.SignOptions) (domain.Signature, error) {
+			attempts++
+			if attempts < signAttempts {
+				return domain.Signature{}, domain.ErrModified
+			}
+			return domain.Signature{Signature: "signature"}, nil
+		},
+	}, 1, &NotifierStub{})
+	defer queue.Close(context.Background())
+
+	job, _ := queue.Submit(context.Background(), "tenant", "device", "test", domain.SignOptions{}, "", "")
+	job = waitFor(t, queue, job.Id)
+
+	assertEqual(t, StatusSucceeded, job.Status)
+	assertEqual(t, signAttempts, attempts)
+}
+
+func TestSubmit_PreservesDeviceOrder(t *testing.T) {
+	var mu sync.Mutex
+	signed := make(map[string][]string)
+	queue := NewQueue(&SignatureDeviceDomainStub{
+		SignTransactionFunc: func(tenant, id, data string, options domain.SignOptions) (domain.Signature, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			signed[id] = append(signed[id], data)
+			return domain.Signature{}, nil
+		},
+	}, 4, &NotifierStub{})
+
+	expected := make(map[string][]string)
+	for i := 0; i < 100; i++ {
+		for _, device := range []string{"a", "b", "c"} {
+			data := strconv.Itoa(i)
+			if _, err := queue.Submit(context.Background(), "tenant", device, data, domain.SignOptions{}, "", ""); err != nil {
+				t.Fatal(err)
+			}
+			expected[device] = append(expected[device], data)
+		}
+	}
+	queue.Close(context.Background())
+
+	assertEqual(t, expected, signed)
+}
+
+func TestSubmit_ErrInvalidCallback(t *testing.T) {
+	queue := NewQueue(&SignatureDeviceDomainStub{}, 1, &NotifierStub{})
+	defer queue.Close(context.Background())
+
+	for _, callbackURL := range []string{
+		"ftp://example.com",
+		"example.com/callback",
+		"https://",
+		"http://localhost:8080/callback",
+		"http://127.0.0.1/callback",
+		"http://[::1]/callback",
+		"http://169.254.169.254/latest/meta-data",
+		"http://10.0.0.1/callback",
+		"http://0.0.0.0/callback",
+	} {
+		_, err := queue.Submit(context.Background(), "tenant", "device", "test", domain.SignOptions{}, callbackURL, "")
+		if !errors.Is(err, ErrInvalidCallback) {
+			t.Errorf("%s: expected ErrInvalidCallback, got %v", callbackURL, err)
+		}
+	}
+}
+
+func TestSubmit_Notifies(t *testing.T) {
+	notified := make(chan Job, 1)
+	queue := NewQueue(&SignatureDeviceDomainStub{
+		SignTransactionFunc: func(tenant, id, data string, options domain.SignOptions) (domain.Signature, error) {
+			return domain.Signature{Signature: "signature"}, nil
+		},
+	}, 1, &NotifierStub{
+		NotifyFunc: func(job Job) {
+			notified <- job
+		},
+	})
+	defer queue.Close(context.Background())
+
+	job, _ := queue.Submit(context.Background(), "tenant", "device", "test", domain.SignOptions{}, "https://example.com/callback", "secret")
+
+	select {
+	case result := <-notified:
+		assertEqual(t, job.Id, result.Id)
+		assertEqual(t, StatusSucceeded, result.Status)
+		assertEqual(t, "secret", result.CallbackSecret)
+	case <-time.After(5 * time.Second):
+		t.Fatal("callback was not notified")
+	}
+}
+
+func TestFind_ErrNotFound(t *testing.T) {
+	queue := NewQueue(&SignatureDeviceDomainStub{}, 1, &NotifierStub{})
+	defer queue.Close(context.Background())
+
+	_, err := queue.Find("tenant", "unknown")
+
+	assertEqual(t, ErrNotFound, err)
+}
+
+func TestFind_ErrNotFoundOtherTenant(t *testing.T) {
+	queue := NewQueue(&SignatureDeviceDomainStub{
+		SignTransactionFunc: func(tenant, id, data string, options domain.SignOptions) (domain.Signature, error) {
+			return domain.Signature{}, nil
+		},
+	}, 1, &NotifierStub{})
+	defer queue.Close(context.Background())
+	job, _ := queue.Submit(context.Background(), "tenant", "device", "test", domain.SignOptions{}, "", "")
+
+	_, err := queue.Find("other", job.Id)
+
+	assertEqual(t, ErrNotFound, err)
+}
+
+func TestHTTPNotifier_Notify(t *testing.T) {
+	type request struct {
+		body      []byte
+		signature string
+	}
+	received := make(chan request, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received <- request{body: body, signature: r.Header.Get(SignatureHeader)}
+	}))
+	defer server.Close()
+	notifier := NewHTTPNotifier()
+	notifier.client = server.Client()
+
+	notifier.Notify(Job{
+		Id:             "job",
+		DeviceId:       "device",
+		CallbackURL:    server.URL,
+		CallbackSecret: "secret",
+		Status:         StatusSucceeded,
+		Signature:      &domain.Signature{Signature: "signature", SignedData: "0_test"},
+		CompletedAt:    time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+	})
+
+	result := <-received
+	var payload CallbackPayload
+	assertEqual(t, nil, json.Unmarshal(result.body, &payload))
+	assertEqual(t, CallbackPayload{
+		JobId:       "job",
+		DeviceId:    "device",
+		Status:      StatusSucceeded,
+		Signature:   "signature",
+		SignedData:  "0_test",
+		CompletedAt: "2024-01-02T03:04:05Z",
+	}, payload)
+	assertEqual(t, nil, webhook.Verify("secret", result.signature, result.body, time.Now()))
+}
+
+func TestHTTPNotifier_RefusesPrivateAddresses(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+	}))
+	defer server.Close()
+
+	NewHTTPNotifier().Notify(Job{Id: "job", CallbackURL: server.URL, CallbackSecret: "secret", Status: StatusSucceeded})
+
+	assertEqual(t, int32(0), atomic.LoadInt32(&requests))
+}
+
+func TestSubmit_NotifiesOffWorker(t *testing.T) {
+	release := make(chan struct{})
+	queue := NewQueue(&SignatureDeviceDomainStub{
+		SignTransactionFunc: func(tenant, id, data string, options domain.SignOptions) (domain.Signature, error) {
+			return domain.Signature{}, nil
+		},
+	}, 1, &NotifierStub{
+		NotifyFunc: func(job Job) {
+			<-release
+		},
+	})
+	defer queue.Close(context.Background())
+	defer close(release)
+
+	_, err := queue.Submit(context.Background(), "tenant", "device", "first", domain.SignOptions{}, "https://example.com/callback", "")
+	assertEqual(t, nil, err)
+	second, err := queue.Submit(context.Background(), "tenant", "device", "second", domain.SignOptions{}, "", "")
+	assertEqual(t, nil, err)
+
+	assertEqual(t, StatusSucceeded, waitFor(t, queue, second.Id).Status)
+}
+
+func TestClose_AbandonsQueuedJobsAtDeadline(t *testing.T) {
+	started := make(chan struct{}, 1)
+	release := make(chan struct{})
+	queue := NewQueue(&SignatureDeviceDomainStub{
+		SignTransactionFunc: func(tenant, id, data string, options domain.SignOptions) (domain.Signature, error) {
+			started <- struct{}{}
+			<-release
+			return domain.Signature{Signature: "signature"}, nil
+		},
+	}, 1, &NotifierStub{})
+	first, _ := queue.Submit(context.Background(), "tenant", "device", "first", domain.SignOptions{}, "", "")
+	second, _ := queue.Submit(context.Background(), "tenant", "device", "second", domain.SignOptions{}, "", "")
+	<-started
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		close(release)
+	}()
+	err := queue.Close(ctx)
+
+	assertEqual(t, context.Canceled, err)
+	assertEqual(t, StatusSucceeded, waitFor(t, queue, first.Id).Status)
+	abandoned := waitFor(t, queue, second.Id)
+	assertEqual(t, StatusFailed, abandoned.Status)
+	assertEqual(t, ErrClosed.Error(), abandoned.Error)
+}
+
+func TestSubmit_ErrClosed(t *testing.T) {
+	queue := NewQueue(&SignatureDeviceDomainStub{}, 1, &NotifierStub{})
+	queue.Close(context.Background())
+
+	_, err := queue.Submit(context.Background(), "tenant", "device", "test", domain.SignOptions{}, "", "")
+
+	assertEqual(t, ErrClosed, err)
+}
+
+type ExporterStub struct{}
+
+func (e ExporterStub) Export(_ []tracing.SpanData) error {
+	return nil
+}
+
+func TestSubmit_ContinuesTrace(t *testing.T) {
+	tracer := tracing.NewTracer(ExporterStub{})
+	defer tracer.Close()
+	ctx, span := tracer.Start(context.Background(), "POST /sign")
+	traceIds := make(chan tracing.TraceId, 1)
+	queue := NewQueue(&SignatureDeviceDomainStub{
+		SignTransactionFunc: func(tenant, id, data string, options domain.SignOptions) (domain.Signature, error) {
+			return domain.Signature{}, nil
+		},
+	}, 1, &NotifierStub{
+		NotifyFunc: func(job Job) {
+			traceIds <- tracing.FromContext(job.context()).SpanContext().TraceId
+		},
+	})
+	defer queue.Close(context.Background())
+
+	_, err := queue.Submit(ctx, "tenant", "device", "test", domain.SignOptions{}, "https://example.com/callback", "")
+	span.End()
+
+	assertEqual(t, nil, err)
+	assertEqual(t, span.SpanContext().TraceId, <-traceIds)
+}
+
+func TestHTTPNotifier_PropagatesTraceparent(t *testing.T) {
+	tracer := tracing.NewTracer(ExporterStub{})
+	defer tracer.Close()
+	ctx, span := tracer.Start(context.Background(), "POST /sign")
+	defer span.End()
+	traceparents := make(chan string, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparents <- r.Header.Get("traceparent")
+	}))
+	defer server.Close()
+
+	notifier := NewHTTPNotifier()
+	notifier.client = server.Client()
+
+	notifier.Notify(Job{Id: "job", CallbackURL: server.URL, Status: StatusSucceeded, trace: tracing.Detach(ctx)})
+
+	parent, err := tracing.ParseTraceparent(<-traceparents)
+	assertEqual(t, nil, err)
+	assertEqual(t, span.SpanContext().TraceId, parent.TraceId)
+}
@@ -1,21 +1,298 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
 	"github.com/fiskaly/coding-challenges/signing-service-challenge/api"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/audit"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/auth"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/config"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/crypto"
 	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/jobs"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/metrics"
 	"github.com/fiskaly/coding-challenges/signing-service-challenge/persistence"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/ratelimit"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/rbac"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/stream"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/tenant"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/tracing"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/transport"
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/webhook"
 	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
 )
 
-const (
-	ListenAddress = ":8080"
-)
+// storage is implemented by persistence backends that can be probed and
+// have to be closed.
+type storage interface {
+	Ping(ctx context.Context) error
+	Close() error
+}
 
 func main() {
-	db := persistence.NewSignatureDeviceDb()
-	server := api.NewServer(ListenAddress, domain.NewSignatureDeviceDomain(db))
+	cfg, printConfig, err := config.Load(os.Args[1:], os.Getenv)
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		log.Fatal("Could not load the configuration: ", err)
+	}
+	if printConfig {
+		if err := cfg.Write(os.Stdout); err != nil {
+			log.Fatal("Could not print the configuration: ", err)
+		}
+		return
+	}
+
+	db, err := newDb(cfg.Persistence)
+	if err != nil {
+		log.Fatal("Could not build signature devices from the event log: ", err)
+	}
+	clock := domain.NewSystemClock()
+
+	tsa, err := domain.NewTimeStampAuthority(db, clock, cfg.TimeStampAuthority.Id, cfg.TimeStampAuthority.Algorithm, cfg.TimeStampAuthority.Policy)
+	if err != nil {
+		log.Fatal("Could not create time-stamp authority: ", err)
+	}
+
+	tenants, err := tenant.NewRegistry(cfg.Limits.DefaultTenantMaxDevices)
+	if err != nil {
+		log.Fatal("Could not create tenant registry: ", err)
+	}
+	webhooks := webhook.NewDispatcher(cfg.Limits.WebhookWorkers, webhook.DefaultRetryPolicy)
+	signatures := stream.NewBroker()
+	registry := metrics.NewRegistry()
+	signatureDeviceDomain := domain.NewSignatureDeviceDomain(
+		db,
+		clock,
+		domain.WithEventPublisher(webhooks),
+		domain.WithEventPublisher(signatures),
+		domain.WithTenantLimits(tenants),
+		domain.WithSigningMetrics(metrics.NewSigningMetrics(registry, db)),
+	)
+	auditLog, err := newAuditLog(cfg.Audit.LogFile)
+	if err != nil {
+		log.Fatal("Could not open audit log: ", err)
+	}
+	keyring, err := newKeyring(cfg.Auth)
+	if err != nil {
+		log.Fatal("Could not create admin API key: ", err)
+	}
+	limiter, err := newLimiter(clock, cfg.Limits)
+	if err != nil {
+		log.Fatal("Could not configure rate limits: ", err)
+	}
+
+	queue := jobs.NewQueue(signatureDeviceDomain, cfg.Limits.JobWorkers, jobs.NewHTTPNotifier())
 
-	if err := server.Run(); err != nil {
-		log.Fatal("Could not start server on ", ListenAddress)
+	options := []api.Option{
+		api.WithTimeStampAuthority(tsa),
+		api.WithJobQueue(queue),
+		api.WithWebhooks(webhooks),
+		api.WithSignatureStream(signatures),
+		api.WithAuditLog(auditLog),
+		api.WithAuthentication(keyring),
+		api.WithTenants(tenants),
+		api.WithRateLimits(limiter),
+		api.WithAccessLog(os.Stdout),
+		api.WithMetrics(registry),
+		// The software key provider, the only one, generates keys from the
+		// system random source.
+		api.WithReadinessCheck("keys", func(ctx context.Context) error {
+			return crypto.CheckRandom()
+		}),
+		api.WithShutdownDelay(cfg.Shutdown.Delay),
+	}
+	if storage, ok := db.(storage); ok {
+		options = append(options, api.WithReadinessCheck("storage", storage.Ping))
+	}
+	if projections, ok := db.(api.IProjections); ok {
+		options = append(options, api.WithProjections(projections))
+	}
+	tlsOptions, err := newTLSOptions(cfg.TLS)
+	if err != nil {
+		log.Fatal("Could not configure TLS: ", err)
+	}
+	options = append(options, tlsOptions...)
+	var tracer tracing.ITracer
+	if cfg.Tracing.OTLPEndpoint != "" {
+		tracer = tracing.NewTracer(tracing.NewOTLPExporter(cfg.Tracing.OTLPEndpoint, cfg.Tracing.ServiceName))
+		options = append(options, api.WithTracer(tracer))
+	}
+	policy, err := newPolicy(cfg.Auth.PolicyFile)
+	if err != nil {
+		log.Fatal("Could not read the access policy: ", err)
+	}
+	options = append(options, api.WithPolicy(policy))
+	server := api.NewServer(cfg.ListenAddress, signatureDeviceDomain, options...)
+
+	signals, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+	defer stop()
+	served := make(chan error, 1)
+	go func() {
+		served <- server.Run()
+	}()
+	select {
+	case err := <-served:
+		if err != nil {
+			log.Fatal("Could not start server on ", cfg.ListenAddress, ": ", err)
+		}
+		return
+	case <-signals.Done():
+	}
+	// A second signal kills the process.
+	stop()
+
+	log.Print("Shutting down")
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Shutdown.Timeout)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		log.Print("Requests were still in flight at shutdown: ", err)
+	}
+	// Queued jobs are signed and webhooks of their signatures enqueued
+	// before persistence is closed, as far as the shutdown timeout allows.
+	if err := queue.Close(ctx); err != nil {
+		log.Print("Abandoned queued jobs at shutdown: ", err)
+	}
+	if err := webhooks.Close(ctx); err != nil {
+		log.Print("Abandoned queued webhook deliveries at shutdown: ", err)
+	}
+	if storage, ok := db.(storage); ok {
+		if err := storage.Close(); err != nil {
+			log.Print("Could not close persistence: ", err)
+		}
+	}
+	if tracer != nil {
+		if err := tracer.Close(); err != nil {
+			log.Print("Could not export the remaining spans: ", err)
+		}
+	}
+}
+
+// newTLSOptions serves HTTPS if a certificate is configured.
+func newTLSOptions(cfg config.TLS) ([]api.Option, error) {
+	if cfg.CertFile == "" {
+		return nil, nil
+	}
+	minVersion, err := transport.ParseVersion(cfg.MinVersion)
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig, err := transport.NewServerConfig(transport.Config{
+		CertFile:     cfg.CertFile,
+		KeyFile:      cfg.KeyFile,
+		ClientCAFile: cfg.ClientCAFile,
+		ClientAuth:   transport.ClientAuth(cfg.ClientAuth),
+		MinVersion:   minVersion,
+	})
+	if err != nil {
+		return nil, err
+	}
+	options := []api.Option{api.WithTLS(tlsConfig)}
+
+	if cfg.ClientIdentitiesFile != "" {
+		file, err := os.Open(cfg.ClientIdentitiesFile)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		identities, err := auth.ReadIdentityMap(file)
+		if err != nil {
+			return nil, err
+		}
+		options = append(options, api.WithClientIdentities(identities))
+	}
+	return options, nil
+}
+
+// newLimiter applies the default rate limits, unset limits are unlimited.
+func newLimiter(clock domain.IClock, limits config.Limits) (ratelimit.ILimiter, error) {
+	defaults := make(map[ratelimit.Scope]ratelimit.Limit)
+	for scope, value := range map[ratelimit.Scope]string{
+		ratelimit.ScopeGlobal: limits.RateGlobal,
+		ratelimit.ScopeKey:    limits.RatePerKey,
+		ratelimit.ScopeDevice: limits.RatePerDevice,
+	} {
+		limit, err := ratelimit.ParseLimit(value)
+		if err != nil {
+			return nil, err
+		}
+		defaults[scope] = limit
+	}
+	return ratelimit.NewLimiter(clock, defaults)
+}
+
+// newDb creates the configured persistence backend.
+func newDb(cfg config.Persistence) (persistence.ISignatureDeviceDb, error) {
+	if cfg.Backend == config.BackendMemory {
+		return persistence.NewSignatureDeviceDb(), nil
+	}
+	return persistence.NewEventSourcedSignatureDeviceDb(persistence.NewEventStore())
+}
+
+// newPolicy reads the roles and role bindings of the access policy. Without
+// a file the policy starts empty and is managed through the admin API.
+func newPolicy(path string) (rbac.IPolicy, error) {
+	if path == "" {
+		return rbac.NewPolicy(), nil
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return rbac.ReadPolicy(file)
+}
+
+// newAuditLog keeps the audit log in memory unless a file is given.
+func newAuditLog(path string) (audit.IAuditLog, error) {
+	if path == "" {
+		return audit.NewLog(audit.NewMemorySink())
+	}
+	sink, err := audit.NewFileSink(path)
+	if err != nil {
+		return nil, err
+	}
+	return audit.NewLog(sink)
+}
+
+// newKeyring imports the admin API key of the default tenant if one is given
+// or was written to the key file before, and otherwise issues one and writes
+// its token to the key file. The token is never logged.
+func newKeyring(cfg config.Auth) (auth.IKeyring, error) {
+	keyring := auth.NewKeyring()
+	token := cfg.AdminAPIKey
+	if token == "" && cfg.AdminAPIKeyFile != "" {
+		written, err := os.ReadFile(cfg.AdminAPIKeyFile)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+		token = strings.TrimSpace(string(written))
+	}
+	if token != "" {
+		_, err := keyring.Import(tenant.DefaultId, "admin", token, auth.Scopes)
+		return keyring, err
+	}
+	_, token, err := keyring.Create(tenant.DefaultId, "admin", auth.Scopes)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(cfg.AdminAPIKeyFile, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := file.WriteString(token + "\n"); err != nil {
+		file.Close()
+		return nil, err
+	}
+	if err := file.Close(); err != nil {
+		return nil, err
 	}
+	log.Print("Issued admin API key, its token was written to ", cfg.AdminAPIKeyFile)
+	return keyring, nil
 }
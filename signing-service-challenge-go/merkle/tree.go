@@ -0,0 +1,123 @@
+package merkle
+
+import (
+	"bytes"
+	"crypto/sha256"
+)
+
+// Hashing follows RFC 6962: leaves and interior nodes use distinct prefixes
+// so that an interior node can never be presented as a leaf.
+const (
+	leafPrefix = 0x00
+	nodePrefix = 0x01
+)
+
+// Tree is a Merkle tree over an ordered list of leaves.
+type Tree struct {
+	leaves [][]byte
+}
+
+// NewTree creates a Tree from the raw leaf data.
+func NewTree(leaves [][]byte) *Tree {
+	hashes := make([][]byte, 0, len(leaves))
+	for _, leaf := range leaves {
+		hashes = append(hashes, LeafHash(leaf))
+	}
+	return &Tree{
+		leaves: hashes,
+	}
+}
+
+// LeafHash returns the hash of a single leaf.
+func LeafHash(data []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte{leafPrefix})
+	h.Write(data)
+	return h.Sum(nil)
+}
+
+func nodeHash(left, right []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte{nodePrefix})
+	h.Write(left)
+	h.Write(right)
+	return h.Sum(nil)
+}
+
+// Size returns the number of leaves.
+func (t *Tree) Size() int {
+	return len(t.leaves)
+}
+
+// Root returns the root hash of the tree.
+func (t *Tree) Root() []byte {
+	return rootOf(t.leaves)
+}
+
+// Proof returns the inclusion proof (audit path) of the leaf at index.
+func (t *Tree) Proof(index int) [][]byte {
+	if index < 0 || index >= len(t.leaves) {
+		return nil
+	}
+	return pathOf(index, t.leaves)
+}
+
+func rootOf(hashes [][]byte) []byte {
+	switch len(hashes) {
+	case 0:
+		empty := sha256.Sum256(nil)
+		return empty[:]
+	case 1:
+		return hashes[0]
+	}
+	k := split(len(hashes))
+	return nodeHash(rootOf(hashes[:k]), rootOf(hashes[k:]))
+}
+
+func pathOf(index int, hashes [][]byte) [][]byte {
+	if len(hashes) <= 1 {
+		return [][]byte{}
+	}
+	k := split(len(hashes))
+	if index < k {
+		return append(pathOf(index, hashes[:k]), rootOf(hashes[k:]))
+	}
+	return append(pathOf(index-k, hashes[k:]), rootOf(hashes[:k]))
+}
+
+// split returns the largest power of two smaller than n.
+func split(n int) int {
+	k := 1
+	for k<<1 < n {
+		k <<= 1
+	}
+	return k
+}
+
+// VerifyInclusion checks that the leaf data is at index of a tree of the
+// given size with the given root, using the algorithm of RFC 9162 2.1.3.2.
+func VerifyInclusion(data []byte, index, size int, proof [][]byte, root []byte) bool {
+	if index < 0 || index >= size {
+		return false
+	}
+
+	fn, sn := index, size-1
+	r := LeafHash(data)
+	for _, p := range proof {
+		if sn == 0 {
+			return false
+		}
+		if fn&1 == 1 || fn == sn {
+			r = nodeHash(p, r)
+			for fn&1 == 0 && fn != 0 {
+				fn >>= 1
+				sn >>= 1
+			}
+		} else {
+			r = nodeHash(r, p)
+		}
+		fn >>= 1
+		sn >>= 1
+	}
+	return sn == 0 && bytes.Equal(r, root)
+}
@@ -0,0 +1,67 @@
+package merkle
+
+import (
+	"encoding/hex"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func assertEqual(t *testing.T, expected any, actual any) {
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("Expected %v, actual %v", expected, actual)
+	}
+}
+
+func leaves(n int) [][]byte {
+	result := make([][]byte, 0, n)
+	for i := 0; i < n; i++ {
+		result = append(result, []byte(fmt.Sprintf("leaf-%d", i)))
+	}
+	return result
+}
+
+func TestRoot_OkSingleLeaf(t *testing.T) {
+	tree := NewTree([][]byte{[]byte("data")})
+
+	assertEqual(t, LeafHash([]byte("data")), tree.Root())
+	assertEqual(t, [][]byte{}, tree.Proof(0))
+}
+
+func TestRoot_OkThreeLeaves(t *testing.T) {
+	data := leaves(3)
+	tree := NewTree(data)
+
+	expected := nodeHash(nodeHash(LeafHash(data[0]), LeafHash(data[1])), LeafHash(data[2]))
+	assertEqual(t, hex.EncodeToString(expected), hex.EncodeToString(tree.Root()))
+}
+
+func TestVerifyInclusion_Ok(t *testing.T) {
+	for size := 1; size <= 17; size++ {
+		data := leaves(size)
+		tree := NewTree(data)
+		for index := 0; index < size; index++ {
+			ok := VerifyInclusion(data[index], index, size, tree.Proof(index), tree.Root())
+
+			assertEqual(t, true, ok)
+		}
+	}
+}
+
+func TestVerifyInclusion_ErrWrongLeaf(t *testing.T) {
+	data := leaves(5)
+	tree := NewTree(data)
+
+	ok := VerifyInclusion(data[1], 2, 5, tree.Proof(2), tree.Root())
+
+	assertEqual(t, false, ok)
+}
+
+func TestVerifyInclusion_ErrWrongSize(t *testing.T) {
+	data := leaves(5)
+	tree := NewTree(data)
+
+	ok := VerifyInclusion(data[4], 4, 6, tree.Proof(4), tree.Root())
+
+	assertEqual(t, false, ok)
+}
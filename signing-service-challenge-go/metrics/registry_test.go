@@ -0,0 +1,82 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/persistence"
+)
+
+func assertEqual(t *testing.T, expected interface{}, actual interface{}) {
+	t.Helper()
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("Expected %v, actual %v", expected, actual)
+	}
+}
+
+func TestWrite_TextFormat(t *testing.T) {
+	registry := NewRegistry()
+	requests := registry.Counter("requests_total", "Requests.", "route")
+	latency := registry.Histogram("latency_seconds", "Latency.", []float64{0.5, 0.1}, "route")
+	registry.Counter("conflicts_total", "Conflicts.")
+	registry.GaugeFunc("devices", "Devices.", func() []Sample {
+		return []Sample{{LabelValues: []string{"b"}, Value: 2}, {LabelValues: []string{"a"}, Value: 1}}
+	}, "state")
+
+	requests.Inc(`/a"b`)
+	requests.Add(2, "/c")
+	latency.Observe(0.2, "/c")
+	latency.Observe(0.05, "/c")
+
+	var buffer bytes.Buffer
+	err := registry.Write(&buffer)
+
+	assertEqual(t, nil, err)
+	assertEqual(t, `# HELP requests_total Requests.
+# TYPE requests_total counter
+requests_total{route="/a\"b"} 1
+requests_total{route="/c"} 2
+# HELP latency_seconds Latency.
+# TYPE latency_seconds histogram
+latency_seconds_bucket{route="/c",le="0.1"} 1
+latency_seconds_bucket{route="/c",le="0.5"} 2
+latency_seconds_bucket{route="/c",le="+Inf"} 2
+latency_seconds_sum{route="/c"} 0.25
+latency_seconds_count{route="/c"} 2
+# HELP conflicts_total Conflicts.
+# TYPE conflicts_total counter
+conflicts_total 0
+# HELP devices Devices.
+# TYPE devices gauge
+devices{state="a"} 1
+devices{state="b"} 2
+`, buffer.String())
+}
+
+func TestSigningMetrics(t *testing.T) {
+	registry := NewRegistry()
+	db := persistence.NewSignatureDeviceDb()
+	_ = db.Store(context.Background(), persistence.SignatureDevice{Tenant: "tenant1", Id: "device1"})
+	signing := NewSigningMetrics(registry, db)
+
+	signing.ObserveSign("ECC", "success", 20*time.Millisecond)
+	signing.ObserveSign("", "device_not_found", time.Millisecond)
+	signing.ObserveConflict()
+
+	var buffer bytes.Buffer
+	_ = registry.Write(&buffer)
+	for _, line := range []string{
+		`signing_devices{state="active"} 1`,
+		`signing_devices{state="decommissioned"} 0`,
+		`signing_sign_requests_total{algorithm="ECC",outcome="success"} 1`,
+		`signing_sign_requests_total{algorithm="unknown",outcome="device_not_found"} 1`,
+		`signing_sign_duration_seconds_bucket{algorithm="ECC",le="0.025"} 1`,
+		`signing_sign_duration_seconds_bucket{algorithm="ECC",le="0.01"} 0`,
+		`signing_conflicts_total 1`,
+	} {
+		assertEqual(t, true, bytes.Contains(buffer.Bytes(), []byte(line+"\n")))
+	}
+}
@@ -0,0 +1,52 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/persistence"
+)
+
+// unknownAlgorithm labels signing calls on devices that were not found.
+const unknownAlgorithm = "unknown"
+
+// ISigningMetrics records the signing calls of the domain.
+type ISigningMetrics interface {
+	ObserveSign(algorithm string, outcome string, duration time.Duration)
+	ObserveConflict()
+}
+
+type SigningMetrics struct {
+	requests  *Counter
+	latency   *Histogram
+	conflicts *Counter
+}
+
+// NewSigningMetrics registers the signing metrics and a gauge of the
+// devices in the db by state.
+func NewSigningMetrics(registry IRegistry, db persistence.ISignatureDeviceDb) ISigningMetrics {
+	registry.GaugeFunc("signing_devices", "Signature devices by state.", func() []Sample {
+		samples := make([]Sample, 0)
+		for state, count := range db.CountByState(context.Background()) {
+			samples = append(samples, Sample{LabelValues: []string{string(state)}, Value: float64(count)})
+		}
+		return samples
+	}, "state")
+	return &SigningMetrics{
+		requests:  registry.Counter("signing_sign_requests_total", "Signing calls by device algorithm and outcome.", "algorithm", "outcome"),
+		latency:   registry.Histogram("signing_sign_duration_seconds", "Latency of signing calls by device algorithm.", DefaultBuckets, "algorithm"),
+		conflicts: registry.Counter("signing_conflicts_total", "Changes of devices that failed with concurrent modifications."),
+	}
+}
+
+func (m *SigningMetrics) ObserveSign(algorithm string, outcome string, duration time.Duration) {
+	if algorithm == "" {
+		algorithm = unknownAlgorithm
+	}
+	m.requests.Inc(algorithm, outcome)
+	m.latency.Observe(duration.Seconds(), algorithm)
+}
+
+func (m *SigningMetrics) ObserveConflict() {
+	m.conflicts.Inc()
+}
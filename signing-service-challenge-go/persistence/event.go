@@ -0,0 +1,158 @@
+package persistence
+
+import "time"
+
+type EventType string
+
+const (
+	EventDeviceCreated     EventType = "DeviceCreated"
+	EventTransactionSigned EventType = "TransactionSigned"
+	EventLabelChanged      EventType = "LabelChanged"
+	EventTagsChanged       EventType = "TagsChanged"
+	EventMetadataChanged   EventType = "MetadataChanged"
+	EventKeyRotated        EventType = "KeyRotated"
+	EventDecommissioned    EventType = "Decommissioned"
+)
+
+// EventData is the payload of an Event.
+type EventData interface {
+	EventType() EventType
+}
+
+// Event is an entry of the append-only stream of a signature device.
+// Version numbers the events of a device starting at 1.
+type Event struct {
+	Tenant     TenantId
+	DeviceId   Id
+	Version    int
+	OccurredAt time.Time
+	Data       EventData
+}
+
+func (e Event) Type() EventType {
+	return e.Data.EventType()
+}
+
+type DeviceCreated struct {
+	Algorithm         string
+	Label             string
+	PublicKey         []byte
+	PrivateKey        []byte
+	LastSignature     string
+	Certificate       []byte
+	Mode              string
+	AggregationWindow time.Duration
+	AggregationSize   int
+}
+
+func (DeviceCreated) EventType() EventType { return EventDeviceCreated }
+
+// TransactionSigned records a signature made with the counter Counter.
+// Signature is the base64 encoded raw signature that the next signature
+// chains to. KeyId identifies the API key the transaction was signed for.
+// LeafKeyIds identifies the API key of every transaction of a Merkle tree
+// whose root was signed, in leaf order.
+type TransactionSigned struct {
+	Counter    int
+	KeyId      string
+	LeafKeyIds []string
+	SignedData string
+	Signature  string
+	SignedAt   time.Time
+}
+
+func (TransactionSigned) EventType() EventType { return EventTransactionSigned }
+
+type LabelChanged struct {
+	Label string
+}
+
+func (LabelChanged) EventType() EventType { return EventLabelChanged }
+
+// TagsChanged replaces all tags of the device.
+type TagsChanged struct {
+	Tags map[string]string
+}
+
+func (TagsChanged) EventType() EventType { return EventTagsChanged }
+
+// MetadataChanged replaces the metadata of the device.
+type MetadataChanged struct {
+	Metadata map[string]interface{}
+}
+
+func (MetadataChanged) EventType() EventType { return EventMetadataChanged }
+
+// KeyRotated replaces the key pair of the device. The replaced key is kept
+// as a RetiredKey, so that the signatures it made can still be verified.
+type KeyRotated struct {
+	PublicKey   []byte
+	PrivateKey  []byte
+	Certificate []byte
+}
+
+func (KeyRotated) EventType() EventType { return EventKeyRotated }
+
+type Decommissioned struct{}
+
+func (Decommissioned) EventType() EventType { return EventDecommissioned }
+
+// Apply returns the state of the device after the event.
+func (d SignatureDevice) Apply(event Event) SignatureDevice {
+	switch data := event.Data.(type) {
+	case DeviceCreated:
+		d = SignatureDevice{
+			Tenant:            event.Tenant,
+			Id:                event.DeviceId,
+			Algorithm:         data.Algorithm,
+			Label:             data.Label,
+			PublicKey:         data.PublicKey,
+			PrivateKey:        data.PrivateKey,
+			LastSignature:     data.LastSignature,
+			Certificate:       data.Certificate,
+			Mode:              data.Mode,
+			AggregationWindow: data.AggregationWindow,
+			AggregationSize:   data.AggregationSize,
+			CreatedAt:         event.OccurredAt,
+		}
+	case TransactionSigned:
+		d.SignatureCounter = data.Counter + 1
+		d.LastSignature = data.Signature
+		d.LastSignedAt = data.SignedAt
+	case LabelChanged:
+		d.Label = data.Label
+	case TagsChanged:
+		d.Tags = data.Tags
+	case MetadataChanged:
+		d.Metadata = data.Metadata
+	case KeyRotated:
+		d.RetiredKeys = append(d.RetiredKeys[:len(d.RetiredKeys):len(d.RetiredKeys)], RetiredKey{
+			PublicKey:    d.PublicKey,
+			Certificate:  d.Certificate,
+			UntilCounter: d.SignatureCounter,
+		})
+		d.PublicKey = data.PublicKey
+		d.PrivateKey = data.PrivateKey
+		d.Certificate = data.Certificate
+	case Decommissioned:
+		d.DecommissionedAt = event.OccurredAt
+	}
+	d.Version = event.Version
+	return d
+}
+
+// Record applies a new event to the device and keeps it as uncommitted
+// until the device is stored.
+func (d SignatureDevice) Record(occurredAt time.Time, data EventData) SignatureDevice {
+	event := Event{
+		Tenant:     d.Tenant,
+		DeviceId:   d.Id,
+		Version:    d.Version + 1,
+		OccurredAt: occurredAt,
+		Data:       data,
+	}
+	uncommitted := append(d.Uncommitted[:len(d.Uncommitted):len(d.Uncommitted)], event)
+	d = d.Apply(event)
+	d.Uncommitted = uncommitted
+	return d
+}
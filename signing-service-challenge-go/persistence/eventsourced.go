@@ -0,0 +1,164 @@
+package persistence
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+var ErrNoEvents = errors.New("no uncommitted events")
+
+// IEventSourcedSignatureDeviceDb is an ISignatureDeviceDb whose devices are
+// projected from an IEventStore.
+type IEventSourcedSignatureDeviceDb interface {
+	ISignatureDeviceDb
+	// Rebuild discards the projection and replays the whole event log.
+	Rebuild() error
+	// Ping reports whether the event store accepts events.
+	Ping(ctx context.Context) error
+	// Close flushes the event store and refuses further changes.
+	Close() error
+}
+
+// EventSourcedSignatureDeviceDb persists devices as the events recorded on
+// them and serves reads from an in-memory projection of the log.
+type EventSourcedSignatureDeviceDb struct {
+	events IEventStore
+
+	mu         sync.RWMutex
+	projection map[TenantId]map[Id]SignatureDevice
+	active     map[TenantId]int
+}
+
+// NewEventSourcedSignatureDeviceDb creates the db and builds the projection
+// from the events already in the store.
+func NewEventSourcedSignatureDeviceDb(events IEventStore) (IEventSourcedSignatureDeviceDb, error) {
+	db := &EventSourcedSignatureDeviceDb{
+		events: events,
+	}
+	if err := db.Rebuild(); err != nil {
+		return nil, err
+	}
+	return db, nil
+}
+
+func (db *EventSourcedSignatureDeviceDb) Rebuild() error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	projection := make(map[TenantId]map[Id]SignatureDevice)
+	active := make(map[TenantId]int)
+	for _, event := range db.events.LoadAll() {
+		devices, exists := projection[event.Tenant]
+		if !exists {
+			devices = make(map[Id]SignatureDevice)
+			projection[event.Tenant] = devices
+		}
+		device := devices[event.DeviceId]
+		if event.Version != device.Version+1 {
+			return ErrModified
+		}
+		devices[event.DeviceId] = device.Apply(event)
+		active[event.Tenant] += activeDelta(device, devices[event.DeviceId])
+	}
+	db.projection = projection
+	db.active = active
+	return nil
+}
+
+func (db *EventSourcedSignatureDeviceDb) Ping(ctx context.Context) error {
+	return db.events.Ping()
+}
+
+func (db *EventSourcedSignatureDeviceDb) Close() error {
+	return db.events.Close()
+}
+
+// Store appends the uncommitted events of a new device, which have to start
+// with DeviceCreated.
+func (db *EventSourcedSignatureDeviceDb) Store(ctx context.Context, device SignatureDevice) error {
+	span := startSpan(ctx, "persistence.Store", device.Tenant, device.Id)
+	defer span.End()
+	if len(device.Uncommitted) == 0 {
+		return ErrNoEvents
+	}
+	return db.append(device.Tenant, device.Id, 0, device.Uncommitted)
+}
+
+// CompareAndSwap appends the uncommitted events of new if no other events
+// have been appended since old was loaded.
+func (db *EventSourcedSignatureDeviceDb) CompareAndSwap(ctx context.Context, old, new SignatureDevice) error {
+	span := startSpan(ctx, "persistence.CompareAndSwap", new.Tenant, new.Id)
+	defer span.End()
+	if len(new.Uncommitted) == 0 {
+		return ErrNoEvents
+	}
+	err := db.append(new.Tenant, new.Id, old.Version, new.Uncommitted)
+	span.SetError(err)
+	return err
+}
+
+func (db *EventSourcedSignatureDeviceDb) append(tenant TenantId, id Id, expectedVersion int, events []Event) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if err := db.events.Append(tenant, id, expectedVersion, events); err != nil {
+		return err
+	}
+	devices, exists := db.projection[tenant]
+	if !exists {
+		devices = make(map[Id]SignatureDevice)
+		db.projection[tenant] = devices
+	}
+	old := devices[id]
+	device := old
+	for _, event := range events {
+		device = device.Apply(event)
+	}
+	devices[id] = device
+	db.active[tenant] += activeDelta(old, device)
+	return nil
+}
+
+func (db *EventSourcedSignatureDeviceDb) FindById(ctx context.Context, tenant TenantId, id Id) (SignatureDevice, error) {
+	span := startSpan(ctx, "persistence.FindById", tenant, id)
+	defer span.End()
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	device, exists := db.projection[tenant][id]
+	if !exists {
+		return SignatureDevice{}, ErrNotFound
+	}
+	return device, nil
+}
+
+func (db *EventSourcedSignatureDeviceDb) FindAll(_ context.Context, tenant TenantId) []SignatureDevice {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	values := make([]SignatureDevice, 0)
+	for key := range db.projection[tenant] {
+		values = append(values, db.projection[tenant][key])
+	}
+	return values
+}
+
+func (db *EventSourcedSignatureDeviceDb) Query(_ context.Context, tenant TenantId, query DeviceQuery) (DevicePage, error) {
+	if err := query.Validate(); err != nil {
+		return DevicePage{}, err
+	}
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	return query.run(db.projection[tenant]), nil
+}
+
+func (db *EventSourcedSignatureDeviceDb) CountByState(_ context.Context) map[DeviceState]int {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	return countByState(db.projection)
+}
+
+func (db *EventSourcedSignatureDeviceDb) CountActive(_ context.Context, tenant TenantId) int {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	return db.active[tenant]
+}
@@ -0,0 +1,144 @@
+package persistence
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+var signedAt = time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+func createdDevice1() SignatureDevice {
+	return SignatureDevice{Tenant: device1.Tenant, Id: device1.Id}.Record(signedAt, DeviceCreated{
+		Algorithm:     device1.Algorithm,
+		Label:         device1.Label,
+		PublicKey:     device1.PublicKey,
+		PrivateKey:    device1.PrivateKey,
+		LastSignature: device1.LastSignature,
+	})
+}
+
+func TestRecord_AppliesEvents(t *testing.T) {
+	device := createdDevice1().
+		Record(signedAt, TransactionSigned{Counter: 0, SignedData: "0_a", Signature: "c2lnbmF0dXJl", SignedAt: signedAt}).
+		Record(signedAt, LabelChanged{Label: "renamed"}).
+		Record(signedAt, KeyRotated{PublicKey: []byte("public"), PrivateKey: []byte("private")}).
+		Record(signedAt, Decommissioned{})
+
+	assertEqual(t, device1.Tenant, device.Tenant)
+	assertEqual(t, device1.Id, device.Id)
+	assertEqual(t, 5, device.Version)
+	assertEqual(t, 1, device.SignatureCounter)
+	assertEqual(t, "c2lnbmF0dXJl", device.LastSignature)
+	assertEqual(t, signedAt, device.LastSignedAt)
+	assertEqual(t, "renamed", device.Label)
+	assertEqual(t, []byte("public"), device.PublicKey)
+	assertEqual(t, []RetiredKey{{PublicKey: device1.PublicKey, UntilCounter: 1}}, device.RetiredKeys)
+	assertEqual(t, signedAt, device.DecommissionedAt)
+
+	types := make([]EventType, 0)
+	for i, event := range device.Uncommitted {
+		assertEqual(t, i+1, event.Version)
+		types = append(types, event.Type())
+	}
+	assertEqual(t, []EventType{
+		EventDeviceCreated, EventTransactionSigned, EventLabelChanged, EventKeyRotated, EventDecommissioned,
+	}, types)
+}
+
+func TestRecord_DoesNotShareUncommitted(t *testing.T) {
+	device := createdDevice1()
+
+	a := device.Record(signedAt, LabelChanged{Label: "a"})
+	b := device.Record(signedAt, LabelChanged{Label: "b"})
+
+	assertEqual(t, LabelChanged{Label: "a"}, a.Uncommitted[1].Data)
+	assertEqual(t, LabelChanged{Label: "b"}, b.Uncommitted[1].Data)
+}
+
+func TestEventSourcedStore_Ok(t *testing.T) {
+	db, _ := NewEventSourcedSignatureDeviceDb(NewEventStore())
+
+	err := db.Store(context.Background(), createdDevice1())
+	device, _ := db.FindById(context.Background(), device1.Tenant, device1.Id)
+
+	assertEqual(t, nil, err)
+	assertEqual(t, device1.PrivateKey, device.PrivateKey)
+	assertEqual(t, 1, device.Version)
+	assertEqual(t, 0, len(device.Uncommitted))
+}
+
+func TestEventSourcedStore_ErrExists(t *testing.T) {
+	db, _ := NewEventSourcedSignatureDeviceDb(NewEventStore())
+	_ = db.Store(context.Background(), createdDevice1())
+
+	err := db.Store(context.Background(), createdDevice1())
+
+	assertEqual(t, ErrExists, err)
+}
+
+func TestEventSourcedStore_ErrNoEvents(t *testing.T) {
+	db, _ := NewEventSourcedSignatureDeviceDb(NewEventStore())
+
+	err := db.Store(context.Background(), device1)
+
+	assertEqual(t, ErrNoEvents, err)
+}
+
+func TestEventSourcedCompareAndSwap_Ok(t *testing.T) {
+	db, _ := NewEventSourcedSignatureDeviceDb(NewEventStore())
+	_ = db.Store(context.Background(), createdDevice1())
+	old, _ := db.FindById(context.Background(), device1.Tenant, device1.Id)
+
+	err := db.CompareAndSwap(context.Background(), old, old.Record(signedAt, TransactionSigned{Counter: 0, Signature: "c2lnbmF0dXJl", SignedAt: signedAt}))
+	device, _ := db.FindById(context.Background(), device1.Tenant, device1.Id)
+
+	assertEqual(t, nil, err)
+	assertEqual(t, 1, device.SignatureCounter)
+	assertEqual(t, 2, device.Version)
+}
+
+func TestEventSourcedCompareAndSwap_ErrModified(t *testing.T) {
+	db, _ := NewEventSourcedSignatureDeviceDb(NewEventStore())
+	_ = db.Store(context.Background(), createdDevice1())
+	old, _ := db.FindById(context.Background(), device1.Tenant, device1.Id)
+	_ = db.CompareAndSwap(context.Background(), old, old.Record(signedAt, LabelChanged{Label: "a"}))
+
+	err := db.CompareAndSwap(context.Background(), old, old.Record(signedAt, LabelChanged{Label: "b"}))
+	device, _ := db.FindById(context.Background(), device1.Tenant, device1.Id)
+
+	assertEqual(t, ErrModified, err)
+	assertEqual(t, "a", device.Label)
+}
+
+func TestEventSourcedRebuild_Ok(t *testing.T) {
+	store := NewEventStore()
+	db, _ := NewEventSourcedSignatureDeviceDb(store)
+	_ = db.Store(context.Background(), createdDevice1())
+	old, _ := db.FindById(context.Background(), device1.Tenant, device1.Id)
+	_ = db.CompareAndSwap(context.Background(), old, old.Record(signedAt, TransactionSigned{Counter: 0, Signature: "c2lnbmF0dXJl", SignedAt: signedAt}))
+	expected, _ := db.FindById(context.Background(), device1.Tenant, device1.Id)
+
+	rebuilt, err := NewEventSourcedSignatureDeviceDb(store)
+	device, _ := rebuilt.FindById(context.Background(), device1.Tenant, device1.Id)
+
+	assertEqual(t, nil, err)
+	assertEqual(t, expected, device)
+	assertEqual(t, nil, db.Rebuild())
+	assertEqual(t, []SignatureDevice{expected}, db.FindAll(context.Background(), device1.Tenant))
+}
+
+func TestEventSourcedCountActive(t *testing.T) {
+	store := NewEventStore()
+	db, _ := NewEventSourcedSignatureDeviceDb(store)
+	_ = db.Store(context.Background(), createdDevice1())
+	assertEqual(t, 1, db.CountActive(context.Background(), device1.Tenant))
+
+	old, _ := db.FindById(context.Background(), device1.Tenant, device1.Id)
+	_ = db.CompareAndSwap(context.Background(), old, old.Record(signedAt, Decommissioned{}))
+	rebuilt, _ := NewEventSourcedSignatureDeviceDb(store)
+
+	assertEqual(t, 0, db.CountActive(context.Background(), device1.Tenant))
+	assertEqual(t, 0, rebuilt.CountActive(context.Background(), device1.Tenant))
+	assertEqual(t, 0, db.CountActive(context.Background(), "other"))
+}
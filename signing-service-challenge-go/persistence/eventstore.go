@@ -0,0 +1,105 @@
+package persistence
+
+import (
+	"errors"
+	"sync"
+)
+
+var ErrClosed = errors.New("event store closed")
+
+// IEventStore is an append-only log of device events.
+type IEventStore interface {
+	// Append adds events to the stream of a device if the stream currently
+	// is at expectedVersion, with 0 denoting a stream that does not exist yet.
+	Append(tenant TenantId, id Id, expectedVersion int, events []Event) error
+	// Load returns the events of a device in the order they were appended.
+	Load(tenant TenantId, id Id) ([]Event, error)
+	// LoadAll returns the events of all devices in the order they were appended.
+	LoadAll() []Event
+	// Ping reports whether events can be appended.
+	Ping() error
+	// Close flushes appended events and refuses further appends.
+	Close() error
+}
+
+// streamKey identifies the stream of a device.
+type streamKey struct {
+	tenant TenantId
+	id     Id
+}
+
+type InMemoryEventStore struct {
+	mu      sync.RWMutex
+	streams map[streamKey][]Event
+	log     []Event
+	closed  bool
+}
+
+func NewEventStore() IEventStore {
+	return &InMemoryEventStore{
+		streams: make(map[streamKey][]Event),
+	}
+}
+
+func (s *InMemoryEventStore) Append(tenant TenantId, id Id, expectedVersion int, events []Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return ErrClosed
+	}
+	key := streamKey{tenant: tenant, id: id}
+	stream, exists := s.streams[key]
+	if expectedVersion == 0 && exists {
+		return ErrExists
+	}
+	if expectedVersion > 0 && !exists {
+		return ErrNotFound
+	}
+	if len(stream) != expectedVersion {
+		return ErrModified
+	}
+	for i, event := range events {
+		if event.Tenant != tenant || event.DeviceId != id || event.Version != expectedVersion+i+1 {
+			return ErrModified
+		}
+	}
+
+	s.streams[key] = append(stream, events...)
+	s.log = append(s.log, events...)
+	return nil
+}
+
+func (s *InMemoryEventStore) Load(tenant TenantId, id Id) ([]Event, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	stream, exists := s.streams[streamKey{tenant: tenant, id: id}]
+	if !exists {
+		return nil, ErrNotFound
+	}
+	return append([]Event(nil), stream...), nil
+}
+
+func (s *InMemoryEventStore) LoadAll() []Event {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]Event(nil), s.log...)
+}
+
+func (s *InMemoryEventStore) Ping() error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.closed {
+		return ErrClosed
+	}
+	return nil
+}
+
+// Close refuses further appends. The events stay readable, there is
+// nothing to flush.
+func (s *InMemoryEventStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	return nil
+}
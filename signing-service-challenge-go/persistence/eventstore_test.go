@@ -0,0 +1,104 @@
+package persistence
+
+import (
+	"testing"
+	"time"
+)
+
+func events(tenant TenantId, id Id, from, to int) []Event {
+	result := make([]Event, 0)
+	for version := from; version <= to; version++ {
+		result = append(result, Event{
+			Tenant:     tenant,
+			DeviceId:   id,
+			Version:    version,
+			OccurredAt: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+			Data:       LabelChanged{Label: "label"},
+		})
+	}
+	return result
+}
+
+func TestAppend_Ok(t *testing.T) {
+	store := NewEventStore()
+
+	err := store.Append("t", "a", 0, events("t", "a", 1, 2))
+	assertEqual(t, nil, err)
+	err = store.Append("t", "a", 2, events("t", "a", 3, 3))
+	assertEqual(t, nil, err)
+
+	stream, err := store.Load("t", "a")
+	assertEqual(t, nil, err)
+	assertEqual(t, events("t", "a", 1, 3), stream)
+}
+
+func TestAppend_ErrExists(t *testing.T) {
+	store := NewEventStore()
+	_ = store.Append("t", "a", 0, events("t", "a", 1, 1))
+
+	err := store.Append("t", "a", 0, events("t", "a", 1, 1))
+
+	assertEqual(t, ErrExists, err)
+}
+
+func TestAppend_ErrNotFound(t *testing.T) {
+	store := NewEventStore()
+
+	err := store.Append("t", "a", 1, events("t", "a", 2, 2))
+
+	assertEqual(t, ErrNotFound, err)
+}
+
+func TestAppend_ErrModified(t *testing.T) {
+	store := NewEventStore()
+	_ = store.Append("t", "a", 0, events("t", "a", 1, 2))
+
+	assertEqual(t, ErrModified, store.Append("t", "a", 1, events("t", "a", 2, 2)))
+	assertEqual(t, ErrModified, store.Append("t", "a", 2, events("t", "a", 4, 4)))
+	assertEqual(t, ErrModified, store.Append("t", "a", 2, events("t", "b", 3, 3)))
+	assertEqual(t, ErrModified, store.Append("t", "a", 2, events("u", "a", 3, 3)))
+}
+
+func TestLoadAll_Ok(t *testing.T) {
+	store := NewEventStore()
+	_ = store.Append("t", "a", 0, events("t", "a", 1, 1))
+	_ = store.Append("t", "b", 0, events("t", "b", 1, 1))
+	_ = store.Append("t", "a", 1, events("t", "a", 2, 2))
+
+	all := store.LoadAll()
+
+	assertEqual(t, []Event{events("t", "a", 1, 1)[0], events("t", "b", 1, 1)[0], events("t", "a", 2, 2)[0]}, all)
+}
+
+func TestAppend_TenantsAreIsolated(t *testing.T) {
+	store := NewEventStore()
+	_ = store.Append("t", "a", 0, events("t", "a", 1, 1))
+
+	err := store.Append("u", "a", 0, events("u", "a", 1, 1))
+	assertEqual(t, nil, err)
+
+	_, err = store.Load("v", "a")
+	assertEqual(t, ErrNotFound, err)
+}
+
+func TestLoad_ErrNotFound(t *testing.T) {
+	store := NewEventStore()
+
+	_, err := store.Load("t", "a")
+
+	assertEqual(t, ErrNotFound, err)
+}
+
+func TestClose_RefusesAppends(t *testing.T) {
+	store := NewEventStore()
+	_ = store.Append("t", "a", 0, events("t", "a", 1, 1))
+	assertEqual(t, nil, store.Ping())
+
+	assertEqual(t, nil, store.Close())
+
+	assertEqual(t, ErrClosed, store.Ping())
+	assertEqual(t, ErrClosed, store.Append("t", "a", 1, events("t", "a", 2, 2)))
+	stream, err := store.Load("t", "a")
+	assertEqual(t, nil, err)
+	assertEqual(t, events("t", "a", 1, 1), stream)
+}
@@ -1,32 +1,74 @@
 package persistence
 
 import (
+	"context"
 	"errors"
 	"sync"
+	"time"
 )
 
 type Id string
 
+// TenantId identifies the organisation owning a device. Devices of different
+// tenants are isolated, even if their ids are equal.
+type TenantId string
+
 type ISignatureDeviceDb interface {
-	Store(device SignatureDevice) error
-	FindById(id Id) (SignatureDevice, error)
-	CompareAndSwap(old, new SignatureDevice) error
-	FindAll() []SignatureDevice
+	Store(ctx context.Context, device SignatureDevice) error
+	FindById(ctx context.Context, tenant TenantId, id Id) (SignatureDevice, error)
+	// CompareAndSwap replaces the device with new unless its version has
+	// changed since old was read.
+	CompareAndSwap(ctx context.Context, old, new SignatureDevice) error
+	FindAll(ctx context.Context, tenant TenantId) []SignatureDevice
+	// Query returns a page of the devices of a tenant matching the query.
+	Query(ctx context.Context, tenant TenantId, query DeviceQuery) (DevicePage, error)
+	// CountByState counts the devices of all tenants by their state.
+	CountByState(ctx context.Context) map[DeviceState]int
+	// CountActive counts the devices of a tenant that are not
+	// decommissioned, without reading them.
+	CountActive(ctx context.Context, tenant TenantId) int
 }
 
 type SignatureDevice struct {
-	Id               Id
-	Algorithm        string
-	Label            string
-	PublicKey        []byte
-	PrivateKey       []byte
-	SignatureCounter int
-	LastSignature    string
+	Tenant     TenantId
+	Id         Id
+	Algorithm  string
+	Label      string
+	PublicKey  []byte
+	PrivateKey []byte
+	// RetiredKeys are the keys replaced by KeyRotated, oldest first.
+	RetiredKeys       []RetiredKey
+	SignatureCounter  int
+	LastSignature     string
+	LastSignedAt      time.Time
+	Certificate       []byte
+	Mode              string
+	AggregationWindow time.Duration
+	AggregationSize   int
+	DecommissionedAt  time.Time
+	CreatedAt         time.Time
+	// Tags and Metadata are never modified in place, events replace them.
+	Tags     map[string]string
+	Metadata map[string]interface{}
+	// Version is the number of events applied to the device.
+	Version int
+	// Uncommitted holds the events recorded since the device was loaded.
+	Uncommitted []Event
+}
+
+// RetiredKey is a public key a device signed with before its key was
+// rotated. It made the signatures with counters below UntilCounter that
+// the key retired before it did not make.
+type RetiredKey struct {
+	PublicKey    []byte
+	Certificate  []byte
+	UntilCounter int
 }
 
 type InMemorySignatureDeviceDb struct {
-	mu    sync.RWMutex
-	store map[Id]SignatureDevice
+	mu     sync.RWMutex
+	store  map[TenantId]map[Id]SignatureDevice
+	active map[TenantId]int
 }
 
 var (
@@ -37,50 +79,89 @@ var (
 
 func NewSignatureDeviceDb() ISignatureDeviceDb {
 	return &InMemorySignatureDeviceDb{
-		store: make(map[Id]SignatureDevice),
+		store:  make(map[TenantId]map[Id]SignatureDevice),
+		active: make(map[TenantId]int),
 	}
 }
 
-func (db *InMemorySignatureDeviceDb) Store(device SignatureDevice) error {
+func (db *InMemorySignatureDeviceDb) Store(ctx context.Context, device SignatureDevice) error {
+	span := startSpan(ctx, "persistence.Store", device.Tenant, device.Id)
+	defer span.End()
 	db.mu.Lock()
 	defer db.mu.Unlock()
-	if _, exists := db.store[device.Id]; exists {
+	devices, exists := db.store[device.Tenant]
+	if !exists {
+		devices = make(map[Id]SignatureDevice)
+		db.store[device.Tenant] = devices
+	}
+	if _, exists := devices[device.Id]; exists {
 		return ErrExists
 	}
-	db.store[device.Id] = device
+	device.Uncommitted = nil
+	devices[device.Id] = device
+	db.active[device.Tenant] += activeDelta(SignatureDevice{}, device)
 	return nil
 }
 
-func (db *InMemorySignatureDeviceDb) FindById(key Id) (SignatureDevice, error) {
+func (db *InMemorySignatureDeviceDb) FindById(ctx context.Context, tenant TenantId, key Id) (SignatureDevice, error) {
+	span := startSpan(ctx, "persistence.FindById", tenant, key)
+	defer span.End()
 	db.mu.RLock()
 	defer db.mu.RUnlock()
-	device, exists := db.store[key]
+	device, exists := db.store[tenant][key]
 	if !exists {
 		return SignatureDevice{}, ErrNotFound
 	}
 	return device, nil
 }
 
-func (db *InMemorySignatureDeviceDb) CompareAndSwap(old, new SignatureDevice) error {
+func (db *InMemorySignatureDeviceDb) CompareAndSwap(ctx context.Context, old, new SignatureDevice) error {
+	span := startSpan(ctx, "persistence.CompareAndSwap", new.Tenant, new.Id)
+	defer span.End()
 	db.mu.Lock()
 	defer db.mu.Unlock()
-	record, exists := db.store[new.Id]
+	record, exists := db.store[new.Tenant][new.Id]
 	if !exists {
+		span.SetError(ErrNotFound)
 		return ErrNotFound
 	}
-	if record.SignatureCounter != old.SignatureCounter {
+	if record.Version != old.Version {
+		span.SetError(ErrModified)
 		return ErrModified
 	}
-	db.store[new.Id] = new
+	new.Uncommitted = nil
+	db.store[new.Tenant][new.Id] = new
+	db.active[new.Tenant] += activeDelta(record, new)
 	return nil
 }
 
-func (db *InMemorySignatureDeviceDb) FindAll() []SignatureDevice {
+func (db *InMemorySignatureDeviceDb) FindAll(_ context.Context, tenant TenantId) []SignatureDevice {
 	db.mu.RLock()
 	defer db.mu.RUnlock()
 	values := make([]SignatureDevice, 0)
-	for key := range db.store {
-		values = append(values, db.store[key])
+	for key := range db.store[tenant] {
+		values = append(values, db.store[tenant][key])
 	}
 	return values
 }
+
+func (db *InMemorySignatureDeviceDb) Query(_ context.Context, tenant TenantId, query DeviceQuery) (DevicePage, error) {
+	if err := query.Validate(); err != nil {
+		return DevicePage{}, err
+	}
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	return query.run(db.store[tenant]), nil
+}
+
+func (db *InMemorySignatureDeviceDb) CountByState(_ context.Context) map[DeviceState]int {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	return countByState(db.store)
+}
+
+func (db *InMemorySignatureDeviceDb) CountActive(_ context.Context, tenant TenantId) int {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	return db.active[tenant]
+}
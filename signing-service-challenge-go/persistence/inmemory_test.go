@@ -1,11 +1,14 @@
 package persistence
 
 import (
+	"context"
 	"reflect"
 	"testing"
+	"time"
 )
 
 var device1 = SignatureDevice{
+	Tenant:    "tenant1",
 	Id:        "550e8400-e29b-11d4-a716-446655440000",
 	Algorithm: "ECC",
 	Label:     "device1",
@@ -33,27 +36,27 @@ func assertEqual(t *testing.T, expected any, actual any) {
 func TestStore_Ok(t *testing.T) {
 	db := NewSignatureDeviceDb()
 
-	err := db.Store(device1)
+	err := db.Store(context.Background(), device1)
 
 	assertEqual(t, nil, err)
-	device, _ := db.FindById(device1.Id)
+	device, _ := db.FindById(context.Background(), device1.Tenant, device1.Id)
 	assertEqual(t, device1, device)
 }
 
 func TestStore_ErrExists(t *testing.T) {
 	db := NewSignatureDeviceDb()
-	_ = db.Store(device1)
+	_ = db.Store(context.Background(), device1)
 
-	err := db.Store(device1)
+	err := db.Store(context.Background(), device1)
 
 	assertEqual(t, ErrExists, err)
 }
 
 func TestFindById_Ok(t *testing.T) {
 	db := NewSignatureDeviceDb()
-	_ = db.Store(device1)
+	_ = db.Store(context.Background(), device1)
 
-	device, err := db.FindById(device1.Id)
+	device, err := db.FindById(context.Background(), device1.Tenant, device1.Id)
 
 	assertEqual(t, nil, err)
 	assertEqual(t, device1, device)
@@ -62,7 +65,7 @@ func TestFindById_Ok(t *testing.T) {
 func TestFindById_ErrNotFound(t *testing.T) {
 	db := NewSignatureDeviceDb()
 
-	device, err := db.FindById(device1.Id)
+	device, err := db.FindById(context.Background(), device1.Tenant, device1.Id)
 
 	assertEqual(t, ErrNotFound, err)
 	assertEqual(t, SignatureDevice{}, device)
@@ -70,8 +73,9 @@ func TestFindById_ErrNotFound(t *testing.T) {
 
 func TestCompareAndSwap_Ok(t *testing.T) {
 	db := NewSignatureDeviceDb()
-	_ = db.Store(device1)
+	_ = db.Store(context.Background(), device1)
 	device2 := SignatureDevice{
+		Tenant:           device1.Tenant,
 		Id:               device1.Id,
 		Algorithm:        device1.Algorithm,
 		Label:            device1.Label,
@@ -79,10 +83,11 @@ func TestCompareAndSwap_Ok(t *testing.T) {
 		PrivateKey:       device1.PrivateKey,
 		SignatureCounter: device1.SignatureCounter + 1,
 		LastSignature:    device1.LastSignature,
+		Version:          device1.Version + 1,
 	}
 
-	err := db.CompareAndSwap(device1, device2)
-	device, _ := db.FindById(device1.Id)
+	err := db.CompareAndSwap(context.Background(), device1, device2)
+	device, _ := db.FindById(context.Background(), device1.Tenant, device1.Id)
 
 	assertEqual(t, nil, err)
 	assertEqual(t, device2, device)
@@ -91,7 +96,7 @@ func TestCompareAndSwap_Ok(t *testing.T) {
 func TestCompareAndSwap_ErrNotFound(t *testing.T) {
 	db := NewSignatureDeviceDb()
 
-	err := db.CompareAndSwap(device1, device1)
+	err := db.CompareAndSwap(context.Background(), device1, device1)
 
 	assertEqual(t, ErrNotFound, err)
 }
@@ -99,23 +104,78 @@ func TestCompareAndSwap_ErrNotFound(t *testing.T) {
 func TestCompareAndSwap_ErrModified(t *testing.T) {
 	db := NewSignatureDeviceDb()
 	device2 := SignatureDevice{
+		Tenant:           device1.Tenant,
+		Id:               device1.Id,
 		SignatureCounter: device1.SignatureCounter + 1,
+		Version:          device1.Version + 1,
 	}
-	_ = db.Store(device2)
+	_ = db.Store(context.Background(), device2)
 	device3 := SignatureDevice{
+		Tenant:           device1.Tenant,
+		Id:               device1.Id,
 		SignatureCounter: device2.SignatureCounter + 1,
+		Version:          device2.Version + 1,
 	}
 
-	err := db.CompareAndSwap(device1, device3)
+	err := db.CompareAndSwap(context.Background(), device1, device3)
 
 	assertEqual(t, ErrModified, err)
 }
 
+func TestCompareAndSwap_ErrModifiedDecommissioned(t *testing.T) {
+	db := NewSignatureDeviceDb()
+	decommissioned := device1
+	decommissioned.DecommissionedAt = time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	decommissioned.Version++
+	_ = db.Store(context.Background(), decommissioned)
+	device2 := device1
+	device2.SignatureCounter++
+	device2.Version++
+
+	err := db.CompareAndSwap(context.Background(), device1, device2)
+
+	assertEqual(t, ErrModified, err)
+}
+
+func TestCompareAndSwap_ErrModifiedLabel(t *testing.T) {
+	db := NewSignatureDeviceDb()
+	_ = db.Store(context.Background(), device1)
+	renamed := device1.Record(time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC), LabelChanged{Label: "renamed"})
+	_ = db.CompareAndSwap(context.Background(), device1, renamed)
+	device2 := device1.Record(time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC), LabelChanged{Label: "other"})
+
+	err := db.CompareAndSwap(context.Background(), device1, device2)
+
+	assertEqual(t, ErrModified, err)
+}
+
+func TestFindById_ErrNotFoundOtherTenant(t *testing.T) {
+	db := NewSignatureDeviceDb()
+	_ = db.Store(context.Background(), device1)
+
+	_, err := db.FindById(context.Background(), "tenant2", device1.Id)
+
+	assertEqual(t, ErrNotFound, err)
+	assertEqual(t, []SignatureDevice{}, db.FindAll(context.Background(), "tenant2"))
+}
+
+func TestStore_SameIdOtherTenant(t *testing.T) {
+	db := NewSignatureDeviceDb()
+	_ = db.Store(context.Background(), device1)
+	device2 := device1
+	device2.Tenant = "tenant2"
+
+	err := db.Store(context.Background(), device2)
+
+	assertEqual(t, nil, err)
+	assertEqual(t, 1, len(db.FindAll(context.Background(), device1.Tenant)))
+}
+
 func TestFindAll_Ok(t *testing.T) {
 	db := NewSignatureDeviceDb()
-	_ = db.Store(device1)
+	_ = db.Store(context.Background(), device1)
 
-	devices := db.FindAll()
+	devices := db.FindAll(context.Background(), device1.Tenant)
 
 	assertEqual(t, device1, devices[0])
 }
@@ -123,7 +183,30 @@ func TestFindAll_Ok(t *testing.T) {
 func TestFindAll_OkEmpty(t *testing.T) {
 	db := NewSignatureDeviceDb()
 
-	device := db.FindAll()
+	device := db.FindAll(context.Background(), device1.Tenant)
 
 	assertEqual(t, []SignatureDevice{}, device)
 }
+
+func TestCountByState(t *testing.T) {
+	db := NewSignatureDeviceDb()
+	_ = db.Store(context.Background(), SignatureDevice{Tenant: "tenant1", Id: "device1"})
+	_ = db.Store(context.Background(), SignatureDevice{Tenant: "tenant2", Id: "device1"})
+	_ = db.Store(context.Background(), SignatureDevice{Tenant: "tenant2", Id: "device2", DecommissionedAt: time.Now()})
+
+	assertEqual(t, map[DeviceState]int{DeviceStateActive: 2, DeviceStateDecommissioned: 1}, db.CountByState(context.Background()))
+}
+
+func TestCountActive(t *testing.T) {
+	db := NewSignatureDeviceDb()
+	_ = db.Store(context.Background(), SignatureDevice{Tenant: "tenant1", Id: "device1"})
+	_ = db.Store(context.Background(), SignatureDevice{Tenant: "tenant1", Id: "device2"})
+	_ = db.Store(context.Background(), SignatureDevice{Tenant: "tenant2", Id: "device1"})
+	old, _ := db.FindById(context.Background(), "tenant1", "device2")
+	decommissioned := old
+	decommissioned.DecommissionedAt = time.Now()
+	_ = db.CompareAndSwap(context.Background(), old, decommissioned)
+
+	assertEqual(t, 1, db.CountActive(context.Background(), "tenant1"))
+	assertEqual(t, 1, db.CountActive(context.Background(), "tenant2"))
+}
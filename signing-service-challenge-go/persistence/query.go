@@ -0,0 +1,216 @@
+package persistence
+
+import (
+	"errors"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+var ErrInvalidQuery = errors.New("invalid query")
+
+// DeviceState filters devices by whether they can still sign.
+type DeviceState string
+
+const (
+	DeviceStateActive         DeviceState = "active"
+	DeviceStateDecommissioned DeviceState = "decommissioned"
+)
+
+// SortField orders the devices of a query. Ties are ordered by id.
+type SortField string
+
+const (
+	SortByCreatedAt        SortField = "created_at"
+	SortByLabel            SortField = "label"
+	SortBySignatureCounter SortField = "signature_counter"
+)
+
+// DeviceQuery selects, orders and pages the devices of a tenant, which a
+// database can translate into a WHERE, ORDER BY and LIMIT clause. The zero
+// DeviceQuery returns every device ordered by creation time.
+type DeviceQuery struct {
+	Algorithm string
+	// LabelContains matches a substring of the label.
+	LabelContains string
+	State         DeviceState
+	// Tags must all be set on a device with equal values.
+	Tags map[string]string
+	// MinCounter and MaxCounter bound the signature counter inclusively
+	// if they are set.
+	MinCounter *int
+	MaxCounter *int
+	// Resources restricts the devices to those matching one of the
+	// patterns "*", "device:<id>" or "label:<label>" of the access policy,
+	// where id and label may contain the wildcards of path.Match. Nil
+	// matches every device, an empty list none.
+	Resources  []string
+	SortBy     SortField
+	Descending bool
+	// After continues a previous page behind the device at the cursor.
+	After *DeviceCursor
+	// Limit is the maximum page size, 0 returns every matching device.
+	Limit int
+}
+
+// DeviceCursor is the position of a device in the order of a query.
+type DeviceCursor struct {
+	CreatedAt        time.Time
+	Label            string
+	SignatureCounter int
+	Id               Id
+}
+
+// Cursor returns the position of the device.
+func (d SignatureDevice) Cursor() DeviceCursor {
+	return DeviceCursor{
+		CreatedAt:        d.CreatedAt,
+		Label:            d.Label,
+		SignatureCounter: d.SignatureCounter,
+		Id:               d.Id,
+	}
+}
+
+// DevicePage is the result of a DeviceQuery. More is set if devices
+// follow the last one of the page.
+type DevicePage struct {
+	Devices []SignatureDevice
+	More    bool
+}
+
+// Validate returns ErrInvalidQuery for unknown states or sort fields and
+// negative limits.
+func (q DeviceQuery) Validate() error {
+	switch q.State {
+	case "", DeviceStateActive, DeviceStateDecommissioned:
+	default:
+		return ErrInvalidQuery
+	}
+	switch q.SortBy {
+	case "", SortByCreatedAt, SortByLabel, SortBySignatureCounter:
+	default:
+		return ErrInvalidQuery
+	}
+	if q.Limit < 0 {
+		return ErrInvalidQuery
+	}
+	return nil
+}
+
+func (q DeviceQuery) matches(device SignatureDevice) bool {
+	if q.Algorithm != "" && device.Algorithm != q.Algorithm {
+		return false
+	}
+	if q.LabelContains != "" && !strings.Contains(device.Label, q.LabelContains) {
+		return false
+	}
+	if q.State == DeviceStateActive && !device.DecommissionedAt.IsZero() {
+		return false
+	}
+	if q.State == DeviceStateDecommissioned && device.DecommissionedAt.IsZero() {
+		return false
+	}
+	for key, value := range q.Tags {
+		if tag, exists := device.Tags[key]; !exists || tag != value {
+			return false
+		}
+	}
+	if q.MinCounter != nil && device.SignatureCounter < *q.MinCounter {
+		return false
+	}
+	if q.MaxCounter != nil && device.SignatureCounter > *q.MaxCounter {
+		return false
+	}
+	if q.Resources != nil && !matchesResource(q.Resources, device) {
+		return false
+	}
+	if q.After != nil && q.compare(device.Cursor(), *q.After) <= 0 {
+		return false
+	}
+	return true
+}
+
+func matchesResource(patterns []string, device SignatureDevice) bool {
+	for _, pattern := range patterns {
+		if pattern == "*" {
+			return true
+		}
+		kind, value, _ := strings.Cut(pattern, ":")
+		var matched bool
+		switch kind {
+		case "device":
+			matched, _ = path.Match(value, string(device.Id))
+		case "label":
+			matched, _ = path.Match(value, device.Label)
+		}
+		if matched {
+			return true
+		}
+	}
+	return false
+}
+
+// compare orders two cursors in the order of the query.
+func (q DeviceQuery) compare(a, b DeviceCursor) int {
+	result := 0
+	switch q.SortBy {
+	case SortByLabel:
+		result = strings.Compare(a.Label, b.Label)
+	case SortBySignatureCounter:
+		result = a.SignatureCounter - b.SignatureCounter
+	default:
+		result = a.CreatedAt.Compare(b.CreatedAt)
+	}
+	if result == 0 {
+		result = strings.Compare(string(a.Id), string(b.Id))
+	}
+	if q.Descending {
+		return -result
+	}
+	return result
+}
+
+// run evaluates the query against all devices of a tenant.
+func (q DeviceQuery) run(devices map[Id]SignatureDevice) DevicePage {
+	matching := make([]SignatureDevice, 0)
+	for _, device := range devices {
+		if q.matches(device) {
+			matching = append(matching, device)
+		}
+	}
+	sort.Slice(matching, func(i, j int) bool {
+		return q.compare(matching[i].Cursor(), matching[j].Cursor()) < 0
+	})
+	if q.Limit > 0 && len(matching) > q.Limit {
+		return DevicePage{Devices: matching[:q.Limit], More: true}
+	}
+	return DevicePage{Devices: matching}
+}
+
+func countByState(devices map[TenantId]map[Id]SignatureDevice) map[DeviceState]int {
+	counts := map[DeviceState]int{DeviceStateActive: 0, DeviceStateDecommissioned: 0}
+	for _, tenantDevices := range devices {
+		for _, device := range tenantDevices {
+			if device.DecommissionedAt.IsZero() {
+				counts[DeviceStateActive]++
+			} else {
+				counts[DeviceStateDecommissioned]++
+			}
+		}
+	}
+	return counts
+}
+
+// activeDelta is how replacing old by new changes the count of active
+// devices of their tenant. The zero old is a device that did not exist.
+func activeDelta(old, new SignatureDevice) int {
+	return active(new) - active(old)
+}
+
+func active(device SignatureDevice) int {
+	if device.Id == "" || !device.DecommissionedAt.IsZero() {
+		return 0
+	}
+	return 1
+}
@@ -0,0 +1,114 @@
+package persistence
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func newQueryDb(t *testing.T) ISignatureDeviceDb {
+	t.Helper()
+	db := NewSignatureDeviceDb()
+	createdAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	for i := 0; i < 5; i++ {
+		device := SignatureDevice{
+			Tenant:           "tenant1",
+			Id:               Id(fmt.Sprintf("device%d", i)),
+			Algorithm:        "ECC",
+			Label:            fmt.Sprintf("till-%d", 4-i),
+			SignatureCounter: i * 10,
+			CreatedAt:        createdAt.Add(time.Duration(i) * time.Minute),
+		}
+		if i%2 == 1 {
+			device.Algorithm = "RSA"
+			device.DecommissionedAt = createdAt
+		}
+		assertEqual(t, nil, db.Store(context.Background(), device))
+	}
+	return db
+}
+
+func deviceIds(page DevicePage) []Id {
+	ids := make([]Id, 0, len(page.Devices))
+	for _, device := range page.Devices {
+		ids = append(ids, device.Id)
+	}
+	return ids
+}
+
+func TestQuery_SortsByCreationTime(t *testing.T) {
+	db := newQueryDb(t)
+
+	page, err := db.Query(context.Background(), "tenant1", DeviceQuery{})
+
+	assertEqual(t, nil, err)
+	assertEqual(t, []Id{"device0", "device1", "device2", "device3", "device4"}, deviceIds(page))
+	assertEqual(t, false, page.More)
+}
+
+func TestQuery_Filters(t *testing.T) {
+	db := newQueryDb(t)
+	min, max := 10, 30
+
+	page, _ := db.Query(context.Background(), "tenant1", DeviceQuery{Algorithm: "RSA"})
+	assertEqual(t, []Id{"device1", "device3"}, deviceIds(page))
+	page, _ = db.Query(context.Background(), "tenant1", DeviceQuery{State: DeviceStateActive})
+	assertEqual(t, []Id{"device0", "device2", "device4"}, deviceIds(page))
+	page, _ = db.Query(context.Background(), "tenant1", DeviceQuery{LabelContains: "-3"})
+	assertEqual(t, []Id{"device1"}, deviceIds(page))
+	page, _ = db.Query(context.Background(), "tenant1", DeviceQuery{MinCounter: &min, MaxCounter: &max})
+	assertEqual(t, []Id{"device1", "device2", "device3"}, deviceIds(page))
+	page, _ = db.Query(context.Background(), "tenant2", DeviceQuery{})
+	assertEqual(t, []Id{}, deviceIds(page))
+}
+
+func TestQuery_Pages(t *testing.T) {
+	db := newQueryDb(t)
+	query := DeviceQuery{SortBy: SortByLabel, Limit: 2}
+
+	page, _ := db.Query(context.Background(), "tenant1", query)
+	assertEqual(t, []Id{"device4", "device3"}, deviceIds(page))
+	assertEqual(t, true, page.More)
+
+	after := page.Devices[1].Cursor()
+	query.After = &after
+	page, _ = db.Query(context.Background(), "tenant1", query)
+	assertEqual(t, []Id{"device2", "device1"}, deviceIds(page))
+
+	after = page.Devices[1].Cursor()
+	page, _ = db.Query(context.Background(), "tenant1", query)
+	assertEqual(t, []Id{"device0"}, deviceIds(page))
+	assertEqual(t, false, page.More)
+}
+
+func TestQuery_FiltersResourcesBeforePaging(t *testing.T) {
+	db := newQueryDb(t)
+	query := DeviceQuery{Resources: []string{"device:device4", "label:till-[23]"}, Limit: 2}
+
+	page, _ := db.Query(context.Background(), "tenant1", query)
+	assertEqual(t, []Id{"device1", "device2"}, deviceIds(page))
+	assertEqual(t, true, page.More)
+
+	page, _ = db.Query(context.Background(), "tenant1", DeviceQuery{Resources: []string{}})
+	assertEqual(t, []Id{}, deviceIds(page))
+	page, _ = db.Query(context.Background(), "tenant1", DeviceQuery{Resources: []string{"*"}})
+	assertEqual(t, 5, len(page.Devices))
+}
+
+func TestQuery_Descending(t *testing.T) {
+	db := newQueryDb(t)
+
+	page, _ := db.Query(context.Background(), "tenant1", DeviceQuery{SortBy: SortBySignatureCounter, Descending: true, Limit: 2})
+
+	assertEqual(t, []Id{"device4", "device3"}, deviceIds(page))
+}
+
+func TestQuery_ErrInvalidQuery(t *testing.T) {
+	db := newQueryDb(t)
+
+	_, err := db.Query(context.Background(), "tenant1", DeviceQuery{SortBy: "public_key"})
+	assertEqual(t, ErrInvalidQuery, err)
+	_, err = db.Query(context.Background(), "tenant1", DeviceQuery{State: "broken"})
+	assertEqual(t, ErrInvalidQuery, err)
+}
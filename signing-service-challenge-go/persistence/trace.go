@@ -0,0 +1,16 @@
+package persistence
+
+import (
+	"context"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/tracing"
+)
+
+// startSpan starts the span of a db call on a device. The span is nil
+// unless ctx is traced.
+func startSpan(ctx context.Context, name string, tenant TenantId, id Id) *tracing.Span {
+	_, span := tracing.Start(ctx, name)
+	span.SetAttribute("tenant", string(tenant))
+	span.SetAttribute("device.id", string(id))
+	return span
+}
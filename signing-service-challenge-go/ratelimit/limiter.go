@@ -0,0 +1,275 @@
+package ratelimit
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain"
+)
+
+var (
+	ErrLimited      = errors.New("rate limit exceeded")
+	ErrInvalidLimit = errors.New("invalid rate limit")
+	ErrInvalidScope = errors.New("invalid rate limit scope")
+	// ErrExceedsBurst is an ErrLimited that waiting does not resolve: more
+	// tokens were requested than a bucket can hold.
+	ErrExceedsBurst = fmt.Errorf("%w: more tokens than the burst", ErrLimited)
+)
+
+// sweepInterval is how often Take evicts the buckets that have refilled
+// completely. A full bucket behaves like a new one, so only the buckets of
+// recent callers are kept.
+const sweepInterval = time.Minute
+
+// Scope tells what a token bucket limits.
+type Scope string
+
+const (
+	ScopeGlobal Scope = "global"
+	ScopeKey    Scope = "key"
+	ScopeDevice Scope = "device"
+)
+
+// IsValid reports whether the scope is known.
+func (s Scope) IsValid() bool {
+	return s == ScopeGlobal || s == ScopeKey || s == ScopeDevice
+}
+
+var scopeOrder = map[Scope]int{ScopeGlobal: 0, ScopeKey: 1, ScopeDevice: 2}
+
+// Limit refills a bucket of Burst tokens at Rate tokens per second. The
+// zero Limit is unlimited.
+type Limit struct {
+	Rate  float64 `json:"rate"`
+	Burst int     `json:"burst"`
+}
+
+// Unlimited reports whether the limit admits every request.
+func (l Limit) Unlimited() bool {
+	return l.Rate == 0
+}
+
+func (l Limit) validate() error {
+	if l.Rate < 0 || l.Burst < 0 || (l.Rate > 0 && l.Burst < 1) {
+		return ErrInvalidLimit
+	}
+	return nil
+}
+
+// ParseLimit parses "<rate>:<burst>", e.g. "10:20" for ten requests per
+// second with bursts of twenty. The empty string is unlimited.
+func ParseLimit(value string) (Limit, error) {
+	if value == "" {
+		return Limit{}, nil
+	}
+	rate, burst, found := strings.Cut(value, ":")
+	if !found {
+		return Limit{}, fmt.Errorf("%w: %q", ErrInvalidLimit, value)
+	}
+	var limit Limit
+	var err error
+	if limit.Rate, err = strconv.ParseFloat(rate, 64); err != nil {
+		return Limit{}, fmt.Errorf("%w: %q", ErrInvalidLimit, value)
+	}
+	if limit.Burst, err = strconv.Atoi(burst); err != nil {
+		return Limit{}, fmt.Errorf("%w: %q", ErrInvalidLimit, value)
+	}
+	if err := limit.validate(); err != nil {
+		return Limit{}, fmt.Errorf("%w: %q", err, value)
+	}
+	return limit, nil
+}
+
+// Key identifies a bucket. Key and device buckets belong to a tenant, the
+// global bucket has none.
+type Key struct {
+	Scope  Scope
+	Tenant string
+	Id     string
+}
+
+// Global is the key of the bucket shared by all requests.
+var Global = Key{Scope: ScopeGlobal}
+
+// State is a snapshot of a bucket.
+type State struct {
+	Key    Key
+	Limit  Limit
+	Tokens float64
+	// Custom is set if the limit overrides the default of the scope.
+	Custom bool
+}
+
+type ILimiter interface {
+	// Take removes n tokens from each of the buckets, or from none of them
+	// if one holds fewer. It then returns ErrLimited and the time until
+	// every bucket holds n tokens again, or ErrExceedsBurst if n is larger
+	// than the burst of one of them.
+	Take(n int, keys ...Key) (time.Duration, error)
+	// SetLimit overrides the default limit of the scope for one bucket.
+	SetLimit(key Key, limit Limit) error
+	// States returns the global bucket and the buckets of a tenant.
+	States(tenant string) []State
+}
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+type Limiter struct {
+	mu        sync.Mutex
+	clock     domain.IClock
+	defaults  map[Scope]Limit
+	overrides map[Key]Limit
+	buckets   map[Key]*bucket
+	swept     time.Time
+}
+
+// NewLimiter creates a Limiter with the default limits of each scope.
+// Scopes without a default are unlimited.
+func NewLimiter(clock domain.IClock, defaults map[Scope]Limit) (ILimiter, error) {
+	limiter := &Limiter{
+		clock:     clock,
+		defaults:  make(map[Scope]Limit),
+		overrides: make(map[Key]Limit),
+		buckets:   make(map[Key]*bucket),
+		swept:     clock.Now(),
+	}
+	for scope, limit := range defaults {
+		if !scope.IsValid() {
+			return nil, ErrInvalidScope
+		}
+		if err := limit.validate(); err != nil {
+			return nil, err
+		}
+		limiter.defaults[scope] = limit
+	}
+	return limiter, nil
+}
+
+func (l *Limiter) Take(n int, keys ...Key) (time.Duration, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.clock.Now()
+	if now.Sub(l.swept) >= sweepInterval {
+		l.sweep(now)
+	}
+	var wait time.Duration
+	buckets := make([]*bucket, 0, len(keys))
+	for _, key := range keys {
+		limit, _ := l.limit(key)
+		if limit.Unlimited() {
+			continue
+		}
+		if n > limit.Burst {
+			return 0, ErrExceedsBurst
+		}
+		b := l.refill(key, limit, now)
+		if b.tokens < float64(n) {
+			missing := time.Duration(math.Ceil((float64(n) - b.tokens) / limit.Rate * float64(time.Second)))
+			if missing > wait {
+				wait = missing
+			}
+		}
+		buckets = append(buckets, b)
+	}
+	if wait > 0 {
+		return wait, ErrLimited
+	}
+	for _, b := range buckets {
+		b.tokens -= float64(n)
+	}
+	return 0, nil
+}
+
+// limit must be called with l.mu held.
+func (l *Limiter) limit(key Key) (Limit, bool) {
+	if limit, exists := l.overrides[key]; exists {
+		return limit, true
+	}
+	return l.defaults[key.Scope], false
+}
+
+// refill must be called with l.mu held. New buckets start full.
+func (l *Limiter) refill(key Key, limit Limit, now time.Time) *bucket {
+	b, exists := l.buckets[key]
+	if !exists {
+		b = &bucket{tokens: float64(limit.Burst), updated: now}
+		l.buckets[key] = b
+		return b
+	}
+	if elapsed := now.Sub(b.updated); elapsed > 0 {
+		b.tokens = math.Min(float64(limit.Burst), b.tokens+elapsed.Seconds()*limit.Rate)
+		b.updated = now
+	}
+	// A lowered limit caps the tokens at once.
+	b.tokens = math.Min(float64(limit.Burst), b.tokens)
+	return b
+}
+
+// sweep evicts the full buckets. It must be called with l.mu held.
+func (l *Limiter) sweep(now time.Time) {
+	for key := range l.buckets {
+		limit, _ := l.limit(key)
+		if limit.Unlimited() || l.refill(key, limit, now).tokens >= float64(limit.Burst) {
+			delete(l.buckets, key)
+		}
+	}
+	l.swept = now
+}
+
+func (l *Limiter) SetLimit(key Key, limit Limit) error {
+	if !key.Scope.IsValid() {
+		return ErrInvalidScope
+	}
+	if err := limit.validate(); err != nil {
+		return err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.overrides[key] = limit
+	return nil
+}
+
+func (l *Limiter) States(tenant string) []State {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.clock.Now()
+	keys := map[Key]struct{}{Global: {}}
+	for key := range l.buckets {
+		if key.Tenant == tenant {
+			keys[key] = struct{}{}
+		}
+	}
+	for key := range l.overrides {
+		if key.Tenant == tenant {
+			keys[key] = struct{}{}
+		}
+	}
+
+	states := make([]State, 0, len(keys))
+	for key := range keys {
+		limit, custom := l.limit(key)
+		state := State{Key: key, Limit: limit, Custom: custom}
+		if !limit.Unlimited() {
+			state.Tokens = l.refill(key, limit, now).tokens
+		}
+		states = append(states, state)
+	}
+	sort.Slice(states, func(i, j int) bool {
+		if states[i].Key.Scope != states[j].Key.Scope {
+			return scopeOrder[states[i].Key.Scope] < scopeOrder[states[j].Key.Scope]
+		}
+		return states[i].Key.Id < states[j].Key.Id
+	})
+	return states
+}
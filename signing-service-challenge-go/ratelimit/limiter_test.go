@@ -0,0 +1,138 @@
+package ratelimit
+
+import (
+	"errors"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func assertEqual(t *testing.T, expected interface{}, actual interface{}) {
+	t.Helper()
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("Expected %v, actual %v", expected, actual)
+	}
+}
+
+func assertErrorIs(t *testing.T, expected error, actual error) {
+	t.Helper()
+	if !errors.Is(actual, expected) {
+		t.Errorf("Expected %v, actual %v", expected, actual)
+	}
+}
+
+type FakeClock struct {
+	Time time.Time
+}
+
+func (c *FakeClock) Now() time.Time {
+	return c.Time
+}
+
+func newClock() *FakeClock {
+	return &FakeClock{Time: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)}
+}
+
+func TestTake_RefillsOverTime(t *testing.T) {
+	clock := newClock()
+	limiter, _ := NewLimiter(clock, map[Scope]Limit{ScopeDevice: {Rate: 2, Burst: 2}})
+	device := Key{Scope: ScopeDevice, Tenant: "tenant1", Id: "device1"}
+
+	_, err := limiter.Take(1, device)
+	assertEqual(t, nil, err)
+	_, err = limiter.Take(1, device)
+	assertEqual(t, nil, err)
+	wait, err := limiter.Take(1, device)
+	assertErrorIs(t, ErrLimited, err)
+	assertEqual(t, 500*time.Millisecond, wait)
+
+	clock.Time = clock.Time.Add(500 * time.Millisecond)
+	_, err = limiter.Take(1, device)
+	assertEqual(t, nil, err)
+}
+
+func TestTake_AllOrNothing(t *testing.T) {
+	clock := newClock()
+	limiter, _ := NewLimiter(clock, map[Scope]Limit{ScopeKey: {Rate: 1, Burst: 5}, ScopeDevice: {Rate: 1, Burst: 1}})
+	key := Key{Scope: ScopeKey, Tenant: "tenant1", Id: "key1"}
+	device := Key{Scope: ScopeDevice, Tenant: "tenant1", Id: "device1"}
+
+	_, err := limiter.Take(1, Global, key, device)
+	assertEqual(t, nil, err)
+	_, err = limiter.Take(1, Global, key, device)
+	assertErrorIs(t, ErrLimited, err)
+
+	states := limiter.States("tenant1")
+	assertEqual(t, 3, len(states))
+	assertEqual(t, Global, states[0].Key)
+	assertEqual(t, 4.0, states[1].Tokens)
+	assertEqual(t, 0.0, states[2].Tokens)
+}
+
+func TestTake_Tokens(t *testing.T) {
+	clock := newClock()
+	limiter, _ := NewLimiter(clock, map[Scope]Limit{ScopeDevice: {Rate: 1, Burst: 5}})
+	device := Key{Scope: ScopeDevice, Tenant: "tenant1", Id: "device1"}
+
+	_, err := limiter.Take(3, device)
+	assertEqual(t, nil, err)
+	wait, err := limiter.Take(3, device)
+	assertErrorIs(t, ErrLimited, err)
+	assertEqual(t, time.Second, wait)
+	_, err = limiter.Take(6, device)
+	assertErrorIs(t, ErrExceedsBurst, err)
+	assertErrorIs(t, ErrLimited, err)
+
+	assertEqual(t, 2.0, limiter.States("tenant1")[1].Tokens)
+}
+
+func TestTake_EvictsFullBuckets(t *testing.T) {
+	clock := newClock()
+	limiter, _ := NewLimiter(clock, map[Scope]Limit{ScopeDevice: {Rate: 1, Burst: 1}})
+	for i := 0; i < 100; i++ {
+		_, _ = limiter.Take(1, Key{Scope: ScopeDevice, Tenant: "tenant1", Id: strconv.Itoa(i)})
+	}
+	recent := Key{Scope: ScopeDevice, Tenant: "tenant1", Id: "recent"}
+
+	clock.Time = clock.Time.Add(sweepInterval)
+	_, err := limiter.Take(1, recent)
+
+	assertEqual(t, nil, err)
+	assertEqual(t, 1, len(limiter.(*Limiter).buckets))
+	_, err = limiter.Take(1, recent)
+	assertErrorIs(t, ErrLimited, err)
+}
+
+func TestSetLimit(t *testing.T) {
+	limiter, _ := NewLimiter(newClock(), nil)
+	device := Key{Scope: ScopeDevice, Tenant: "tenant1", Id: "device1"}
+
+	_, err := limiter.Take(1, device)
+	assertEqual(t, nil, err)
+	assertEqual(t, nil, limiter.SetLimit(device, Limit{Rate: 0.1, Burst: 1}))
+	_, err = limiter.Take(1, device)
+	assertEqual(t, nil, err)
+	wait, err := limiter.Take(1, device)
+	assertErrorIs(t, ErrLimited, err)
+	assertEqual(t, 10*time.Second, wait)
+
+	assertErrorIs(t, ErrInvalidLimit, limiter.SetLimit(device, Limit{Rate: 1, Burst: 0}))
+	assertErrorIs(t, ErrInvalidScope, limiter.SetLimit(Key{Scope: "ip"}, Limit{}))
+	assertEqual(t, 1, len(limiter.States("tenant2")))
+}
+
+func TestParseLimit(t *testing.T) {
+	limit, err := ParseLimit("10:20")
+	assertEqual(t, nil, err)
+	assertEqual(t, Limit{Rate: 10, Burst: 20}, limit)
+
+	limit, err = ParseLimit("")
+	assertEqual(t, nil, err)
+	assertEqual(t, true, limit.Unlimited())
+
+	for _, value := range []string{"10", "x:1", "1:x", "-1:1", "1:0"} {
+		_, err = ParseLimit(value)
+		assertErrorIs(t, ErrInvalidLimit, err)
+	}
+}
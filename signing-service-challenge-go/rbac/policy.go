@@ -0,0 +1,334 @@
+package rbac
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrNotFound        = errors.New("not found")
+	ErrForbidden       = errors.New("forbidden by policy")
+	ErrExists          = errors.New("already exists")
+	ErrInvalidRole     = errors.New("invalid role")
+	ErrUnknownRole     = errors.New("unknown role")
+	ErrInvalidBinding  = errors.New("invalid role binding")
+	ErrInvalidResource = errors.New("invalid resource pattern")
+)
+
+// Action is an operation on a signature device.
+type Action string
+
+const (
+	ActionCreate       Action = "devices:create"
+	ActionRead         Action = "devices:read"
+	ActionSign         Action = "devices:sign"
+	ActionVerify       Action = "devices:verify"
+	ActionDecommission Action = "devices:decommission"
+	// ActionUpdate grants changes of the label, tags and metadata.
+	ActionUpdate Action = "devices:update"
+	// ActionRotateKey grants the replacement of the key pair.
+	ActionRotateKey Action = "devices:rotate-key"
+	// ActionReadJournal grants the streams of committed signatures.
+	ActionReadJournal Action = "journal:read"
+)
+
+// Actions lists every known action.
+var Actions = []Action{ActionCreate, ActionRead, ActionSign, ActionVerify, ActionDecommission, ActionUpdate, ActionRotateKey, ActionReadJournal}
+
+// IsValid reports whether the action is known.
+func (a Action) IsValid() bool {
+	for _, known := range Actions {
+		if known == a {
+			return true
+		}
+	}
+	return false
+}
+
+// Role is a named set of actions. Roles without a tenant are built in and
+// exist in every tenant.
+type Role struct {
+	Name    string   `json:"name"`
+	Tenant  string   `json:"tenant,omitempty"`
+	Actions []Action `json:"actions"`
+}
+
+func (r Role) allows(action Action) bool {
+	for _, allowed := range r.Actions {
+		if allowed == action {
+			return true
+		}
+	}
+	return false
+}
+
+// BuiltinRoles are defined in every tenant.
+var BuiltinRoles = []Role{
+	{Name: "cashier", Actions: []Action{ActionRead, ActionSign, ActionVerify}},
+	{Name: "operator", Actions: []Action{ActionCreate, ActionRead, ActionVerify, ActionDecommission, ActionUpdate, ActionRotateKey}},
+	{Name: "auditor", Actions: []Action{ActionRead, ActionVerify, ActionReadJournal}},
+}
+
+// Binding grants a role to a subject on the devices matching one of the
+// resource patterns. The subject is the id of an API key or the identity of
+// a client certificate.
+//
+// A pattern is "*" for every device, "device:<id>" or "label:<label>", where
+// the id and the label may contain the wildcards of path.Match.
+type Binding struct {
+	Id        string    `json:"id"`
+	Tenant    string    `json:"tenant"`
+	Subject   string    `json:"subject"`
+	Role      string    `json:"role"`
+	Resources []string  `json:"resources"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Resource is the device an action is performed on.
+type Resource struct {
+	DeviceId string
+	Label    string
+}
+
+// AllDevices is the resource of tenant-wide operations. Only the "*"
+// pattern matches it.
+var AllDevices = Resource{}
+
+func (r Resource) matches(pattern string) bool {
+	if pattern == "*" {
+		return true
+	}
+	if r == AllDevices {
+		return false
+	}
+	kind, value, _ := strings.Cut(pattern, ":")
+	var matched bool
+	switch kind {
+	case "device":
+		matched, _ = path.Match(value, r.DeviceId)
+	case "label":
+		matched, _ = path.Match(value, r.Label)
+	}
+	return matched
+}
+
+func validateResource(pattern string) error {
+	if pattern == "*" {
+		return nil
+	}
+	kind, value, found := strings.Cut(pattern, ":")
+	if !found || value == "" || (kind != "device" && kind != "label") {
+		return ErrInvalidResource
+	}
+	if _, err := path.Match(value, ""); err != nil {
+		return ErrInvalidResource
+	}
+	return nil
+}
+
+type IPolicy interface {
+	DefineRole(role Role) (Role, error)
+	// Roles returns the built-in and the custom roles of a tenant.
+	Roles(tenant string) []Role
+	Bind(binding Binding) (Binding, error)
+	Bindings(tenant string) []Binding
+	Unbind(tenant string, id string) error
+	// Authorize returns ErrForbidden unless a binding of the subject allows
+	// the action on the resource.
+	Authorize(tenant string, subject string, action Action, resource Resource) error
+	// Resources returns the resource patterns on which bindings of the
+	// subject allow the action, so that lists can be filtered by them.
+	Resources(tenant string, subject string, action Action) []string
+}
+
+type roleKey struct {
+	tenant string
+	name   string
+}
+
+type Policy struct {
+	mu       sync.RWMutex
+	roles    map[roleKey]Role
+	bindings map[string]Binding
+}
+
+// NewPolicy creates a policy with the built-in roles and no bindings.
+func NewPolicy() IPolicy {
+	return &Policy{
+		roles:    make(map[roleKey]Role),
+		bindings: make(map[string]Binding),
+	}
+}
+
+// PolicyFile is the JSON representation of the roles and bindings of a
+// policy. Binding ids are generated if they are omitted.
+type PolicyFile struct {
+	Roles    []Role    `json:"roles"`
+	Bindings []Binding `json:"bindings"`
+}
+
+// ReadPolicy creates a Policy from a JSON PolicyFile.
+func ReadPolicy(reader io.Reader) (IPolicy, error) {
+	var file PolicyFile
+	if err := json.NewDecoder(reader).Decode(&file); err != nil {
+		return nil, err
+	}
+	policy := NewPolicy()
+	for _, role := range file.Roles {
+		if _, err := policy.DefineRole(role); err != nil {
+			return nil, err
+		}
+	}
+	for _, binding := range file.Bindings {
+		if _, err := policy.Bind(binding); err != nil {
+			return nil, err
+		}
+	}
+	return policy, nil
+}
+
+func (p *Policy) DefineRole(role Role) (Role, error) {
+	if role.Name == "" || role.Tenant == "" || len(role.Actions) == 0 {
+		return Role{}, ErrInvalidRole
+	}
+	for _, action := range role.Actions {
+		if !action.IsValid() {
+			return Role{}, ErrInvalidRole
+		}
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, exists := p.role(role.Tenant, role.Name); exists {
+		return Role{}, ErrExists
+	}
+	p.roles[roleKey{role.Tenant, role.Name}] = role
+	return role, nil
+}
+
+// role must be called with p.mu held.
+func (p *Policy) role(tenant string, name string) (Role, bool) {
+	for _, builtin := range BuiltinRoles {
+		if builtin.Name == name {
+			return builtin, true
+		}
+	}
+	role, exists := p.roles[roleKey{tenant, name}]
+	return role, exists
+}
+
+func (p *Policy) Roles(tenant string) []Role {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	roles := append([]Role(nil), BuiltinRoles...)
+	custom := make([]Role, 0)
+	for key, role := range p.roles {
+		if key.tenant == tenant {
+			custom = append(custom, role)
+		}
+	}
+	sort.Slice(custom, func(i, j int) bool {
+		return custom[i].Name < custom[j].Name
+	})
+	return append(roles, custom...)
+}
+
+func (p *Policy) Bind(binding Binding) (Binding, error) {
+	if binding.Tenant == "" || binding.Subject == "" || len(binding.Resources) == 0 {
+		return Binding{}, ErrInvalidBinding
+	}
+	for _, resource := range binding.Resources {
+		if err := validateResource(resource); err != nil {
+			return Binding{}, err
+		}
+	}
+	if binding.Id == "" {
+		binding.Id = uuid.NewString()
+	}
+	if binding.CreatedAt.IsZero() {
+		binding.CreatedAt = time.Now().UTC()
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, exists := p.role(binding.Tenant, binding.Role); !exists {
+		return Binding{}, ErrUnknownRole
+	}
+	if _, exists := p.bindings[binding.Id]; exists {
+		return Binding{}, ErrExists
+	}
+	p.bindings[binding.Id] = binding
+	return binding, nil
+}
+
+func (p *Policy) Bindings(tenant string) []Binding {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	bindings := make([]Binding, 0)
+	for _, binding := range p.bindings {
+		if binding.Tenant == tenant {
+			bindings = append(bindings, binding)
+		}
+	}
+	sort.Slice(bindings, func(i, j int) bool {
+		return bindings[i].CreatedAt.Before(bindings[j].CreatedAt)
+	})
+	return bindings
+}
+
+func (p *Policy) Unbind(tenant string, id string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	binding, exists := p.bindings[id]
+	if !exists || binding.Tenant != tenant {
+		return ErrNotFound
+	}
+	delete(p.bindings, id)
+	return nil
+}
+
+func (p *Policy) Authorize(tenant string, subject string, action Action, resource Resource) error {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	for _, binding := range p.bindings {
+		if binding.Tenant != tenant || binding.Subject != subject {
+			continue
+		}
+		role, exists := p.role(tenant, binding.Role)
+		if !exists || !role.allows(action) {
+			continue
+		}
+		for _, pattern := range binding.Resources {
+			if resource.matches(pattern) {
+				return nil
+			}
+		}
+	}
+	return ErrForbidden
+}
+
+func (p *Policy) Resources(tenant string, subject string, action Action) []string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	resources := make([]string, 0)
+	for _, binding := range p.bindings {
+		if binding.Tenant != tenant || binding.Subject != subject {
+			continue
+		}
+		role, exists := p.role(tenant, binding.Role)
+		if !exists || !role.allows(action) {
+			continue
+		}
+		resources = append(resources, binding.Resources...)
+	}
+	sort.Strings(resources)
+	return resources
+}
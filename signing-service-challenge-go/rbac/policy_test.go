@@ -0,0 +1,112 @@
+package rbac
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func assertEqual(t *testing.T, expected interface{}, actual interface{}) {
+	t.Helper()
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("Expected %v, actual %v", expected, actual)
+	}
+}
+
+func assertErrorIs(t *testing.T, expected error, actual error) {
+	t.Helper()
+	if !errors.Is(actual, expected) {
+		t.Errorf("Expected %v, actual %v", expected, actual)
+	}
+}
+
+func TestAuthorize_Cashier(t *testing.T) {
+	policy := NewPolicy()
+	_, err := policy.Bind(Binding{Tenant: "tenant1", Subject: "key1", Role: "cashier", Resources: []string{"device:550e8400-*", "label:till-*"}})
+	assertEqual(t, nil, err)
+
+	assertEqual(t, nil, policy.Authorize("tenant1", "key1", ActionSign, Resource{DeviceId: "550e8400-e29b-11d4-a716-446655440000"}))
+	assertEqual(t, nil, policy.Authorize("tenant1", "key1", ActionSign, Resource{DeviceId: "other", Label: "till-1"}))
+	assertErrorIs(t, ErrForbidden, policy.Authorize("tenant1", "key1", ActionSign, Resource{DeviceId: "other", Label: "office"}))
+	assertErrorIs(t, ErrForbidden, policy.Authorize("tenant1", "key1", ActionDecommission, Resource{DeviceId: "550e8400-e29b-11d4-a716-446655440000"}))
+	assertErrorIs(t, ErrForbidden, policy.Authorize("tenant1", "key1", ActionRead, AllDevices))
+	assertErrorIs(t, ErrForbidden, policy.Authorize("tenant2", "key1", ActionSign, Resource{DeviceId: "550e8400-e29b-11d4-a716-446655440000"}))
+	assertErrorIs(t, ErrForbidden, policy.Authorize("tenant1", "key2", ActionSign, Resource{DeviceId: "550e8400-e29b-11d4-a716-446655440000"}))
+}
+
+func TestAuthorize_Wildcard(t *testing.T) {
+	policy := NewPolicy()
+	_, _ = policy.Bind(Binding{Tenant: "tenant1", Subject: "key1", Role: "auditor", Resources: []string{"*"}})
+
+	assertEqual(t, nil, policy.Authorize("tenant1", "key1", ActionReadJournal, AllDevices))
+	assertEqual(t, nil, policy.Authorize("tenant1", "key1", ActionRead, Resource{DeviceId: "device1"}))
+	assertErrorIs(t, ErrForbidden, policy.Authorize("tenant1", "key1", ActionSign, Resource{DeviceId: "device1"}))
+}
+
+func TestResources(t *testing.T) {
+	policy := NewPolicy()
+	_, _ = policy.Bind(Binding{Tenant: "tenant1", Subject: "key1", Role: "cashier", Resources: []string{"label:till-*"}})
+	_, _ = policy.Bind(Binding{Tenant: "tenant1", Subject: "key1", Role: "auditor", Resources: []string{"device:device1"}})
+
+	assertEqual(t, []string{"device:device1", "label:till-*"}, policy.Resources("tenant1", "key1", ActionRead))
+	assertEqual(t, []string{"label:till-*"}, policy.Resources("tenant1", "key1", ActionSign))
+	assertEqual(t, []string{}, policy.Resources("tenant1", "key1", ActionDecommission))
+	assertEqual(t, []string{}, policy.Resources("tenant2", "key1", ActionRead))
+}
+
+func TestDefineRole(t *testing.T) {
+	policy := NewPolicy()
+
+	_, err := policy.DefineRole(Role{Name: "supervisor", Tenant: "tenant1", Actions: []Action{ActionSign, ActionDecommission}})
+	assertEqual(t, nil, err)
+	_, err = policy.DefineRole(Role{Name: "cashier", Tenant: "tenant1", Actions: []Action{ActionSign}})
+	assertErrorIs(t, ErrExists, err)
+	_, err = policy.DefineRole(Role{Name: "root", Tenant: "tenant1", Actions: []Action{"everything"}})
+	assertErrorIs(t, ErrInvalidRole, err)
+
+	_, err = policy.Bind(Binding{Tenant: "tenant2", Subject: "key1", Role: "supervisor", Resources: []string{"*"}})
+	assertErrorIs(t, ErrUnknownRole, err)
+	_, err = policy.Bind(Binding{Tenant: "tenant1", Subject: "key1", Role: "supervisor", Resources: []string{"*"}})
+	assertEqual(t, nil, err)
+	assertEqual(t, nil, policy.Authorize("tenant1", "key1", ActionDecommission, Resource{DeviceId: "device1"}))
+	assertEqual(t, len(BuiltinRoles)+1, len(policy.Roles("tenant1")))
+	assertEqual(t, len(BuiltinRoles), len(policy.Roles("tenant2")))
+}
+
+func TestBind_InvalidResource(t *testing.T) {
+	policy := NewPolicy()
+
+	for _, resource := range []string{"device1", "device:", "store:1", "label:[", ""} {
+		_, err := policy.Bind(Binding{Tenant: "tenant1", Subject: "key1", Role: "cashier", Resources: []string{resource}})
+		assertErrorIs(t, ErrInvalidResource, err)
+	}
+}
+
+func TestUnbind(t *testing.T) {
+	policy := NewPolicy()
+	binding, _ := policy.Bind(Binding{Tenant: "tenant1", Subject: "key1", Role: "cashier", Resources: []string{"*"}})
+
+	assertErrorIs(t, ErrNotFound, policy.Unbind("tenant2", binding.Id))
+	assertEqual(t, nil, policy.Unbind("tenant1", binding.Id))
+	assertErrorIs(t, ErrForbidden, policy.Authorize("tenant1", "key1", ActionSign, Resource{DeviceId: "device1"}))
+	assertEqual(t, 0, len(policy.Bindings("tenant1")))
+}
+
+func TestReadPolicy(t *testing.T) {
+	policy, err := ReadPolicy(strings.NewReader(`{
+		"roles": [{"name": "supervisor", "tenant": "tenant1", "actions": ["devices:sign", "devices:decommission"]}],
+		"bindings": [
+			{"tenant": "tenant1", "subject": "CN=till-1", "role": "cashier", "resources": ["label:till-1"]},
+			{"tenant": "tenant1", "subject": "key1", "role": "supervisor", "resources": ["*"]}
+		]
+	}`))
+	assertEqual(t, nil, err)
+
+	assertEqual(t, nil, policy.Authorize("tenant1", "CN=till-1", ActionSign, Resource{DeviceId: "device1", Label: "till-1"}))
+	assertEqual(t, nil, policy.Authorize("tenant1", "key1", ActionDecommission, Resource{DeviceId: "device1"}))
+	assertEqual(t, 2, len(policy.Bindings("tenant1")))
+
+	_, err = ReadPolicy(strings.NewReader(`{"bindings": [{"tenant": "tenant1", "subject": "key1", "role": "unknown", "resources": ["*"]}]}`))
+	assertErrorIs(t, ErrUnknownRole, err)
+}
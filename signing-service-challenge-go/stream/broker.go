@@ -0,0 +1,200 @@
+package stream
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain"
+)
+
+var ErrInvalidEventId = errors.New("invalid last event id")
+
+const (
+	// HistorySize is the number of signatures kept per tenant for resuming
+	// the streams of its devices and of all its devices.
+	HistorySize = 4096
+	// SubscriberBuffer is the number of entries a subscriber may fall behind
+	// before it is disconnected.
+	SubscriberBuffer = 256
+)
+
+// Entry is a committed signature as it is sent to the streams.
+type Entry struct {
+	Tenant    string
+	DeviceId  string
+	Signature domain.Signature
+}
+
+// DeviceEventId is the SSE id of the entry in the stream of its device,
+// which is its signature counter.
+func (e Entry) DeviceEventId() string {
+	return strconv.Itoa(e.Signature.Counter)
+}
+
+// GlobalEventId is the SSE id of the entry in the global stream.
+func (e Entry) GlobalEventId() string {
+	return e.DeviceId + ":" + strconv.Itoa(e.Signature.Counter)
+}
+
+// Subscription receives the entries committed after it was created. C is
+// closed when the subscriber falls too far behind or the subscription is closed.
+type Subscription struct {
+	C <-chan Entry
+
+	tenant   string
+	deviceId string
+	c        chan Entry
+	broker   *Broker
+}
+
+// Close unregisters the subscription.
+func (s *Subscription) Close() {
+	s.broker.mu.Lock()
+	defer s.broker.mu.Unlock()
+	s.broker.remove(s)
+}
+
+// Replay holds the retained entries after the last event id of a
+// subscriber. Reset is set if the entry of the last event id is no longer
+// retained, so that entries after it may be missing from Entries.
+type Replay struct {
+	Entries []Entry
+	Reset   bool
+}
+
+type IBroker interface {
+	domain.IEventPublisher
+	// Subscribe registers a subscriber for a device of a tenant, or for all
+	// devices of the tenant if deviceId is empty. It returns the retained
+	// entries after lastEventId, which are not sent on the subscription.
+	Subscribe(tenant, deviceId string, lastEventId string) (Replay, *Subscription, error)
+}
+
+// Broker fans committed signatures out to stream subscribers and retains a
+// bounded history per tenant so that clients can resume with Last-Event-ID.
+type Broker struct {
+	mu          sync.Mutex
+	histories   map[string][]Entry
+	subscribers map[*Subscription]struct{}
+}
+
+func NewBroker() IBroker {
+	return &Broker{
+		histories:   make(map[string][]Entry),
+		subscribers: make(map[*Subscription]struct{}),
+	}
+}
+
+func (b *Broker) Publish(event domain.Event) {
+	if event.Type != domain.EventSignatureCreated || event.Signature == nil {
+		return
+	}
+	entry := Entry{
+		Tenant:    event.Device.Tenant,
+		DeviceId:  event.Device.Id,
+		Signature: *event.Signature,
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.histories[entry.Tenant] = appendBounded(b.histories[entry.Tenant], entry, HistorySize)
+
+	for subscriber := range b.subscribers {
+		if subscriber.tenant != entry.Tenant {
+			continue
+		}
+		if subscriber.deviceId != "" && subscriber.deviceId != entry.DeviceId {
+			continue
+		}
+		select {
+		case subscriber.c <- entry:
+		default:
+			// A slow client is disconnected and resumes from its last event id.
+			b.remove(subscriber)
+		}
+	}
+}
+
+func appendBounded(entries []Entry, entry Entry, size int) []Entry {
+	entries = append(entries, entry)
+	if len(entries) > size {
+		entries = append(entries[:0:0], entries[len(entries)-size:]...)
+	}
+	return entries
+}
+
+func (b *Broker) Subscribe(tenant, deviceId string, lastEventId string) (Replay, *Subscription, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	replay, err := replayAfter(b.histories[tenant], deviceId, lastEventId)
+	if err != nil {
+		return Replay{}, nil, err
+	}
+
+	c := make(chan Entry, SubscriberBuffer)
+	subscription := &Subscription{
+		C:        c,
+		tenant:   tenant,
+		deviceId: deviceId,
+		c:        c,
+		broker:   b,
+	}
+	b.subscribers[subscription] = struct{}{}
+	return replay, subscription, nil
+}
+
+// remove has to be called with b.mu held.
+func (b *Broker) remove(subscription *Subscription) {
+	if _, exists := b.subscribers[subscription]; exists {
+		delete(b.subscribers, subscription)
+		close(subscription.c)
+	}
+}
+
+// replayAfter returns the entries of the device, or of all devices if
+// deviceId is empty, after the one with lastEventId. If that entry is no
+// longer retained, every retained entry is replayed with Reset set.
+func replayAfter(history []Entry, deviceId, lastEventId string) (Replay, error) {
+	if lastEventId == "" {
+		return Replay{}, nil
+	}
+	lastDeviceId, counter, err := parseEventId(deviceId, lastEventId)
+	if err != nil {
+		return Replay{}, err
+	}
+
+	start, reset := 0, true
+	for i := len(history) - 1; i >= 0; i-- {
+		if history[i].DeviceId == lastDeviceId && history[i].Signature.Counter == counter {
+			start, reset = i+1, false
+			break
+		}
+	}
+	replay := Replay{Reset: reset}
+	for _, entry := range history[start:] {
+		if deviceId == "" || entry.DeviceId == deviceId {
+			replay.Entries = append(replay.Entries, entry)
+		}
+	}
+	return replay, nil
+}
+
+// parseEventId parses the DeviceEventId of an entry of the device, or the
+// GlobalEventId if deviceId is empty.
+func parseEventId(deviceId, eventId string) (string, int, error) {
+	if deviceId == "" {
+		separator := strings.LastIndex(eventId, ":")
+		if separator < 0 {
+			return "", 0, ErrInvalidEventId
+		}
+		deviceId, eventId = eventId[:separator], eventId[separator+1:]
+	}
+	counter, err := strconv.Atoi(eventId)
+	if err != nil {
+		return "", 0, ErrInvalidEventId
+	}
+	return deviceId, counter, nil
+}
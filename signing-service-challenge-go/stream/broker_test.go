@@ -0,0 +1,165 @@
+package stream
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain"
+)
+
+func assertEqual(t *testing.T, expected interface{}, actual interface{}) {
+	t.Helper()
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("Expected %v, actual %v", expected, actual)
+	}
+}
+
+func signatureCreated(deviceId string, counter int) domain.Event {
+	return domain.Event{
+		Type:      domain.EventSignatureCreated,
+		Device:    domain.SignatureDevice{Tenant: "tenant1", Id: deviceId},
+		Signature: &domain.Signature{Counter: counter},
+	}
+}
+
+func counters(entries []Entry) []string {
+	result := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		result = append(result, entry.GlobalEventId())
+	}
+	return result
+}
+
+func TestSubscribe_DeviceReceivesOwnSignatures(t *testing.T) {
+	broker := NewBroker()
+	_, subscription, _ := broker.Subscribe("tenant1", "a", "")
+	defer subscription.Close()
+
+	broker.Publish(signatureCreated("b", 0))
+	broker.Publish(signatureCreated("a", 0))
+	broker.Publish(domain.Event{Type: domain.EventDeviceCreated, Device: domain.SignatureDevice{Id: "a"}})
+
+	assertEqual(t, "a:0", (<-subscription.C).GlobalEventId())
+	assertEqual(t, 0, len(subscription.C))
+}
+
+func TestSubscribe_GlobalReceivesAllSignatures(t *testing.T) {
+	broker := NewBroker()
+	_, subscription, _ := broker.Subscribe("tenant1", "", "")
+	defer subscription.Close()
+
+	broker.Publish(signatureCreated("b", 0))
+	broker.Publish(signatureCreated("a", 0))
+
+	assertEqual(t, "b:0", (<-subscription.C).GlobalEventId())
+	assertEqual(t, "a:0", (<-subscription.C).GlobalEventId())
+}
+
+func TestSubscribe_FiltersTenants(t *testing.T) {
+	broker := NewBroker()
+	broker.Publish(signatureCreated("a", 0))
+	replay, subscription, _ := broker.Subscribe("tenant2", "", "a:0")
+	defer subscription.Close()
+
+	broker.Publish(signatureCreated("a", 1))
+
+	assertEqual(t, 0, len(replay.Entries))
+	assertEqual(t, 0, len(subscription.C))
+}
+
+func TestSubscribe_ResumesDeviceFromCounter(t *testing.T) {
+	broker := NewBroker()
+	for counter := 0; counter < 4; counter++ {
+		broker.Publish(signatureCreated("a", counter))
+		broker.Publish(signatureCreated("b", counter))
+	}
+
+	replay, subscription, err := broker.Subscribe("tenant1", "a", "1")
+	defer subscription.Close()
+
+	assertEqual(t, nil, err)
+	assertEqual(t, false, replay.Reset)
+	assertEqual(t, []string{"a:2", "a:3"}, counters(replay.Entries))
+}
+
+func TestSubscribe_ResumesGlobalFromEventId(t *testing.T) {
+	broker := NewBroker()
+	broker.Publish(signatureCreated("a", 0))
+	broker.Publish(signatureCreated("b", 0))
+	broker.Publish(signatureCreated("a", 1))
+
+	replay, subscription, err := broker.Subscribe("tenant1", "", "a:0")
+	defer subscription.Close()
+
+	assertEqual(t, nil, err)
+	assertEqual(t, false, replay.Reset)
+	assertEqual(t, []string{"b:0", "a:1"}, counters(replay.Entries))
+}
+
+func TestSubscribe_ResetsUnretainedEventId(t *testing.T) {
+	broker := NewBroker()
+	broker.Publish(signatureCreated("a", 5))
+	broker.Publish(signatureCreated("b", 0))
+
+	replay, subscription, err := broker.Subscribe("tenant1", "", "a:4")
+	defer subscription.Close()
+
+	assertEqual(t, nil, err)
+	assertEqual(t, true, replay.Reset)
+	assertEqual(t, []string{"a:5", "b:0"}, counters(replay.Entries))
+}
+
+func TestSubscribe_ErrInvalidEventId(t *testing.T) {
+	broker := NewBroker()
+
+	_, _, err := broker.Subscribe("tenant1", "a", "a:1")
+	assertEqual(t, ErrInvalidEventId, err)
+
+	_, _, err = broker.Subscribe("tenant1", "", "1")
+	assertEqual(t, ErrInvalidEventId, err)
+}
+
+func TestPublish_BoundsHistory(t *testing.T) {
+	broker := NewBroker()
+	for counter := 0; counter < HistorySize+10; counter++ {
+		broker.Publish(signatureCreated("a", counter))
+	}
+
+	replay, subscription, _ := broker.Subscribe("tenant1", "a", "0")
+	defer subscription.Close()
+
+	assertEqual(t, true, replay.Reset)
+	assertEqual(t, HistorySize, len(replay.Entries))
+	assertEqual(t, 10, replay.Entries[0].Signature.Counter)
+}
+
+func TestPublish_BoundsHistoryAcrossDevices(t *testing.T) {
+	broker := NewBroker()
+	for device := 0; device < HistorySize+10; device++ {
+		broker.Publish(signatureCreated(strconv.Itoa(device), 0))
+	}
+
+	replay, subscription, _ := broker.Subscribe("tenant1", "", "0:0")
+	defer subscription.Close()
+
+	assertEqual(t, true, replay.Reset)
+	assertEqual(t, HistorySize, len(replay.Entries))
+	assertEqual(t, "10:0", replay.Entries[0].GlobalEventId())
+}
+
+func TestPublish_DisconnectsSlowSubscriber(t *testing.T) {
+	broker := NewBroker()
+	_, subscription, _ := broker.Subscribe("tenant1", "a", "")
+
+	for counter := 0; counter <= SubscriberBuffer; counter++ {
+		broker.Publish(signatureCreated("a", counter))
+	}
+
+	received := 0
+	for range subscription.C {
+		received++
+	}
+	assertEqual(t, SubscriberBuffer, received)
+	subscription.Close()
+}
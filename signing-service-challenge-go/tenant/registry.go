@@ -0,0 +1,124 @@
+package tenant
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrNotFound     = errors.New("not found")
+	ErrInvalidLimit = errors.New("invalid device limit")
+)
+
+// DefaultId identifies the tenant that exists from the start. It owns the
+// bootstrap admin key.
+const DefaultId = "default"
+
+// Tenant is an organisation owning an isolated set of signature devices.
+type Tenant struct {
+	Id   string
+	Name string
+	// MaxDevices limits the number of active devices, 0 means unlimited.
+	MaxDevices int
+	CreatedAt  time.Time
+}
+
+type IRegistry interface {
+	Create(name string, maxDevices int) (Tenant, error)
+	Find(id string) (Tenant, error)
+	List() []Tenant
+	SetMaxDevices(id string, maxDevices int) (Tenant, error)
+	// MaxDevices returns the device limit of a tenant, which makes the
+	// registry a domain.ITenantLimits.
+	MaxDevices(id string) int
+}
+
+type Registry struct {
+	mu      sync.RWMutex
+	tenants map[string]Tenant
+}
+
+// NewRegistry creates a registry containing the default tenant.
+func NewRegistry(defaultMaxDevices int) (IRegistry, error) {
+	if defaultMaxDevices < 0 {
+		return nil, ErrInvalidLimit
+	}
+	return &Registry{
+		tenants: map[string]Tenant{
+			DefaultId: {
+				Id:         DefaultId,
+				Name:       DefaultId,
+				MaxDevices: defaultMaxDevices,
+				CreatedAt:  time.Now().UTC(),
+			},
+		},
+	}, nil
+}
+
+func (r *Registry) Create(name string, maxDevices int) (Tenant, error) {
+	if maxDevices < 0 {
+		return Tenant{}, ErrInvalidLimit
+	}
+	tenant := Tenant{
+		Id:         uuid.NewString(),
+		Name:       name,
+		MaxDevices: maxDevices,
+		CreatedAt:  time.Now().UTC(),
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.tenants[tenant.Id] = tenant
+	return tenant, nil
+}
+
+func (r *Registry) Find(id string) (Tenant, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	tenant, exists := r.tenants[id]
+	if !exists {
+		return Tenant{}, ErrNotFound
+	}
+	return tenant, nil
+}
+
+func (r *Registry) List() []Tenant {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	tenants := make([]Tenant, 0, len(r.tenants))
+	for _, tenant := range r.tenants {
+		tenants = append(tenants, tenant)
+	}
+	sort.Slice(tenants, func(i, j int) bool {
+		return tenants[i].CreatedAt.Before(tenants[j].CreatedAt)
+	})
+	return tenants
+}
+
+// SetMaxDevices changes the device limit of a tenant. Lowering it below the
+// number of devices the tenant owns only prevents the creation of new ones.
+func (r *Registry) SetMaxDevices(id string, maxDevices int) (Tenant, error) {
+	if maxDevices < 0 {
+		return Tenant{}, ErrInvalidLimit
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	tenant, exists := r.tenants[id]
+	if !exists {
+		return Tenant{}, ErrNotFound
+	}
+	tenant.MaxDevices = maxDevices
+	r.tenants[id] = tenant
+	return tenant, nil
+}
+
+func (r *Registry) MaxDevices(id string) int {
+	tenant, err := r.Find(id)
+	if err != nil {
+		return 0
+	}
+	return tenant.MaxDevices
+}
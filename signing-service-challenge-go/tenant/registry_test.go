@@ -0,0 +1,56 @@
+package tenant
+
+import (
+	"reflect"
+	"testing"
+)
+
+func assertEqual(t *testing.T, expected interface{}, actual interface{}) {
+	t.Helper()
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("Expected %v, actual %v", expected, actual)
+	}
+}
+
+func TestNewRegistry_CreatesDefault(t *testing.T) {
+	registry, err := NewRegistry(10)
+
+	assertEqual(t, nil, err)
+	tenant, err := registry.Find(DefaultId)
+	assertEqual(t, nil, err)
+	assertEqual(t, 10, tenant.MaxDevices)
+	assertEqual(t, 10, registry.MaxDevices(DefaultId))
+}
+
+func TestCreate_Ok(t *testing.T) {
+	registry, _ := NewRegistry(0)
+
+	tenant, err := registry.Create("merchant", 5)
+
+	assertEqual(t, nil, err)
+	found, _ := registry.Find(tenant.Id)
+	assertEqual(t, tenant, found)
+	assertEqual(t, 2, len(registry.List()))
+}
+
+func TestCreate_ErrInvalidLimit(t *testing.T) {
+	registry, _ := NewRegistry(0)
+
+	_, err := registry.Create("merchant", -1)
+
+	assertEqual(t, ErrInvalidLimit, err)
+}
+
+func TestSetMaxDevices(t *testing.T) {
+	registry, _ := NewRegistry(0)
+	tenant, _ := registry.Create("merchant", 5)
+
+	updated, err := registry.SetMaxDevices(tenant.Id, 7)
+	assertEqual(t, nil, err)
+	assertEqual(t, 7, updated.MaxDevices)
+	assertEqual(t, 7, registry.MaxDevices(tenant.Id))
+
+	_, err = registry.SetMaxDevices("unknown", 7)
+	assertEqual(t, ErrNotFound, err)
+	assertEqual(t, 0, registry.MaxDevices("unknown"))
+}
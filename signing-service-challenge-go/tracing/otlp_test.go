@@ -0,0 +1,96 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+// collectorStub is a local OTLP/HTTP collector that keeps the spans it
+// receives.
+type collectorStub struct {
+	mu    sync.Mutex
+	paths []string
+	spans []otlpSpan
+}
+
+func (c *collectorStub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var request otlpRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.paths = append(c.paths, r.URL.Path)
+	for _, resourceSpans := range request.ResourceSpans {
+		for _, scopeSpans := range resourceSpans.ScopeSpans {
+			c.spans = append(c.spans, scopeSpans.Spans...)
+		}
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func TestOTLPExporter_ExportsToCollector(t *testing.T) {
+	collector := &collectorStub{}
+	server := httptest.NewServer(collector)
+	defer server.Close()
+	tracer := NewTracer(NewOTLPExporter(server.URL, "signing-service"))
+
+	remote, _ := ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	ctx, root := tracer.Start(ContextWithRemoteParent(context.Background(), remote), "POST /sign")
+	root.SetKind(KindServer)
+	_, child := Start(ctx, "crypto.Sign")
+	child.SetAttribute("device.algorithm", "ECC")
+	child.SetError(errors.New("signing failed"))
+	child.End()
+	root.End()
+	_ = tracer.Close()
+
+	assertEqual(t, []string{OTLPTracesPath}, collector.paths)
+	assertEqual(t, 2, len(collector.spans))
+	signSpan, rootSpan := collector.spans[0], collector.spans[1]
+	assertEqual(t, "POST /sign", rootSpan.Name)
+	assertEqual(t, KindServer, rootSpan.Kind)
+	assertEqual(t, "4bf92f3577b34da6a3ce929d0e0e4736", rootSpan.TraceId)
+	assertEqual(t, "00f067aa0ba902b7", rootSpan.ParentSpanId)
+	assertEqual(t, 0, rootSpan.Status.Code)
+	assertEqual(t, "crypto.Sign", signSpan.Name)
+	assertEqual(t, rootSpan.TraceId, signSpan.TraceId)
+	assertEqual(t, rootSpan.SpanId, signSpan.ParentSpanId)
+	assertEqual(t, "device.algorithm", signSpan.Attributes[0].Key)
+	assertEqual(t, "ECC", signSpan.Attributes[0].Value.StringValue)
+	assertEqual(t, otlpStatus{Code: otlpStatusError, Message: "signing failed"}, signSpan.Status)
+}
+
+func TestTracer_DoesNotExportUnsampledTraces(t *testing.T) {
+	collector := &collectorStub{}
+	server := httptest.NewServer(collector)
+	defer server.Close()
+	tracer := NewTracer(NewOTLPExporter(server.URL, "signing-service"))
+
+	remote, _ := ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00")
+	ctx, root := tracer.Start(ContextWithRemoteParent(context.Background(), remote), "POST /sign")
+	_, child := Start(ctx, "crypto.Sign")
+	child.End()
+	root.End()
+	_ = tracer.Close()
+
+	assertEqual(t, 0, len(collector.spans))
+	assertEqual(t, false, root.SpanContext().Sampled)
+}
+
+func TestOTLPExporter_CollectorError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	err := NewOTLPExporter(server.URL, "signing-service").Export([]SpanData{{Name: "span"}})
+
+	assertEqual(t, "collector returned 503 Service Unavailable", err.Error())
+}
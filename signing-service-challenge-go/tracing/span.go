@@ -0,0 +1,212 @@
+package tracing
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+)
+
+var ErrInvalidTraceparent = errors.New("invalid traceparent")
+
+// TraceparentHeader propagates the SpanContext of the caller (W3C Trace
+// Context).
+const TraceparentHeader = "traceparent"
+
+type TraceId [16]byte
+
+func (id TraceId) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+type SpanId [8]byte
+
+func (id SpanId) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+// SpanContext identifies a span across process boundaries.
+type SpanContext struct {
+	TraceId TraceId
+	SpanId  SpanId
+	// Sampled spans are exported.
+	Sampled bool
+}
+
+// IsValid reports whether the trace and span id are set.
+func (c SpanContext) IsValid() bool {
+	return c.TraceId != TraceId{} && c.SpanId != SpanId{}
+}
+
+// Traceparent formats the SpanContext as a traceparent header value.
+func (c SpanContext) Traceparent() string {
+	flags := "00"
+	if c.Sampled {
+		flags = "01"
+	}
+	return "00-" + c.TraceId.String() + "-" + c.SpanId.String() + "-" + flags
+}
+
+// ParseTraceparent parses a traceparent header value of version 00. Later
+// versions are parsed as far as version 00 defines them.
+func ParseTraceparent(value string) (SpanContext, error) {
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || (parts[0] == "00" && len(parts) != 4) {
+		return SpanContext{}, ErrInvalidTraceparent
+	}
+	var c SpanContext
+	var flags [1]byte
+	if !decodeHex(c.TraceId[:], parts[1]) || !decodeHex(c.SpanId[:], parts[2]) || !decodeHex(flags[:], parts[3]) || !c.IsValid() {
+		return SpanContext{}, ErrInvalidTraceparent
+	}
+	c.Sampled = flags[0]&1 == 1
+	return c, nil
+}
+
+// decodeHex decodes lowercase hex of exactly the length of destination.
+func decodeHex(destination []byte, value string) bool {
+	if len(value) != 2*len(destination) || strings.ToLower(value) != value {
+		return false
+	}
+	_, err := hex.Decode(destination, []byte(value))
+	return err == nil
+}
+
+func newTraceId() TraceId {
+	var id TraceId
+	_, _ = rand.Read(id[:])
+	return id
+}
+
+func newSpanId() SpanId {
+	var id SpanId
+	_, _ = rand.Read(id[:])
+	return id
+}
+
+// SpanKind tells the role of a span in a request.
+type SpanKind int
+
+const (
+	KindInternal SpanKind = 1
+	KindServer   SpanKind = 2
+	KindClient   SpanKind = 3
+)
+
+type Attribute struct {
+	Key   string
+	Value string
+}
+
+// Span times a stage of a request. All methods can be called on a nil
+// Span, which is returned if no tracer is configured.
+type Span struct {
+	tracer *Tracer
+
+	mu         sync.Mutex
+	name       string
+	kind       SpanKind
+	context    SpanContext
+	parent     SpanId
+	start      time.Time
+	end        time.Time
+	attributes []Attribute
+	err        string
+	ended      bool
+}
+
+// SpanContext returns the identity of the span, the zero SpanContext for
+// a nil Span.
+func (s *Span) SpanContext() SpanContext {
+	if s == nil {
+		return SpanContext{}
+	}
+	return s.context
+}
+
+func (s *Span) SetName(name string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.name = name
+}
+
+func (s *Span) SetKind(kind SpanKind) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.kind = kind
+}
+
+func (s *Span) SetAttribute(key, value string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.attributes = append(s.attributes, Attribute{Key: key, Value: value})
+}
+
+// SetError marks the span as failed. A nil error is ignored.
+func (s *Span) SetError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.err = err.Error()
+}
+
+// End ends the span and hands it to the exporter if it is sampled. Only the
+// first call has an effect.
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.end = time.Now()
+	s.mu.Unlock()
+	if s.context.Sampled {
+		s.tracer.enqueue(s.snapshot())
+	}
+}
+
+// SpanData is an ended span as it is exported.
+type SpanData struct {
+	Name       string
+	Kind       SpanKind
+	Context    SpanContext
+	Parent     SpanId
+	Start      time.Time
+	End        time.Time
+	Attributes []Attribute
+	// Error is the message of the error that failed the span, empty if it
+	// succeeded.
+	Error string
+}
+
+func (s *Span) snapshot() SpanData {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return SpanData{
+		Name:       s.name,
+		Kind:       s.kind,
+		Context:    s.context,
+		Parent:     s.parent,
+		Start:      s.start,
+		End:        s.end,
+		Attributes: append([]Attribute(nil), s.attributes...),
+		Error:      s.err,
+	}
+}
@@ -0,0 +1,58 @@
+package tracing
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func assertEqual(t *testing.T, expected interface{}, actual interface{}) {
+	t.Helper()
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("Expected %v, actual %v", expected, actual)
+	}
+}
+
+func TestParseTraceparent_Ok(t *testing.T) {
+	value := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+	parent, err := ParseTraceparent(value)
+
+	assertEqual(t, nil, err)
+	assertEqual(t, "4bf92f3577b34da6a3ce929d0e0e4736", parent.TraceId.String())
+	assertEqual(t, "00f067aa0ba902b7", parent.SpanId.String())
+	assertEqual(t, true, parent.Sampled)
+	assertEqual(t, value, parent.Traceparent())
+}
+
+func TestParseTraceparent_FutureVersion(t *testing.T) {
+	parent, err := ParseTraceparent("01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00-extra")
+
+	assertEqual(t, nil, err)
+	assertEqual(t, false, parent.Sampled)
+}
+
+func TestParseTraceparent_ErrInvalidTraceparent(t *testing.T) {
+	for _, value := range []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e473-00f067aa0ba902b7-01",
+	} {
+		_, err := ParseTraceparent(value)
+		assertEqual(t, ErrInvalidTraceparent, err)
+	}
+}
+
+func TestStart_WithoutTracer(t *testing.T) {
+	ctx, span := Start(context.Background(), "untraced")
+
+	assertEqual(t, (*Span)(nil), span)
+	assertEqual(t, (*Span)(nil), FromContext(ctx))
+	span.SetAttribute("key", "value")
+	span.End()
+}
@@ -0,0 +1,177 @@
+package tracing
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// QueueSize is the number of ended spans buffered for export. Spans
+	// are dropped while the queue is full.
+	QueueSize = 2048
+	// BatchSize is the maximum number of spans exported at once.
+	BatchSize = 512
+	// BatchTimeout is how long ended spans wait for a batch to fill.
+	BatchTimeout = time.Second
+)
+
+// IExporter sends ended spans to a tracing backend.
+type IExporter interface {
+	Export(spans []SpanData) error
+}
+
+type ITracer interface {
+	// Start starts a span as child of the span of ctx, else of the remote
+	// parent of ctx, else as root of a new trace. The returned context
+	// carries the span.
+	Start(ctx context.Context, name string) (context.Context, *Span)
+	// Close exports the spans that have ended and stops the export.
+	Close() error
+}
+
+type Tracer struct {
+	exporter IExporter
+	spans    chan SpanData
+	wg       sync.WaitGroup
+
+	mu     sync.RWMutex
+	closed bool
+}
+
+// NewTracer creates a Tracer that exports ended spans in batches.
+func NewTracer(exporter IExporter) ITracer {
+	t := &Tracer{
+		exporter: exporter,
+		spans:    make(chan SpanData, QueueSize),
+	}
+	t.wg.Add(1)
+	go t.export()
+	return t
+}
+
+func (t *Tracer) Start(ctx context.Context, name string) (context.Context, *Span) {
+	span := &Span{
+		tracer: t,
+		name:   name,
+		kind:   KindInternal,
+		start:  time.Now(),
+	}
+	if parent := FromContext(ctx); parent != nil {
+		span.context = SpanContext{TraceId: parent.context.TraceId, Sampled: parent.context.Sampled}
+		span.parent = parent.context.SpanId
+	} else if remote, ok := ctx.Value(remoteParentKey{}).(SpanContext); ok && remote.IsValid() {
+		span.context = SpanContext{TraceId: remote.TraceId, Sampled: remote.Sampled}
+		span.parent = remote.SpanId
+	} else {
+		span.context = SpanContext{TraceId: newTraceId(), Sampled: true}
+	}
+	span.context.SpanId = newSpanId()
+	return context.WithValue(ctx, spanKey{}, span), span
+}
+
+func (t *Tracer) Close() error {
+	t.mu.Lock()
+	if !t.closed {
+		t.closed = true
+		close(t.spans)
+	}
+	t.mu.Unlock()
+	t.wg.Wait()
+	return nil
+}
+
+func (t *Tracer) enqueue(span SpanData) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	if t.closed {
+		return
+	}
+	select {
+	case t.spans <- span:
+	default:
+	}
+}
+
+func (t *Tracer) export() {
+	defer t.wg.Done()
+	ticker := time.NewTicker(BatchTimeout)
+	defer ticker.Stop()
+
+	batch := make([]SpanData, 0, BatchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := t.exporter.Export(batch); err != nil {
+			log.Printf("tracing: exporting %d spans: %v", len(batch), err)
+		}
+		batch = make([]SpanData, 0, BatchSize)
+	}
+	for {
+		select {
+		case span, ok := <-t.spans:
+			if !ok {
+				flush()
+				return
+			}
+			batch = append(batch, span)
+			if len(batch) == BatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
+
+type spanKey struct{}
+
+type remoteParentKey struct{}
+
+// Start starts a child of the span of ctx with its tracer. Without a span
+// in ctx nothing is traced and the returned Span is nil.
+func Start(ctx context.Context, name string) (context.Context, *Span) {
+	parent := FromContext(ctx)
+	if parent == nil {
+		return ctx, nil
+	}
+	return parent.tracer.Start(ctx, name)
+}
+
+// FromContext returns the span of ctx, or nil.
+func FromContext(ctx context.Context) *Span {
+	span, _ := ctx.Value(spanKey{}).(*Span)
+	return span
+}
+
+// ContextWithRemoteParent makes the span of another process the parent of
+// the next span started with ctx.
+func ContextWithRemoteParent(ctx context.Context, parent SpanContext) context.Context {
+	return context.WithValue(ctx, remoteParentKey{}, parent)
+}
+
+// Detach returns a context that carries the span of ctx but is neither
+// cancelled nor has a deadline, for work that outlives a request.
+func Detach(ctx context.Context) context.Context {
+	detached := context.Background()
+	if span := FromContext(ctx); span != nil {
+		detached = context.WithValue(detached, spanKey{}, span)
+	}
+	return detached
+}
+
+// Extract returns the remote parent of an incoming request.
+func Extract(header http.Header) (SpanContext, bool) {
+	parent, err := ParseTraceparent(header.Get(TraceparentHeader))
+	return parent, err == nil
+}
+
+// Inject propagates the span of ctx to an outgoing request.
+func Inject(ctx context.Context, header http.Header) {
+	if span := FromContext(ctx); span != nil {
+		header.Set(TraceparentHeader, span.SpanContext().Traceparent())
+	}
+}
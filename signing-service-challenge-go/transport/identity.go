@@ -0,0 +1,18 @@
+package transport
+
+import "crypto/tls"
+
+// ClientIdentity returns the identity of a verified client certificate: its
+// SPIFFE ID if it has one and its subject otherwise.
+func ClientIdentity(state *tls.ConnectionState) (string, bool) {
+	if state == nil || len(state.VerifiedChains) == 0 || len(state.VerifiedChains[0]) == 0 {
+		return "", false
+	}
+	certificate := state.VerifiedChains[0][0]
+	for _, uri := range certificate.URIs {
+		if uri.Scheme == "spiffe" {
+			return uri.String(), true
+		}
+	}
+	return certificate.Subject.String(), true
+}
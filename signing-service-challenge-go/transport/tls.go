@@ -0,0 +1,199 @@
+package transport
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+var (
+	ErrInvalidVersion    = errors.New("invalid tls version")
+	ErrInvalidClientAuth = errors.New("invalid client auth")
+	ErrInvalidClientCAs  = errors.New("no certificates in client ca file")
+	ErrMissingClientCAs  = errors.New("client auth requires a client ca file")
+)
+
+// DefaultReloadInterval bounds how often the certificate files are checked
+// for changes.
+const DefaultReloadInterval = 10 * time.Second
+
+type ClientAuth string
+
+const (
+	// ClientAuthNone does not request client certificates.
+	ClientAuthNone ClientAuth = "none"
+	// ClientAuthOptional verifies client certificates if they are presented.
+	ClientAuthOptional ClientAuth = "optional"
+	// ClientAuthRequire rejects connections without a valid client certificate.
+	ClientAuthRequire ClientAuth = "require"
+)
+
+func (c ClientAuth) tlsClientAuth() (tls.ClientAuthType, error) {
+	switch c {
+	case "", ClientAuthNone:
+		return tls.NoClientCert, nil
+	case ClientAuthOptional:
+		return tls.VerifyClientCertIfGiven, nil
+	case ClientAuthRequire:
+		return tls.RequireAndVerifyClientCert, nil
+	default:
+		return 0, ErrInvalidClientAuth
+	}
+}
+
+// ParseVersion parses a TLS version such as "1.2".
+func ParseVersion(version string) (uint16, error) {
+	switch version {
+	case "", "1.2":
+		return tls.VersionTLS12, nil
+	case "1.3":
+		return tls.VersionTLS13, nil
+	default:
+		return 0, ErrInvalidVersion
+	}
+}
+
+type Config struct {
+	CertFile string
+	KeyFile  string
+	// ClientCAFile holds the PEM encoded CAs client certificates are verified against.
+	ClientCAFile string
+	ClientAuth   ClientAuth
+	MinVersion   uint16
+	// ReloadInterval defaults to DefaultReloadInterval.
+	ReloadInterval time.Duration
+}
+
+// NewServerConfig returns a tls.Config that serves the certificate and
+// verifies clients against the CAs of the configured files. Changed files
+// are picked up by new connections without a restart.
+func NewServerConfig(config Config) (*tls.Config, error) {
+	clientAuth, err := config.ClientAuth.tlsClientAuth()
+	if err != nil {
+		return nil, err
+	}
+	if clientAuth != tls.NoClientCert && config.ClientCAFile == "" {
+		return nil, ErrMissingClientCAs
+	}
+	if config.MinVersion == 0 {
+		config.MinVersion = tls.VersionTLS12
+	}
+	if config.ReloadInterval == 0 {
+		config.ReloadInterval = DefaultReloadInterval
+	}
+
+	reloader := &reloader{config: config, now: time.Now}
+	if err := reloader.load(); err != nil {
+		return nil, err
+	}
+
+	base := &tls.Config{
+		MinVersion: config.MinVersion,
+		ClientAuth: clientAuth,
+	}
+	return &tls.Config{
+		MinVersion: config.MinVersion,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			certificate, clientCAs := reloader.current()
+			clientConfig := base.Clone()
+			clientConfig.Certificates = []tls.Certificate{*certificate}
+			clientConfig.ClientCAs = clientCAs
+			return clientConfig, nil
+		},
+	}, nil
+}
+
+// reloader keeps the certificate and client CAs of the newest files that
+// could be loaded.
+type reloader struct {
+	config Config
+	now    func() time.Time
+
+	mu          sync.Mutex
+	certificate *tls.Certificate
+	clientCAs   *x509.CertPool
+	modTimes    []time.Time
+	checkedAt   time.Time
+}
+
+func (r *reloader) files() []string {
+	files := []string{r.config.CertFile, r.config.KeyFile}
+	if r.config.ClientCAFile != "" {
+		files = append(files, r.config.ClientCAFile)
+	}
+	return files
+}
+
+func (r *reloader) stat() ([]time.Time, error) {
+	modTimes := make([]time.Time, 0, 3)
+	for _, file := range r.files() {
+		info, err := os.Stat(file)
+		if err != nil {
+			return nil, err
+		}
+		modTimes = append(modTimes, info.ModTime())
+	}
+	return modTimes, nil
+}
+
+// load reads all files. It has to be called with r.mu held or before the
+// reloader is shared.
+func (r *reloader) load() error {
+	modTimes, err := r.stat()
+	if err != nil {
+		return err
+	}
+	certificate, err := tls.LoadX509KeyPair(r.config.CertFile, r.config.KeyFile)
+	if err != nil {
+		return err
+	}
+	var clientCAs *x509.CertPool
+	if r.config.ClientCAFile != "" {
+		pem, err := os.ReadFile(r.config.ClientCAFile)
+		if err != nil {
+			return err
+		}
+		clientCAs = x509.NewCertPool()
+		if !clientCAs.AppendCertsFromPEM(pem) {
+			return ErrInvalidClientCAs
+		}
+	}
+
+	r.certificate = &certificate
+	r.clientCAs = clientCAs
+	r.modTimes = modTimes
+	r.checkedAt = r.now()
+	return nil
+}
+
+// current reloads the files if the reload interval has passed and any of
+// them changed. A failed reload keeps the previous certificates.
+func (r *reloader) current() (*tls.Certificate, *x509.CertPool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.now().Sub(r.checkedAt) >= r.config.ReloadInterval {
+		r.checkedAt = r.now()
+		modTimes, err := r.stat()
+		if err == nil && changed(r.modTimes, modTimes) {
+			err = r.load()
+		}
+		if err != nil {
+			log.Printf("tls: keeping previous certificates: %v", err)
+		}
+	}
+	return r.certificate, r.clientCAs
+}
+
+func changed(old, new []time.Time) bool {
+	for i := range old {
+		if !old[i].Equal(new[i]) {
+			return true
+		}
+	}
+	return false
+}
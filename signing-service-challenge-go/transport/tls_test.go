@@ -0,0 +1,213 @@
+package transport
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func assertEqual(t *testing.T, expected interface{}, actual interface{}) {
+	t.Helper()
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("Expected %v, actual %v", expected, actual)
+	}
+}
+
+type issued struct {
+	certificate *x509.Certificate
+	key         *ecdsa.PrivateKey
+	certPEM     []byte
+	keyPEM      []byte
+}
+
+// issue creates a certificate signed by parent, or a self-signed CA if
+// parent is nil.
+func issue(t *testing.T, parent *issued, template *x509.Certificate) *issued {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template.SerialNumber = big.NewInt(time.Now().UnixNano())
+	template.NotBefore = time.Now().Add(-time.Hour)
+	template.NotAfter = time.Now().Add(time.Hour)
+	signer, signerCertificate := key, template
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+		template.KeyUsage = x509.KeyUsageCertSign
+	} else {
+		signer, signerCertificate = parent.key, parent.certificate
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, signerCertificate, &key.PublicKey, signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certificate, _ := x509.ParseCertificate(der)
+	keyDER, _ := x509.MarshalECPrivateKey(key)
+	return &issued{
+		certificate: certificate,
+		key:         key,
+		certPEM:     pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		keyPEM:      pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}),
+	}
+}
+
+func serverCertificate(t *testing.T, ca *issued, name string) *issued {
+	return issue(t, ca, &x509.Certificate{
+		Subject:     pkix.Name{CommonName: name},
+		IPAddresses: []net.IP{net.ParseIP("127.0.0.1")},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	})
+}
+
+func writeFiles(t *testing.T, dir string, ca, server *issued) Config {
+	t.Helper()
+	config := Config{
+		CertFile:     filepath.Join(dir, "server.pem"),
+		KeyFile:      filepath.Join(dir, "server-key.pem"),
+		ClientCAFile: filepath.Join(dir, "ca.pem"),
+	}
+	for file, content := range map[string][]byte{
+		config.CertFile:     server.certPEM,
+		config.KeyFile:      server.keyPEM,
+		config.ClientCAFile: ca.certPEM,
+	} {
+		if err := os.WriteFile(file, content, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return config
+}
+
+func TestNewServerConfig_MutualTLS(t *testing.T) {
+	ca := issue(t, nil, &x509.Certificate{Subject: pkix.Name{CommonName: "ca"}})
+	config := writeFiles(t, t.TempDir(), ca, serverCertificate(t, ca, "server"))
+	config.ClientAuth = ClientAuthRequire
+	config.MinVersion = tls.VersionTLS13
+	tlsConfig, err := NewServerConfig(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		identity, _ := ClientIdentity(r.TLS)
+		_, _ = io.WriteString(w, identity)
+	}))
+	server.TLS = tlsConfig
+	server.Config.ErrorLog = log.New(io.Discard, "", 0)
+	server.StartTLS()
+	defer server.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.certificate)
+	spiffe, _ := url.Parse("spiffe://mesh.example.com/ns/pos/sa/terminal")
+	client := issue(t, ca, &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "terminal"},
+		URIs:        []*url.URL{spiffe},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	})
+	clientCertificate, _ := tls.X509KeyPair(client.certPEM, client.keyPEM)
+
+	withCertificate := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{
+		RootCAs:      roots,
+		Certificates: []tls.Certificate{clientCertificate},
+	}}}
+	response, err := withCertificate.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(response.Body)
+	response.Body.Close()
+	assertEqual(t, "spiffe://mesh.example.com/ns/pos/sa/terminal", string(body))
+	assertEqual(t, uint16(tls.VersionTLS13), response.TLS.Version)
+
+	withoutCertificate := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots}}}
+	if _, err := withoutCertificate.Get(server.URL); err == nil {
+		t.Error("Expected connection without client certificate to fail")
+	}
+}
+
+func TestReloader_ReloadsChangedFiles(t *testing.T) {
+	ca := issue(t, nil, &x509.Certificate{Subject: pkix.Name{CommonName: "ca"}})
+	dir := t.TempDir()
+	config := writeFiles(t, dir, ca, serverCertificate(t, ca, "first"))
+	config.ReloadInterval = time.Minute
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	r := &reloader{config: config, now: func() time.Time { return now }}
+	if err := r.load(); err != nil {
+		t.Fatal(err)
+	}
+
+	second := serverCertificate(t, ca, "second")
+	_ = os.WriteFile(config.CertFile, second.certPEM, 0o600)
+	_ = os.WriteFile(config.KeyFile, second.keyPEM, 0o600)
+	later := time.Now().Add(time.Hour)
+	_ = os.Chtimes(config.CertFile, later, later)
+	_ = os.Chtimes(config.KeyFile, later, later)
+
+	certificate, _ := r.current()
+	leaf, _ := x509.ParseCertificate(certificate.Certificate[0])
+	assertEqual(t, "first", leaf.Subject.CommonName)
+
+	now = now.Add(time.Minute)
+	certificate, _ = r.current()
+	leaf, _ = x509.ParseCertificate(certificate.Certificate[0])
+	assertEqual(t, "second", leaf.Subject.CommonName)
+}
+
+func TestReloader_KeepsCertificateOnError(t *testing.T) {
+	ca := issue(t, nil, &x509.Certificate{Subject: pkix.Name{CommonName: "ca"}})
+	config := writeFiles(t, t.TempDir(), ca, serverCertificate(t, ca, "first"))
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	r := &reloader{config: config, now: func() time.Time { return now }}
+	_ = r.load()
+
+	_ = os.WriteFile(config.CertFile, []byte("invalid"), 0o600)
+	later := time.Now().Add(time.Hour)
+	_ = os.Chtimes(config.CertFile, later, later)
+	now = now.Add(DefaultReloadInterval)
+
+	certificate, _ := r.current()
+	leaf, _ := x509.ParseCertificate(certificate.Certificate[0])
+	assertEqual(t, "first", leaf.Subject.CommonName)
+}
+
+func TestNewServerConfig_Invalid(t *testing.T) {
+	_, err := NewServerConfig(Config{ClientAuth: "sometimes"})
+	assertEqual(t, ErrInvalidClientAuth, err)
+
+	_, err = NewServerConfig(Config{ClientAuth: ClientAuthRequire})
+	assertEqual(t, ErrMissingClientCAs, err)
+
+	_, err = ParseVersion("1.0")
+	assertEqual(t, ErrInvalidVersion, err)
+}
+
+func TestClientIdentity_Subject(t *testing.T) {
+	ca := issue(t, nil, &x509.Certificate{Subject: pkix.Name{CommonName: "ca"}})
+	client := issue(t, ca, &x509.Certificate{Subject: pkix.Name{CommonName: "terminal", Organization: []string{"merchant"}}})
+
+	identity, ok := ClientIdentity(&tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{client.certificate, ca.certificate}}})
+	assertEqual(t, true, ok)
+	assertEqual(t, "CN=terminal,O=merchant", identity)
+
+	_, ok = ClientIdentity(&tls.ConnectionState{PeerCertificates: []*x509.Certificate{client.certificate}})
+	assertEqual(t, false, ok)
+}
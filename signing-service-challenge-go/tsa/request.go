@@ -0,0 +1,135 @@
+package tsa
+
+import (
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrBadRequest          = errors.New("bad time-stamp request")
+	ErrBadAlgorithm        = errors.New("unsupported hash algorithm")
+	ErrUnacceptedPolicy    = errors.New("unaccepted policy")
+	ErrUnacceptedExtension = errors.New("unaccepted extension")
+	ErrInvalidPolicy       = errors.New("invalid policy")
+)
+
+var (
+	oidSHA1   = asn1.ObjectIdentifier{1, 3, 14, 3, 2, 26}
+	oidSHA256 = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}
+	oidSHA384 = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 2}
+	oidSHA512 = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 3}
+)
+
+// hashLengths lists the accepted message imprint algorithms and their digest sizes.
+var hashLengths = []struct {
+	oid    asn1.ObjectIdentifier
+	length int
+}{
+	{oidSHA1, 20},
+	{oidSHA256, 32},
+	{oidSHA384, 48},
+	{oidSHA512, 64},
+}
+
+type messageImprint struct {
+	HashAlgorithm pkix.AlgorithmIdentifier
+	HashedMessage []byte
+}
+
+type timeStampReq struct {
+	Version        int
+	MessageImprint asn1.RawValue
+	ReqPolicy      asn1.ObjectIdentifier `asn1:"optional"`
+	Nonce          *big.Int              `asn1:"optional"`
+	CertReq        bool                  `asn1:"optional"`
+	Extensions     []pkix.Extension      `asn1:"optional,tag:0"`
+}
+
+// Request is a validated RFC 3161 TimeStampReq.
+type Request struct {
+	HashAlgorithm asn1.ObjectIdentifier
+	HashedMessage []byte
+	Nonce         *big.Int
+	CertReq       bool
+	// Policy is the TSA policy the client requested, empty if it accepts
+	// any.
+	Policy asn1.ObjectIdentifier
+
+	// messageImprint keeps the DER of the imprint so that it can be
+	// copied into the TSTInfo byte by byte.
+	messageImprint []byte
+}
+
+// ParseRequest decodes and validates a DER encoded TimeStampReq.
+func ParseRequest(der []byte) (Request, error) {
+	var req timeStampReq
+	rest, err := asn1.Unmarshal(der, &req)
+	if err != nil || len(rest) > 0 || req.Version != 1 {
+		return Request{}, ErrBadRequest
+	}
+
+	var imprint messageImprint
+	rest, err = asn1.Unmarshal(req.MessageImprint.FullBytes, &imprint)
+	if err != nil || len(rest) > 0 {
+		return Request{}, ErrBadRequest
+	}
+	length, ok := hashLength(imprint.HashAlgorithm.Algorithm)
+	if !ok {
+		return Request{}, ErrBadAlgorithm
+	}
+	if length != len(imprint.HashedMessage) {
+		return Request{}, ErrBadRequest
+	}
+	if len(req.Extensions) > 0 {
+		return Request{}, ErrUnacceptedExtension
+	}
+
+	return Request{
+		HashAlgorithm:  imprint.HashAlgorithm.Algorithm,
+		HashedMessage:  imprint.HashedMessage,
+		Nonce:          req.Nonce,
+		CertReq:        req.CertReq,
+		Policy:         req.ReqPolicy,
+		messageImprint: req.MessageImprint.FullBytes,
+	}, nil
+}
+
+// accepts reports whether a token issued under the policy satisfies the
+// request.
+func (r Request) accepts(policy asn1.ObjectIdentifier) bool {
+	return len(r.Policy) == 0 || r.Policy.Equal(policy)
+}
+
+// ParsePolicy parses a TSA policy in dotted notation, e.g. "1.2.3.4.1".
+func ParsePolicy(oid string) (asn1.ObjectIdentifier, error) {
+	arcs := strings.Split(oid, ".")
+	if len(arcs) < 2 {
+		return nil, ErrInvalidPolicy
+	}
+	policy := make(asn1.ObjectIdentifier, 0, len(arcs))
+	for _, arc := range arcs {
+		value, err := strconv.Atoi(arc)
+		if err != nil || value < 0 || arc != strconv.Itoa(value) {
+			return nil, ErrInvalidPolicy
+		}
+		policy = append(policy, value)
+	}
+	// The first two arcs are encoded together, which restricts them.
+	if policy[0] > 2 || (policy[0] < 2 && policy[1] >= 40) {
+		return nil, ErrInvalidPolicy
+	}
+	return policy, nil
+}
+
+func hashLength(algorithm asn1.ObjectIdentifier) (int, bool) {
+	for _, hash := range hashLengths {
+		if hash.oid.Equal(algorithm) {
+			return hash.length, true
+		}
+	}
+	return 0, false
+}
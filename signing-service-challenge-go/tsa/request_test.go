@@ -0,0 +1,97 @@
+package tsa
+
+import (
+	"crypto/sha256"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"math/big"
+	"reflect"
+	"testing"
+)
+
+func assertEqual(t *testing.T, expected any, actual any) {
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("Expected %v, actual %v", expected, actual)
+	}
+}
+
+func newRequest(t *testing.T, algorithm asn1.ObjectIdentifier, digest []byte, policy asn1.ObjectIdentifier) []byte {
+	imprint, err := asn1.Marshal(messageImprint{
+		HashAlgorithm: pkix.AlgorithmIdentifier{Algorithm: algorithm, Parameters: asn1.NullRawValue},
+		HashedMessage: digest,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := asn1.Marshal(timeStampReq{
+		Version:        1,
+		MessageImprint: asn1.RawValue{FullBytes: imprint},
+		ReqPolicy:      policy,
+		Nonce:          big.NewInt(42),
+		CertReq:        true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return der
+}
+
+var digest = sha256.Sum256([]byte("data"))
+
+func TestParseRequest_Ok(t *testing.T) {
+	der := newRequest(t, oidSHA256, digest[:], nil)
+
+	request, err := ParseRequest(der)
+
+	assertEqual(t, nil, err)
+	assertEqual(t, oidSHA256, request.HashAlgorithm)
+	assertEqual(t, digest[:], request.HashedMessage)
+	assertEqual(t, big.NewInt(42), request.Nonce)
+	assertEqual(t, true, request.CertReq)
+}
+
+func TestParseRequest_ErrBadRequest(t *testing.T) {
+	_, err := ParseRequest([]byte("data"))
+
+	assertEqual(t, ErrBadRequest, err)
+}
+
+func TestParseRequest_ErrBadRequestDigestLength(t *testing.T) {
+	der := newRequest(t, oidSHA384, digest[:], nil)
+
+	_, err := ParseRequest(der)
+
+	assertEqual(t, ErrBadRequest, err)
+}
+
+func TestParseRequest_ErrBadAlgorithm(t *testing.T) {
+	der := newRequest(t, asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 5}, digest[:16], nil)
+
+	_, err := ParseRequest(der)
+
+	assertEqual(t, ErrBadAlgorithm, err)
+}
+
+func TestParseRequest_OkPolicy(t *testing.T) {
+	der := newRequest(t, oidSHA256, digest[:], asn1.ObjectIdentifier{1, 2, 3})
+
+	request, err := ParseRequest(der)
+
+	assertEqual(t, nil, err)
+	assertEqual(t, asn1.ObjectIdentifier{1, 2, 3}, request.Policy)
+}
+
+func TestParsePolicy_Ok(t *testing.T) {
+	policy, err := ParsePolicy("1.3.6.1.4.1.4146.1.1")
+
+	assertEqual(t, nil, err)
+	assertEqual(t, asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 4146, 1, 1}, policy)
+}
+
+func TestParsePolicy_ErrInvalidPolicy(t *testing.T) {
+	for _, oid := range []string{"", "1", "1..2", "1.-2", "1.+2", "1.02", "3.1", "1.40", "1.2.x"} {
+		_, err := ParsePolicy(oid)
+
+		assertEqual(t, ErrInvalidPolicy, err)
+	}
+}
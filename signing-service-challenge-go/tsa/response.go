@@ -0,0 +1,281 @@
+package tsa
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"math/big"
+	"time"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/crypto"
+)
+
+const (
+	statusGranted   = 0
+	statusRejection = 2
+)
+
+// PKIFailureInfo bits as defined in RFC 3161 section 2.4.2.
+const (
+	failureBadAlg              = 0
+	failureBadRequest          = 2
+	failureUnacceptedPolicy    = 15
+	failureUnacceptedExtension = 16
+	failureSystemFailure       = 25
+)
+
+var (
+	oidSignedData                 = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2}
+	oidTSTInfo                    = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 16, 1, 4}
+	oidAttributeContentType       = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 3}
+	oidAttributeMessageDigest     = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 4}
+	oidAttributeSigningCertV2     = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 16, 2, 47}
+	digestAlgorithmIdentifierSHA2 = pkix.AlgorithmIdentifier{Algorithm: oidSHA256, Parameters: asn1.NullRawValue}
+)
+
+type accuracy struct {
+	Seconds int `asn1:"optional"`
+}
+
+type tstInfo struct {
+	Version        int
+	Policy         asn1.ObjectIdentifier
+	MessageImprint asn1.RawValue
+	SerialNumber   *big.Int
+	GenTime        time.Time `asn1:"generalized"`
+	Accuracy       accuracy  `asn1:"optional"`
+	Nonce          *big.Int  `asn1:"optional"`
+}
+
+type contentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue
+}
+
+type encapsulatedContentInfo struct {
+	EContentType asn1.ObjectIdentifier
+	EContent     []byte `asn1:"explicit,tag:0"`
+}
+
+type issuerAndSerialNumber struct {
+	Issuer       asn1.RawValue
+	SerialNumber *big.Int
+}
+
+type attribute struct {
+	Type   asn1.ObjectIdentifier
+	Values []asn1.RawValue `asn1:"set"`
+}
+
+type essCertIDv2 struct {
+	CertHash []byte
+}
+
+type signingCertificateV2 struct {
+	Certs []essCertIDv2
+}
+
+type signerInfo struct {
+	Version            int
+	SID                issuerAndSerialNumber
+	DigestAlgorithm    pkix.AlgorithmIdentifier
+	SignedAttrs        asn1.RawValue
+	SignatureAlgorithm pkix.AlgorithmIdentifier
+	Signature          []byte
+}
+
+type signedData struct {
+	Version          int
+	DigestAlgorithms []pkix.AlgorithmIdentifier `asn1:"set"`
+	EncapContentInfo encapsulatedContentInfo
+	Certificates     asn1.RawValue `asn1:"optional"`
+	SignerInfos      []signerInfo  `asn1:"set"`
+}
+
+type pkiStatusInfo struct {
+	Status       int
+	StatusString []asn1.RawValue `asn1:"optional"`
+	FailInfo     asn1.BitString  `asn1:"optional"`
+}
+
+type timeStampResp struct {
+	Status         pkiStatusInfo
+	TimeStampToken asn1.RawValue `asn1:"optional"`
+}
+
+// Token is a signed TimeStampToken together with the raw signature over it.
+type Token struct {
+	DER       []byte
+	Signature []byte
+}
+
+// NewToken issues a TimeStampToken for the request under the TSA policy,
+// or fails with ErrUnacceptedPolicy if the request asks for another one.
+// The token is a CMS SignedData over a TSTInfo and is signed with the given
+// Signer, whose certificate has to be passed in DER encoding.
+func NewToken(request Request, policy asn1.ObjectIdentifier, serialNumber int, genTime time.Time, algorithm string, certificate []byte, signer crypto.Signer) (Token, error) {
+	if !request.accepts(policy) {
+		return Token{}, ErrUnacceptedPolicy
+	}
+	cert, err := x509.ParseCertificate(certificate)
+	if err != nil {
+		return Token{}, err
+	}
+	signatureAlgorithm, err := crypto.SignatureAlgorithmIdentifier(algorithm)
+	if err != nil {
+		return Token{}, err
+	}
+
+	info, err := asn1.Marshal(tstInfo{
+		Version:        1,
+		Policy:         policy,
+		MessageImprint: asn1.RawValue{FullBytes: request.messageImprint},
+		SerialNumber:   big.NewInt(int64(serialNumber)),
+		GenTime:        genTime.UTC().Truncate(time.Second),
+		Accuracy:       accuracy{Seconds: 1},
+		Nonce:          request.Nonce,
+	})
+	if err != nil {
+		return Token{}, err
+	}
+
+	signedAttrs, err := marshalSignedAttributes(info, certificate)
+	if err != nil {
+		return Token{}, err
+	}
+	// The signature covers the attributes as a SET OF, while the SignerInfo
+	// carries them with an implicit [0] tag instead.
+	signature, err := signer.Sign(signedAttrs)
+	if err != nil {
+		return Token{}, err
+	}
+	taggedAttrs := append([]byte{0xa0}, signedAttrs[1:]...)
+
+	var certificates asn1.RawValue
+	if request.CertReq {
+		certificates = asn1.RawValue{
+			Class:      asn1.ClassContextSpecific,
+			Tag:        0,
+			IsCompound: true,
+			Bytes:      certificate,
+		}
+	}
+
+	content, err := asn1.Marshal(signedData{
+		Version:          3,
+		DigestAlgorithms: []pkix.AlgorithmIdentifier{digestAlgorithmIdentifierSHA2},
+		EncapContentInfo: encapsulatedContentInfo{
+			EContentType: oidTSTInfo,
+			EContent:     info,
+		},
+		Certificates: certificates,
+		SignerInfos: []signerInfo{
+			{
+				Version: 1,
+				SID: issuerAndSerialNumber{
+					Issuer:       asn1.RawValue{FullBytes: cert.RawIssuer},
+					SerialNumber: cert.SerialNumber,
+				},
+				DigestAlgorithm:    digestAlgorithmIdentifierSHA2,
+				SignedAttrs:        asn1.RawValue{FullBytes: taggedAttrs},
+				SignatureAlgorithm: signatureAlgorithm,
+				Signature:          signature,
+			},
+		},
+	})
+	if err != nil {
+		return Token{}, err
+	}
+
+	token, err := asn1.Marshal(contentInfo{
+		ContentType: oidSignedData,
+		Content: asn1.RawValue{
+			Class:      asn1.ClassContextSpecific,
+			Tag:        0,
+			IsCompound: true,
+			Bytes:      content,
+		},
+	})
+	if err != nil {
+		return Token{}, err
+	}
+	return Token{
+		DER:       token,
+		Signature: signature,
+	}, nil
+}
+
+func marshalSignedAttributes(info []byte, certificate []byte) ([]byte, error) {
+	contentType, err := asn1.Marshal(oidTSTInfo)
+	if err != nil {
+		return nil, err
+	}
+	infoDigest := sha256.Sum256(info)
+	messageDigest, err := asn1.Marshal(infoDigest[:])
+	if err != nil {
+		return nil, err
+	}
+	certificateDigest := sha256.Sum256(certificate)
+	signingCertificate, err := asn1.Marshal(signingCertificateV2{
+		Certs: []essCertIDv2{{CertHash: certificateDigest[:]}},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return asn1.MarshalWithParams([]attribute{
+		{Type: oidAttributeContentType, Values: []asn1.RawValue{{FullBytes: contentType}}},
+		{Type: oidAttributeMessageDigest, Values: []asn1.RawValue{{FullBytes: messageDigest}}},
+		{Type: oidAttributeSigningCertV2, Values: []asn1.RawValue{{FullBytes: signingCertificate}}},
+	}, "set")
+}
+
+// NewResponse wraps a granted TimeStampToken into a DER encoded TimeStampResp.
+func NewResponse(token Token) ([]byte, error) {
+	return asn1.Marshal(timeStampResp{
+		Status: pkiStatusInfo{
+			Status: statusGranted,
+		},
+		TimeStampToken: asn1.RawValue{FullBytes: token.DER},
+	})
+}
+
+// NewRejection creates a DER encoded TimeStampResp that rejects a request
+// with the failure info matching err.
+func NewRejection(err error) ([]byte, error) {
+	failure := failureSystemFailure
+	switch {
+	case errors.Is(err, ErrBadAlgorithm):
+		failure = failureBadAlg
+	case errors.Is(err, ErrBadRequest):
+		failure = failureBadRequest
+	case errors.Is(err, ErrUnacceptedPolicy):
+		failure = failureUnacceptedPolicy
+	case errors.Is(err, ErrUnacceptedExtension):
+		failure = failureUnacceptedExtension
+	}
+
+	statusString, marshalErr := asn1.MarshalWithParams(err.Error(), "utf8")
+	if marshalErr != nil {
+		return nil, marshalErr
+	}
+
+	return asn1.Marshal(timeStampResp{
+		Status: pkiStatusInfo{
+			Status:       statusRejection,
+			StatusString: []asn1.RawValue{{FullBytes: statusString}},
+			FailInfo:     failureBitString(failure),
+		},
+	})
+}
+
+func failureBitString(bit int) asn1.BitString {
+	bytes := make([]byte, bit/8+1)
+	bytes[bit/8] = 0x80 >> (bit % 8)
+	return asn1.BitString{
+		Bytes:     bytes,
+		BitLength: bit + 1,
+	}
+}
@@ -0,0 +1,80 @@
+package tsa
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/crypto"
+)
+
+var privateKeyEcc = `-----BEGIN PRIVATE_KEY-----
+MIGkAgEBBDA10Qv12By0KByh0aaUZCmcwCSdhkMecHgRoYY3U2iiTmR4QU2iQbD6
+IoeIHX10dgWgBwYFK4EEACKhZANiAASpKAp5IJCe//Maazpo9NAvChtA9nN1tENS
+UJRJlf/uzPLYkkyjXVnYctQgteqTpNvtwk1eUNlE66yoC5LexPyjPJ8xpxSD+pu/
+CyjmsnZywGVS42qb2Up3nMdYvgKMiBA=
+-----END PRIVATE_KEY-----`
+
+var genTime = time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+func TestNewToken_Ok(t *testing.T) {
+	certificate, _ := crypto.NewTimeStampingCertificate("ECC", []byte(privateKeyEcc), "test", genTime)
+	signer, _ := crypto.NewSigner("ECC", []byte(privateKeyEcc))
+	request, _ := ParseRequest(newRequest(t, oidSHA256, digest[:], nil))
+
+	token, err := NewToken(request, asn1.ObjectIdentifier{1, 2, 3, 4, 1}, 7, genTime, "ECC", certificate, signer)
+
+	assertEqual(t, nil, err)
+	var info contentInfo
+	_, _ = asn1.Unmarshal(token.DER, &info)
+	var content signedData
+	_, _ = asn1.Unmarshal(info.Content.Bytes, &content)
+	var tst tstInfo
+	_, _ = asn1.Unmarshal(content.EncapContentInfo.EContent, &tst)
+	assertEqual(t, asn1.ObjectIdentifier{1, 2, 3, 4, 1}, tst.Policy)
+	assertEqual(t, big.NewInt(7), tst.SerialNumber)
+	assertEqual(t, genTime, tst.GenTime)
+	assertEqual(t, big.NewInt(42), tst.Nonce)
+	assertEqual(t, certificate, content.Certificates.Bytes)
+
+	signerInfo := content.SignerInfos[0]
+	assertEqual(t, token.Signature, signerInfo.Signature)
+	signedAttrs := append([]byte{0x31}, signerInfo.SignedAttrs.FullBytes[1:]...)
+	cert, _ := x509.ParseCertificate(certificate)
+	assertEqual(t, nil, cert.CheckSignature(x509.ECDSAWithSHA256, signedAttrs, signerInfo.Signature))
+
+	var attributes []attribute
+	_, _ = asn1.UnmarshalWithParams(signedAttrs, &attributes, "set")
+	var messageDigest []byte
+	for _, attribute := range attributes {
+		if attribute.Type.Equal(oidAttributeMessageDigest) {
+			_, _ = asn1.Unmarshal(attribute.Values[0].FullBytes, &messageDigest)
+		}
+	}
+	infoDigest := sha256.Sum256(content.EncapContentInfo.EContent)
+	assertEqual(t, infoDigest[:], messageDigest)
+}
+
+func TestNewToken_ErrUnacceptedPolicy(t *testing.T) {
+	certificate, _ := crypto.NewTimeStampingCertificate("ECC", []byte(privateKeyEcc), "test", genTime)
+	signer, _ := crypto.NewSigner("ECC", []byte(privateKeyEcc))
+	request, _ := ParseRequest(newRequest(t, oidSHA256, digest[:], asn1.ObjectIdentifier{1, 2, 3}))
+
+	_, err := NewToken(request, asn1.ObjectIdentifier{1, 2, 3, 4, 1}, 7, genTime, "ECC", certificate, signer)
+
+	assertEqual(t, ErrUnacceptedPolicy, err)
+}
+
+func TestNewRejection_Ok(t *testing.T) {
+	der, err := NewRejection(ErrBadAlgorithm)
+
+	assertEqual(t, nil, err)
+	var response timeStampResp
+	_, _ = asn1.Unmarshal(der, &response)
+	assertEqual(t, statusRejection, response.Status.Status)
+	assertEqual(t, 1, response.Status.FailInfo.At(failureBadAlg))
+	assertEqual(t, 0, len(response.TimeStampToken.FullBytes))
+}
@@ -0,0 +1,426 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrNotFound         = errors.New("not found")
+	ErrInvalidURL       = errors.New("invalid webhook url")
+	ErrInvalidEventType = errors.New("invalid event type")
+	ErrInvalidSignature = errors.New("invalid signature")
+	errQueueFull        = errors.New("delivery queue full")
+	errAbandoned        = errors.New("abandoned at shutdown")
+)
+
+const (
+	SignatureHeader = "X-Webhook-Signature"
+	EventHeader     = "X-Webhook-Event"
+	DeliveryHeader  = "X-Webhook-Delivery"
+
+	// QueueSize is the number of deliveries buffered for the workers.
+	QueueSize = 4096
+	// MaxDeadLetters bounds the dead-letter list, the oldest entries are dropped first.
+	MaxDeadLetters = 1000
+	// DeliveryTimeout bounds a single delivery attempt.
+	DeliveryTimeout = 10 * time.Second
+	// SignatureTolerance is how far the timestamp of a signature may be from
+	// the clock of the receiver. Every attempt is signed anew.
+	SignatureTolerance = 5 * time.Minute
+)
+
+// RetryPolicy defines the exponential backoff between delivery attempts.
+type RetryPolicy struct {
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+}
+
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts:    8,
+	InitialBackoff: time.Second,
+	MaxBackoff:     5 * time.Minute,
+}
+
+// backoff returns the delay after the given number of failed attempts.
+func (p RetryPolicy) backoff(attempts int) time.Duration {
+	delay := p.InitialBackoff
+	for i := 1; i < attempts && delay < p.MaxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > p.MaxBackoff {
+		return p.MaxBackoff
+	}
+	return delay
+}
+
+// Subscription registers a URL for a set of event types of the devices of
+// a tenant.
+type Subscription struct {
+	Id         string
+	Tenant     string
+	URL        string
+	EventTypes []domain.EventType
+	Secret     string
+	CreatedAt  time.Time
+}
+
+func (s Subscription) matches(eventType domain.EventType) bool {
+	for _, subscribed := range s.EventTypes {
+		if subscribed == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+type DeliveryStatus string
+
+const (
+	DeliveryPending DeliveryStatus = "pending"
+	DeliveryDead    DeliveryStatus = "dead"
+)
+
+// Delivery is a single event on its way to a single subscription.
+type Delivery struct {
+	Id             string
+	Tenant         string
+	SubscriptionId string
+	EventId        string
+	EventType      domain.EventType
+	Payload        []byte
+	Status         DeliveryStatus
+	Attempts       int
+	LastError      string
+	CreatedAt      time.Time
+}
+
+type IDispatcher interface {
+	domain.IEventPublisher
+	Subscribe(tenant, url string, eventTypes []domain.EventType, secret string) (Subscription, error)
+	Subscriptions(tenant string) []Subscription
+	Unsubscribe(tenant, id string) error
+	DeadLetters(tenant string) []Delivery
+	Replay(tenant, deliveryId string) (Delivery, error)
+	// Close stops the workers and waits for the queued deliveries until
+	// ctx expires. Deliveries still queued then move to the dead letters.
+	Close(ctx context.Context) error
+}
+
+// Dispatcher delivers domain events to the subscribed URLs. Failed
+// deliveries are retried with exponential backoff and end up in the
+// dead-letter list once the retry policy is exhausted, where they can be
+// replayed from.
+type Dispatcher struct {
+	client *http.Client
+	// checkHost refuses subscription URLs, see CheckHost.
+	checkHost func(host string) error
+	retry     RetryPolicy
+	queue     chan string
+	wg        sync.WaitGroup
+
+	mu            sync.Mutex
+	subscriptions map[string]Subscription
+	deliveries    map[string]*Delivery
+	deadLetters   []*Delivery
+	timers        map[string]*time.Timer
+	closed        bool
+	abandoned     bool
+}
+
+// NewDispatcher creates a Dispatcher and starts its workers.
+func NewDispatcher(workers int, retry RetryPolicy) IDispatcher {
+	d := &Dispatcher{
+		client:        NewPublicClient(DeliveryTimeout),
+		checkHost:     CheckHost,
+		retry:         retry,
+		queue:         make(chan string, QueueSize),
+		subscriptions: make(map[string]Subscription),
+		deliveries:    make(map[string]*Delivery),
+		timers:        make(map[string]*time.Timer),
+	}
+	for i := 0; i < workers; i++ {
+		d.wg.Add(1)
+		go d.work()
+	}
+	return d
+}
+
+func (d *Dispatcher) Subscribe(tenant, rawURL string, eventTypes []domain.EventType, secret string) (Subscription, error) {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return Subscription{}, ErrInvalidURL
+	}
+	if err := d.checkHost(parsed.Hostname()); err != nil {
+		return Subscription{}, fmt.Errorf("%w: %v", ErrInvalidURL, err)
+	}
+	if len(eventTypes) == 0 {
+		return Subscription{}, ErrInvalidEventType
+	}
+	for _, eventType := range eventTypes {
+		if !isEventType(eventType) {
+			return Subscription{}, ErrInvalidEventType
+		}
+	}
+	if secret == "" {
+		random := make([]byte, 32)
+		if _, err := rand.Read(random); err != nil {
+			return Subscription{}, err
+		}
+		secret = hex.EncodeToString(random)
+	}
+
+	subscription := Subscription{
+		Id:         uuid.NewString(),
+		Tenant:     tenant,
+		URL:        rawURL,
+		EventTypes: eventTypes,
+		Secret:     secret,
+		CreatedAt:  time.Now().UTC(),
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.subscriptions[subscription.Id] = subscription
+	return subscription, nil
+}
+
+func isEventType(eventType domain.EventType) bool {
+	for _, known := range domain.EventTypes {
+		if known == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+func (d *Dispatcher) Subscriptions(tenant string) []Subscription {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	result := make([]Subscription, 0)
+	for _, subscription := range d.subscriptions {
+		if subscription.Tenant == tenant {
+			result = append(result, subscription)
+		}
+	}
+	return result
+}
+
+// Unsubscribe removes a subscription. Its pending deliveries are dropped.
+func (d *Dispatcher) Unsubscribe(tenant, id string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if subscription, exists := d.subscriptions[id]; !exists || subscription.Tenant != tenant {
+		return ErrNotFound
+	}
+	delete(d.subscriptions, id)
+	return nil
+}
+
+// Publish queues a delivery of the event for every matching subscription
+// of the tenant owning the device.
+func (d *Dispatcher) Publish(event domain.Event) {
+	payload, err := newPayload(event)
+	if err != nil {
+		log.Printf("webhook: encoding event %s: %v", event.Id, err)
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closed {
+		return
+	}
+	for _, subscription := range d.subscriptions {
+		if subscription.Tenant != event.Device.Tenant || !subscription.matches(event.Type) {
+			continue
+		}
+		delivery := &Delivery{
+			Id:             uuid.NewString(),
+			Tenant:         subscription.Tenant,
+			SubscriptionId: subscription.Id,
+			EventId:        event.Id,
+			EventType:      event.Type,
+			Payload:        payload,
+			Status:         DeliveryPending,
+			CreatedAt:      time.Now().UTC(),
+		}
+		d.deliveries[delivery.Id] = delivery
+		d.enqueue(delivery)
+	}
+}
+
+func (d *Dispatcher) DeadLetters(tenant string) []Delivery {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	result := make([]Delivery, 0)
+	for _, delivery := range d.deadLetters {
+		if delivery.Tenant == tenant {
+			result = append(result, *delivery)
+		}
+	}
+	return result
+}
+
+// Replay moves a dead letter back into the queue with a fresh retry budget.
+func (d *Dispatcher) Replay(tenant, deliveryId string) (Delivery, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for i, delivery := range d.deadLetters {
+		if delivery.Id != deliveryId || delivery.Tenant != tenant {
+			continue
+		}
+		if _, exists := d.subscriptions[delivery.SubscriptionId]; !exists {
+			return Delivery{}, ErrNotFound
+		}
+		d.deadLetters = append(d.deadLetters[:i], d.deadLetters[i+1:]...)
+		delivery.Status = DeliveryPending
+		delivery.Attempts = 0
+		delivery.LastError = ""
+		d.deliveries[delivery.Id] = delivery
+		d.enqueue(delivery)
+		return *delivery, nil
+	}
+	return Delivery{}, ErrNotFound
+}
+
+// Close stops the workers. Deliveries waiting for a retry are dropped. Once
+// ctx expires the deliveries still queued move to the dead-letter list,
+// only the attempts in flight are waited for.
+func (d *Dispatcher) Close(ctx context.Context) error {
+	d.mu.Lock()
+	if !d.closed {
+		d.closed = true
+		for _, timer := range d.timers {
+			timer.Stop()
+		}
+		close(d.queue)
+	}
+	d.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		d.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+	}
+	d.mu.Lock()
+	d.abandoned = true
+	d.mu.Unlock()
+	<-done
+	return ctx.Err()
+}
+
+// enqueue hands a delivery to the workers without blocking. It has to be
+// called with d.mu held.
+func (d *Dispatcher) enqueue(delivery *Delivery) {
+	select {
+	case d.queue <- delivery.Id:
+	default:
+		d.kill(delivery, errQueueFull)
+	}
+}
+
+// kill moves a delivery to the dead-letter list. It has to be called with d.mu held.
+func (d *Dispatcher) kill(delivery *Delivery, err error) {
+	delete(d.deliveries, delivery.Id)
+	delivery.Status = DeliveryDead
+	delivery.LastError = err.Error()
+	d.deadLetters = append(d.deadLetters, delivery)
+	if len(d.deadLetters) > MaxDeadLetters {
+		d.deadLetters = d.deadLetters[len(d.deadLetters)-MaxDeadLetters:]
+	}
+}
+
+func (d *Dispatcher) work() {
+	defer d.wg.Done()
+	for id := range d.queue {
+		d.deliver(id)
+	}
+}
+
+func (d *Dispatcher) deliver(id string) {
+	d.mu.Lock()
+	delivery, exists := d.deliveries[id]
+	if !exists {
+		d.mu.Unlock()
+		return
+	}
+	subscription, exists := d.subscriptions[delivery.SubscriptionId]
+	if !exists {
+		delete(d.deliveries, id)
+		d.mu.Unlock()
+		return
+	}
+	if d.abandoned {
+		d.kill(delivery, errAbandoned)
+		d.mu.Unlock()
+		return
+	}
+	payload := delivery.Payload
+	eventType := delivery.EventType
+	d.mu.Unlock()
+
+	err := d.send(subscription, id, eventType, payload)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err == nil {
+		delete(d.deliveries, id)
+		return
+	}
+	delivery.Attempts++
+	delivery.LastError = err.Error()
+	if delivery.Attempts >= d.retry.MaxAttempts {
+		d.kill(delivery, err)
+		return
+	}
+	if d.closed {
+		return
+	}
+	d.timers[id] = time.AfterFunc(d.retry.backoff(delivery.Attempts), func() {
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		delete(d.timers, id)
+		if !d.closed {
+			d.enqueue(delivery)
+		}
+	})
+}
+
+func (d *Dispatcher) send(subscription Subscription, id string, eventType domain.EventType, payload []byte) error {
+	request, err := http.NewRequest(http.MethodPost, subscription.URL, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set(SignatureHeader, Sign(subscription.Secret, time.Now(), payload))
+	request.Header.Set(EventHeader, string(eventType))
+	request.Header.Set(DeliveryHeader, id)
+
+	response, err := d.client.Do(request)
+	if err != nil {
+		return err
+	}
+	response.Body.Close()
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %s", response.Status)
+	}
+	return nil
+}
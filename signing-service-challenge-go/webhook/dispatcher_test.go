@@ -0,0 +1,316 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain"
+)
+
+func assertEqual(t *testing.T, expected interface{}, actual interface{}) {
+	t.Helper()
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("Expected %v, actual %v", expected, actual)
+	}
+}
+
+var fastRetry = RetryPolicy{
+	MaxAttempts:    3,
+	InitialBackoff: time.Millisecond,
+	MaxBackoff:     4 * time.Millisecond,
+}
+
+var signatureCreated = domain.Event{
+	Id:         "event",
+	Type:       domain.EventSignatureCreated,
+	OccurredAt: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+	Device:     domain.SignatureDevice{Tenant: "tenant1", Id: "550e8400-e29b-11d4-a716-446655440000"},
+	Signature: &domain.Signature{
+		Counter:    0,
+		Signature:  "c2lnbmF0dXJl",
+		SignedData: "0_test_NTUwZTg0MDAtZTI5Yi0xMWQ0LWE3MTYtNDQ2NjU1NDQwMDAw_2024-01-02T03:04:05Z",
+		SignedAt:   time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+	},
+}
+
+type received struct {
+	header http.Header
+	body   []byte
+}
+
+func newReceiver(t *testing.T, status func(attempt int32) int) (*httptest.Server, <-chan received) {
+	requests := make(chan received, 16)
+	var attempts int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests <- received{header: r.Header, body: body}
+		w.WriteHeader(status(atomic.AddInt32(&attempts, 1)))
+	}))
+	t.Cleanup(server.Close)
+	return server, requests
+}
+
+func waitForDeadLetters(t *testing.T, dispatcher IDispatcher, count int) []Delivery {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if deadLetters := dispatcher.DeadLetters("tenant1"); len(deadLetters) == count {
+			return deadLetters
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("expected %d dead letters", count)
+	return nil
+}
+
+// newLocalDispatcher creates a Dispatcher that delivers to the loopback
+// address of the test receivers.
+func newLocalDispatcher(workers int, retry RetryPolicy) IDispatcher {
+	dispatcher := NewDispatcher(workers, retry).(*Dispatcher)
+	dispatcher.client = &http.Client{Timeout: DeliveryTimeout}
+	dispatcher.checkHost = func(string) error { return nil }
+	return dispatcher
+}
+
+func TestPublish_DeliversSignedPayload(t *testing.T) {
+	server, requests := newReceiver(t, func(int32) int { return http.StatusNoContent })
+	dispatcher := newLocalDispatcher(1, fastRetry)
+	defer dispatcher.Close(context.Background())
+	subscription, _ := dispatcher.Subscribe("tenant1", server.URL, []domain.EventType{domain.EventSignatureCreated}, "secret")
+
+	dispatcher.Publish(signatureCreated)
+	request := <-requests
+
+	assertEqual(t, nil, Verify("secret", request.header.Get(SignatureHeader), request.body, time.Now()))
+	assertEqual(t, "signature.created", request.header.Get(EventHeader))
+	assertEqual(t, "secret", subscription.Secret)
+
+	var envelope map[string]any
+	_ = json.Unmarshal(request.body, &envelope)
+	assertEqual(t, map[string]any{
+		"id":          "event",
+		"type":        "signature.created",
+		"occurred_at": "2024-01-02T03:04:05Z",
+		"data": map[string]any{
+			"device_id":   "550e8400-e29b-11d4-a716-446655440000",
+			"counter":     float64(0),
+			"signature":   "c2lnbmF0dXJl",
+			"signed_data": "0_test_NTUwZTg0MDAtZTI5Yi0xMWQ0LWE3MTYtNDQ2NjU1NDQwMDAw_2024-01-02T03:04:05Z",
+			"signed_at":   "2024-01-02T03:04:05Z",
+		},
+	}, envelope)
+}
+
+func TestPublish_FiltersEventTypes(t *testing.T) {
+	server, requests := newReceiver(t, func(int32) int { return http.StatusOK })
+	dispatcher := newLocalDispatcher(1, fastRetry)
+	_, _ = dispatcher.Subscribe("tenant1", server.URL, []domain.EventType{domain.EventDeviceCreated}, "")
+
+	dispatcher.Publish(signatureCreated)
+	dispatcher.Close(context.Background())
+
+	assertEqual(t, 0, len(requests))
+}
+
+func TestPublish_FiltersTenants(t *testing.T) {
+	server, requests := newReceiver(t, func(int32) int { return http.StatusOK })
+	dispatcher := newLocalDispatcher(1, fastRetry)
+	_, _ = dispatcher.Subscribe("tenant2", server.URL, []domain.EventType{domain.EventSignatureCreated}, "")
+
+	dispatcher.Publish(signatureCreated)
+	dispatcher.Close(context.Background())
+
+	assertEqual(t, 0, len(requests))
+	assertEqual(t, []Subscription{}, dispatcher.Subscriptions("tenant1"))
+}
+
+func TestPublish_RetriesWithBackoff(t *testing.T) {
+	server, requests := newReceiver(t, func(attempt int32) int {
+		if attempt < 3 {
+			return http.StatusInternalServerError
+		}
+		return http.StatusOK
+	})
+	dispatcher := newLocalDispatcher(1, fastRetry)
+	defer dispatcher.Close(context.Background())
+	_, _ = dispatcher.Subscribe("tenant1", server.URL, []domain.EventType{domain.EventSignatureCreated}, "")
+
+	dispatcher.Publish(signatureCreated)
+	first := <-requests
+	<-requests
+	third := <-requests
+
+	assertEqual(t, first.header.Get(DeliveryHeader), third.header.Get(DeliveryHeader))
+	assertEqual(t, 0, len(dispatcher.DeadLetters("tenant1")))
+}
+
+func TestPublish_DeadLetterAndReplay(t *testing.T) {
+	var healthy atomic.Bool
+	server, requests := newReceiver(t, func(int32) int {
+		if healthy.Load() {
+			return http.StatusOK
+		}
+		return http.StatusServiceUnavailable
+	})
+	dispatcher := newLocalDispatcher(1, fastRetry)
+	defer dispatcher.Close(context.Background())
+	subscription, _ := dispatcher.Subscribe("tenant1", server.URL, []domain.EventType{domain.EventSignatureCreated}, "")
+
+	dispatcher.Publish(signatureCreated)
+	deadLetters := waitForDeadLetters(t, dispatcher, 1)
+	for i := 0; i < fastRetry.MaxAttempts; i++ {
+		<-requests
+	}
+
+	assertEqual(t, subscription.Id, deadLetters[0].SubscriptionId)
+	assertEqual(t, DeliveryDead, deadLetters[0].Status)
+	assertEqual(t, fastRetry.MaxAttempts, deadLetters[0].Attempts)
+	assertEqual(t, "unexpected status 503 Service Unavailable", deadLetters[0].LastError)
+
+	healthy.Store(true)
+	replayed, err := dispatcher.Replay("tenant1", deadLetters[0].Id)
+	request := <-requests
+
+	assertEqual(t, nil, err)
+	assertEqual(t, DeliveryPending, replayed.Status)
+	assertEqual(t, deadLetters[0].Id, request.header.Get(DeliveryHeader))
+	assertEqual(t, 0, len(dispatcher.DeadLetters("tenant1")))
+}
+
+func TestReplay_ErrNotFound(t *testing.T) {
+	dispatcher := newLocalDispatcher(1, fastRetry)
+	defer dispatcher.Close(context.Background())
+
+	_, err := dispatcher.Replay("tenant1", "unknown")
+
+	assertEqual(t, ErrNotFound, err)
+}
+
+func TestSubscribe_GeneratesSecret(t *testing.T) {
+	dispatcher := newLocalDispatcher(1, fastRetry)
+	defer dispatcher.Close(context.Background())
+
+	subscription, err := dispatcher.Subscribe("tenant1", "https://example.com/hook", []domain.EventType{domain.EventDeviceCreated}, "")
+
+	assertEqual(t, nil, err)
+	assertEqual(t, 64, len(subscription.Secret))
+	assertEqual(t, []Subscription{subscription}, dispatcher.Subscriptions("tenant1"))
+}
+
+func TestSubscribe_ErrInvalid(t *testing.T) {
+	dispatcher := NewDispatcher(1, fastRetry)
+	defer dispatcher.Close(context.Background())
+
+	_, err := dispatcher.Subscribe("tenant1", "ftp://example.com", []domain.EventType{domain.EventDeviceCreated}, "")
+	assertEqual(t, ErrInvalidURL, err)
+
+	_, err = dispatcher.Subscribe("tenant1", "https://example.com", []domain.EventType{"device.exploded"}, "")
+	assertEqual(t, ErrInvalidEventType, err)
+
+	_, err = dispatcher.Subscribe("tenant1", "https://example.com", nil, "")
+	assertEqual(t, ErrInvalidEventType, err)
+
+	for _, rawURL := range []string{
+		"http://169.254.169.254/latest/meta-data",
+		"http://127.0.0.1:8080/hook",
+		"http://10.0.0.1/hook",
+		"http://[::1]/hook",
+		"http://localhost/hook",
+	} {
+		_, err = dispatcher.Subscribe("tenant1", rawURL, []domain.EventType{domain.EventDeviceCreated}, "")
+		assertEqual(t, true, errors.Is(err, ErrInvalidURL))
+	}
+}
+
+func TestPublish_RefusesPrivateAddresses(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+	}))
+	t.Cleanup(server.Close)
+	dispatcher := NewDispatcher(1, RetryPolicy{MaxAttempts: 1})
+	defer dispatcher.Close(context.Background())
+	// Host names are only resolved when the delivery is dialed.
+	dispatcher.(*Dispatcher).checkHost = func(string) error { return nil }
+	_, _ = dispatcher.Subscribe("tenant1", server.URL, []domain.EventType{domain.EventSignatureCreated}, "")
+
+	dispatcher.Publish(signatureCreated)
+	deadLetters := waitForDeadLetters(t, dispatcher, 1)
+
+	assertEqual(t, int32(0), atomic.LoadInt32(&requests))
+	assertEqual(t, true, strings.Contains(deadLetters[0].LastError, ErrPrivateAddress.Error()))
+}
+
+func TestUnsubscribe(t *testing.T) {
+	dispatcher := newLocalDispatcher(1, fastRetry)
+	defer dispatcher.Close(context.Background())
+	subscription, _ := dispatcher.Subscribe("tenant1", "https://example.com/hook", []domain.EventType{domain.EventDeviceCreated}, "")
+
+	assertEqual(t, nil, dispatcher.Unsubscribe("tenant1", subscription.Id))
+	assertEqual(t, ErrNotFound, dispatcher.Unsubscribe("tenant1", subscription.Id))
+	assertEqual(t, []Subscription{}, dispatcher.Subscriptions("tenant1"))
+}
+
+func TestRetryPolicy_Backoff(t *testing.T) {
+	policy := RetryPolicy{InitialBackoff: time.Second, MaxBackoff: 5 * time.Second}
+
+	assertEqual(t, time.Second, policy.backoff(1))
+	assertEqual(t, 2*time.Second, policy.backoff(2))
+	assertEqual(t, 4*time.Second, policy.backoff(3))
+	assertEqual(t, 5*time.Second, policy.backoff(4))
+	assertEqual(t, 5*time.Second, policy.backoff(40))
+}
+
+func TestClose_AbandonsQueuedDeliveriesAtDeadline(t *testing.T) {
+	started := make(chan struct{}, 1)
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		<-release
+	}))
+	t.Cleanup(server.Close)
+	dispatcher := newLocalDispatcher(1, fastRetry)
+	_, _ = dispatcher.Subscribe("tenant1", server.URL, []domain.EventType{domain.EventSignatureCreated}, "")
+	dispatcher.Publish(signatureCreated)
+	dispatcher.Publish(signatureCreated)
+	<-started
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		close(release)
+	}()
+	err := dispatcher.Close(ctx)
+
+	assertEqual(t, context.Canceled, err)
+	deadLetters := dispatcher.DeadLetters("tenant1")
+	assertEqual(t, 1, len(deadLetters))
+	assertEqual(t, "abandoned at shutdown", deadLetters[0].LastError)
+}
+
+func TestVerify(t *testing.T) {
+	signedAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	header := Sign("secret", signedAt, []byte("payload"))
+
+	assertEqual(t, "t=1704164645,v1=", header[:16])
+	assertEqual(t, nil, Verify("secret", header, []byte("payload"), signedAt.Add(SignatureTolerance)))
+	for _, err := range []error{
+		Verify("secret", header, []byte("tampered"), signedAt),
+		Verify("other", header, []byte("payload"), signedAt),
+		Verify("secret", header, []byte("payload"), signedAt.Add(SignatureTolerance+time.Second)),
+		Verify("secret", header, []byte("payload"), signedAt.Add(-SignatureTolerance-time.Second)),
+		Verify("secret", "sha256=00", []byte("payload"), signedAt),
+	} {
+		assertEqual(t, true, errors.Is(err, ErrInvalidSignature))
+	}
+}
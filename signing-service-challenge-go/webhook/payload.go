@@ -0,0 +1,123 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fiskaly/coding-challenges/signing-service-challenge/domain"
+)
+
+// Envelope is the JSON body of every webhook request.
+type Envelope struct {
+	Id         string           `json:"id"`
+	Type       domain.EventType `json:"type"`
+	OccurredAt time.Time        `json:"occurred_at"`
+	Data       any              `json:"data"`
+}
+
+type DeviceData struct {
+	Id               string            `json:"id"`
+	Algorithm        string            `json:"algorithm"`
+	Label            string            `json:"label"`
+	SignatureCounter int               `json:"signature_counter"`
+	Mode             string            `json:"mode"`
+	Tags             map[string]string `json:"tags,omitempty"`
+	DecommissionedAt *time.Time        `json:"decommissioned_at,omitempty"`
+}
+
+type SignatureData struct {
+	DeviceId   string    `json:"device_id"`
+	Counter    int       `json:"counter"`
+	KeyId      string    `json:"key_id,omitempty"`
+	LeafKeyIds []string  `json:"leaf_key_ids,omitempty"`
+	Signature  string    `json:"signature"`
+	SignedData string    `json:"signed_data"`
+	SignedAt   time.Time `json:"signed_at"`
+}
+
+func newPayload(event domain.Event) ([]byte, error) {
+	envelope := Envelope{
+		Id:         event.Id,
+		Type:       event.Type,
+		OccurredAt: event.OccurredAt,
+	}
+	if event.Signature != nil {
+		envelope.Data = SignatureData{
+			DeviceId:   event.Device.Id,
+			Counter:    event.Signature.Counter,
+			KeyId:      event.Signature.KeyId,
+			LeafKeyIds: event.Signature.LeafKeyIds,
+			Signature:  event.Signature.Signature,
+			SignedData: event.Signature.SignedData,
+			SignedAt:   event.Signature.SignedAt,
+		}
+	} else {
+		device := DeviceData{
+			Id:               event.Device.Id,
+			Algorithm:        event.Device.Algorithm,
+			Label:            event.Device.Label,
+			SignatureCounter: event.Device.SignatureCounter,
+			Mode:             string(event.Device.Mode),
+			Tags:             event.Device.Tags,
+		}
+		if !event.Device.DecommissionedAt.IsZero() {
+			decommissionedAt := event.Device.DecommissionedAt
+			device.DecommissionedAt = &decommissionedAt
+		}
+		envelope.Data = device
+	}
+	return json.Marshal(envelope)
+}
+
+// signatureVersion prefixes the HMAC in the SignatureHeader, so that the
+// scheme can change without breaking receivers.
+const signatureVersion = "v1"
+
+// Sign returns the value of the SignatureHeader for a payload sent at the
+// given time: "t=<unix seconds>,v1=<hex HMAC-SHA256>", the HMAC being keyed
+// with the subscription secret over the timestamp, a dot and the body.
+func Sign(secret string, timestamp time.Time, payload []byte) string {
+	t := strconv.FormatInt(timestamp.Unix(), 10)
+	return "t=" + t + "," + signatureVersion + "=" + hex.EncodeToString(mac(secret, t, payload))
+}
+
+// Verify checks a SignatureHeader value against the payload. Signatures
+// whose timestamp is more than SignatureTolerance away from now are
+// rejected, which stops replays of captured requests.
+func Verify(secret, header string, payload []byte, now time.Time) error {
+	var t, signature string
+	for _, field := range strings.Split(header, ",") {
+		name, value, _ := strings.Cut(field, "=")
+		switch name {
+		case "t":
+			t = value
+		case signatureVersion:
+			signature = value
+		}
+	}
+	seconds, err := strconv.ParseInt(t, 10, 64)
+	if err != nil || signature == "" {
+		return fmt.Errorf("%w: malformed header", ErrInvalidSignature)
+	}
+	if age := now.Sub(time.Unix(seconds, 0)); age > SignatureTolerance || age < -SignatureTolerance {
+		return fmt.Errorf("%w: timestamp outside the tolerance", ErrInvalidSignature)
+	}
+	expected, err := hex.DecodeString(signature)
+	if err != nil || !hmac.Equal(expected, mac(secret, t, payload)) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+func mac(secret, timestamp string, payload []byte) []byte {
+	hash := hmac.New(sha256.New, []byte(secret))
+	hash.Write([]byte(timestamp + "."))
+	hash.Write(payload)
+	return hash.Sum(nil)
+}